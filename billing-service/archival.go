@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	enumspb "go.temporal.io/api/enums/v1"
+	namespacepb "go.temporal.io/api/namespace/v1"
+	"go.temporal.io/api/workflowservice/v1"
+)
+
+// Archival URI schemes supported by the Temporal archivers
+var archivalSchemes = map[string]bool{
+	"file": true, // local filesystem provider, for development and testing
+	"s3":   true,
+	"gs":   true,
+}
+
+// ArchivalConfig holds the history and visibility archival settings of a namespace
+type ArchivalConfig struct {
+	HistoryState    string `json:"history_state"` // "enabled" or "disabled"
+	HistoryURI      string `json:"history_uri,omitempty"`
+	VisibilityState string `json:"visibility_state"` // "enabled" or "disabled"
+	VisibilityURI   string `json:"visibility_uri,omitempty"`
+}
+
+var errArchivalURIChanged = errors.New("archival URI cannot be changed once set")
+
+// withDefaults fills in disabled states for unset fields
+func (a ArchivalConfig) withDefaults() ArchivalConfig {
+	if a.HistoryState == "" {
+		a.HistoryState = "disabled"
+	}
+	if a.VisibilityState == "" {
+		a.VisibilityState = "disabled"
+	}
+	return a
+}
+
+// Validate checks states and URIs
+func (a ArchivalConfig) Validate() error {
+	if err := validateArchival("history", a.HistoryState, a.HistoryURI); err != nil {
+		return err
+	}
+	return validateArchival("visibility", a.VisibilityState, a.VisibilityURI)
+}
+
+func validateArchival(kind, state, uri string) error {
+	switch state {
+	case "enabled":
+		if uri == "" {
+			return fmt.Errorf("%s archival URI is required when enabled", kind)
+		}
+	case "disabled":
+		if uri == "" {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%s archival state must be enabled or disabled", kind)
+	}
+	return validateArchivalURI(uri)
+}
+
+// validateArchivalURI checks the URI is one the Temporal archivers can write to
+func validateArchivalURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid archival URI: %w", err)
+	}
+	if !archivalSchemes[u.Scheme] {
+		return fmt.Errorf("unsupported archival URI scheme %q", u.Scheme)
+	}
+	if u.Scheme == "file" {
+		if u.Host != "" || !path.IsAbs(u.Path) {
+			return fmt.Errorf("file archival URI must be an absolute path, e.g. file:///tmp/temporal_archival")
+		}
+		return nil
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%s archival URI must name a bucket", u.Scheme)
+	}
+	return nil
+}
+
+// checkArchivalURIsUnchanged enforces Temporal's rule that a set URI is permanent
+func checkArchivalURIsUnchanged(current, next ArchivalConfig) error {
+	if current.HistoryURI != "" && next.HistoryURI != current.HistoryURI {
+		return fmt.Errorf("history %w", errArchivalURIChanged)
+	}
+	if current.VisibilityURI != "" && next.VisibilityURI != current.VisibilityURI {
+		return fmt.Errorf("visibility %w", errArchivalURIChanged)
+	}
+	return nil
+}
+
+func archivalState(state string) enumspb.ArchivalState {
+	if state == "enabled" {
+		return enumspb.ARCHIVAL_STATE_ENABLED
+	}
+	return enumspb.ARCHIVAL_STATE_DISABLED
+}
+
+// GetNamespaceArchival returns the archival settings of a namespace
+func (s *BillingService) GetNamespaceArchival(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	ns, err := s.getNamespace(r.Context(), nsID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ns.Archival)
+}
+
+// UpdateNamespaceArchival applies archival settings to the Temporal namespace and stores them
+func (s *BillingService) UpdateNamespaceArchival(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ArchivalConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ns, err := s.getNamespace(r.Context(), nsID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ns.Status != "active" {
+		http.Error(w, "Namespace is not active", http.StatusConflict)
+		return
+	}
+
+	// Keep previously set URIs when the request only toggles the state
+	if req.HistoryURI == "" {
+		req.HistoryURI = ns.Archival.HistoryURI
+	}
+	if req.VisibilityURI == "" {
+		req.VisibilityURI = ns.Archival.VisibilityURI
+	}
+	req = req.withDefaults()
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkArchivalURIsUnchanged(ns.Archival, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := s.clusterEndpoint(r.Context(), ns.ClusterID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	conn, err := dialTemporal(r.Context(), endpoint)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to connect to Temporal: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	_, err = workflowservice.NewWorkflowServiceClient(conn).UpdateNamespace(r.Context(), &workflowservice.UpdateNamespaceRequest{
+		Namespace: ns.TemporalNamespace,
+		Config: &namespacepb.NamespaceConfig{
+			HistoryArchivalState:    archivalState(req.HistoryState),
+			HistoryArchivalUri:      req.HistoryURI,
+			VisibilityArchivalState: archivalState(req.VisibilityState),
+			VisibilityArchivalUri:   req.VisibilityURI,
+		},
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update Temporal namespace: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	_, err = s.db.Exec(r.Context(),
+		`UPDATE namespaces SET history_archival_state = $1, history_archival_uri = NULLIF($2, ''),
+		 visibility_archival_state = $3, visibility_archival_uri = NULLIF($4, ''), updated_at = NOW()
+		 WHERE id = $5`,
+		req.HistoryState, req.HistoryURI, req.VisibilityState, req.VisibilityURI, nsID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
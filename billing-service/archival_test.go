@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestArchivalConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ArchivalConfig
+		wantErr bool
+	}{
+		{"defaults", ArchivalConfig{}.withDefaults(), false},
+		{"local filesystem", ArchivalConfig{"enabled", "file:///tmp/temporal_archival/history", "enabled", "file:///tmp/temporal_archival/visibility"}, false},
+		{"s3 bucket", ArchivalConfig{"enabled", "s3://archives/history", "disabled", ""}, false},
+		{"disabled keeps uri", ArchivalConfig{"disabled", "gs://archives/history", "disabled", ""}, false},
+		{"enabled without uri", ArchivalConfig{"enabled", "", "disabled", ""}, true},
+		{"invalid state", ArchivalConfig{"on", "file:///tmp/a", "disabled", ""}, true},
+		{"relative file path", ArchivalConfig{"enabled", "file://tmp/a", "disabled", ""}, true},
+		{"unsupported scheme", ArchivalConfig{"disabled", "", "enabled", "http://example.com/a"}, true},
+		{"s3 without bucket", ArchivalConfig{"disabled", "", "enabled", "s3:///a"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckArchivalURIsUnchanged(t *testing.T) {
+	current := ArchivalConfig{"enabled", "file:///tmp/a", "disabled", ""}
+
+	if err := checkArchivalURIsUnchanged(current, ArchivalConfig{"disabled", "file:///tmp/a", "enabled", "file:///tmp/b"}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	err := checkArchivalURIsUnchanged(current, ArchivalConfig{"enabled", "file:///tmp/c", "disabled", ""})
+	if !errors.Is(err, errArchivalURIChanged) {
+		t.Errorf("Expected %v, got %v", errArchivalURIChanged, err)
+	}
+}
+
+func TestCalculateCostIncludesArchivedStorage(t *testing.T) {
+	usage := UsageSummary{ArchivedStorageGBH: decimal.NewFromInt(10000)}
+
+	// 10,000 GB-hours at 0.0105 cents
+	if cost := mustCost(t, usage, defaultPlan("free")); cost != 105 {
+		t.Errorf("Expected 105 cents, got %d", cost)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -78,6 +80,38 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// AdminAuth guards the operator API, which changes plans, prices and balances for
+// every organization, with a token set in BILLING_ADMIN_TOKEN. Organization API keys
+// are refused, and without a token the operator API is disabled.
+type AdminAuth struct {
+	token string
+}
+
+// NewAdminAuthFromEnv creates the operator API guard from BILLING_ADMIN_TOKEN
+func NewAdminAuthFromEnv() *AdminAuth {
+	return &AdminAuth{token: os.Getenv("BILLING_ADMIN_TOKEN")}
+}
+
+// Authenticate lets through requests carrying the operator token, as a Bearer token or
+// in X-Admin-Token
+func (a *AdminAuth) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Admin-Token")
+		if token == "" {
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if token == "" {
+			http.Error(w, `{"error": "missing operator token"}`, http.StatusUnauthorized)
+			return
+		}
+		if a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			http.Error(w, `{"error": "operator token required"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // validateAPIKey checks if the API key is valid and returns the organization ID
 func (m *AuthMiddleware) validateAPIKey(ctx context.Context, key string) (uuid.UUID, error) {
 	// API key format: tc_live_<prefix>_<secret>
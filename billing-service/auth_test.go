@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestAdminRoutesRequireOperatorToken(t *testing.T) {
+	r := mux.NewRouter()
+	registerAdminRoutes(r, &BillingService{}, &AdminAuth{token: "op_secret"})
+
+	// Malformed bodies are rejected by the handlers before they reach the database
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/api/v1/admin/plans"},
+		{"POST", "/api/v1/admin/organizations/" + uuid.New().String() + "/credits"},
+		{"POST", "/api/v1/admin/organizations/" + uuid.New().String() + "/contracts"},
+		{"POST", "/api/v1/admin/coupons"},
+		{"POST", "/api/v1/admin/clusters"},
+		{"PUT", "/api/v1/admin/clusters/" + uuid.New().String()},
+	}
+	orgKey := "tc_live_1a2b3c4d_0123456789abcdef"
+	for _, route := range routes {
+		cases := []struct {
+			name     string
+			header   string
+			value    string
+			expected int
+		}{
+			{"no credentials", "", "", http.StatusUnauthorized},
+			{"organization API key", "Authorization", "Bearer " + orgKey, http.StatusForbidden},
+			{"organization API key header", "X-Admin-Token", orgKey, http.StatusForbidden},
+			{"operator token", "Authorization", "Bearer op_secret", http.StatusBadRequest},
+			{"operator token header", "X-Admin-Token", "op_secret", http.StatusBadRequest},
+		}
+		for _, c := range cases {
+			t.Run(route.path+" "+c.name, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, route.path, bytes.NewBufferString("{"))
+				if c.header != "" {
+					req.Header.Set(c.header, c.value)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != c.expected {
+					t.Errorf("Expected status %d, got %d: %s", c.expected, w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestAdminRoutesDisabledWithoutToken(t *testing.T) {
+	r := mux.NewRouter()
+	registerAdminRoutes(r, &BillingService{}, &AdminAuth{})
+
+	req := httptest.NewRequest("POST", "/api/v1/admin/plans", bytes.NewBufferString("{"))
+	req.Header.Set("Authorization", "Bearer anything")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without an operator token configured, got %d", w.Code)
+	}
+}
@@ -5,25 +5,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 
 	"github.com/gorilla/mux"
-	"github.com/stripe/stripe-go/v76"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
 )
 
 func TestCheckoutSessionEndpoint(t *testing.T) {
-	// Skip if no Stripe key configured
-	stripeKey := os.Getenv("STRIPE_SECRET_KEY")
-	if stripeKey == "" {
-		t.Skip("Skipping checkout test - STRIPE_SECRET_KEY not set")
-	}
-
-	// Initialize Stripe
-	stripe.Key = stripeKey
-
-	// Create service with nil DB (we won't hit DB in this test)
-	svc := &BillingService{db: nil}
+	// Create service with nil DB (we won't hit DB in this test) and the fake provider
+	svc := &BillingService{db: nil, payments: payments.NewFake("")}
 
 	r := mux.NewRouter()
 	r.HandleFunc("/api/v1/stripe/checkout", svc.CreateCheckoutSession).Methods("POST")
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"go.temporal.io/api/workflowservice/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Cluster is a Temporal cluster that namespaces can be placed on
+type Cluster struct {
+	ID                uuid.UUID  `json:"id"`
+	Name              string     `json:"name"`
+	Region            string     `json:"region"`
+	GRPCEndpoint      string     `json:"grpc_endpoint"`
+	PrometheusURL     string     `json:"prometheus_url,omitempty"`
+	MaxNamespaces     int        `json:"max_namespaces"` // 0 means unlimited
+	NamespaceCount    int        `json:"namespace_count"`
+	Status            string     `json:"status"` // "active", "draining", "disabled"
+	Health            string     `json:"health"` // "unknown", "healthy", "unhealthy"
+	LastHealthCheckAt *time.Time `json:"last_health_check_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+var (
+	errRegionNotServed    = errors.New("no cluster serves this region")
+	errNoClusterAvailable = errors.New("no cluster with free capacity in region")
+	errClusterNotFound    = errors.New("cluster not found")
+	errClusterUnavailable = errors.New("cluster is not accepting namespaces")
+)
+
+// defaultTemporalEndpoint is used when no clusters are registered
+func defaultTemporalEndpoint() string {
+	endpoint := os.Getenv("TEMPORAL_GRPC_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "localhost:7233"
+	}
+	return endpoint
+}
+
+// dialTemporal opens a connection to a Temporal frontend
+func dialTemporal(ctx context.Context, endpoint string) (*grpc.ClientConn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	return grpc.DialContext(dialCtx, endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+// selectCluster picks the least utilized healthy cluster in the region
+func selectCluster(clusters []Cluster, region string) (*Cluster, error) {
+	var candidates []Cluster
+	served := false
+	for _, c := range clusters {
+		if c.Region != region {
+			continue
+		}
+		served = true
+		if clusterAvailable(c) != nil {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	if !served {
+		return nil, errRegionNotServed
+	}
+	if len(candidates) == 0 {
+		return nil, errNoClusterAvailable
+	}
+
+	utilization := func(c Cluster) float64 {
+		if c.MaxNamespaces == 0 {
+			return 0
+		}
+		return float64(c.NamespaceCount) / float64(c.MaxNamespaces)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ui, uj := utilization(candidates[i]), utilization(candidates[j])
+		if ui != uj {
+			return ui < uj
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	return &candidates[0], nil
+}
+
+// clusterAvailable returns why a cluster cannot take another namespace, nil if it can:
+// it must be active, not unhealthy, and have free capacity
+func clusterAvailable(c Cluster) error {
+	switch {
+	case c.Status != "active":
+		return fmt.Errorf("%w: cluster %s is %s", errClusterUnavailable, c.Name, c.Status)
+	case c.Health == "unhealthy":
+		return fmt.Errorf("%w: cluster %s is unhealthy", errClusterUnavailable, c.Name)
+	case c.MaxNamespaces > 0 && c.NamespaceCount >= c.MaxNamespaces:
+		return fmt.Errorf("%w: cluster %s is full (%d of %d namespaces)", errClusterUnavailable, c.Name,
+			c.NamespaceCount, c.MaxNamespaces)
+	}
+	return nil
+}
+
+// targetCluster returns an explicitly chosen cluster if it could take another
+// namespace, by the checks selectCluster places namespaces with
+func targetCluster(clusters []Cluster, id uuid.UUID) (*Cluster, error) {
+	for i := range clusters {
+		if clusters[i].ID == id {
+			if err := clusterAvailable(clusters[i]); err != nil {
+				return nil, err
+			}
+			return &clusters[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errClusterNotFound, id)
+}
+
+// lockClusterCapacity locks a cluster's row until the transaction q ends and checks it
+// can still take another namespace, so concurrent creates cannot both take its last slot
+func lockClusterCapacity(ctx context.Context, q rowQuerier, id uuid.UUID) error {
+	c := Cluster{ID: id}
+	err := q.QueryRow(ctx,
+		`SELECT name, region, max_namespaces, status, health FROM clusters WHERE id = $1 FOR UPDATE`, id).
+		Scan(&c.Name, &c.Region, &c.MaxNamespaces, &c.Status, &c.Health)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", errClusterNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock cluster %s: %w", id, err)
+	}
+	err = q.QueryRow(ctx,
+		`SELECT COUNT(*) FROM namespaces WHERE cluster_id = $1 AND status != 'failed'`, id).Scan(&c.NamespaceCount)
+	if err != nil {
+		return fmt.Errorf("failed to count namespaces on cluster %s: %w", id, err)
+	}
+	return clusterAvailable(c)
+}
+
+// listClusters returns all registered clusters with their current namespace counts
+func (s *BillingService) listClusters(ctx context.Context) ([]Cluster, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT c.id, c.name, c.region, c.grpc_endpoint, COALESCE(c.prometheus_url, ''), c.max_namespaces,
+		        c.status, c.health, c.last_health_check_at, c.created_at,
+		        (SELECT COUNT(*) FROM namespaces n WHERE n.cluster_id = c.id AND n.status != 'failed')
+		 FROM clusters c ORDER BY c.region, c.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clusters := make([]Cluster, 0)
+	for rows.Next() {
+		var c Cluster
+		if err := rows.Scan(&c.ID, &c.Name, &c.Region, &c.GRPCEndpoint, &c.PrometheusURL, &c.MaxNamespaces,
+			&c.Status, &c.Health, &c.LastHealthCheckAt, &c.CreatedAt, &c.NamespaceCount); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, rows.Err()
+}
+
+// placeNamespace chooses the cluster for a new namespace. It returns nil when no
+// clusters are registered, in which case the default endpoint is used.
+func (s *BillingService) placeNamespace(ctx context.Context, region string) (*Cluster, error) {
+	clusters, err := s.listClusters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+	return selectCluster(clusters, region)
+}
+
+// clusterEndpoint returns the frontend endpoint for a namespace's cluster
+func (s *BillingService) clusterEndpoint(ctx context.Context, clusterID *uuid.UUID) (string, error) {
+	if clusterID == nil {
+		return defaultTemporalEndpoint(), nil
+	}
+	var endpoint string
+	err := s.db.QueryRow(ctx,
+		`SELECT grpc_endpoint FROM clusters WHERE id = $1`, *clusterID).Scan(&endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster %s: %w", clusterID, err)
+	}
+	return endpoint, nil
+}
+
+// ListClusters lists the cluster registry
+func (s *BillingService) ListClusters(w http.ResponseWriter, r *http.Request) {
+	clusters, err := s.listClusters(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clusters)
+}
+
+// CreateClusterRequest is the request body for registering a cluster
+type CreateClusterRequest struct {
+	Name          string `json:"name"`
+	Region        string `json:"region"`
+	GRPCEndpoint  string `json:"grpc_endpoint"`
+	PrometheusURL string `json:"prometheus_url"`
+	MaxNamespaces int    `json:"max_namespaces"`
+}
+
+// CreateCluster registers a Temporal cluster
+func (s *BillingService) CreateCluster(w http.ResponseWriter, r *http.Request) {
+	var req CreateClusterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.Region == "" || req.GRPCEndpoint == "" {
+		http.Error(w, "name, region and grpc_endpoint are required", http.StatusBadRequest)
+		return
+	}
+	if req.MaxNamespaces < 0 {
+		http.Error(w, "max_namespaces must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	c := Cluster{
+		ID:            uuid.New(),
+		Name:          req.Name,
+		Region:        req.Region,
+		GRPCEndpoint:  req.GRPCEndpoint,
+		PrometheusURL: req.PrometheusURL,
+		MaxNamespaces: req.MaxNamespaces,
+		Status:        "active",
+		Health:        "unknown",
+		CreatedAt:     time.Now(),
+	}
+
+	_, err := s.db.Exec(r.Context(),
+		`INSERT INTO clusters (id, name, region, grpc_endpoint, prometheus_url, max_namespaces, status, health, created_at)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9)`,
+		c.ID, c.Name, c.Region, c.GRPCEndpoint, c.PrometheusURL, c.MaxNamespaces, c.Status, c.Health, c.CreatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// UpdateCluster changes a cluster's status or capacity
+func (s *BillingService) UpdateCluster(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid cluster ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status        string `json:"status"`
+		MaxNamespaces *int   `json:"max_namespaces"`
+		PrometheusURL string `json:"prometheus_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Status {
+	case "", "active", "draining", "disabled":
+	default:
+		http.Error(w, "status must be active, draining or disabled", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.db.Exec(r.Context(),
+		`UPDATE clusters SET status = COALESCE(NULLIF($1, ''), status),
+		 max_namespaces = COALESCE($2, max_namespaces),
+		 prometheus_url = COALESCE(NULLIF($3, ''), prometheus_url),
+		 updated_at = NOW()
+		 WHERE id = $4`,
+		req.Status, req.MaxNamespaces, req.PrometheusURL, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected() == 0 {
+		http.Error(w, "Cluster not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunClusterHealthChecks periodically probes every registered cluster until ctx is done
+func (s *BillingService) RunClusterHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.checkClusterHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *BillingService) checkClusterHealth(ctx context.Context) {
+	clusters, err := s.listClusters(ctx)
+	if err != nil {
+		log.Printf("Cluster health check failed to list clusters: %v", err)
+		return
+	}
+
+	for _, c := range clusters {
+		health := "healthy"
+		if err := probeCluster(ctx, c.GRPCEndpoint); err != nil {
+			log.Printf("Cluster %s (%s) is unhealthy: %v", c.Name, c.GRPCEndpoint, err)
+			health = "unhealthy"
+		}
+		s.db.Exec(ctx,
+			`UPDATE clusters SET health = $1, last_health_check_at = NOW() WHERE id = $2`, health, c.ID)
+	}
+}
+
+// probeCluster calls GetSystemInfo on a Temporal frontend
+func probeCluster(ctx context.Context, endpoint string) error {
+	probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	conn, err := dialTemporal(probeCtx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = workflowservice.NewWorkflowServiceClient(conn).GetSystemInfo(probeCtx, &workflowservice.GetSystemInfoRequest{})
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSelectCluster(t *testing.T) {
+	clusters := []Cluster{
+		{Name: "use1-a", Region: "us-east-1", Status: "active", Health: "healthy", MaxNamespaces: 100, NamespaceCount: 80},
+		{Name: "use1-b", Region: "us-east-1", Status: "active", Health: "healthy", MaxNamespaces: 200, NamespaceCount: 50},
+		{Name: "use1-c", Region: "us-east-1", Status: "draining", Health: "healthy", MaxNamespaces: 100, NamespaceCount: 0},
+		{Name: "euw1-a", Region: "eu-west-1", Status: "active", Health: "unhealthy", MaxNamespaces: 100, NamespaceCount: 0},
+		{Name: "euw1-b", Region: "eu-west-1", Status: "active", Health: "unknown", MaxNamespaces: 10, NamespaceCount: 10},
+		{Name: "aps1-a", Region: "ap-south-1", Status: "active", Health: "unknown", MaxNamespaces: 0, NamespaceCount: 500},
+	}
+
+	tests := []struct {
+		name        string
+		region      string
+		expected    string
+		expectedErr error
+	}{
+		{"least utilized healthy cluster", "us-east-1", "use1-b", nil},
+		{"unhealthy and full clusters skipped", "eu-west-1", "", errNoClusterAvailable},
+		{"unlimited capacity with unknown health", "ap-south-1", "aps1-a", nil},
+		{"region not served", "us-west-2", "", errRegionNotServed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := selectCluster(clusters, tt.region)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if c.Name != tt.expected {
+				t.Errorf("Expected cluster %s, got %s", tt.expected, c.Name)
+			}
+		})
+	}
+}
+
+func TestSelectClusterTieBreaksByName(t *testing.T) {
+	clusters := []Cluster{
+		{Name: "b", Region: "us-east-1", Status: "active", Health: "healthy", MaxNamespaces: 10, NamespaceCount: 5},
+		{Name: "a", Region: "us-east-1", Status: "active", Health: "healthy", MaxNamespaces: 20, NamespaceCount: 10},
+	}
+
+	c, err := selectCluster(clusters, "us-east-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.Name != "a" {
+		t.Errorf("Expected cluster a on equal utilization, got %s", c.Name)
+	}
+}
+
+func TestTargetCluster(t *testing.T) {
+	clusters := []Cluster{
+		{ID: uuid.New(), Name: "use1-a", Region: "us-east-1", Status: "active", Health: "healthy", MaxNamespaces: 100, NamespaceCount: 80},
+		{ID: uuid.New(), Name: "use1-b", Region: "us-east-1", Status: "draining", Health: "healthy", MaxNamespaces: 100, NamespaceCount: 0},
+		{ID: uuid.New(), Name: "euw1-a", Region: "eu-west-1", Status: "active", Health: "unhealthy", MaxNamespaces: 100, NamespaceCount: 0},
+		{ID: uuid.New(), Name: "euw1-b", Region: "eu-west-1", Status: "active", Health: "unknown", MaxNamespaces: 10, NamespaceCount: 10},
+	}
+
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		expectedErr error
+	}{
+		{"active with free capacity", clusters[0].ID, nil},
+		{"draining", clusters[1].ID, errClusterUnavailable},
+		{"unhealthy", clusters[2].ID, errClusterUnavailable},
+		{"full", clusters[3].ID, errClusterUnavailable},
+		{"not registered", uuid.New(), errClusterNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := targetCluster(clusters, tt.id)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if c.ID != tt.id {
+				t.Errorf("Expected cluster %s, got %s", tt.id, c.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/billing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+// writeReport writes a billing report as text for people, or as json or csv for finance
+func writeReport(w io.Writer, r *billing.Report, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "csv":
+		return writeCSVReport(w, r)
+	default:
+		return writeTextReport(w, r)
+	}
+}
+
+// writeCSVReport writes one row per subscription period or contract, then one total
+// row per currency with the status "total"
+func writeCSVReport(w io.Writer, r *billing.Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period_start", "period_end", "organization_id", "contract_id", "kind", "status", "currency", "subtotal_cents",
+		"discount_cents", "credits_cents", "tax_cents", "total_cents", "invoice_number", "error"})
+	cents := func(c int64) string { return strconv.FormatInt(c, 10) }
+	instant := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	for _, l := range r.Lines {
+		contract := ""
+		if l.ContractID != nil {
+			contract = l.ContractID.String()
+		}
+		cw.Write([]string{instant(l.PeriodStart), instant(l.PeriodEnd), l.OrganizationID.String(), contract, string(l.Kind), string(l.Status), string(l.Currency),
+			cents(l.SubtotalCents), cents(l.DiscountCents), cents(l.CreditsCents), cents(l.TaxCents), cents(l.TotalCents),
+			l.InvoiceNumber, l.Error})
+	}
+	for _, t := range r.Totals {
+		cw.Write([]string{instant(r.PeriodStart), instant(r.PeriodEnd), "", "", "", "total", string(t.Currency),
+			cents(t.SubtotalCents), cents(t.DiscountCents), cents(t.CreditsCents), cents(t.TaxCents), cents(t.TotalCents),
+			"", ""})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTextReport writes the totals per currency and every failure
+func writeTextReport(w io.Writer, r *billing.Report) error {
+	title := "Billing run"
+	switch {
+	case r.DryRun:
+		title = "Billing dry run"
+	case r.RunID != nil:
+		title = "Billing run " + r.RunID.String()
+	}
+	fmt.Fprintf(w, "%s for %s\n", title, r.PeriodStart.Format("2 January 2006"))
+	fmt.Fprintf(w, "%d processed, %d failed\n\n", r.Processed, r.Failed)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Currency\tInvoices\tSubtotal\tDiscounts\tCredits\tTax\tTotal\t")
+	for _, t := range r.Totals {
+		amount := func(c int64) string { return money.FromMinor(t.Currency, c).String() }
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n", t.Currency, t.Invoices, amount(t.SubtotalCents),
+			amount(-t.DiscountCents), amount(-t.CreditsCents), amount(t.TaxCents), amount(t.TotalCents))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Failed > 0 {
+		fmt.Fprintf(w, "\nFailures:\n")
+		for _, l := range r.Lines {
+			if l.Status != runs.OrgFailed {
+				continue
+			}
+			subject := "organization " + l.OrganizationID.String()
+			if l.ContractID != nil {
+				subject = "contract " + l.ContractID.String()
+			}
+			fmt.Fprintf(w, "  %s %s: %s\n", l.Kind, subject, l.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/billing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+func TestParseFlags(t *testing.T) {
+	org := uuid.New()
+	cfg, err := parseFlags([]string{"--dry-run", "--org", org.String(), "--date", "2026-09-30", "--output", "csv",
+		"--concurrency", "32", "--org-timeout", "30s"})
+	if err != nil {
+		t.Fatalf("Expected the flags to parse, got %v", err)
+	}
+	opts := cfg.opts
+	if !opts.DryRun || opts.Resume || opts.OrgID != org || cfg.output != "csv" ||
+		!opts.Date.Equal(time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a dry run of %s for 2026-09-30 as csv, got %+v as %s", org, opts, cfg.output)
+	}
+	if opts.Concurrency != 32 || opts.OrgTimeout != 30*time.Second {
+		t.Errorf("Expected 32 organizations at once within 30s each, got %d within %s", opts.Concurrency, opts.OrgTimeout)
+	}
+
+	cfg, err = parseFlags([]string{"--period", "2026-02"})
+	if err != nil || !cfg.opts.Date.Equal(time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected February 2026 to be billed in the run of its last day, got %s (%v)", cfg.opts.Date, err)
+	}
+
+	cfg, err = parseFlags(nil)
+	if err != nil || cfg.opts.OrgID != uuid.Nil || !cfg.opts.Date.IsZero() || cfg.output != "text" || cfg.opts.Concurrency != 8 {
+		t.Errorf("Expected yesterday for every organization as text, got %+v (%v)", cfg, err)
+	}
+
+	for _, args := range [][]string{{"--org", "acme"}, {"--date", "2026-09"}, {"--output", "xml"}, {"--concurrency", "0"},
+		{"--period", "2026-09-01"}, {"--period", "2026-09", "--date", "2026-09-30"}} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("Expected %q to be rejected", args)
+		}
+	}
+}
+
+func testReport() *billing.Report {
+	day := time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
+	r := &billing.Report{PeriodStart: day, PeriodEnd: day.AddDate(0, 0, 1), DryRun: true}
+	r.Add(billing.ReportLine{OrganizationID: uuid.New(), Kind: invoices.Usage, Status: runs.OrgDryRun, Currency: money.EUR,
+		PeriodStart: time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC), PeriodEnd: time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC),
+		SubtotalCents: 9200, CreditsCents: 1000, TaxCents: 1558, TotalCents: 9758})
+	r.Add(billing.ReportLine{OrganizationID: uuid.New(), Kind: invoices.Usage, Status: runs.OrgFailed,
+		Error: "organization has no stripe customer ID"})
+	return r
+}
+
+func TestWriteCSVReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, testReport(), "csv"); err != nil {
+		t.Fatalf("Expected a csv report, got %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid csv, got %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected a header, 2 lines and a total, got %q", records)
+	}
+	total := records[3]
+	if total[5] != "total" || total[6] != "EUR" || total[11] != "9758" {
+		t.Errorf("Expected a EUR total of 9758, got %q", total)
+	}
+	if records[1][0] != "2026-08-15T00:00:00Z" || records[1][1] != "2026-09-15T00:00:00Z" {
+		t.Errorf("Expected the line for the period 2026-08-15 to 2026-09-15, got %q", records[1])
+	}
+	if records[2][5] != "failed" || records[2][13] == "" {
+		t.Errorf("Expected the failure with its error, got %q", records[2])
+	}
+}
+
+func TestWriteTextReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, testReport(), "text"); err != nil {
+		t.Fatalf("Expected a text report, got %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Billing dry run for 30 September 2026", "1 processed, 1 failed", "EUR", "Failures:", "no stripe customer ID"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", want, out)
+		}
+	}
+}
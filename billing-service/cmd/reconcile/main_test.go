@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/reconcile"
+)
+
+func TestParseFlags(t *testing.T) {
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	org := uuid.New()
+	cfg, err := parseFlags([]string{"--org", org.String(), "--since", "2026-09-01", "--fix", "--output", "csv"}, now)
+	if err != nil {
+		t.Fatalf("Expected the flags to parse, got %v", err)
+	}
+	if cfg.opts.OrgID != org || !cfg.opts.Fix || cfg.output != "csv" ||
+		!cfg.opts.Since.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected to fix %s since 2026-09-01 as csv, got %+v as %s", org, cfg.opts, cfg.output)
+	}
+
+	cfg, err = parseFlags(nil, now)
+	if err != nil || cfg.opts.Fix || cfg.opts.OrgID != uuid.Nil || cfg.output != "json" ||
+		!cfg.opts.Since.Equal(time.Date(2026, 7, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a json report of every organization since 2026-07-21, got %+v (%v)", cfg, err)
+	}
+
+	for _, args := range [][]string{{"--org", "acme"}, {"--since", "2026-09"}, {"--output", "text"}, {"--timeout", "0s"}} {
+		if _, err := parseFlags(args, now); err == nil {
+			t.Errorf("Expected %q to be rejected", args)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	id := uuid.New()
+	r := &reconcile.Report{Provider: "stripe", Customers: 1, Discrepancies: []reconcile.Discrepancy{
+		{OrganizationID: uuid.New(), Object: reconcile.ObjectInvoice, ProviderID: "in_1", LocalID: &id,
+			Issue: reconcile.Status, Local: "pending", Provider: "paid", Fixable: true, Fixed: true},
+		{OrganizationID: uuid.New(), Object: reconcile.ObjectSubscription, ProviderID: "sub_1",
+			Issue: reconcile.MissingAtProvider, Local: "active"},
+	}}
+
+	var out bytes.Buffer
+	if err := writeReport(&out, r, "csv"); err != nil {
+		t.Fatalf("Expected a csv report, got %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %v (%v)", rows, err)
+	}
+	if rows[1][3] != id.String() || rows[1][4] != "status" || rows[1][8] != "true" {
+		t.Errorf("Expected a fixed status discrepancy of %s, got %v", id, rows[1])
+	}
+	if rows[2][3] != "" || rows[2][4] != "missing_at_provider" || rows[2][7] != "false" {
+		t.Errorf("Expected an unfixable subscription missing at the provider, got %v", rows[2])
+	}
+
+	out.Reset()
+	if err := writeReport(&out, r, "json"); err != nil {
+		t.Fatalf("Expected a json report, got %v", err)
+	}
+	var decoded reconcile.Report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded.Discrepancies) != 2 {
+		t.Errorf("Expected the json report to decode, got %+v (%v)", decoded, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// ContractStatus is a contract with its progress towards the commitment
+type ContractStatus struct {
+	contracts.Contract
+	SpendCents           int64 `json:"spend_cents"`
+	RemainingCommitCents int64 `json:"remaining_commit_cents"`
+}
+
+// CreateContract records negotiated terms for an organization on a sales-led plan.
+// Billing periods that start within the term are priced on the contract.
+func (s *BillingService) CreateContract(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req contracts.Contract
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.OrganizationID = orgID
+
+	var currency money.Currency
+	var planID string
+	err = s.db.QueryRow(r.Context(),
+		`SELECT o.billing_currency, s.plan FROM organizations o
+		 JOIN subscriptions s ON s.organization_id = o.id WHERE o.id = $1`, orgID).Scan(&currency, &planID)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = currency
+	}
+	if req.Currency != currency {
+		http.Error(w, "Contract currency must match the organization's billing currency", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := s.catalog.Lookup(r.Context(), planID, req.StartsAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if plan.SelfServe {
+		http.Error(w, "Contracts are only available on sales-led plans", http.StatusBadRequest)
+		return
+	}
+
+	contract, err := s.contracts.Create(r.Context(), req)
+	switch {
+	case errors.Is(err, contracts.ErrInvalidContract):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, contracts.ErrContractOverlaps):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(contract)
+}
+
+// ListContracts returns an organization's contracts with spend to date
+func (s *BillingService) ListContracts(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.contracts.List(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]ContractStatus, 0, len(list))
+	for _, c := range list {
+		spend, err := s.contracts.Spend(r.Context(), c.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := ContractStatus{Contract: c, SpendCents: spend}
+		if spend < c.CommitCents {
+			status.RemainingCommitCents = c.CommitCents - spend
+		}
+		resp = append(resp, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
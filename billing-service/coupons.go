@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// CreateCouponRequest defines a coupon and whether to mirror it to Stripe
+type CreateCouponRequest struct {
+	Code             string           `json:"code"`
+	Name             string           `json:"name"`
+	PercentOff       decimal.Decimal  `json:"percent_off"`
+	AmountOffCents   int64            `json:"amount_off_cents"`
+	Currency         money.Currency   `json:"currency"`
+	Duration         coupons.Duration `json:"duration"`
+	DurationInMonths int              `json:"duration_in_months"`
+	MaxRedemptions   int              `json:"max_redemptions"`
+	Plans            []string         `json:"plans"`
+	RedeemBy         *time.Time       `json:"redeem_by"`
+	MirrorToStripe   bool             `json:"mirror_to_stripe"`
+}
+
+// CreateCoupon defines a coupon. Discounts are always calculated here and reach
+// Stripe invoices as a discount line; a mirrored Stripe coupon keeps the promotion
+// visible in the Stripe dashboard but is not attached to customers.
+func (s *BillingService) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	var req CreateCouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.coupons.Create(r.Context(), coupons.Coupon{
+		Code:             req.Code,
+		Name:             req.Name,
+		PercentOff:       req.PercentOff,
+		AmountOffCents:   req.AmountOffCents,
+		Currency:         req.Currency,
+		Duration:         req.Duration,
+		DurationInMonths: req.DurationInMonths,
+		MaxRedemptions:   req.MaxRedemptions,
+		Plans:            req.Plans,
+		RedeemBy:         req.RedeemBy,
+	})
+	switch {
+	case errors.Is(err, coupons.ErrInvalidCoupon):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, coupons.ErrCouponExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.MirrorToStripe {
+		id, err := s.payments.CreateCoupon(r.Context(), c)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Coupon created but not mirrored to %s: %v", s.payments.Name(), err), http.StatusBadGateway)
+			return
+		}
+		if err := s.coupons.SetStripeCouponID(r.Context(), c.ID, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.StripeCouponID = id
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// ListCoupons returns every coupon with its redemption count
+func (s *BillingService) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	list, err := s.coupons.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []coupons.Coupon{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// RedeemCoupon applies a promotion code to an organization. The discount starts with
+// the billing period that ends after redemption.
+func (s *BillingService) RedeemCoupon(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	plan, _, err := s.subscriptionPlan(r.Context(), orgID, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redemption, err := s.coupons.Redeem(r.Context(), orgID, req.Code, plan.ID, now)
+	switch {
+	case errors.Is(err, coupons.ErrCouponNotFound):
+		http.Error(w, "Coupon not found", http.StatusNotFound)
+		return
+	case errors.Is(err, coupons.ErrCouponExpired), errors.Is(err, coupons.ErrCouponNotForPlan):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, coupons.ErrCouponExhausted), errors.Is(err, coupons.ErrAlreadyRedeemed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(redemption)
+}
+
+// ListRedemptions returns the coupons an organization has redeemed
+func (s *BillingService) ListRedemptions(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.coupons.Redemptions(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []coupons.Redemption{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
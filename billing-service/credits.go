@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/credits"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// CreditBalance summarizes an organization's credits
+type CreditBalance struct {
+	OrganizationID uuid.UUID        `json:"organization_id"`
+	Currency       money.Currency   `json:"currency"`
+	AvailableCents int64            `json:"available_cents"`
+	Credits        []credits.Credit `json:"credits"`
+}
+
+// GrantCredit grants a promotional, SLA or prepaid credit to an organization. Credits
+// are always in the organization's billing currency.
+func (s *BillingService) GrantCredit(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Kind        credits.Kind `json:"kind"`
+		AmountCents int64        `json:"amount_cents"`
+		Currency    string       `json:"currency"`
+		Description string       `json:"description"`
+		ExpiresAt   *time.Time   `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var billingCurrency money.Currency
+	err = s.db.QueryRow(r.Context(),
+		`SELECT billing_currency FROM organizations WHERE id = $1`, orgID).Scan(&billingCurrency)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	currency := billingCurrency
+	if req.Currency != "" {
+		if currency, err = money.ParseCurrency(req.Currency); err != nil {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+	}
+	if currency != billingCurrency {
+		http.Error(w, fmt.Sprintf("Credits must be in the organization's billing currency (%s)", billingCurrency), http.StatusBadRequest)
+		return
+	}
+
+	credit, err := s.credits.Grant(r.Context(), credits.Credit{
+		OrganizationID: orgID,
+		Kind:           req.Kind,
+		Currency:       currency,
+		AmountCents:    req.AmountCents,
+		Description:    req.Description,
+		ExpiresAt:      req.ExpiresAt,
+	})
+	if errors.Is(err, credits.ErrInvalidCredit) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(credit)
+}
+
+// ListCredits returns an organization's credits and the balance still available
+func (s *BillingService) ListCredits(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var currency money.Currency
+	err = s.db.QueryRow(r.Context(),
+		`SELECT billing_currency FROM organizations WHERE id = $1`, orgID).Scan(&currency)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	list, err := s.credits.List(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	balance := CreditBalance{OrganizationID: orgID, Currency: currency, Credits: make([]credits.Credit, 0, len(list))}
+	now := time.Now()
+	for _, c := range list {
+		if c.UsableFor(currency, now) {
+			balance.AvailableCents += c.RemainingCents
+		}
+		balance.Credits = append(balance.Credits, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balance)
+}
+
+// ListCreditDebits returns the ledger of credits burned against an organization's invoices
+func (s *BillingService) ListCreditDebits(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	debits, err := s.credits.Debits(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if debits == nil {
+		debits = []credits.Debit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(debits)
+}
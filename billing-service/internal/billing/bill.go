@@ -0,0 +1,219 @@
+// Package billing prices usage into bills and invoices them. Billing runs invoice the
+// subscription periods that ended by the end of a day, and true up contracts at the end
+// of their term.
+package billing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/credits"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/tax"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/trials"
+)
+
+// MonthlyBill represents a calculated monthly bill
+type MonthlyBill struct {
+	OrganizationID         uuid.UUID       `json:"organization_id"`
+	PeriodStart            time.Time       `json:"period_start"`
+	PeriodEnd              time.Time       `json:"period_end"`
+	Plan                   string          `json:"plan"`
+	PlanVersion            int             `json:"plan_version"`
+	ContractID             *uuid.UUID      `json:"contract_id,omitempty"`
+	TrialEndsAt            *time.Time      `json:"trial_ends_at,omitempty"`
+	Currency               money.Currency  `json:"currency"`
+	Rounding               money.Rounding  `json:"rounding"`
+	BaseCostCents          int64           `json:"base_cost_cents"`
+	ActionsUsed            int64           `json:"actions_used"`
+	ActionsIncluded        int64           `json:"actions_included"`
+	ActionOverageCents     int64           `json:"action_overage_cents"`
+	ReplicatedActions      int64           `json:"replicated_actions"`
+	ReplicatedActionsCents int64           `json:"replicated_actions_cents"`
+	ActiveStorageGBH       decimal.Decimal `json:"active_storage_gbh"`
+	ActiveStorageCents     int64           `json:"active_storage_cents"`
+	RetainedStorageGBH     decimal.Decimal `json:"retained_storage_gbh"`
+	RetainedStorageCents   int64           `json:"retained_storage_cents"`
+	ArchivedStorageGBH     decimal.Decimal `json:"archived_storage_gbh"`
+	ArchivedStorageCents   int64           `json:"archived_storage_cents"`
+	SubtotalCents          int64           `json:"subtotal_cents"`
+	DiscountCents          int64           `json:"discount_cents"`
+	CreditsAppliedCents    int64           `json:"credits_applied_cents"`
+	TotalCents             int64           `json:"total_cents"`
+
+	// Included, used and overage amounts of every meter
+	Meters []pricing.Charge `json:"meters"`
+
+	// Promotion code of the coupon discounting this bill
+	Coupon string `json:"coupon,omitempty"`
+
+	// Credits burned against this bill
+	Credits []credits.Debit `json:"credits,omitempty"`
+
+	// Taxes charged on the total after discounts and credits
+	TaxCents int64      `json:"tax_cents"`
+	Taxes    []tax.Line `json:"taxes,omitempty"`
+}
+
+// NewMonthlyBill prices a period's usage on a plan version in the organization's
+// billing currency. The total is the sum of the rounded lines.
+func NewMonthlyBill(orgID uuid.UUID, periodStart, periodEnd time.Time, plan plans.Plan, currency money.Currency, usage pricing.Usage, rounding money.Rounding) (*MonthlyBill, error) {
+	base, book, err := plan.PricesIn(currency)
+	if err != nil {
+		return nil, err
+	}
+	quote := pricing.Price(currency, book, usage, plan.Allowance(periodStart, periodEnd), rounding)
+
+	bill := &MonthlyBill{
+		OrganizationID:         orgID,
+		PeriodStart:            periodStart,
+		PeriodEnd:              periodEnd,
+		Plan:                   plan.Name,
+		PlanVersion:            plan.Version,
+		Currency:               quote.Currency,
+		Rounding:               quote.Rounding,
+		BaseCostCents:          base.BasePriceMinor,
+		ActionsUsed:            usage.Actions,
+		ActionsIncluded:        plan.ActionsIncluded,
+		ActionOverageCents:     quote.Charge(pricing.MeterActions).Cents,
+		ReplicatedActions:      usage.ReplicatedActions,
+		ReplicatedActionsCents: quote.Charge(pricing.MeterReplicatedActions).Cents,
+		ActiveStorageGBH:       usage.ActiveStorageGBH,
+		ActiveStorageCents:     quote.Charge(pricing.MeterActiveStorage).Cents,
+		RetainedStorageGBH:     usage.RetainedStorageGBH,
+		RetainedStorageCents:   quote.Charge(pricing.MeterRetainedStorage).Cents,
+		ArchivedStorageGBH:     usage.ArchivedStorageGBH,
+		ArchivedStorageCents:   quote.Charge(pricing.MeterArchivedStorage).Cents,
+		Meters:                 quote.Charges,
+	}
+	bill.SubtotalCents = bill.BaseCostCents + quote.TotalCents
+	bill.TotalCents = bill.SubtotalCents
+
+	return bill, nil
+}
+
+// ApplyTrial charges the base price only for the part of the period after a trial
+func (b *MonthlyBill) ApplyTrial(trialEndsAt *time.Time) {
+	share := trials.PaidShare(b.PeriodStart, b.PeriodEnd, trialEndsAt)
+	if share.Equal(decimal.NewFromInt(1)) {
+		return
+	}
+	b.TrialEndsAt = trialEndsAt
+	base := decimal.NewFromInt(b.BaseCostCents).Mul(share).Round(0).IntPart()
+	b.SubtotalCents -= b.BaseCostCents - base
+	b.BaseCostCents = base
+	b.TotalCents = b.SubtotalCents - b.DiscountCents - b.CreditsAppliedCents
+}
+
+// ApplyDiscount takes a redeemed coupon's discount off the bill total
+func (b *MonthlyBill) ApplyDiscount(r *coupons.Redemption, planID string) {
+	b.DiscountCents = r.Discount(planID, b.Currency, b.PeriodEnd, b.SubtotalCents)
+	if b.DiscountCents > 0 {
+		b.Coupon = r.Coupon.Code
+	}
+	b.TotalCents = b.SubtotalCents - b.DiscountCents - b.CreditsAppliedCents
+}
+
+// ApplyCredits deducts burned credits from the bill total
+func (b *MonthlyBill) ApplyCredits(debits []credits.Debit) {
+	b.Credits = debits
+	b.CreditsAppliedCents = credits.Total(debits)
+	b.TotalCents = b.SubtotalCents - b.DiscountCents - b.CreditsAppliedCents
+}
+
+// ApplyTax adds the taxes on the amount left to pay to the bill total
+func (b *MonthlyBill) ApplyTax(r tax.Result) {
+	b.Taxes = r.Lines
+	b.TaxCents = r.TotalCents()
+	b.TotalCents = b.SubtotalCents - b.DiscountCents - b.CreditsAppliedCents + b.TaxCents
+}
+
+// periodReference identifies an organization's billing period. Credits burned for a
+// period are recorded against it, so recalculating the bill reuses the same debits.
+func periodReference(orgID uuid.UUID, periodStart time.Time) string {
+	return fmt.Sprintf("INV-%s-%s", orgID.String()[:8], periodStart.UTC().Format("20060102"))
+}
+
+// periodLabel names a billing period on invoices: the month for calendar months, and
+// the dates otherwise
+func periodLabel(start, end time.Time) string {
+	start, end = start.UTC(), end.UTC()
+	if start.Day() == 1 && start.Equal(start.Truncate(24*time.Hour)) && end.Equal(start.AddDate(0, 1, 0)) {
+		return start.Format("January 2006")
+	}
+	return start.Format("Jan 2, 2006") + " - " + end.Format("Jan 2, 2006")
+}
+
+// meter returns the charge of one meter on the bill
+func (b *MonthlyBill) meter(m pricing.Meter) pricing.Charge {
+	for _, c := range b.Meters {
+		if c.Meter == m {
+			return c
+		}
+	}
+	return pricing.Charge{Meter: m}
+}
+
+// meterDescription describes a usage line with its included, used and overage amounts
+func meterDescription(label, unit string, places int32, c pricing.Charge) string {
+	return fmt.Sprintf("%s (%s %s used, %s included, %s billed)", label,
+		c.Used.StringFixed(places), unit, c.Included.StringFixed(places), c.Overage.StringFixed(places))
+}
+
+// lines lists the line items of a bill. Discounts and credits are negative and left
+// out when empty, and taxes come last.
+func (b *MonthlyBill) lines() []invoices.Line {
+	one := decimal.NewFromInt(1)
+	usage := func(label, unit string, places int32, m pricing.Meter, cents int64) invoices.Line {
+		c := b.meter(m)
+		return invoices.Line{Kind: invoices.LineUsage, Description: meterDescription(label, unit, places, c),
+			Meter: m, Quantity: c.Overage, Unit: unit, AmountCents: cents}
+	}
+	// Every meter is listed, even when its allowance covers it
+	lines := []invoices.Line{
+		{Kind: invoices.LineBase, Description: fmt.Sprintf("%s Plan - %s", b.Plan, periodLabel(b.PeriodStart, b.PeriodEnd)),
+			Quantity: one, AmountCents: b.BaseCostCents},
+		usage("Action Overage", "actions", 0, pricing.MeterActions, b.ActionOverageCents),
+		usage("Replicated Actions", "actions", 0, pricing.MeterReplicatedActions, b.ReplicatedActionsCents),
+		usage("Active Storage", "GB-hours", 2, pricing.MeterActiveStorage, b.ActiveStorageCents),
+		usage("Retained Storage", "GB-hours", 2, pricing.MeterRetainedStorage, b.RetainedStorageCents),
+		usage("Archived Storage", "GB-hours", 2, pricing.MeterArchivedStorage, b.ArchivedStorageCents),
+	}
+	if b.DiscountCents != 0 {
+		lines = append(lines, invoices.Line{Kind: invoices.LineDiscount, Description: fmt.Sprintf("Discount (%s)", b.Coupon),
+			Quantity: one, AmountCents: -b.DiscountCents})
+	}
+	if b.CreditsAppliedCents != 0 {
+		lines = append(lines, invoices.Line{Kind: invoices.LineCredit, Description: "Credits applied", Quantity: one,
+			AmountCents: -b.CreditsAppliedCents})
+	}
+
+	// Reverse-charged taxes are kept at zero so the invoice states them
+	for _, t := range b.Taxes {
+		lines = append(lines, invoices.Line{Kind: invoices.LineTax, Description: t.Description, AmountCents: t.AmountCents})
+	}
+	return lines
+}
+
+// invoice returns the bill as an invoice to be issued
+func (b *MonthlyBill) invoice() invoices.Invoice {
+	return invoices.Invoice{
+		OrganizationID: b.OrganizationID,
+		Kind:           invoices.Usage,
+		PeriodStart:    b.PeriodStart,
+		PeriodEnd:      b.PeriodEnd,
+		Currency:       b.Currency,
+		SubtotalCents:  b.SubtotalCents,
+		DiscountCents:  b.DiscountCents,
+		TaxCents:       b.TaxCents,
+		TotalCents:     b.TotalCents,
+		ContractID:     b.ContractID,
+		Lines:          b.lines(),
+	}
+}
@@ -0,0 +1,346 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/credits"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/proration"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/tax"
+)
+
+func defaultPlan(id string) plans.Plan {
+	return plans.Effective(plans.Defaults(), id, time.Now())
+}
+
+// mustBill prices usage with per-line rounding, failing the test if the plan is not
+// offered in the currency
+func mustBill(t testing.TB, plan plans.Plan, currency money.Currency, periodStart, periodEnd time.Time, usage pricing.Usage) *MonthlyBill {
+	t.Helper()
+	bill, err := NewMonthlyBill(uuid.New(), periodStart, periodEnd, plan, currency, usage, money.PerLine)
+	if err != nil {
+		t.Fatalf("Expected a bill, got %v", err)
+	}
+	return bill
+}
+
+func TestMonthlyBillUsesPlanPrices(t *testing.T) {
+	plan := defaultPlan("essential")
+	usage := pricing.Usage{Actions: 3000000}
+
+	listPrice := mustBill(t, plan, money.USD, time.Now(), time.Now(), usage)
+	if listPrice.ActionOverageCents != 2*pricing.CentsPerMillionActions {
+		t.Errorf("Expected %d cents at list price, got %d", 2*pricing.CentsPerMillionActions, listPrice.ActionOverageCents)
+	}
+
+	plan.Prices = pricing.PriceBook{pricing.MeterActions: pricing.Flat(1000000, true, decimal.NewFromInt(4000))}
+	override := mustBill(t, plan, money.USD, time.Now(), time.Now(), usage)
+	if override.ActionOverageCents != 8000 {
+		t.Errorf("Expected 8000 cents with plan override, got %d", override.ActionOverageCents)
+	}
+}
+
+func TestMonthlyBillShowsMeterAllowances(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	usage := pricing.Usage{Actions: 500000, ActiveStorageGBH: decimal.NewFromInt(1000)}
+	bill := mustBill(t, defaultPlan("essential"), money.USD, start, start.AddDate(0, 0, 30), usage)
+
+	if len(bill.Meters) != len(pricing.Meters) {
+		t.Fatalf("Expected a line for every meter, got %d", len(bill.Meters))
+	}
+
+	active := bill.meter(pricing.MeterActiveStorage)
+	if !active.Included.Equal(decimal.NewFromInt(720)) || !active.Overage.Equal(decimal.NewFromInt(280)) {
+		t.Errorf("Expected 720 included and 280 overage GB-hours, got %+v", active)
+	}
+	if bill.ActiveStorageCents != 1176 {
+		t.Errorf("Expected 280 GB-hours at 4.2 cents (1176), got %d", bill.ActiveStorageCents)
+	}
+
+	actions := bill.meter(pricing.MeterActions)
+	if !actions.Overage.IsZero() || bill.ActionOverageCents != 0 {
+		t.Errorf("Expected no action overage within the allowance, got %+v", actions)
+	}
+
+	expected := "Active Storage (1000.00 GB-hours used, 720.00 included, 280.00 billed)"
+	if desc := meterDescription("Active Storage", "GB-hours", 2, active); desc != expected {
+		t.Errorf("Expected %q, got %q", expected, desc)
+	}
+}
+
+func TestMonthlyBillInOrgCurrency(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	usage := pricing.Usage{Actions: 3000000, ActiveStorageGBH: decimal.NewFromInt(820)}
+
+	tests := []struct {
+		currency        money.Currency
+		expectedBase    int64
+		expectedActions int64
+		expectedStorage int64
+	}{
+		{money.USD, 10000, 2 * 2500, 420},
+		{money.EUR, 9200, 2 * 2300, 390},
+		{money.GBP, 8000, 2 * 2000, 330},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.currency), func(t *testing.T) {
+			bill := mustBill(t, defaultPlan("essential"), tt.currency, start, end, usage)
+			if bill.Currency != tt.currency {
+				t.Errorf("Expected bill in %s, got %s", tt.currency, bill.Currency)
+			}
+			if bill.BaseCostCents != tt.expectedBase {
+				t.Errorf("Expected base %d, got %d", tt.expectedBase, bill.BaseCostCents)
+			}
+			if bill.ActionOverageCents != tt.expectedActions {
+				t.Errorf("Expected action overage %d, got %d", tt.expectedActions, bill.ActionOverageCents)
+			}
+			if bill.ActiveStorageCents != tt.expectedStorage {
+				t.Errorf("Expected active storage %d, got %d", tt.expectedStorage, bill.ActiveStorageCents)
+			}
+			if expected := tt.expectedBase + tt.expectedActions + tt.expectedStorage; bill.TotalCents != expected {
+				t.Errorf("Expected total %d, got %d", expected, bill.TotalCents)
+			}
+			for _, c := range bill.Meters {
+				if c.Amount.Currency != tt.currency {
+					t.Errorf("Expected %s line in %s, got %s", c.Meter, tt.currency, c.Amount.Currency)
+				}
+			}
+		})
+	}
+}
+
+func TestMonthlyBillRejectsUnofferedCurrency(t *testing.T) {
+	_, err := NewMonthlyBill(uuid.New(), time.Now(), time.Now(), defaultPlan("essential"), "JPY", pricing.Usage{}, money.PerLine)
+	if !errors.Is(err, plans.ErrCurrencyNotOffered) {
+		t.Errorf("Expected ErrCurrencyNotOffered, got %v", err)
+	}
+}
+
+func TestMonthlyBillAppliesCredits(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	bill := mustBill(t, defaultPlan("essential"), money.USD, start, start.AddDate(0, 1, 0), pricing.Usage{Actions: 2000000})
+	if bill.SubtotalCents != 12500 || bill.TotalCents != 12500 {
+		t.Fatalf("Expected 12500 before credits, got subtotal %d and total %d", bill.SubtotalCents, bill.TotalCents)
+	}
+
+	bill.ApplyCredits([]credits.Debit{{AmountCents: 10000}, {AmountCents: 500}})
+	if bill.CreditsAppliedCents != 10500 {
+		t.Errorf("Expected 10500 credits applied, got %d", bill.CreditsAppliedCents)
+	}
+	if bill.TotalCents != 2000 || bill.SubtotalCents != 12500 {
+		t.Errorf("Expected 2000 due on a 12500 subtotal, got %d on %d", bill.TotalCents, bill.SubtotalCents)
+	}
+}
+
+func TestMonthlyBillOnContract(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	contract := contracts.Contract{
+		Currency:        money.USD,
+		StartsAt:        start.AddDate(0, -8, 0),
+		EndsAt:          start.AddDate(0, 4, 0),
+		CommitCents:     6000000,
+		MonthlyFeeCents: 250000,
+		Discounts:       map[pricing.Meter]decimal.Decimal{pricing.MeterActions: decimal.NewFromInt(20)},
+		TrueUp:          contracts.TrueUpShortfall,
+	}
+	plan := contract.Apply(defaultPlan("enterprise"))
+	usage := pricing.Usage{Actions: plan.ActionsIncluded + 10000000}
+
+	bill := mustBill(t, plan, money.USD, start, start.AddDate(0, 1, 0), usage)
+	if bill.BaseCostCents != 250000 {
+		t.Errorf("Expected the contract platform fee, got %d", bill.BaseCostCents)
+	}
+	if expected := int64(10 * pricing.CentsPerMillionActions * 8 / 10); bill.ActionOverageCents != expected {
+		t.Errorf("Expected %d with a 20%% discount, got %d", expected, bill.ActionOverageCents)
+	}
+}
+
+func TestMonthlyBillAppliesCoupon(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	redemption := &coupons.Redemption{
+		Coupon:     coupons.Coupon{Code: "LAUNCH20", PercentOff: decimal.NewFromInt(20), Duration: coupons.Forever},
+		RedeemedAt: start.AddDate(0, -2, 0),
+	}
+	plan := defaultPlan("essential")
+	bill := mustBill(t, plan, money.USD, start, end, pricing.Usage{Actions: 2000000})
+	bill.ApplyDiscount(redemption, plan.ID)
+	bill.ApplyCredits([]credits.Debit{{AmountCents: 1000}})
+	if bill.DiscountCents != 2500 || bill.Coupon != "LAUNCH20" {
+		t.Errorf("Expected LAUNCH20 to take 2500 off, got %d from %q", bill.DiscountCents, bill.Coupon)
+	}
+	if bill.TotalCents != 9000 || bill.SubtotalCents != 12500 {
+		t.Errorf("Expected 9000 due on a 12500 subtotal, got %d on %d", bill.TotalCents, bill.SubtotalCents)
+	}
+}
+
+func TestMonthlyBillExcludesTrial(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	trialEnd := start.AddDate(0, 0, 12)
+	plan := defaultPlan("essential")
+
+	// Usage is already limited to the time after the trial
+	bill := mustBill(t, plan, money.USD, start, end, pricing.Usage{Actions: 2000000})
+	bill.ApplyTrial(&trialEnd)
+	if bill.BaseCostCents != 6000 {
+		t.Errorf("Expected 18 of 30 days of the base price, got %d", bill.BaseCostCents)
+	}
+	if bill.SubtotalCents != 8500 || bill.TotalCents != 8500 {
+		t.Errorf("Expected 8500 after the trial, got subtotal %d and total %d", bill.SubtotalCents, bill.TotalCents)
+	}
+
+	trialing := mustBill(t, plan, money.USD, start, end, pricing.Usage{})
+	trialing.ApplyTrial(&end)
+	if trialing.TotalCents != 0 {
+		t.Errorf("Expected nothing due for a period in trial, got %d", trialing.TotalCents)
+	}
+}
+
+func TestMonthlyBillProratesPlanChange(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	split := start.AddDate(0, 0, 10)
+	plan := proration.Blend([]proration.Segment{
+		{Plan: defaultPlan("essential"), From: start, To: split},
+		{Plan: defaultPlan("business"), From: split, To: end},
+	}, start, end)
+
+	bill := mustBill(t, plan, money.EUR, start, end, pricing.Usage{})
+	if bill.BaseCostCents != 33734 {
+		t.Errorf("Expected 10 days of essential and 20 of business in EUR, got %d", bill.BaseCostCents)
+	}
+}
+
+func TestMonthlyBillInvoiceItems(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	redemption := &coupons.Redemption{
+		Coupon:     coupons.Coupon{Code: "LAUNCH20", PercentOff: decimal.NewFromInt(20), Duration: coupons.Forever},
+		RedeemedAt: start,
+	}
+	plan := defaultPlan("essential")
+	bill := mustBill(t, plan, money.USD, start, end, pricing.Usage{Actions: 2000000})
+	bill.ApplyDiscount(redemption, plan.ID)
+	bill.ApplyCredits([]credits.Debit{{AmountCents: 1000}})
+
+	ctx := context.Background()
+	fake := payments.NewFake("")
+	cust, _ := fake.CreateCustomer(ctx, payments.CustomerParams{Name: "Acme"})
+	inv, err := fake.CreateInvoice(ctx, payments.InvoiceParams{CustomerID: cust.ID, Currency: bill.Currency, Items: invoiceItems(bill.invoice())})
+	if err != nil {
+		t.Fatalf("Expected an invoice, got %v", err)
+	}
+
+	// Base, every meter including those within their allowance, discount and credits
+	items := fake.InvoiceItems(inv.ID)
+	if len(items) != 8 {
+		t.Fatalf("Expected 8 invoice items, got %+v", items)
+	}
+	if items[6].AmountCents != -2500 || items[7].AmountCents != -1000 {
+		t.Errorf("Expected the discount and credits as negative items, got %d and %d", items[6].AmountCents, items[7].AmountCents)
+	}
+	if inv.TotalCents != bill.TotalCents {
+		t.Errorf("Expected the provider invoice to total %d, got %d", bill.TotalCents, inv.TotalCents)
+	}
+}
+
+func TestMonthlyBillInvoice(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	plan := defaultPlan("essential")
+	bill := mustBill(t, plan, money.USD, start, end, pricing.Usage{Actions: 2000000})
+	bill.ApplyCredits([]credits.Debit{{AmountCents: 1000}})
+
+	inv := bill.invoice()
+	if err := inv.Validate(); err != nil {
+		t.Fatalf("Expected the bill's invoice to add up, got %v", err)
+	}
+	if len(inv.Lines) != 7 {
+		t.Fatalf("Expected base, five usage and credit lines, got %+v", inv.Lines)
+	}
+
+	usage := inv.Lines[1]
+	if usage.Kind != invoices.LineUsage || usage.Meter != pricing.MeterActions || usage.Unit != "actions" ||
+		!usage.Quantity.Equal(bill.meter(pricing.MeterActions).Overage) {
+		t.Errorf("Expected the action overage with its billed quantity, got %+v", usage)
+	}
+	if storage := inv.Lines[3]; storage.Meter != pricing.MeterActiveStorage || storage.AmountCents != 0 ||
+		storage.Description != "Active Storage (0.00 GB-hours used, 720.00 included, 0.00 billed)" {
+		t.Errorf("Expected active storage within its allowance to be listed, got %+v", storage)
+	}
+	if credit := inv.Lines[6]; credit.Kind != invoices.LineCredit || credit.AmountCents != -1000 {
+		t.Errorf("Expected a -1000 credit line, got %+v", credit)
+	}
+	if items := invoiceItems(inv); len(items) != 7 || items[0].Description != inv.Lines[0].Description {
+		t.Errorf("Expected one provider item per line, got %+v", items)
+	}
+}
+
+func TestMonthlyBillTax(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		customer      tax.Customer
+		expectedTax   int64
+		expectedLines int
+	}{
+		{"german consumer", tax.Customer{Address: tax.Address{Country: "DE"}}, 1558, 8},
+		{"german business", tax.Customer{Address: tax.Address{Country: "DE"},
+			TaxIDs: []tax.ID{{Type: tax.EUVAT, Value: "DE123456789", Country: "DE"}}}, 0, 8},
+		{"US customer", tax.Customer{Address: tax.Address{Country: "US"}}, 0, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bill := mustBill(t, defaultPlan("essential"), money.EUR, start, end, pricing.Usage{})
+			bill.ApplyCredits([]credits.Debit{{AmountCents: 1000}})
+			taxes, err := tax.NewLocal("US").Calculate(ctx, tax.Request{Customer: tt.customer, Currency: bill.Currency, AmountCents: bill.TotalCents})
+			if err != nil {
+				t.Fatalf("Expected taxes, got %v", err)
+			}
+			bill.ApplyTax(taxes)
+
+			if bill.TaxCents != tt.expectedTax || bill.TotalCents != bill.SubtotalCents-1000+tt.expectedTax {
+				t.Errorf("Expected tax %d on top of the credited total, got tax %d and total %d", tt.expectedTax, bill.TaxCents, bill.TotalCents)
+			}
+			inv := bill.invoice()
+			if err := inv.Validate(); err != nil {
+				t.Errorf("Expected the taxed invoice to add up, got %v", err)
+			}
+			items := invoiceItems(inv)
+			if len(items) != tt.expectedLines {
+				t.Fatalf("Expected %d provider items, got %+v", tt.expectedLines, items)
+			}
+			if len(bill.Taxes) > 0 && items[len(items)-1].Description != bill.Taxes[0].Description {
+				t.Errorf("Expected the tax as the last item, got %+v", items[len(items)-1])
+			}
+		})
+	}
+}
+
+func TestPeriodLabel(t *testing.T) {
+	september := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	if label := periodLabel(september, september.AddDate(0, 1, 0)); label != "September 2026" {
+		t.Errorf("Expected September 2026, got %s", label)
+	}
+	start := time.Date(2026, 9, 15, 4, 0, 0, 0, time.UTC)
+	if label := periodLabel(start, start.AddDate(0, 1, 0)); label != "Sep 15, 2026 - Oct 15, 2026" {
+		t.Errorf("Expected Sep 15, 2026 - Oct 15, 2026, got %s", label)
+	}
+}
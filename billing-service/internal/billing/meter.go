@@ -0,0 +1,259 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/credits"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/proration"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/tax"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/trials"
+)
+
+// UsageMeter handles usage-based billing calculations
+type UsageMeter struct {
+	db        *pgxpool.Pool
+	accounts  accountStore
+	catalog   planCatalog
+	contracts *contracts.Store
+	coupons   *coupons.Store
+	changes   *proration.Store
+	credits   *credits.Ledger
+	invoices  invoiceStore
+	taxes     *tax.Store
+	taxEngine tax.Engine
+	payments  payments.Provider
+	rounding  money.Rounding
+	runs      runStore
+}
+
+// NewUsageMeter creates a new usage meter that invoices through a payment provider
+func NewUsageMeter(db *pgxpool.Pool, provider payments.Provider) *UsageMeter {
+	return &UsageMeter{db: db, accounts: accounts{db: db}, catalog: plans.NewCatalog(db), contracts: contracts.NewStore(db),
+		coupons: coupons.NewStore(db), changes: proration.NewStore(db),
+		credits: credits.NewLedger(db), invoices: invoices.NewStore(db), taxes: tax.NewStore(db), taxEngine: taxEngineFromEnv(),
+		payments: provider, rounding: RoundingFromEnv(), runs: runs.NewStore(db)}
+}
+
+// RoundingFromEnv reads the invoice rounding mode from BILLING_ROUNDING
+// (per_line, per_invoice or bankers), defaulting to per_line.
+func RoundingFromEnv() money.Rounding {
+	rounding, err := money.ParseRounding(os.Getenv("BILLING_ROUNDING"))
+	if err != nil {
+		log.Printf("Ignoring BILLING_ROUNDING: %v", err)
+		return money.PerLine
+	}
+	return rounding
+}
+
+// taxEngineFromEnv creates the tax engine named by TAX_ENGINE, falling back to the
+// local rules when it is not recognized
+func taxEngineFromEnv() tax.Engine {
+	engine, err := tax.NewFromEnv()
+	if err != nil {
+		log.Printf("Ignoring TAX_ENGINE: %v", err)
+		return tax.NewLocal("US")
+	}
+	return engine
+}
+
+// CalculateMonthlyBill calculates the bill for an organization for a given month,
+// burning the credits that pay for it
+func (m *UsageMeter) CalculateMonthlyBill(ctx context.Context, orgID uuid.UUID, periodStart, periodEnd time.Time) (*MonthlyBill, error) {
+	versions, err := m.planVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.calculateMonthlyBill(ctx, versions, orgID, periodStart, periodEnd, false)
+}
+
+// PreviewMonthlyBill calculates the bill as CalculateMonthlyBill does, showing the
+// credits that would pay for it without burning them
+func (m *UsageMeter) PreviewMonthlyBill(ctx context.Context, orgID uuid.UUID, periodStart, periodEnd time.Time) (*MonthlyBill, error) {
+	versions, err := m.planVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.calculateMonthlyBill(ctx, versions, orgID, periodStart, periodEnd, true)
+}
+
+// planVersions reads every version of every plan, which a billing run reads once and
+// shares between the organizations it bills
+func (m *UsageMeter) planVersions(ctx context.Context) ([]plans.Plan, error) {
+	versions, err := m.catalog.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+	return versions, nil
+}
+
+// calculateMonthlyBill calculates a bill on the plans in versions
+func (m *UsageMeter) calculateMonthlyBill(ctx context.Context, versions []plans.Plan, orgID uuid.UUID, periodStart, periodEnd time.Time,
+	preview bool) (*MonthlyBill, error) {
+	sub, err := m.accounts.subscription(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bill on the plan version in effect when the period started
+	plan := plans.Effective(versions, sub.plan, periodStart)
+
+	// Plan changes prorate the base price and included allowances per day
+	changes, err := m.changes.Since(ctx, orgID, periodStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan changes: %w", err)
+	}
+	segments, err := proration.Segments(plan, changes, periodStart, periodEnd, func(id string) (plans.Plan, error) {
+		return plans.Effective(versions, id, periodStart), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	plan = proration.Blend(segments, periodStart, periodEnd)
+
+	// Contract terms replace the plan's prices while a contract covers the period
+	contract, err := m.contracts.Active(ctx, orgID, periodStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contract: %w", err)
+	}
+	if contract != nil {
+		plan = contract.Apply(plan)
+	}
+
+	// Get usage aggregates for the period. Each hour is billed in the period it starts
+	// in, since periods need not start on the hour. Usage during a trial is not billed.
+	usage, err := m.accounts.usage(ctx, orgID, trials.PaidFrom(periodStart, sub.trialEndsAt), periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	bill, err := NewMonthlyBill(orgID, periodStart, periodEnd, plan, sub.currency, usage, m.rounding)
+	if err != nil {
+		return nil, err
+	}
+	if contract != nil {
+		bill.ContractID = &contract.ID
+	}
+	bill.ApplyTrial(sub.trialEndsAt)
+
+	// Coupons discount the subtotal before credits are burned
+	redemption, err := m.coupons.Active(ctx, orgID, periodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+	bill.ApplyDiscount(redemption, plan.ID)
+
+	// Burn credits, soonest expiry first, before charging the rest
+	burn := m.credits.Apply
+	if preview {
+		burn = m.credits.Preview
+	}
+	debits, err := burn(ctx, orgID, bill.Currency, periodReference(orgID, periodStart), periodStart, periodEnd, bill.SubtotalCents-bill.DiscountCents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply credits: %w", err)
+	}
+	bill.ApplyCredits(debits)
+
+	// Tax what is left to pay after discounts and credits
+	customer, err := m.taxes.Customer(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax details: %w", err)
+	}
+	taxes, err := m.taxEngine.Calculate(ctx, tax.Request{Customer: customer, Currency: bill.Currency, AmountCents: bill.TotalCents})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate tax: %w", err)
+	}
+	bill.ApplyTax(taxes)
+	return bill, nil
+}
+
+// invoiceItems lists the lines of an invoice as invoiced by the payment provider
+func invoiceItems(inv invoices.Invoice) []payments.InvoiceItem {
+	items := make([]payments.InvoiceItem, 0, len(inv.Lines))
+	for _, line := range inv.Lines {
+		items = append(items, payments.InvoiceItem{AmountCents: line.AmountCents, Description: line.Description})
+	}
+	return items
+}
+
+// GenerateInvoice invoices the monthly bill through the payment provider and stores it.
+// The provider call carries idempotencyKey, so retrying after a failure returns what the
+// failed attempt created. The returned invoice has the provider's invoice ID as soon as
+// the provider has one, even when storing it failed.
+func (m *UsageMeter) GenerateInvoice(ctx context.Context, bill *MonthlyBill, idempotencyKey string) (invoices.Invoice, error) {
+	invoice := bill.invoice()
+
+	// Get the provider's customer ID
+	stripeCustomerID, err := m.accounts.customerID(ctx, bill.OrganizationID)
+	if err != nil {
+		return invoice, fmt.Errorf("failed to get %s customer: %w", m.payments.Name(), err)
+	}
+
+	if stripeCustomerID == "" {
+		return invoice, fmt.Errorf("organization has no %s customer ID", m.payments.Name())
+	}
+
+	// Create and finalize invoice
+	inv, err := m.payments.CreateInvoice(ctx, payments.InvoiceParams{
+		CustomerID:     stripeCustomerID,
+		Currency:       bill.Currency,
+		Items:          invoiceItems(invoice),
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		return invoice, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	invoice.ProviderInvoiceID = inv.ID
+	return m.storeInvoice(ctx, invoice)
+}
+
+// storeInvoice stores an invoice the payment provider has issued
+func (m *UsageMeter) storeInvoice(ctx context.Context, invoice invoices.Invoice) (invoices.Invoice, error) {
+	invoice.Status = "pending"
+	stored, err := m.invoices.Create(ctx, invoice)
+	if err != nil {
+		return invoice, fmt.Errorf("%s invoice %s was created but not stored: %w", m.payments.Name(), invoice.ProviderInvoiceID, err)
+	}
+	return stored, nil
+}
+
+// reportUsage sends a bill's metered usage to payment providers that keep usage
+// records. Events are keyed by organization, period and meter, so a rerun is ignored.
+func (m *UsageMeter) reportUsage(ctx context.Context, bill *MonthlyBill) error {
+	reporter, ok := m.payments.(payments.UsageReporter)
+	if !ok {
+		return nil
+	}
+	subID, err := m.accounts.providerSubscriptionID(ctx, bill.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if subID == "" {
+		return nil
+	}
+
+	events := make([]payments.UsageEvent, 0, len(pricing.Meters))
+	for _, meter := range pricing.Meters {
+		events = append(events, payments.UsageEvent{
+			TransactionID:  fmt.Sprintf("%s-%s-%s", bill.OrganizationID, bill.PeriodStart.UTC().Format("2006-01-02"), meter),
+			SubscriptionID: subID,
+			Code:           string(meter),
+			Timestamp:      bill.PeriodEnd.Add(-time.Second),
+			Value:          bill.meter(meter).Used,
+		})
+	}
+	return reporter.ReportUsage(ctx, events)
+}
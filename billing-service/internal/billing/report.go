@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+// Report summarizes a billing run for finance review. The period is the day
+// whose run it is.
+type Report struct {
+	RunID       *uuid.UUID    `json:"run_id,omitempty"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	DryRun      bool          `json:"dry_run"`
+	Processed   int           `json:"processed"`
+	Failed      int           `json:"failed"`
+	Totals      []ReportTotal `json:"totals"`
+	Lines       []ReportLine  `json:"lines"`
+}
+
+// ReportLine is the outcome of billing an organization for a period of its
+// subscription, or a contract true-up for its term. Amounts are those invoiced, or that
+// would be in a dry run.
+type ReportLine struct {
+	OrganizationID uuid.UUID      `json:"organization_id"`
+	ContractID     *uuid.UUID     `json:"contract_id,omitempty"`
+	Kind           invoices.Kind  `json:"kind"`
+	PeriodStart    time.Time      `json:"period_start"`
+	PeriodEnd      time.Time      `json:"period_end"`
+	Status         runs.OrgStatus `json:"status"`
+	Currency       money.Currency `json:"currency,omitempty"`
+	SubtotalCents  int64          `json:"subtotal_cents"`
+	DiscountCents  int64          `json:"discount_cents"`
+	CreditsCents   int64          `json:"credits_cents"`
+	TaxCents       int64          `json:"tax_cents"`
+	TotalCents     int64          `json:"total_cents"`
+	InvoiceNumber  string         `json:"invoice_number,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// ReportTotal sums the invoiced lines in one currency
+type ReportTotal struct {
+	Currency      money.Currency `json:"currency"`
+	Invoices      int            `json:"invoices"`
+	SubtotalCents int64          `json:"subtotal_cents"`
+	DiscountCents int64          `json:"discount_cents"`
+	CreditsCents  int64          `json:"credits_cents"`
+	TaxCents      int64          `json:"tax_cents"`
+	TotalCents    int64          `json:"total_cents"`
+}
+
+// reportLine reports an invoice, or a bill that would be invoiced
+func reportLine(inv invoices.Invoice, status runs.OrgStatus) ReportLine {
+	return ReportLine{
+		OrganizationID: inv.OrganizationID,
+		ContractID:     inv.ContractID,
+		Kind:           inv.Kind,
+		PeriodStart:    inv.PeriodStart,
+		PeriodEnd:      inv.PeriodEnd,
+		Status:         status,
+		Currency:       inv.Currency,
+		SubtotalCents:  inv.SubtotalCents,
+		DiscountCents:  inv.DiscountCents,
+		CreditsCents:   inv.SubtotalCents - inv.DiscountCents + inv.TaxCents - inv.TotalCents,
+		TaxCents:       inv.TaxCents,
+		TotalCents:     inv.TotalCents,
+		InvoiceNumber:  inv.Number,
+	}
+}
+
+// Add records a line, counting invoiced lines and those a dry run would invoice
+// towards the totals of their currency
+func (r *Report) Add(l ReportLine) {
+	r.Lines = append(r.Lines, l)
+	if l.Status == runs.OrgFailed {
+		r.Failed++
+		return
+	}
+	r.Processed++
+	if l.Status != runs.OrgInvoiced && l.Status != runs.OrgDryRun {
+		return
+	}
+
+	i := 0
+	for i < len(r.Totals) && r.Totals[i].Currency != l.Currency {
+		i++
+	}
+	if i == len(r.Totals) {
+		r.Totals = append(r.Totals, ReportTotal{Currency: l.Currency})
+	}
+	t := &r.Totals[i]
+	t.Invoices++
+	t.SubtotalCents += l.SubtotalCents
+	t.DiscountCents += l.DiscountCents
+	t.CreditsCents += l.CreditsCents
+	t.TaxCents += l.TaxCents
+	t.TotalCents += l.TotalCents
+}
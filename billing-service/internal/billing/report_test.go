@@ -0,0 +1,35 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/credits"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+func TestReport(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	bill := mustBill(t, defaultPlan("essential"), money.USD, start, start.AddDate(0, 1, 0), pricing.Usage{})
+	bill.ApplyCredits([]credits.Debit{{AmountCents: 1000}})
+
+	report := &Report{}
+	report.Add(reportLine(bill.invoice(), runs.OrgInvoiced))
+	report.Add(reportLine(bill.invoice(), runs.OrgDryRun))
+	report.Add(ReportLine{Kind: invoices.Usage, Status: runs.OrgSkipped})
+	report.Add(ReportLine{Kind: invoices.Usage, Status: runs.OrgFailed, Error: "no customer"})
+
+	if report.Processed != 3 || report.Failed != 1 {
+		t.Errorf("Expected 3 processed and 1 failed, got %d and %d", report.Processed, report.Failed)
+	}
+	if len(report.Totals) != 1 {
+		t.Fatalf("Expected totals in one currency, got %+v", report.Totals)
+	}
+	total := report.Totals[0]
+	if total.Invoices != 2 || total.SubtotalCents != 20000 || total.CreditsCents != 2000 || total.TotalCents != 18000 {
+		t.Errorf("Expected 2 invoices of 10000 less 1000 credits, got %+v", total)
+	}
+}
@@ -0,0 +1,313 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+// RunOptions controls a monthly billing run
+type RunOptions struct {
+	// Resume continues the day's existing run, billing only the organizations it has
+	// not invoiced yet. Without it a day that already has a run is not billed again.
+	Resume bool
+	// DryRun calculates the bills without recording the run, burning credits or calling
+	// the payment provider
+	DryRun bool
+	// OrgID bills a single organization when set. It is billed as part of the day's
+	// run, which is started or resumed as needed but not finished.
+	OrgID uuid.UUID
+	// Date is the day, in UTC, whose run bills the subscription periods that ended by
+	// its end. Yesterday is billed when it is zero.
+	Date time.Time
+	// Concurrency is how many organizations are billed at once, 1 when unset
+	Concurrency int
+	// OrgTimeout bounds the billing of each organization; zero means no limit
+	OrgTimeout time.Duration
+	// Progress, when set, counts the organizations as they are billed
+	Progress *runs.Progress
+}
+
+// billingDay returns the day a run bills, which must have ended by now
+func billingDay(date, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	if date.IsZero() {
+		end := now.Truncate(24 * time.Hour)
+		return end.AddDate(0, 0, -1), end, nil
+	}
+	date = date.UTC()
+	if !date.Equal(date.Truncate(24 * time.Hour)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("day must start at midnight UTC, got %s", date.Format(time.RFC3339))
+	}
+	end := date.AddDate(0, 0, 1)
+	if end.After(now) {
+		return time.Time{}, time.Time{}, fmt.Errorf("day %s has not ended yet", date.Format("2006-01-02"))
+	}
+	return date, end, nil
+}
+
+// dueSubscription is an organization's subscription with periods to bill, or the
+// reason they could not be worked out
+type dueSubscription struct {
+	orgID   uuid.UUID
+	periods []runs.Period
+	err     error
+}
+
+// dueSubscriptions returns the subscriptions with periods that ended by cutoff and have
+// not been billed, only that of one organization when orgID is set: active paid ones,
+// and those whose plan changed since they were last billed, so an organization that
+// moved to the free plan is still billed for the paid part of its last period. Periods
+// follow each subscription's billing anchor in the organization's time zone.
+func (m *UsageMeter) dueSubscriptions(ctx context.Context, orgID uuid.UUID, cutoff time.Time) ([]dueSubscription, error) {
+	subs, err := m.accounts.dueSubscriptions(ctx, orgID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	var due []dueSubscription
+	for _, s := range subs {
+		sub := dueSubscription{orgID: s.orgID}
+		loc, err := time.LoadLocation(s.timezone)
+		if err != nil {
+			sub.err = fmt.Errorf("invalid billing timezone %q: %w", s.timezone, err)
+			due = append(due, sub)
+			continue
+		}
+		var from time.Time
+		if s.billedThrough != nil {
+			from = *s.billedThrough
+		}
+		sub.periods = runs.Schedule{Anchor: s.anchor, Location: loc}.Due(from, cutoff)
+		if len(sub.periods) > 0 {
+			due = append(due, sub)
+		}
+	}
+	if orgID != uuid.Nil && len(subs) == 0 {
+		return nil, fmt.Errorf("organization %s has no active paid subscription or unbilled plan change", orgID)
+	}
+	return due, nil
+}
+
+// RunMonthlyBilling bills the subscription periods that ended by the end of a day and
+// trues up the contracts whose term ended by then and is billed in full. Each
+// subscription is billed for its own periods, which follow its billing anchor. The run
+// and the outcome for every organization are recorded, and an error is returned with
+// the report if any of them failed, so the run can be resumed. Organizations are billed
+// concurrently, and only one process bills at a time.
+func (m *UsageMeter) RunMonthlyBilling(ctx context.Context, opts RunOptions) (*Report, error) {
+	day, cutoff, err := billingDay(opts.Date, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{PeriodStart: day, PeriodEnd: cutoff, DryRun: opts.DryRun, Totals: []ReportTotal{}}
+
+	// A dry run records nothing, but skips what the day's run already billed
+	var run runs.Run
+	if opts.DryRun {
+		run, err = m.runs.ForPeriod(ctx, day)
+		if err != nil && !errors.Is(err, runs.ErrNotFound) {
+			return nil, err
+		}
+	} else {
+		release, err := m.runs.Lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		run, err = m.runs.Start(ctx, day, cutoff, opts.Resume || opts.OrgID != uuid.Nil)
+		if err != nil {
+			return nil, err
+		}
+		report.RunID = &run.ID
+	}
+	states, err := m.runs.Orgs(ctx, run.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscriptions are read under the lock, so no other run is billing them
+	due, err := m.dueSubscriptions(ctx, opts.OrgID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	// Every organization is billed on the catalog as it was when the run started
+	versions, err := m.planVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		log.Printf("Dry run of monthly billing for periods ended by %s", cutoff.Format(time.RFC3339))
+	} else {
+		log.Printf("Running monthly billing %s (attempt %d) for periods ended by %s", run.ID, run.Attempts, cutoff.Format(time.RFC3339))
+	}
+
+	// Organizations an earlier attempt billed are no longer due, but still reported
+	subs := make(map[uuid.UUID]dueSubscription, len(due))
+	orgIDs := make([]uuid.UUID, 0, len(due))
+	for _, sub := range due {
+		subs[sub.orgID] = sub
+		orgIDs = append(orgIDs, sub.orgID)
+	}
+	for orgID, state := range states {
+		if _, ok := subs[orgID]; !ok && state.Done() && (opts.OrgID == uuid.Nil || orgID == opts.OrgID) {
+			subs[orgID] = dueSubscription{orgID: orgID}
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	opts.Progress.SetTotal(len(orgIDs))
+	pool := runs.Pool{Concurrency: opts.Concurrency, Timeout: opts.OrgTimeout}
+	results := runs.Each(ctx, pool, orgIDs, func(orgCtx context.Context, orgID uuid.UUID) []ReportLine {
+		lines := m.runOrganization(ctx, orgCtx, run.ID, states[orgID], subs[orgID], versions, opts.DryRun)
+		opts.Progress.Record(lines[len(lines)-1].Status)
+		return lines
+	})
+	progress := make(map[uuid.UUID]orgProgress, len(results))
+	for _, lines := range results {
+		for _, line := range lines {
+			report.Add(line)
+			progress[line.OrganizationID] = progress[line.OrganizationID].add(line)
+		}
+	}
+
+	// Contracts whose term ended by the end of the day owe any unused commitment
+	for _, line := range m.runContractTrueUps(ctx, cutoff, opts, progress) {
+		report.Add(line)
+	}
+
+	if !opts.DryRun && opts.OrgID == uuid.Nil {
+		// Record the outcome even when the run ran out of time
+		if err := m.runs.Finish(context.WithoutCancel(ctx), run.ID, report.Processed, report.Failed); err != nil {
+			log.Printf("Failed to finish billing run %s: %v", run.ID, err)
+		}
+	}
+	log.Printf("Monthly billing complete: %d processed, %d failed", report.Processed, report.Failed)
+	if report.Failed > 0 {
+		return report, fmt.Errorf("billing run %s failed for %d of %d organizations and contracts; resume it to retry them",
+			run.ID, report.Failed, report.Processed+report.Failed)
+	}
+	return report, nil
+}
+
+// runOrganization bills the due periods of an organization's subscription as part of
+// a run, oldest first, unless a previous attempt did, and records the outcome. A period
+// that fails stops the later ones, so the subscription is always billed through the end
+// of the last period invoiced. Billing is bounded by orgCtx while the outcome is
+// recorded under the run's ctx, so an organization that runs out of time is recorded
+// as failed. It returns a line per period billed or attempted.
+func (m *UsageMeter) runOrganization(ctx, orgCtx context.Context, runID uuid.UUID, state runs.Org, sub dueSubscription,
+	versions []plans.Plan, dryRun bool) []ReportLine {
+	if state.Done() {
+		return []ReportLine{m.billedReportLine(orgCtx, state)}
+	}
+	state.RunID, state.OrganizationID = runID, sub.orgID
+
+	var lines []ReportLine
+	err := sub.err
+	for i := 0; err == nil && i < len(sub.periods); i++ {
+		var line ReportLine
+		line, err = m.billOrganization(orgCtx, &state, sub.periods[i], versions, dryRun)
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, ReportLine{OrganizationID: sub.orgID, Kind: invoices.Usage})
+	}
+	if err != nil {
+		log.Printf("Failed to bill org %s: %v", sub.orgID, err)
+		state.Status, state.Error = runs.OrgFailed, err.Error()
+	} else {
+		state.Error = ""
+	}
+	if !dryRun {
+		if err := m.runs.SetOrg(ctx, state); err != nil {
+			log.Printf("Failed to record billing of org %s: %v", sub.orgID, err)
+			state.Status, state.Error = runs.OrgFailed, err.Error()
+		}
+	}
+	last := &lines[len(lines)-1]
+	last.Status, last.Error = state.Status, state.Error
+	return lines
+}
+
+// billedReportLine reports an organization a previous attempt of the run billed
+func (m *UsageMeter) billedReportLine(ctx context.Context, state runs.Org) ReportLine {
+	if state.InvoiceID != nil {
+		if inv, err := m.invoices.Get(ctx, *state.InvoiceID); err == nil {
+			return reportLine(inv, state.Status)
+		}
+	}
+	return ReportLine{OrganizationID: state.OrganizationID, Kind: invoices.Usage, Status: state.Status}
+}
+
+// billOrganization bills an organization for a period of its subscription, recording
+// in state how far it got, and then that the subscription is billed through the end of
+// the period. An organization that already has an invoice for the period is not billed
+// again, and one whose provider invoice was created but not stored only has it stored.
+// A dry run calculates the bill without burning credits or invoicing it.
+func (m *UsageMeter) billOrganization(ctx context.Context, state *runs.Org, period runs.Period, versions []plans.Plan,
+	dryRun bool) (ReportLine, error) {
+	line := ReportLine{OrganizationID: state.OrganizationID, Kind: invoices.Usage, PeriodStart: period.Start, PeriodEnd: period.End}
+	// A provider invoice recorded by an earlier attempt belongs to the period it billed
+	if state.PeriodStart == nil || !state.PeriodStart.Equal(period.Start) {
+		state.PeriodStart, state.ProviderInvoiceID, state.InvoiceID = &period.Start, "", nil
+	}
+
+	existing, err := m.invoices.ForPeriod(ctx, state.OrganizationID, invoices.Usage, period.Start)
+	if err == nil {
+		state.Status, state.InvoiceID, state.ProviderInvoiceID = runs.OrgInvoiced, &existing.ID, existing.ProviderInvoiceID
+		if !dryRun {
+			err = m.accounts.markBilled(ctx, state.OrganizationID, period.End)
+		}
+		return reportLine(existing, state.Status), err
+	}
+	if !errors.Is(err, invoices.ErrNotFound) {
+		return line, err
+	}
+
+	bill, err := m.calculateMonthlyBill(ctx, versions, state.OrganizationID, period.Start, period.End, dryRun)
+	if err != nil {
+		return line, fmt.Errorf("failed to calculate bill: %w", err)
+	}
+
+	if dryRun {
+		state.Status = runs.OrgDryRun
+		if bill.SubtotalCents <= 0 {
+			state.Status = runs.OrgSkipped
+		}
+		return reportLine(bill.invoice(), state.Status), nil
+	}
+
+	if err := m.reportUsage(ctx, bill); err != nil {
+		log.Printf("Failed to report usage for org %s: %v", state.OrganizationID, err)
+	}
+
+	// Invoice bills paid entirely by credits too, so the credits show up
+	if bill.SubtotalCents <= 0 {
+		state.Status = runs.OrgSkipped
+		return reportLine(bill.invoice(), state.Status), m.accounts.markBilled(ctx, state.OrganizationID, period.End)
+	}
+
+	var inv invoices.Invoice
+	if state.ProviderInvoiceID != "" {
+		inv = bill.invoice()
+		inv.ProviderInvoiceID = state.ProviderInvoiceID
+		inv, err = m.storeInvoice(ctx, inv)
+	} else {
+		inv, err = m.GenerateInvoice(ctx, bill, runs.IdempotencyKey(state.OrganizationID, period.Start))
+	}
+	state.ProviderInvoiceID = inv.ProviderInvoiceID
+	if err != nil {
+		return reportLine(inv, runs.OrgFailed), err
+	}
+	state.Status, state.InvoiceID = runs.OrgInvoiced, &inv.ID
+	return reportLine(inv, state.Status), m.accounts.markBilled(ctx, state.OrganizationID, period.End)
+}
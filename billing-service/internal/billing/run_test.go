@@ -0,0 +1,126 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/tax"
+)
+
+func TestBillingDay(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		date     time.Time
+		expected string
+		invalid  bool
+	}{
+		{"yesterday by default", time.Time{}, "2026-10-18", false},
+		{"earlier day", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), "2026-03-31", false},
+		{"today has not ended", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "", true},
+		{"not midnight UTC", time.Date(2026, 9, 2, 0, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start, end, err := billingDay(c.date, now)
+			if c.invalid {
+				if err == nil {
+					t.Errorf("Expected an invalid day, got %s", start)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected a day, got %v", err)
+			}
+			if start.Format("2006-01-02") != c.expected || !end.Equal(start.AddDate(0, 0, 1)) {
+				t.Errorf("Expected %s, got %s to %s", c.expected, start, end)
+			}
+		})
+	}
+}
+
+func TestRunMonthlyBillingBillsTenThousandOrgs(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	provider := payments.NewFake("whsec_test")
+	billingRuns := newFakeRuns()
+	catalog := &fakeCatalog{}
+	meter := &UsageMeter{accounts: store, catalog: catalog, invoices: store, runs: billingRuns, payments: provider,
+		taxEngine: tax.NewLocal("US"), rounding: money.PerLine}
+
+	// Essential subscriptions billed through September 1st, every tenth with an extra
+	// million actions, and ten without a customer at the provider
+	anchor := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	var missing []uuid.UUID
+	for i := 0; i < 10000; i++ {
+		orgID := uuid.New()
+		billedThrough := anchor
+		store.subs[orgID] = subscription{orgID: orgID, plan: "essential", currency: money.USD, timezone: "UTC",
+			anchor: anchor, billedThrough: &billedThrough}
+		if i%10 == 0 {
+			store.usages[orgID] = pricing.Usage{Actions: 2000000}
+		}
+		if i%1000 == 999 {
+			missing = append(missing, orgID)
+			continue
+		}
+		customer, err := provider.CreateCustomer(ctx, payments.CustomerParams{Name: orgID.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.customers[orgID] = customer.ID
+	}
+
+	opts := RunOptions{Date: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Concurrency: 100, OrgTimeout: time.Second}
+	report, err := meter.RunMonthlyBilling(ctx, opts)
+	if err == nil {
+		t.Fatal("Expected the run to fail for the organizations without a customer")
+	}
+	if report.Processed != 9990 || report.Failed != 10 {
+		t.Errorf("Expected 9990 processed and 10 failed, got %d and %d", report.Processed, report.Failed)
+	}
+	if n := catalog.lists.Load(); n != 1 {
+		t.Errorf("Expected the plan catalog to be read once per run, got %d reads", n)
+	}
+
+	// Resuming bills the organizations that failed, and only those
+	for _, orgID := range missing {
+		customer, _ := provider.CreateCustomer(ctx, payments.CustomerParams{Name: orgID.String()})
+		store.customers[orgID] = customer.ID
+	}
+	opts.Resume = true
+	report, err = meter.RunMonthlyBilling(ctx, opts)
+	if err != nil {
+		t.Fatalf("Expected the resumed run to succeed, got %v", err)
+	}
+	if report.Processed != 10000 || report.Failed != 0 {
+		t.Errorf("Expected 10000 processed and none failed, got %d and %d", report.Processed, report.Failed)
+	}
+	if len(report.Totals) != 1 || report.Totals[0].Invoices != 10000 || report.Totals[0].TotalCents != 10000*10000+1000*2500 {
+		t.Errorf("Expected 10000 invoices for $1,025,000, got %+v", report.Totals)
+	}
+
+	if n := len(provider.Events()); n != 10000 {
+		t.Errorf("Expected 10000 invoices at the provider, got %d", n)
+	}
+	if n := len(store.invoices); n != 10000 {
+		t.Errorf("Expected 10000 invoices stored, got %d", n)
+	}
+	periodEnd := anchor.AddDate(0, 1, 0)
+	for orgID, sub := range store.subs {
+		if !sub.billedThrough.Equal(periodEnd) {
+			t.Fatalf("Expected org %s to be billed through %s, got %s", orgID, periodEnd, sub.billedThrough)
+		}
+	}
+	for _, o := range billingRuns.orgs[*report.RunID] {
+		if o.Status != runs.OrgInvoiced {
+			t.Fatalf("Expected every organization invoiced, got %s for %s", o.Status, o.OrganizationID)
+		}
+	}
+}
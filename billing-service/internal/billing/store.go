@@ -0,0 +1,183 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+// planCatalog lists the versions of the plans a meter bills on
+type planCatalog interface {
+	List(ctx context.Context) ([]plans.Plan, error)
+}
+
+// runStore records billing runs and how far they got with each organization
+type runStore interface {
+	Lock(ctx context.Context) (release func(), err error)
+	Start(ctx context.Context, periodStart, periodEnd time.Time, resume bool) (runs.Run, error)
+	ForPeriod(ctx context.Context, periodStart time.Time) (runs.Run, error)
+	Orgs(ctx context.Context, runID uuid.UUID) (map[uuid.UUID]runs.Org, error)
+	SetOrg(ctx context.Context, o runs.Org) error
+	Finish(ctx context.Context, runID uuid.UUID, processed, failed int) error
+}
+
+// invoiceStore stores the invoices a meter issues
+type invoiceStore interface {
+	Create(ctx context.Context, inv invoices.Invoice) (invoices.Invoice, error)
+	CreateTx(ctx context.Context, tx pgx.Tx, inv invoices.Invoice) (invoices.Invoice, error)
+	Get(ctx context.Context, id uuid.UUID) (invoices.Invoice, error)
+	ForPeriod(ctx context.Context, orgID uuid.UUID, kind invoices.Kind, periodStart time.Time) (invoices.Invoice, error)
+	ForContract(ctx context.Context, contractID uuid.UUID, kind invoices.Kind) (invoices.Invoice, error)
+}
+
+// accountStore reads the subscriptions, customers and usage a meter bills, and records
+// how far each subscription is billed
+type accountStore interface {
+	dueSubscriptions(ctx context.Context, orgID uuid.UUID, cutoff time.Time) ([]subscription, error)
+	subscription(ctx context.Context, orgID uuid.UUID) (subscription, error)
+	usage(ctx context.Context, orgID uuid.UUID, from, to time.Time) (pricing.Usage, error)
+	customerID(ctx context.Context, orgID uuid.UUID) (string, error)
+	providerSubscriptionID(ctx context.Context, orgID uuid.UUID) (string, error)
+	markBilled(ctx context.Context, orgID uuid.UUID, through time.Time) error
+	billedThrough(ctx context.Context, orgID uuid.UUID) (time.Time, error)
+}
+
+// subscription is an organization's subscription as billed
+type subscription struct {
+	orgID         uuid.UUID
+	plan          string
+	currency      money.Currency
+	timezone      string
+	anchor        time.Time
+	billedThrough *time.Time
+	trialEndsAt   *time.Time
+}
+
+// accounts is the accountStore in Postgres
+type accounts struct {
+	db *pgxpool.Pool
+}
+
+// dueSubscriptions returns the active paid subscriptions and those with a plan change
+// not billed yet, ordered by organization
+func (a accounts) dueSubscriptions(ctx context.Context, orgID uuid.UUID, cutoff time.Time) ([]subscription, error) {
+	query := `SELECT o.id, o.billing_timezone, COALESCE(s.billing_anchor, s.current_period_start, s.created_at), s.billed_through
+		 FROM organizations o
+		 JOIN subscriptions s ON s.organization_id = o.id
+		 WHERE ((s.status = 'active' AND s.plan != 'free')
+		        OR EXISTS (SELECT 1 FROM subscription_changes c
+		                   WHERE c.organization_id = o.id AND c.status = 'applied' AND c.effective_at < $1
+		                     AND (s.billed_through IS NULL OR c.effective_at >= s.billed_through)))`
+	args := []any{cutoff}
+	if orgID != uuid.Nil {
+		query += ` AND o.id = $2`
+		args = append(args, orgID)
+	}
+	rows, err := a.db.Query(ctx, query+` ORDER BY o.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []subscription
+	for rows.Next() {
+		var sub subscription
+		if err := rows.Scan(&sub.orgID, &sub.timezone, &sub.anchor, &sub.billedThrough); err != nil {
+			return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// subscription returns an organization's plan, billing currency and trial
+func (a accounts) subscription(ctx context.Context, orgID uuid.UUID) (subscription, error) {
+	sub := subscription{orgID: orgID}
+	err := a.db.QueryRow(ctx,
+		`SELECT s.plan, o.billing_currency, s.trial_ends_at
+		 FROM subscriptions s JOIN organizations o ON o.id = s.organization_id
+		 WHERE s.organization_id = $1`, orgID).Scan(&sub.plan, &sub.currency, &sub.trialEndsAt)
+	if err != nil {
+		return sub, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// usage sums an organization's usage in the hours that start from from until to
+func (a accounts) usage(ctx context.Context, orgID uuid.UUID, from, to time.Time) (pricing.Usage, error) {
+	var u pricing.Usage
+	err := a.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(total_actions), 0),
+		        COALESCE(SUM(total_replicated_actions), 0),
+		        COALESCE(SUM(active_storage_gbh), 0),
+		        COALESCE(SUM(retained_storage_gbh), 0),
+		        COALESCE(SUM(archived_storage_gbh), 0)
+		 FROM usage_aggregates
+		 WHERE organization_id = $1 AND period_start >= $2 AND period_start < $3`,
+		orgID, from, to).
+		Scan(&u.Actions, &u.ReplicatedActions, &u.ActiveStorageGBH, &u.RetainedStorageGBH, &u.ArchivedStorageGBH)
+	if err != nil {
+		return u, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return u, nil
+}
+
+// customerID returns an organization's customer ID at the payment provider
+func (a accounts) customerID(ctx context.Context, orgID uuid.UUID) (string, error) {
+	var id string
+	err := a.db.QueryRow(ctx, `SELECT stripe_customer_id FROM organizations WHERE id = $1`, orgID).Scan(&id)
+	return id, err
+}
+
+// providerSubscriptionID returns the ID of an organization's subscription at the
+// payment provider, empty when it has none
+func (a accounts) providerSubscriptionID(ctx context.Context, orgID uuid.UUID) (string, error) {
+	var id string
+	err := a.db.QueryRow(ctx,
+		`SELECT COALESCE(stripe_subscription_id, '') FROM subscriptions WHERE organization_id = $1`, orgID).
+		Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return id, nil
+}
+
+// markBilled records that an organization's subscription is billed through a time,
+// unless it already is further
+func (a accounts) markBilled(ctx context.Context, orgID uuid.UUID, through time.Time) error {
+	_, err := a.db.Exec(ctx,
+		`UPDATE subscriptions SET billed_through = $2, updated_at = NOW()
+		 WHERE organization_id = $1 AND (billed_through IS NULL OR billed_through < $2)`,
+		orgID, through)
+	if err != nil {
+		return fmt.Errorf("failed to record subscription billed through %s: %w", through.Format(time.RFC3339), err)
+	}
+	return nil
+}
+
+// billedThrough returns the end of the last period invoiced on an organization's
+// subscription, zero when it has none
+func (a accounts) billedThrough(ctx context.Context, orgID uuid.UUID) (time.Time, error) {
+	var billedThrough *time.Time
+	err := a.db.QueryRow(ctx, `SELECT billed_through FROM subscriptions WHERE organization_id = $1`, orgID).Scan(&billedThrough)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if billedThrough == nil {
+		return time.Time{}, nil
+	}
+	return *billedThrough, nil
+}
@@ -0,0 +1,236 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+// fakeCatalog serves the default plans and counts how often they are listed
+type fakeCatalog struct {
+	lists atomic.Int64
+}
+
+func (c *fakeCatalog) List(ctx context.Context) ([]plans.Plan, error) {
+	c.lists.Add(1)
+	return plans.Defaults(), nil
+}
+
+// fakeRuns keeps billing runs in memory
+type fakeRuns struct {
+	mu   sync.Mutex
+	runs map[int64]runs.Run
+	orgs map[uuid.UUID]map[uuid.UUID]runs.Org
+}
+
+func newFakeRuns() *fakeRuns {
+	return &fakeRuns{runs: map[int64]runs.Run{}, orgs: map[uuid.UUID]map[uuid.UUID]runs.Org{}}
+}
+
+// fakeStore keeps the invoices and subscriptions of a meter in memory
+type fakeStore struct {
+	mu        sync.Mutex
+	subs      map[uuid.UUID]subscription
+	usages    map[uuid.UUID]pricing.Usage
+	customers map[uuid.UUID]string
+	invoices  map[uuid.UUID]invoices.Invoice
+	periods   map[string]uuid.UUID
+	numbers   map[uuid.UUID]int64
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		subs:      map[uuid.UUID]subscription{},
+		usages:    map[uuid.UUID]pricing.Usage{},
+		customers: map[uuid.UUID]string{},
+		invoices:  map[uuid.UUID]invoices.Invoice{},
+		periods:   map[string]uuid.UUID{},
+		numbers:   map[uuid.UUID]int64{},
+	}
+}
+
+func periodKey(orgID uuid.UUID, kind invoices.Kind, periodStart time.Time) string {
+	return fmt.Sprintf("%s/%s/%d", orgID, kind, periodStart.Unix())
+}
+
+func (r *fakeRuns) Lock(ctx context.Context) (func(), error) {
+	return func() {}, nil
+}
+
+func (r *fakeRuns) Start(ctx context.Context, periodStart, periodEnd time.Time, resume bool) (runs.Run, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[periodStart.Unix()]
+	switch {
+	case !ok:
+		run = runs.Run{ID: uuid.New(), PeriodStart: periodStart, PeriodEnd: periodEnd, StartedAt: time.Now()}
+	case !resume:
+		return runs.Run{}, runs.ErrRunExists
+	}
+	run.Status = runs.Running
+	run.Attempts++
+	r.runs[periodStart.Unix()] = run
+	return run, nil
+}
+
+func (r *fakeRuns) ForPeriod(ctx context.Context, periodStart time.Time) (runs.Run, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[periodStart.Unix()]
+	if !ok {
+		return runs.Run{}, runs.ErrNotFound
+	}
+	return run, nil
+}
+
+func (r *fakeRuns) Orgs(ctx context.Context, runID uuid.UUID) (map[uuid.UUID]runs.Org, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	orgs := map[uuid.UUID]runs.Org{}
+	for id, o := range r.orgs[runID] {
+		orgs[id] = o
+	}
+	return orgs, nil
+}
+
+func (r *fakeRuns) SetOrg(ctx context.Context, o runs.Org) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.orgs[o.RunID] == nil {
+		r.orgs[o.RunID] = map[uuid.UUID]runs.Org{}
+	}
+	o.Attempts = r.orgs[o.RunID][o.OrganizationID].Attempts + 1
+	r.orgs[o.RunID][o.OrganizationID] = o
+	return nil
+}
+
+func (r *fakeRuns) Finish(ctx context.Context, runID uuid.UUID, processed, failed int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, run := range r.runs {
+		if run.ID == runID {
+			run.Status, run.Processed, run.Failed = runs.Completed, processed, failed
+			if failed > 0 {
+				run.Status = runs.Failed
+			}
+			r.runs[key] = run
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) Create(ctx context.Context, inv invoices.Invoice) (invoices.Invoice, error) {
+	if err := inv.Validate(); err != nil {
+		return invoices.Invoice{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := periodKey(inv.OrganizationID, inv.Kind, inv.PeriodStart)
+	if _, ok := s.periods[key]; ok {
+		return invoices.Invoice{}, fmt.Errorf("invoice for %s already stored", key)
+	}
+	s.numbers[inv.OrganizationID]++
+	inv.ID, inv.CreatedAt = uuid.New(), time.Now()
+	inv.Number = invoices.FormatNumber(inv.OrganizationID, s.numbers[inv.OrganizationID])
+	s.invoices[inv.ID] = inv
+	s.periods[key] = inv.ID
+	return inv, nil
+}
+
+func (s *fakeStore) CreateTx(ctx context.Context, tx pgx.Tx, inv invoices.Invoice) (invoices.Invoice, error) {
+	return invoices.Invoice{}, errors.New("fake store has no transactions")
+}
+
+func (s *fakeStore) Get(ctx context.Context, id uuid.UUID) (invoices.Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv, ok := s.invoices[id]
+	if !ok {
+		return invoices.Invoice{}, invoices.ErrNotFound
+	}
+	return inv, nil
+}
+
+func (s *fakeStore) ForPeriod(ctx context.Context, orgID uuid.UUID, kind invoices.Kind, periodStart time.Time) (invoices.Invoice, error) {
+	s.mu.Lock()
+	id, ok := s.periods[periodKey(orgID, kind, periodStart)]
+	s.mu.Unlock()
+	if !ok {
+		return invoices.Invoice{}, invoices.ErrNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *fakeStore) ForContract(ctx context.Context, contractID uuid.UUID, kind invoices.Kind) (invoices.Invoice, error) {
+	return invoices.Invoice{}, invoices.ErrNotFound
+}
+
+func (s *fakeStore) dueSubscriptions(ctx context.Context, orgID uuid.UUID, cutoff time.Time) ([]subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var subs []subscription
+	for id, sub := range s.subs {
+		if sub.plan != "free" && (orgID == uuid.Nil || id == orgID) {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].orgID.String() < subs[j].orgID.String() })
+	return subs, nil
+}
+
+func (s *fakeStore) subscription(ctx context.Context, orgID uuid.UUID) (subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[orgID]
+	if !ok {
+		return sub, fmt.Errorf("failed to get subscription: %w", pgx.ErrNoRows)
+	}
+	return sub, nil
+}
+
+func (s *fakeStore) usage(ctx context.Context, orgID uuid.UUID, from, to time.Time) (pricing.Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usages[orgID], nil
+}
+
+func (s *fakeStore) customerID(ctx context.Context, orgID uuid.UUID) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.customers[orgID], nil
+}
+
+func (s *fakeStore) providerSubscriptionID(ctx context.Context, orgID uuid.UUID) (string, error) {
+	return "", nil
+}
+
+func (s *fakeStore) markBilled(ctx context.Context, orgID uuid.UUID, through time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.subs[orgID]
+	if sub.billedThrough == nil || sub.billedThrough.Before(through) {
+		sub.billedThrough = &through
+		s.subs[orgID] = sub
+	}
+	return nil
+}
+
+func (s *fakeStore) billedThrough(ctx context.Context, orgID uuid.UUID) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b := s.subs[orgID].billedThrough; b != nil {
+		return *b, nil
+	}
+	return time.Time{}, nil
+}
@@ -0,0 +1,203 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+// orgProgress is how far a run got billing an organization
+type orgProgress struct {
+	billedThrough time.Time
+	failed        bool
+}
+
+// add records a line the run reported for the organization
+func (p orgProgress) add(l ReportLine) orgProgress {
+	if l.Status == runs.OrgFailed {
+		p.failed = true
+	} else if l.PeriodEnd.After(p.billedThrough) {
+		p.billedThrough = l.PeriodEnd
+	}
+	return p
+}
+
+// termBilled reports whether every period of a contract's term has been billed, so its
+// spend is final: the subscription is billed through the end of the term, counting the
+// periods billed in this run, and the run did not fail for the organization. A period
+// that straddles the end of the term is billed on the contract, so the true-up waits
+// for it.
+func (p orgProgress) termBilled(c contracts.Contract, billedThrough time.Time) bool {
+	if p.failed {
+		return false
+	}
+	if p.billedThrough.After(billedThrough) {
+		billedThrough = p.billedThrough
+	}
+	return !billedThrough.Before(c.EndsAt)
+}
+
+// runContractTrueUps invoices the shortfall of every contract whose term ended by asOf
+// and has been billed in full, only for one organization when opts.OrgID is set.
+// Contracts whose last periods are not billed yet are left for a later run. A dry run
+// reports the shortfalls without invoicing them.
+func (m *UsageMeter) runContractTrueUps(ctx context.Context, asOf time.Time, opts RunOptions, progress map[uuid.UUID]orgProgress) []ReportLine {
+	due, err := m.contracts.DueForTrueUp(ctx, asOf)
+	if err != nil {
+		log.Printf("Failed to get contracts due for true-up: %v", err)
+		return []ReportLine{{OrganizationID: opts.OrgID, Kind: invoices.TrueUp, Status: runs.OrgFailed,
+			Error: fmt.Sprintf("failed to get contracts due for true-up: %v", err)}}
+	}
+
+	var lines []ReportLine
+	for _, c := range due {
+		if opts.OrgID != uuid.Nil && c.OrganizationID != opts.OrgID {
+			continue
+		}
+		line := ReportLine{OrganizationID: c.OrganizationID, ContractID: &c.ID, Kind: invoices.TrueUp,
+			Status: runs.OrgFailed, Currency: c.Currency}
+
+		billedThrough, err := m.accounts.billedThrough(ctx, c.OrganizationID)
+		if err != nil {
+			log.Printf("Failed to get billing of contract %s: %v", c.ID, err)
+			line.Error = err.Error()
+			lines = append(lines, line)
+			continue
+		}
+		if !progress[c.OrganizationID].termBilled(c, billedThrough) {
+			log.Printf("Contract %s true-up waits for org %s to be billed through %s", c.ID, c.OrganizationID, c.EndsAt.Format(time.RFC3339))
+			continue
+		}
+
+		spend, err := m.contracts.Spend(ctx, c.ID)
+		if err != nil {
+			log.Printf("Failed to get spend for contract %s: %v", c.ID, err)
+			line.Error = err.Error()
+			lines = append(lines, line)
+			continue
+		}
+
+		shortfall := c.Shortfall(spend)
+		switch {
+		case opts.DryRun && shortfall <= 0:
+			line.Status = runs.OrgSkipped
+		case opts.DryRun:
+			line = reportLine(trueUpInvoice(c, spend, shortfall), runs.OrgDryRun)
+		case shortfall <= 0:
+			line.Status = runs.OrgSkipped
+			if err := m.contracts.MarkTrueUp(ctx, m.db, c.ID, shortfall, time.Now()); err != nil {
+				log.Printf("Failed to record true-up for contract %s: %v", c.ID, err)
+				line.Status, line.Error = runs.OrgFailed, err.Error()
+			}
+		default:
+			inv, err := m.GenerateTrueUpInvoice(ctx, c, spend, shortfall)
+			if err != nil {
+				log.Printf("Failed to generate true-up invoice for contract %s: %v", c.ID, err)
+				line.Error = err.Error()
+				break
+			}
+			line = reportLine(inv, runs.OrgInvoiced)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// trueUpInvoice returns the invoice for the unused commitment of a contract term
+func trueUpInvoice(c contracts.Contract, spend, shortfall int64) invoices.Invoice {
+	line := invoices.Line{
+		Kind: invoices.LineTrueUp,
+		Description: fmt.Sprintf("Committed spend true-up %s - %s (%s committed, %s used)",
+			c.StartsAt.Format("January 2006"), c.EndsAt.AddDate(0, 0, -1).Format("January 2006"),
+			money.FromMinor(c.Currency, c.CommitCents), money.FromMinor(c.Currency, spend)),
+		Quantity:    decimal.NewFromInt(1),
+		AmountCents: shortfall,
+	}
+	return invoices.Invoice{
+		OrganizationID: c.OrganizationID,
+		Kind:           invoices.TrueUp,
+		PeriodStart:    c.StartsAt,
+		PeriodEnd:      c.EndsAt,
+		Currency:       c.Currency,
+		SubtotalCents:  shortfall,
+		TotalCents:     shortfall,
+		ContractID:     &c.ID,
+		Lines:          []invoices.Line{line},
+	}
+}
+
+// GenerateTrueUpInvoice invoices the unused commitment of a contract term, and records
+// the contract as trued up in the transaction that stores the invoice. A contract that
+// already has a true-up invoice, stored by a run that failed to record it, is only
+// recorded as trued up, so it is never invoiced twice.
+func (m *UsageMeter) GenerateTrueUpInvoice(ctx context.Context, c contracts.Contract, spend, shortfall int64) (invoices.Invoice, error) {
+	existing, err := m.invoices.ForContract(ctx, c.ID, invoices.TrueUp)
+	if err == nil {
+		if err := m.contracts.MarkTrueUp(ctx, m.db, c.ID, existing.SubtotalCents, existing.CreatedAt); err != nil {
+			return existing, fmt.Errorf("failed to record true-up: %w", err)
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, invoices.ErrNotFound) {
+		return invoices.Invoice{}, err
+	}
+
+	invoice := trueUpInvoice(c, spend, shortfall)
+	stripeCustomerID, err := m.accounts.customerID(ctx, c.OrganizationID)
+	if err != nil {
+		return invoice, fmt.Errorf("failed to get %s customer: %w", m.payments.Name(), err)
+	}
+	if stripeCustomerID == "" {
+		return invoice, fmt.Errorf("organization has no %s customer ID", m.payments.Name())
+	}
+
+	inv, err := m.payments.CreateInvoice(ctx, payments.InvoiceParams{
+		CustomerID:     stripeCustomerID,
+		Currency:       c.Currency,
+		Items:          invoiceItems(invoice),
+		IdempotencyKey: runs.TrueUpIdempotencyKey(c.ID),
+	})
+	if err != nil {
+		return invoice, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	invoice.ProviderInvoiceID = inv.ID
+	invoice.Status = "pending"
+	stored, err := m.storeTrueUp(ctx, c, invoice)
+	if err != nil {
+		return invoice, fmt.Errorf("%s invoice %s was created but not stored: %w", m.payments.Name(), invoice.ProviderInvoiceID, err)
+	}
+	return stored, nil
+}
+
+// storeTrueUp stores a contract's true-up invoice and records the contract as trued
+// up, both or neither
+func (m *UsageMeter) storeTrueUp(ctx context.Context, c contracts.Contract, invoice invoices.Invoice) (invoices.Invoice, error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return invoices.Invoice{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	stored, err := m.invoices.CreateTx(ctx, tx, invoice)
+	if err != nil {
+		return invoices.Invoice{}, err
+	}
+	if err := m.contracts.MarkTrueUp(ctx, tx, c.ID, stored.SubtotalCents, stored.CreatedAt); err != nil {
+		return invoices.Invoice{}, fmt.Errorf("failed to record true-up: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return invoices.Invoice{}, err
+	}
+	return stored, nil
+}
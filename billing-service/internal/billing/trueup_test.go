@@ -0,0 +1,66 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/runs"
+)
+
+func TestTrueUpWaitsForPeriodStraddlingTermEnd(t *testing.T) {
+	orgID := uuid.New()
+	endsAt := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	c := contracts.Contract{OrganizationID: orgID, StartsAt: endsAt.AddDate(-1, 0, 0), EndsAt: endsAt}
+
+	// Periods run from the 15th, so the one billed on the contract's last day ends
+	// two weeks after the term
+	schedule := runs.Schedule{Anchor: time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC), Location: time.UTC}
+	billedThrough := time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC)
+	if due := schedule.Due(billedThrough, endsAt.AddDate(0, 0, 1)); len(due) != 0 {
+		t.Fatalf("Expected no period due the day after the term ended, got %+v", due)
+	}
+	if (orgProgress{}).termBilled(c, billedThrough) {
+		t.Error("Expected the true-up to wait for the period straddling the end of the term")
+	}
+
+	due := schedule.Due(billedThrough, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+	if len(due) != 1 || !due[0].Start.Before(endsAt) || !due[0].End.After(endsAt) {
+		t.Fatalf("Expected the period straddling the end of the term to be due, got %+v", due)
+	}
+	line := ReportLine{OrganizationID: orgID, Kind: invoices.Usage, PeriodStart: due[0].Start, PeriodEnd: due[0].End}
+
+	cases := []struct {
+		name     string
+		status   runs.OrgStatus
+		recorded time.Time
+		expected bool
+	}{
+		{"invoiced in this run", runs.OrgInvoiced, due[0].End, true},
+		{"dry run", runs.OrgDryRun, billedThrough, true},
+		{"failed in this run", runs.OrgFailed, billedThrough, false},
+		{"not due in this run", "", billedThrough, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var p orgProgress
+			if tt.status != "" {
+				l := line
+				l.Status = tt.status
+				p = p.add(l)
+			}
+			if got := p.termBilled(c, tt.recorded); got != tt.expected {
+				t.Errorf("Expected term billed %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// A later period failing stops the true-up even though the term is billed
+	failed := orgProgress{}.add(ReportLine{Status: runs.OrgInvoiced, PeriodEnd: due[0].End}).
+		add(ReportLine{Status: runs.OrgFailed, PeriodEnd: due[0].End.AddDate(0, 1, 0)})
+	if failed.termBilled(c, due[0].End) {
+		t.Error("Expected no true-up for an organization that failed in this run")
+	}
+}
@@ -2,9 +2,12 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
 )
 
 // RunMigrations applies idempotent schema required for the billing service.
@@ -20,6 +23,9 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			name VARCHAR(255) NOT NULL,
 			slug VARCHAR(255) UNIQUE NOT NULL,
 			stripe_customer_id VARCHAR(255),
+			billing_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+			billing_address JSONB NOT NULL DEFAULT '{}',
+			billing_timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
 			created_at TIMESTAMPTZ DEFAULT NOW(),
 			updated_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
@@ -34,6 +40,32 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			retained_storage_gb DECIMAL(10,2) DEFAULT 4,
 			current_period_start TIMESTAMPTZ,
 			current_period_end TIMESTAMPTZ,
+			billing_anchor TIMESTAMPTZ,
+			billed_through TIMESTAMPTZ,
+			trial_ends_at TIMESTAMPTZ,
+			trial_reminded_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS subscription_changes (
+			id UUID PRIMARY KEY,
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			from_plan VARCHAR(50) NOT NULL,
+			to_plan VARCHAR(50) NOT NULL,
+			effective_at TIMESTAMPTZ NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'applied',
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS clusters (
+			id UUID PRIMARY KEY,
+			name VARCHAR(255) UNIQUE NOT NULL,
+			region VARCHAR(50) NOT NULL,
+			grpc_endpoint VARCHAR(255) NOT NULL,
+			prometheus_url VARCHAR(255),
+			max_namespaces INT NOT NULL DEFAULT 0,
+			status VARCHAR(50) NOT NULL DEFAULT 'active',
+			health VARCHAR(50) NOT NULL DEFAULT 'unknown',
+			last_health_check_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ DEFAULT NOW(),
 			updated_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
@@ -43,19 +75,45 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			name VARCHAR(255) NOT NULL,
 			temporal_namespace VARCHAR(255) UNIQUE NOT NULL,
 			region VARCHAR(50) DEFAULT 'us-east-1',
+			cluster_id UUID REFERENCES clusters(id),
+			is_global BOOLEAN NOT NULL DEFAULT FALSE,
 			status VARCHAR(50) DEFAULT 'provisioning',
 			retention_days INT DEFAULT 7,
+			history_archival_state VARCHAR(20) NOT NULL DEFAULT 'disabled',
+			history_archival_uri TEXT,
+			visibility_archival_state VARCHAR(20) NOT NULL DEFAULT 'disabled',
+			visibility_archival_uri TEXT,
+			certificate_filters JSONB NOT NULL DEFAULT '[]',
 			created_at TIMESTAMPTZ DEFAULT NOW(),
 			updated_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
+		`CREATE TABLE IF NOT EXISTS namespace_clusters (
+			namespace_id UUID REFERENCES namespaces(id) ON DELETE CASCADE,
+			cluster_id UUID REFERENCES clusters(id),
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (namespace_id, cluster_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS namespace_failovers (
+			id UUID PRIMARY KEY,
+			namespace_id UUID REFERENCES namespaces(id) ON DELETE CASCADE,
+			from_cluster_id UUID REFERENCES clusters(id),
+			to_cluster_id UUID REFERENCES clusters(id),
+			reason TEXT,
+			status VARCHAR(50) NOT NULL DEFAULT 'in_progress',
+			error TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			completed_at TIMESTAMPTZ
+		);`,
 		`CREATE TABLE IF NOT EXISTS usage_records (
 			id UUID PRIMARY KEY,
 			organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
 			namespace_id VARCHAR(255) NOT NULL,
 			recorded_at TIMESTAMPTZ NOT NULL,
 			action_count BIGINT DEFAULT 0,
+			replicated_action_count BIGINT DEFAULT 0,
 			active_storage_bytes BIGINT DEFAULT 0,
 			retained_storage_bytes BIGINT DEFAULT 0,
+			archived_storage_bytes BIGINT DEFAULT 0,
 			workflow_started BIGINT DEFAULT 0,
 			activity_started BIGINT DEFAULT 0,
 			timer_started BIGINT DEFAULT 0,
@@ -69,8 +127,25 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			period_start TIMESTAMPTZ NOT NULL,
 			period_end TIMESTAMPTZ NOT NULL,
 			total_actions BIGINT DEFAULT 0,
+			total_replicated_actions BIGINT DEFAULT 0,
 			active_storage_gbh DECIMAL(20,6) DEFAULT 0,
 			retained_storage_gbh DECIMAL(20,6) DEFAULT 0,
+			archived_storage_gbh DECIMAL(20,6) DEFAULT 0,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS contracts (
+			id UUID PRIMARY KEY,
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+			starts_at TIMESTAMPTZ NOT NULL,
+			ends_at TIMESTAMPTZ NOT NULL,
+			commit_cents BIGINT NOT NULL DEFAULT 0,
+			monthly_fee_cents BIGINT NOT NULL DEFAULT 0,
+			prices JSONB NOT NULL DEFAULT '{}',
+			discounts JSONB NOT NULL DEFAULT '{}',
+			true_up VARCHAR(20) NOT NULL DEFAULT 'shortfall',
+			true_up_cents BIGINT,
+			true_up_invoiced_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
 		`CREATE TABLE IF NOT EXISTS invoices (
@@ -81,23 +156,121 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			period_start TIMESTAMPTZ NOT NULL,
 			period_end TIMESTAMPTZ NOT NULL,
 			subtotal_cents BIGINT DEFAULT 0,
+			discount_cents BIGINT DEFAULT 0,
 			tax_cents BIGINT DEFAULT 0,
 			total_cents BIGINT DEFAULT 0,
+			refunded_cents BIGINT NOT NULL DEFAULT 0,
+			currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+			kind VARCHAR(20) NOT NULL DEFAULT 'usage',
+			contract_id UUID REFERENCES contracts(id),
 			status VARCHAR(50) DEFAULT 'draft',
 			line_items JSONB,
 			paid_at TIMESTAMPTZ,
 			due_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
+		`CREATE TABLE IF NOT EXISTS invoice_line_items (
+			id UUID PRIMARY KEY,
+			invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			description TEXT NOT NULL,
+			meter VARCHAR(50),
+			quantity DECIMAL(24, 6) NOT NULL DEFAULT 0,
+			unit VARCHAR(50),
+			amount_cents BIGINT NOT NULL,
+			UNIQUE (invoice_id, position)
+		);`,
+		`CREATE TABLE IF NOT EXISTS invoice_sequences (
+			organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+			last_number BIGINT NOT NULL DEFAULT 0
+		);`,
+		`CREATE TABLE IF NOT EXISTS billing_runs (
+			id UUID PRIMARY KEY,
+			period_start TIMESTAMPTZ NOT NULL UNIQUE,
+			period_end TIMESTAMPTZ NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'running',
+			attempts INT NOT NULL DEFAULT 1,
+			processed INT NOT NULL DEFAULT 0,
+			failed INT NOT NULL DEFAULT 0,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ
+		);`,
+		`CREATE TABLE IF NOT EXISTS billing_run_organizations (
+			run_id UUID NOT NULL REFERENCES billing_runs(id) ON DELETE CASCADE,
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			period_start TIMESTAMPTZ,
+			provider_invoice_id VARCHAR(255),
+			invoice_id UUID REFERENCES invoices(id),
+			error TEXT,
+			attempts INT NOT NULL DEFAULT 1,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (run_id, organization_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS webhook_events (
+			provider VARCHAR(20) NOT NULL,
+			event_id VARCHAR(255) NOT NULL,
+			type VARCHAR(100) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'processed',
+			claimed_at TIMESTAMPTZ,
+			processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (provider, event_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS organization_tax_ids (
+			id UUID PRIMARY KEY,
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL,
+			value VARCHAR(50) NOT NULL,
+			country VARCHAR(2) NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			UNIQUE (organization_id, type, value)
+		);`,
 		`CREATE TABLE IF NOT EXISTS credits (
 			id UUID PRIMARY KEY,
 			organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+			kind VARCHAR(50) NOT NULL DEFAULT 'promotional',
+			currency VARCHAR(3) NOT NULL DEFAULT 'USD',
 			amount_cents BIGINT NOT NULL,
 			remaining_cents BIGINT NOT NULL,
 			description VARCHAR(255),
 			expires_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
+		`CREATE TABLE IF NOT EXISTS credit_debits (
+			id UUID PRIMARY KEY,
+			credit_id UUID NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			invoice_number VARCHAR(50) NOT NULL,
+			period_start TIMESTAMPTZ NOT NULL,
+			period_end TIMESTAMPTZ NOT NULL,
+			amount_cents BIGINT NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			UNIQUE (credit_id, invoice_number)
+		);`,
+		`CREATE TABLE IF NOT EXISTS coupons (
+			id UUID PRIMARY KEY,
+			code VARCHAR(50) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			percent_off DECIMAL(5, 2) NOT NULL DEFAULT 0,
+			amount_off_cents BIGINT NOT NULL DEFAULT 0,
+			currency VARCHAR(3),
+			duration VARCHAR(20) NOT NULL,
+			duration_in_months INT NOT NULL DEFAULT 0,
+			max_redemptions INT NOT NULL DEFAULT 0,
+			times_redeemed INT NOT NULL DEFAULT 0,
+			plans TEXT[] NOT NULL DEFAULT '{}',
+			redeem_by TIMESTAMPTZ,
+			stripe_coupon_id VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS coupon_redemptions (
+			id UUID PRIMARY KEY,
+			coupon_id UUID NOT NULL REFERENCES coupons(id),
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			redeemed_at TIMESTAMPTZ NOT NULL,
+			UNIQUE (coupon_id, organization_id)
+		);`,
 		`CREATE TABLE IF NOT EXISTS api_keys (
 			id UUID PRIMARY KEY,
 			organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
@@ -127,6 +300,40 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			created_at TIMESTAMPTZ DEFAULT NOW(),
 			updated_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
+		`CREATE TABLE IF NOT EXISTS namespace_grants (
+			namespace_id UUID REFERENCES namespaces(id) ON DELETE CASCADE,
+			identity_id UUID REFERENCES identities(id) ON DELETE CASCADE,
+			permission VARCHAR(50) NOT NULL DEFAULT 'read',
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (namespace_id, identity_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS plan_versions (
+			plan_id VARCHAR(50) NOT NULL,
+			version INT NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			base_price_cents BIGINT NOT NULL DEFAULT 0,
+			base_price_display VARCHAR(255) NOT NULL DEFAULT '',
+			actions_included BIGINT NOT NULL DEFAULT 0,
+			active_storage_gb DECIMAL(10,2) NOT NULL DEFAULT 0,
+			retained_storage_gb DECIMAL(10,2) NOT NULL DEFAULT 0,
+			stripe_price_id VARCHAR(255),
+			trial_days INT NOT NULL DEFAULT 0,
+			prices JSONB NOT NULL DEFAULT '{}',
+			currency_prices JSONB NOT NULL DEFAULT '{}',
+			max_namespaces INT NOT NULL DEFAULT 0,
+			max_retention_days INT NOT NULL DEFAULT 0,
+			namespace_rps INT NOT NULL DEFAULT 0,
+			namespace_global_rps INT NOT NULL DEFAULT 0,
+			replication BOOLEAN NOT NULL DEFAULT FALSE,
+			public BOOLEAN NOT NULL DEFAULT FALSE,
+			self_serve BOOLEAN NOT NULL DEFAULT FALSE,
+			sort_order INT NOT NULL DEFAULT 0,
+			features TEXT[] NOT NULL DEFAULT '{}',
+			effective_from TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (plan_id, version)
+		);`,
 		`CREATE TABLE IF NOT EXISTS audit_logs (
 			id UUID PRIMARY KEY,
 			organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
@@ -147,6 +354,66 @@ func RunMigrations(pool *pgxpool.Pool) error {
 		}
 	}
 
+	// Add missing columns to existing tables (for upgrades)
+	alterStatements := []string{
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_hash VARCHAR(255)`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS cluster_id UUID REFERENCES clusters(id)`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS is_global BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS replicated_action_count BIGINT DEFAULT 0`,
+		`ALTER TABLE usage_aggregates ADD COLUMN IF NOT EXISTS total_replicated_actions BIGINT DEFAULT 0`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS history_archival_state VARCHAR(20) NOT NULL DEFAULT 'disabled'`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS history_archival_uri TEXT`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS visibility_archival_state VARCHAR(20) NOT NULL DEFAULT 'disabled'`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS visibility_archival_uri TEXT`,
+		`ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS archived_storage_bytes BIGINT DEFAULT 0`,
+		`ALTER TABLE usage_aggregates ADD COLUMN IF NOT EXISTS archived_storage_gbh DECIMAL(20,6) DEFAULT 0`,
+		`ALTER TABLE namespaces ADD COLUMN IF NOT EXISTS certificate_filters JSONB NOT NULL DEFAULT '[]'`,
+		`ALTER TABLE plan_versions ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE plan_versions ADD COLUMN IF NOT EXISTS currency_prices JSONB NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS billing_currency VARCHAR(3) NOT NULL DEFAULT 'USD'`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS billing_address JSONB NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE invoices ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD'`,
+		`ALTER TABLE invoices ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'usage'`,
+		`ALTER TABLE invoices ADD COLUMN IF NOT EXISTS contract_id UUID REFERENCES contracts(id)`,
+		`ALTER TABLE invoices ADD COLUMN IF NOT EXISTS discount_cents BIGINT DEFAULT 0`,
+		`ALTER TABLE plan_versions ADD COLUMN IF NOT EXISTS trial_days INT NOT NULL DEFAULT 0`,
+		`ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_ends_at TIMESTAMPTZ`,
+		`ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_reminded_at TIMESTAMPTZ`,
+		`ALTER TABLE credits ADD COLUMN IF NOT EXISTS kind VARCHAR(50) NOT NULL DEFAULT 'promotional'`,
+		`ALTER TABLE credits ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD'`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS billing_timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'`,
+		`ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_anchor TIMESTAMPTZ`,
+		`ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billed_through TIMESTAMPTZ`,
+		`ALTER TABLE billing_run_organizations ADD COLUMN IF NOT EXISTS period_start TIMESTAMPTZ`,
+		`ALTER TABLE invoices ADD COLUMN IF NOT EXISTS refunded_cents BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'processed'`,
+		`ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ`,
+		`DO $$ BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns
+			           WHERE table_name = 'plans' AND column_name = 'namespace_actions_per_second') THEN
+				ALTER TABLE plans RENAME COLUMN namespace_actions_per_second TO namespace_global_rps;
+			END IF;
+		END $$`,
+		// Subscriptions billed by calendar month continue from the last month invoiced
+		`UPDATE subscriptions SET billing_anchor = COALESCE(current_period_start, created_at) WHERE billing_anchor IS NULL`,
+		`UPDATE subscriptions s SET billed_through = (
+		   SELECT MAX(i.period_end) FROM invoices i WHERE i.organization_id = s.organization_id AND i.kind = 'usage')
+		 WHERE billed_through IS NULL`,
+		// Calendar-month runs could number two invoices of an organization alike
+		// (INV-<org>-<YYYYMM>); suffix the later ones so idx_invoices_number can be built
+		`UPDATE invoices i SET invoice_number = d.invoice_number || '-' || d.n
+		 FROM (SELECT id, invoice_number, ROW_NUMBER() OVER (
+		         PARTITION BY organization_id, invoice_number ORDER BY created_at, id) AS n
+		       FROM invoices) d
+		 WHERE i.id = d.id AND d.n > 1`,
+	}
+	for _, stmt := range alterStatements {
+		if _, err := pool.Exec(ctx, stmt); err != nil && !alreadyExists(err) {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Indexes run after the ALTERs, which add some of the columns they cover
 	indexes := []string{
 		`CREATE INDEX IF NOT EXISTS idx_usage_org_time ON usage_records(organization_id, recorded_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_usage_namespace ON usage_records(namespace_id, recorded_at);`,
@@ -154,24 +421,41 @@ func RunMigrations(pool *pgxpool.Pool) error {
 		`CREATE INDEX IF NOT EXISTS idx_invoices_org ON invoices(organization_id, created_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_api_keys_org ON api_keys(organization_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_namespaces_org ON namespaces(organization_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_namespaces_cluster ON namespaces(cluster_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_clusters_region ON clusters(region);`,
+		`CREATE INDEX IF NOT EXISTS idx_namespace_failovers_ns ON namespace_failovers(namespace_id, created_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_identities_org ON identities(organization_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_identities_type ON identities(organization_id, type);`,
 		`CREATE INDEX IF NOT EXISTS idx_audit_logs_org ON audit_logs(organization_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_contracts_org ON contracts(organization_id, starts_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_invoices_contract ON invoices(contract_id);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_number ON invoices(organization_id, invoice_number);`,
+		`CREATE INDEX IF NOT EXISTS idx_invoices_provider ON invoices(stripe_invoice_id);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_true_up ON invoices(contract_id) WHERE kind = 'true_up';`,
+		`CREATE INDEX IF NOT EXISTS idx_credits_org ON credits(organization_id, expires_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_credit_debits_org ON credit_debits(organization_id, invoice_number);`,
+		`CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_org ON coupon_redemptions(organization_id, redeemed_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_subscriptions_trial ON subscriptions(trial_ends_at) WHERE status = 'trialing';`,
+		`CREATE INDEX IF NOT EXISTS idx_subscription_changes_org ON subscription_changes(organization_id, effective_at);`,
 	}
 
 	for _, stmt := range indexes {
-		// Ignore errors for indexes - they may fail if tables don't exist yet
-		// or if indexes already exist
-		pool.Exec(ctx, stmt)
+		if _, err := pool.Exec(ctx, stmt); err != nil && !alreadyExists(err) {
+			return fmt.Errorf("migration failed: %w", err)
+		}
 	}
 
-	// Add missing columns to existing tables (for upgrades)
-	alterStatements := []string{
-		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_hash VARCHAR(255)`,
-	}
-	for _, stmt := range alterStatements {
-		pool.Exec(ctx, stmt)
+	if err := plans.Seed(ctx, pool); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
 	}
 
 	return nil
 }
+
+// alreadyExists reports whether err is Postgres refusing to create an index or
+// column that is already there, which concurrent starts can race into.
+func alreadyExists(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == "42P07" || pgErr.Code == "42701" || pgErr.Code == "42710")
+}
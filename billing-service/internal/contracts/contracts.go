@@ -0,0 +1,157 @@
+// Package contracts models committed-spend contracts for sales-led plans. A
+// contract replaces the plan's base price with a negotiated platform fee, overrides
+// and discounts unit rates for its term, and commits the organization to a minimum
+// spend that is trued up when the term ends.
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+// TrueUp selects what happens to unused commitment at the end of a term
+type TrueUp string
+
+const (
+	// TrueUpShortfall invoices the commitment minus the term's spend
+	TrueUpShortfall TrueUp = "shortfall"
+
+	// TrueUpNone waives any shortfall
+	TrueUpNone TrueUp = "none"
+)
+
+var (
+	ErrInvalidContract  = errors.New("invalid contract")
+	ErrContractOverlaps = errors.New("contract overlaps an existing contract")
+	ErrContractNotFound = errors.New("contract not found")
+)
+
+// Contract holds an organization's negotiated terms for [StartsAt, EndsAt)
+type Contract struct {
+	ID             uuid.UUID      `json:"id"`
+	OrganizationID uuid.UUID      `json:"organization_id"`
+	Currency       money.Currency `json:"currency"`
+	StartsAt       time.Time      `json:"starts_at"`
+	EndsAt         time.Time      `json:"ends_at"`
+
+	// Minimum spend over the whole term, in minor units
+	CommitCents int64 `json:"commit_cents"`
+
+	// Platform fee charged every billing period instead of the plan's base price
+	MonthlyFeeCents int64 `json:"monthly_fee_cents"`
+
+	// Custom unit rates, replacing the plan's prices meter by meter
+	Prices pricing.PriceBook `json:"prices,omitempty"`
+
+	// Percentage off each meter's usage charges, applied after custom rates
+	Discounts map[pricing.Meter]decimal.Decimal `json:"discounts,omitempty"`
+
+	TrueUp           TrueUp     `json:"true_up"`
+	TrueUpCents      *int64     `json:"true_up_cents,omitempty"`
+	TrueUpInvoicedAt *time.Time `json:"true_up_invoiced_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Validate checks a contract's terms
+func (c Contract) Validate() error {
+	if parsed, err := money.ParseCurrency(string(c.Currency)); err != nil || parsed != c.Currency {
+		return fmt.Errorf("%w: invalid currency %q", ErrInvalidContract, c.Currency)
+	}
+	if c.StartsAt.IsZero() || !c.EndsAt.After(c.StartsAt) {
+		return fmt.Errorf("%w: term must end after it starts", ErrInvalidContract)
+	}
+	if c.CommitCents < 0 || c.MonthlyFeeCents < 0 {
+		return fmt.Errorf("%w: commitment and fee must not be negative", ErrInvalidContract)
+	}
+	if c.TrueUp != TrueUpShortfall && c.TrueUp != TrueUpNone {
+		return fmt.Errorf("%w: true-up must be %s or %s", ErrInvalidContract, TrueUpShortfall, TrueUpNone)
+	}
+	if err := c.Prices.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidContract, err)
+	}
+
+	meters := make([]pricing.Meter, 0, len(c.Discounts))
+	for m := range c.Discounts {
+		meters = append(meters, m)
+	}
+	sort.Slice(meters, func(i, j int) bool { return meters[i] < meters[j] })
+	for _, m := range meters {
+		if !isMeter(m) {
+			return fmt.Errorf("%w: unknown meter %s", ErrInvalidContract, m)
+		}
+		if d := c.Discounts[m]; d.IsNegative() || d.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("%w: %s discount must be between 0 and 100 percent", ErrInvalidContract, m)
+		}
+	}
+	return nil
+}
+
+func isMeter(m pricing.Meter) bool {
+	for _, known := range pricing.Meters {
+		if m == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether a billing period starting at t falls within the term
+func (c Contract) Covers(t time.Time) bool {
+	return !t.Before(c.StartsAt) && t.Before(c.EndsAt)
+}
+
+// Overlaps reports whether two contracts' terms overlap
+func (c Contract) Overlaps(o Contract) bool {
+	return c.StartsAt.Before(o.EndsAt) && o.StartsAt.Before(c.EndsAt)
+}
+
+// Apply returns the plan priced on the contract's terms in the contract's currency:
+// the platform fee replaces the base price, and unit rates are overridden and then
+// discounted. Included usage is unchanged.
+func (c Contract) Apply(p plans.Plan) plans.Plan {
+	price, book, err := p.PricesIn(c.Currency)
+	if err != nil {
+		// Contracts may bill in a currency the plan is not sold in
+		book, _ = pricing.ListPrices(c.Currency)
+		price = plans.CurrencyPrice{}
+	}
+	book = book.With(c.Prices)
+	for m, pct := range c.Discounts {
+		if mp, ok := book[m]; ok {
+			book[m] = mp.Scale(decimal.NewFromInt(100).Sub(pct).Div(decimal.NewFromInt(100)))
+		}
+	}
+
+	price.BasePriceMinor = c.MonthlyFeeCents
+	price.BasePriceDisplay = "Contract"
+	price.Prices = book
+	if c.Currency == money.USD {
+		p.BasePriceCents = price.BasePriceMinor
+		p.BasePriceDisplay = price.BasePriceDisplay
+		p.Prices = book
+		return p
+	}
+	currencies := make(map[money.Currency]plans.CurrencyPrice, len(p.CurrencyPrices)+1)
+	for k, v := range p.CurrencyPrices {
+		currencies[k] = v
+	}
+	currencies[c.Currency] = price
+	p.CurrencyPrices = currencies
+	return p
+}
+
+// Shortfall returns the true-up owed for a term's spend
+func (c Contract) Shortfall(spendCents int64) int64 {
+	if c.TrueUp != TrueUpShortfall || spendCents >= c.CommitCents {
+		return 0
+	}
+	return c.CommitCents - spendCents
+}
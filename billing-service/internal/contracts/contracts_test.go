@@ -0,0 +1,141 @@
+package contracts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+var termStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func annual(currency money.Currency) Contract {
+	return Contract{
+		Currency:        currency,
+		StartsAt:        termStart,
+		EndsAt:          termStart.AddDate(1, 0, 0),
+		CommitCents:     12000000,
+		MonthlyFeeCents: 500000,
+		Prices:          pricing.PriceBook{pricing.MeterActions: pricing.Flat(1000000, true, decimal.NewFromInt(2000))},
+		Discounts:       map[pricing.Meter]decimal.Decimal{pricing.MeterActions: decimal.NewFromInt(10), pricing.MeterActiveStorage: decimal.NewFromInt(50)},
+		TrueUp:          TrueUpShortfall,
+	}
+}
+
+func TestApplyPricesPlanOnContract(t *testing.T) {
+	enterprise := plans.Effective(plans.Defaults(), "enterprise", termStart)
+
+	tests := []struct {
+		currency        money.Currency
+		expectedActions string
+		expectedActive  string
+	}{
+		{money.USD, "1800", "2.1"},
+		{money.EUR, "1800", "1.95"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.currency), func(t *testing.T) {
+			plan := annual(tt.currency).Apply(enterprise)
+			price, book, err := plan.PricesIn(tt.currency)
+			if err != nil {
+				t.Fatalf("Expected contract plan to be priced in %s, got %v", tt.currency, err)
+			}
+			if price.BasePriceMinor != 500000 {
+				t.Errorf("Expected the platform fee to replace the base price, got %d", price.BasePriceMinor)
+			}
+			if got := book[pricing.MeterActions].Tiers[0].UnitCents; !got.Equal(decimal.RequireFromString(tt.expectedActions)) {
+				t.Errorf("Expected discounted custom action rate %s, got %s", tt.expectedActions, got)
+			}
+			if got := book[pricing.MeterActiveStorage].Tiers[0].UnitCents; !got.Equal(decimal.RequireFromString(tt.expectedActive)) {
+				t.Errorf("Expected discounted list storage rate %s, got %s", tt.expectedActive, got)
+			}
+			if plan.ActionsIncluded != enterprise.ActionsIncluded {
+				t.Errorf("Expected included actions to be unchanged, got %d", plan.ActionsIncluded)
+			}
+		})
+	}
+
+	if enterprise.BasePriceCents != 0 || len(enterprise.Prices) != 0 {
+		t.Error("Expected Apply to leave the catalog plan unchanged")
+	}
+}
+
+func TestShortfall(t *testing.T) {
+	c := annual(money.USD)
+
+	tests := []struct {
+		name     string
+		trueUp   TrueUp
+		spend    int64
+		expected int64
+	}{
+		{"under commitment", TrueUpShortfall, 9000000, 3000000},
+		{"met commitment", TrueUpShortfall, 12000000, 0},
+		{"over commitment", TrueUpShortfall, 15000000, 0},
+		{"shortfall waived", TrueUpNone, 9000000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.TrueUp = tt.trueUp
+			if got := c.Shortfall(tt.spend); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestContractTerm(t *testing.T) {
+	c := annual(money.USD)
+	if !c.Covers(termStart) || !c.Covers(termStart.AddDate(0, 11, 0)) {
+		t.Error("Expected periods starting within the term to be covered")
+	}
+	if c.Covers(termStart.AddDate(1, 0, 0)) || c.Covers(termStart.AddDate(0, 0, -1)) {
+		t.Error("Expected periods outside the term not to be covered")
+	}
+
+	renewal := annual(money.USD)
+	renewal.StartsAt, renewal.EndsAt = c.EndsAt, c.EndsAt.AddDate(1, 0, 0)
+	if c.Overlaps(renewal) {
+		t.Error("Expected back-to-back terms not to overlap")
+	}
+	renewal.StartsAt = c.EndsAt.AddDate(0, -1, 0)
+	if !c.Overlaps(renewal) {
+		t.Error("Expected overlapping terms to overlap")
+	}
+}
+
+func TestContractValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Contract)
+		wantErr bool
+	}{
+		{"valid", func(c *Contract) {}, false},
+		{"empty term", func(c *Contract) { c.EndsAt = c.StartsAt }, true},
+		{"negative commitment", func(c *Contract) { c.CommitCents = -1 }, true},
+		{"unknown true-up", func(c *Contract) { c.TrueUp = "rollover" }, true},
+		{"invalid currency", func(c *Contract) { c.Currency = "euro" }, true},
+		{"invalid rates", func(c *Contract) { c.Prices = pricing.PriceBook{pricing.MeterActions: {Mode: "flat"}} }, true},
+		{"discount over 100%", func(c *Contract) { c.Discounts[pricing.MeterActions] = decimal.NewFromInt(101) }, true},
+		{"discount on unknown meter", func(c *Contract) { c.Discounts["egress"] = decimal.NewFromInt(5) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := annual(money.USD)
+			tt.modify(&c)
+			err := c.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
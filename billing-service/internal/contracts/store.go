@@ -0,0 +1,184 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+const contractColumns = `id, organization_id, currency, starts_at, ends_at, commit_cents, monthly_fee_cents,
+	prices, discounts, true_up, true_up_cents, true_up_invoiced_at, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Execer runs a statement on a pool or inside a transaction
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func scanContract(row rowScanner) (Contract, error) {
+	var c Contract
+	err := row.Scan(&c.ID, &c.OrganizationID, &c.Currency, &c.StartsAt, &c.EndsAt, &c.CommitCents, &c.MonthlyFeeCents,
+		&c.Prices, &c.Discounts, &c.TrueUp, &c.TrueUpCents, &c.TrueUpInvoicedAt, &c.CreatedAt)
+	return c, err
+}
+
+// Store reads and writes contracts in Postgres. A nil Store, or one without a
+// database, has no contracts.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the contracts table
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+func (s *Store) query(ctx context.Context, sql string, args ...any) ([]Contract, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+contractColumns+` FROM contracts `+sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contracts: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Contract
+	for rows.Next() {
+		c, err := scanContract(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Create adds a contract. Terms of one organization's contracts may not overlap.
+func (s *Store) Create(ctx context.Context, c Contract) (Contract, error) {
+	if !s.available() {
+		return Contract{}, fmt.Errorf("contract store has no database")
+	}
+	if c.TrueUp == "" {
+		c.TrueUp = TrueUpShortfall
+	}
+	if c.Prices == nil {
+		c.Prices = pricing.PriceBook{}
+	}
+	if c.Discounts == nil {
+		c.Discounts = map[pricing.Meter]decimal.Decimal{}
+	}
+	if err := c.Validate(); err != nil {
+		return Contract{}, err
+	}
+	c.ID = uuid.New()
+	c.CreatedAt = time.Now()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Contract{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize contract terms per organization
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('contracts:' || $1))`, c.OrganizationID.String()); err != nil {
+		return Contract{}, err
+	}
+
+	var overlapping bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM contracts WHERE organization_id = $1 AND starts_at < $3 AND ends_at > $2)`,
+		c.OrganizationID, c.StartsAt, c.EndsAt).Scan(&overlapping)
+	if err != nil {
+		return Contract{}, err
+	}
+	if overlapping {
+		return Contract{}, ErrContractOverlaps
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO contracts (id, organization_id, currency, starts_at, ends_at, commit_cents, monthly_fee_cents,
+		                        prices, discounts, true_up, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		c.ID, c.OrganizationID, c.Currency, c.StartsAt, c.EndsAt, c.CommitCents, c.MonthlyFeeCents,
+		c.Prices, c.Discounts, c.TrueUp, c.CreatedAt)
+	if err != nil {
+		return Contract{}, fmt.Errorf("failed to create contract: %w", err)
+	}
+	return c, tx.Commit(ctx)
+}
+
+// List returns an organization's contracts, newest term first
+func (s *Store) List(ctx context.Context, orgID uuid.UUID) ([]Contract, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	return s.query(ctx, `WHERE organization_id = $1 ORDER BY starts_at DESC`, orgID)
+}
+
+// Get returns one contract
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (Contract, error) {
+	if !s.available() {
+		return Contract{}, ErrContractNotFound
+	}
+	c, err := scanContract(s.db.QueryRow(ctx, `SELECT `+contractColumns+` FROM contracts WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Contract{}, ErrContractNotFound
+	}
+	return c, err
+}
+
+// Active returns the contract covering a billing period that starts at t, or nil
+func (s *Store) Active(ctx context.Context, orgID uuid.UUID, t time.Time) (*Contract, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	list, err := s.query(ctx, `WHERE organization_id = $1 AND starts_at <= $2 AND ends_at > $2`, orgID, t)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Spend returns the usage invoiced under a contract, before credits
+func (s *Store) Spend(ctx context.Context, id uuid.UUID) (int64, error) {
+	if !s.available() {
+		return 0, nil
+	}
+	var spend int64
+	err := s.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(subtotal_cents), 0) FROM invoices WHERE contract_id = $1 AND kind = 'usage'`, id).
+		Scan(&spend)
+	return spend, err
+}
+
+// DueForTrueUp returns contracts whose term ended by asOf and that have not been trued up
+func (s *Store) DueForTrueUp(ctx context.Context, asOf time.Time) ([]Contract, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	return s.query(ctx, `WHERE ends_at <= $1 AND true_up_invoiced_at IS NULL ORDER BY ends_at`, asOf)
+}
+
+// MarkTrueUp records that a contract's term has been trued up, in a transaction when q
+// is one
+func (s *Store) MarkTrueUp(ctx context.Context, q Execer, id uuid.UUID, cents int64, at time.Time) error {
+	if !s.available() {
+		return fmt.Errorf("contract store has no database")
+	}
+	_, err := q.Exec(ctx,
+		`UPDATE contracts SET true_up_cents = $2, true_up_invoiced_at = $3 WHERE id = $1`, id, cents, at)
+	return err
+}
@@ -0,0 +1,193 @@
+// Package coupons defines promotional discounts and their redemption by
+// organizations. Discounts are applied while a bill is calculated, so usage
+// estimates and invoices always agree on them.
+package coupons
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// Duration selects how many billing periods a redeemed coupon discounts
+type Duration string
+
+const (
+	Once      Duration = "once"
+	Repeating Duration = "repeating"
+	Forever   Duration = "forever"
+)
+
+var (
+	ErrInvalidCoupon    = errors.New("invalid coupon")
+	ErrCouponNotFound   = errors.New("coupon not found")
+	ErrCouponExists     = errors.New("coupon code already exists")
+	ErrCouponExpired    = errors.New("coupon has expired")
+	ErrCouponExhausted  = errors.New("coupon has reached its maximum redemptions")
+	ErrCouponNotForPlan = errors.New("coupon does not apply to this plan")
+	ErrAlreadyRedeemed  = errors.New("organization already has an active coupon")
+)
+
+var codePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{2,49}$`)
+
+// NormalizeCode upper-cases a promotion code so codes match case-insensitively
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Coupon is a discount customers redeem with its promotion code. It takes either a
+// percentage or a fixed amount off each discounted bill.
+type Coupon struct {
+	ID               uuid.UUID       `json:"id"`
+	Code             string          `json:"code"`
+	Name             string          `json:"name"`
+	PercentOff       decimal.Decimal `json:"percent_off"`
+	AmountOffCents   int64           `json:"amount_off_cents"`
+	Currency         money.Currency  `json:"currency,omitempty"`
+	Duration         Duration        `json:"duration"`
+	DurationInMonths int             `json:"duration_in_months,omitempty"`
+
+	// Zero means unlimited
+	MaxRedemptions int `json:"max_redemptions"`
+	TimesRedeemed  int `json:"times_redeemed"`
+
+	// Plans the coupon can discount. Empty means every plan.
+	Plans []string `json:"plans,omitempty"`
+
+	// Last moment the code can be redeemed
+	RedeemBy *time.Time `json:"redeem_by,omitempty"`
+
+	StripeCouponID string    `json:"stripe_coupon_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Validate checks a coupon definition
+func (c Coupon) Validate() error {
+	if !codePattern.MatchString(c.Code) {
+		return fmt.Errorf("%w: code must be 3-50 upper-case letters, digits, dashes or underscores", ErrInvalidCoupon)
+	}
+	if c.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCoupon)
+	}
+
+	percent := !c.PercentOff.IsZero()
+	if percent == (c.AmountOffCents != 0) {
+		return fmt.Errorf("%w: set exactly one of percent_off and amount_off_cents", ErrInvalidCoupon)
+	}
+	if percent && (!c.PercentOff.IsPositive() || c.PercentOff.GreaterThan(decimal.NewFromInt(100))) {
+		return fmt.Errorf("%w: percent_off must be between 0 and 100", ErrInvalidCoupon)
+	}
+	if !percent {
+		if c.AmountOffCents < 0 {
+			return fmt.Errorf("%w: amount_off_cents must be positive", ErrInvalidCoupon)
+		}
+		if parsed, err := money.ParseCurrency(string(c.Currency)); err != nil || parsed != c.Currency {
+			return fmt.Errorf("%w: amount off coupons need a currency", ErrInvalidCoupon)
+		}
+	}
+
+	switch c.Duration {
+	case Once, Forever:
+		if c.DurationInMonths != 0 {
+			return fmt.Errorf("%w: duration_in_months only applies to %s coupons", ErrInvalidCoupon, Repeating)
+		}
+	case Repeating:
+		if c.DurationInMonths <= 0 {
+			return fmt.Errorf("%w: %s coupons need duration_in_months", ErrInvalidCoupon, Repeating)
+		}
+	default:
+		return fmt.Errorf("%w: duration must be %s, %s or %s", ErrInvalidCoupon, Once, Repeating, Forever)
+	}
+	if c.MaxRedemptions < 0 {
+		return fmt.Errorf("%w: max_redemptions must not be negative", ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// AppliesToPlan reports whether the coupon can discount a plan
+func (c Coupon) AppliesToPlan(planID string) bool {
+	if len(c.Plans) == 0 {
+		return true
+	}
+	for _, p := range c.Plans {
+		if p == planID {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRedeemable reports why an organization on a plan cannot redeem the coupon now
+func (c Coupon) CheckRedeemable(planID string, now time.Time) error {
+	if c.RedeemBy != nil && now.After(*c.RedeemBy) {
+		return ErrCouponExpired
+	}
+	if c.MaxRedemptions > 0 && c.TimesRedeemed >= c.MaxRedemptions {
+		return ErrCouponExhausted
+	}
+	if !c.AppliesToPlan(planID) {
+		return ErrCouponNotForPlan
+	}
+	return nil
+}
+
+// Redemption is a coupon redeemed by an organization
+type Redemption struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Coupon         Coupon    `json:"coupon"`
+	RedeemedAt     time.Time `json:"redeemed_at"`
+}
+
+// ends returns when the redemption stops discounting, if it ever does
+func (r Redemption) ends() (time.Time, bool) {
+	switch r.Coupon.Duration {
+	case Once:
+		return r.RedeemedAt.AddDate(0, 1, 0), true
+	case Repeating:
+		return r.RedeemedAt.AddDate(0, r.Coupon.DurationInMonths, 0), true
+	}
+	return time.Time{}, false
+}
+
+// Covers reports whether the redemption discounts the billing period ending at
+// periodEnd. A coupon discounts the periods that end after it was redeemed: the first
+// one for once, those ending within DurationInMonths for repeating, all for forever.
+func (r Redemption) Covers(periodEnd time.Time) bool {
+	if !periodEnd.After(r.RedeemedAt) {
+		return false
+	}
+	end, ok := r.ends()
+	return !ok || !periodEnd.After(end)
+}
+
+// ActiveAt reports whether the redemption still discounts periods ending after t
+func (r Redemption) ActiveAt(t time.Time) bool {
+	end, ok := r.ends()
+	return !ok || t.Before(end)
+}
+
+// Discount returns the amount taken off a bill's subtotal. Percentages are rounded
+// half up to whole minor units; neither kind of discount exceeds the subtotal.
+func (r *Redemption) Discount(planID string, currency money.Currency, periodEnd time.Time, subtotal int64) int64 {
+	if r == nil || subtotal <= 0 || !r.Covers(periodEnd) || !r.Coupon.AppliesToPlan(planID) {
+		return 0
+	}
+
+	var off int64
+	if !r.Coupon.PercentOff.IsZero() {
+		off = decimal.NewFromInt(subtotal).Mul(r.Coupon.PercentOff).Div(decimal.NewFromInt(100)).Round(0).IntPart()
+	} else if r.Coupon.Currency == currency {
+		off = r.Coupon.AmountOffCents
+	}
+	if off > subtotal {
+		off = subtotal
+	}
+	return off
+}
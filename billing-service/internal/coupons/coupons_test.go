@@ -0,0 +1,146 @@
+package coupons
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+var redeemed = time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+
+func percent(off int64, duration Duration, months int) Coupon {
+	return Coupon{
+		Code:             "SPRING25",
+		Name:             "Spring promotion",
+		PercentOff:       decimal.NewFromInt(off),
+		Duration:         duration,
+		DurationInMonths: months,
+	}
+}
+
+func TestCouponValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Coupon)
+		wantErr bool
+	}{
+		{"valid percent", func(c *Coupon) {}, false},
+		{"valid amount", func(c *Coupon) { c.PercentOff, c.AmountOffCents, c.Currency = decimal.Zero, 5000, money.EUR }, false},
+		{"lower-case code", func(c *Coupon) { c.Code = "spring25" }, true},
+		{"short code", func(c *Coupon) { c.Code = "AB" }, true},
+		{"missing name", func(c *Coupon) { c.Name = "" }, true},
+		{"both discounts", func(c *Coupon) { c.AmountOffCents, c.Currency = 5000, money.USD }, true},
+		{"no discount", func(c *Coupon) { c.PercentOff = decimal.Zero }, true},
+		{"over 100%", func(c *Coupon) { c.PercentOff = decimal.NewFromInt(120) }, true},
+		{"amount without currency", func(c *Coupon) { c.PercentOff, c.AmountOffCents = decimal.Zero, 5000 }, true},
+		{"repeating without months", func(c *Coupon) { c.Duration = Repeating }, true},
+		{"months on forever", func(c *Coupon) { c.Duration, c.DurationInMonths = Forever, 3 }, true},
+		{"unknown duration", func(c *Coupon) { c.Duration = "weekly" }, true},
+		{"negative redemptions", func(c *Coupon) { c.MaxRedemptions = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := percent(25, Once, 0)
+			tt.modify(&c)
+			err := c.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckRedeemable(t *testing.T) {
+	deadline := redeemed.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name     string
+		modify   func(c *Coupon)
+		expected error
+	}{
+		{"redeemable", func(c *Coupon) {}, nil},
+		{"past redeem by", func(c *Coupon) { c.RedeemBy = &deadline }, ErrCouponExpired},
+		{"exhausted", func(c *Coupon) { c.MaxRedemptions, c.TimesRedeemed = 10, 10 }, ErrCouponExhausted},
+		{"under limit", func(c *Coupon) { c.MaxRedemptions, c.TimesRedeemed = 10, 9 }, nil},
+		{"other plan", func(c *Coupon) { c.Plans = []string{"business"} }, ErrCouponNotForPlan},
+		{"restricted to plan", func(c *Coupon) { c.Plans = []string{"business", "essential"} }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := percent(25, Once, 0)
+			tt.modify(&c)
+			if err := c.CheckRedeemable("essential", redeemed); err != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestRedemptionCovers(t *testing.T) {
+	april, may, july, august := redeemed.AddDate(0, 0, 17), redeemed.AddDate(0, 1, 17), redeemed.AddDate(0, 3, 17), redeemed.AddDate(0, 4, 17)
+
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		expected map[time.Time]bool
+	}{
+		{"once", percent(25, Once, 0), map[time.Time]bool{redeemed: false, april: true, may: false}},
+		{"repeating", percent(25, Repeating, 4), map[time.Time]bool{april: true, july: true, august: false}},
+		{"forever", percent(25, Forever, 0), map[time.Time]bool{april: true, august: true, august.AddDate(5, 0, 0): true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Redemption{Coupon: tt.coupon, RedeemedAt: redeemed}
+			for periodEnd, expected := range tt.expected {
+				if got := r.Covers(periodEnd); got != expected {
+					t.Errorf("Expected Covers(%s) = %v, got %v", periodEnd.Format("2006-01-02"), expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	periodEnd := redeemed.AddDate(0, 0, 17)
+	amount := Coupon{AmountOffCents: 5000, Currency: money.EUR, Duration: Forever}
+	restricted := percent(25, Forever, 0)
+	restricted.Plans = []string{"business"}
+
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		currency money.Currency
+		subtotal int64
+		expected int64
+	}{
+		{"percent", percent(25, Forever, 0), money.USD, 12500, 3125},
+		{"percent rounds half up", percent(25, Forever, 0), money.USD, 10002, 2501},
+		{"amount", amount, money.EUR, 12500, 5000},
+		{"amount capped at subtotal", amount, money.EUR, 3000, 3000},
+		{"amount in another currency", amount, money.USD, 12500, 0},
+		{"plan not covered", restricted, money.USD, 12500, 0},
+		{"nothing to discount", percent(25, Forever, 0), money.USD, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Redemption{Coupon: tt.coupon, RedeemedAt: redeemed}
+			if got := r.Discount("essential", tt.currency, periodEnd, tt.subtotal); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+
+	var none *Redemption
+	if got := none.Discount("essential", money.USD, periodEnd, 12500); got != 0 {
+		t.Errorf("Expected no discount without a coupon, got %d", got)
+	}
+}
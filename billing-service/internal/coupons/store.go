@@ -0,0 +1,221 @@
+package coupons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const couponColumns = `c.id, c.code, c.name, c.percent_off, c.amount_off_cents, COALESCE(c.currency, ''), c.duration,
+	c.duration_in_months, c.max_redemptions, c.times_redeemed, c.plans, c.redeem_by, COALESCE(c.stripe_coupon_id, ''),
+	c.created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCoupon(row rowScanner, extra ...any) (Coupon, error) {
+	var c Coupon
+	dest := []any{&c.ID, &c.Code, &c.Name, &c.PercentOff, &c.AmountOffCents, &c.Currency, &c.Duration,
+		&c.DurationInMonths, &c.MaxRedemptions, &c.TimesRedeemed, &c.Plans, &c.RedeemBy, &c.StripeCouponID,
+		&c.CreatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	return c, err
+}
+
+// Store reads and writes coupons and redemptions in Postgres. A nil Store, or one
+// without a database, has no coupons.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the coupons and coupon_redemptions tables
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+// Create adds a coupon
+func (s *Store) Create(ctx context.Context, c Coupon) (Coupon, error) {
+	if !s.available() {
+		return Coupon{}, fmt.Errorf("coupon store has no database")
+	}
+	c.Code = NormalizeCode(c.Code)
+	if c.Plans == nil {
+		c.Plans = []string{}
+	}
+	if err := c.Validate(); err != nil {
+		return Coupon{}, err
+	}
+	c.ID = uuid.New()
+	c.TimesRedeemed = 0
+	c.CreatedAt = time.Now()
+
+	var currency *string
+	if c.Currency != "" {
+		code := string(c.Currency)
+		currency = &code
+	}
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO coupons (id, code, name, percent_off, amount_off_cents, currency, duration, duration_in_months,
+		                      max_redemptions, plans, redeem_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		c.ID, c.Code, c.Name, c.PercentOff, c.AmountOffCents, currency, c.Duration, c.DurationInMonths,
+		c.MaxRedemptions, c.Plans, c.RedeemBy, c.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return Coupon{}, ErrCouponExists
+	}
+	if err != nil {
+		return Coupon{}, fmt.Errorf("failed to create coupon: %w", err)
+	}
+	return c, nil
+}
+
+// SetStripeCouponID records the Stripe coupon a coupon is mirrored to
+func (s *Store) SetStripeCouponID(ctx context.Context, id uuid.UUID, stripeCouponID string) error {
+	if !s.available() {
+		return fmt.Errorf("coupon store has no database")
+	}
+	_, err := s.db.Exec(ctx, `UPDATE coupons SET stripe_coupon_id = $2 WHERE id = $1`, id, stripeCouponID)
+	return err
+}
+
+// List returns every coupon, newest first
+func (s *Store) List(ctx context.Context) ([]Coupon, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+couponColumns+` FROM coupons c ORDER BY c.created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list coupons: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Coupon
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Get returns the coupon with a promotion code
+func (s *Store) Get(ctx context.Context, code string) (Coupon, error) {
+	if !s.available() {
+		return Coupon{}, ErrCouponNotFound
+	}
+	c, err := scanCoupon(s.db.QueryRow(ctx,
+		`SELECT `+couponColumns+` FROM coupons c WHERE c.code = $1`, NormalizeCode(code)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Coupon{}, ErrCouponNotFound
+	}
+	return c, err
+}
+
+// Redemptions returns an organization's redemptions, newest first
+func (s *Store) Redemptions(ctx context.Context, orgID uuid.UUID) ([]Redemption, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT `+couponColumns+`, r.id, r.organization_id, r.redeemed_at
+		 FROM coupon_redemptions r JOIN coupons c ON c.id = r.coupon_id
+		 WHERE r.organization_id = $1 ORDER BY r.redeemed_at DESC`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Redemption
+	for rows.Next() {
+		var r Redemption
+		c, err := scanCoupon(rows, &r.ID, &r.OrganizationID, &r.RedeemedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.Coupon = c
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// Active returns the redemption that discounts an organization's billing period
+// ending at periodEnd, or nil
+func (s *Store) Active(ctx context.Context, orgID uuid.UUID, periodEnd time.Time) (*Redemption, error) {
+	list, err := s.Redemptions(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Covers(periodEnd) {
+			return &list[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Redeem applies a promotion code to an organization on a plan. An organization has
+// at most one active coupon, and each redemption counts towards the coupon's limit.
+func (s *Store) Redeem(ctx context.Context, orgID uuid.UUID, code, planID string, now time.Time) (Redemption, error) {
+	if !s.available() {
+		return Redemption{}, fmt.Errorf("coupon store has no database")
+	}
+
+	existing, err := s.Redemptions(ctx, orgID)
+	if err != nil {
+		return Redemption{}, err
+	}
+	for _, r := range existing {
+		if r.ActiveAt(now) {
+			return Redemption{}, ErrAlreadyRedeemed
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Redemption{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := scanCoupon(tx.QueryRow(ctx,
+		`SELECT `+couponColumns+` FROM coupons c WHERE c.code = $1 FOR UPDATE`, NormalizeCode(code)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Redemption{}, ErrCouponNotFound
+	}
+	if err != nil {
+		return Redemption{}, err
+	}
+	if err := c.CheckRedeemable(planID, now); err != nil {
+		return Redemption{}, err
+	}
+
+	r := Redemption{ID: uuid.New(), OrganizationID: orgID, Coupon: c, RedeemedAt: now}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO coupon_redemptions (id, coupon_id, organization_id, redeemed_at) VALUES ($1, $2, $3, $4)`,
+		r.ID, c.ID, orgID, now)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return Redemption{}, ErrAlreadyRedeemed
+	}
+	if err != nil {
+		return Redemption{}, fmt.Errorf("failed to redeem coupon: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE coupons SET times_redeemed = times_redeemed + 1 WHERE id = $1`, c.ID); err != nil {
+		return Redemption{}, err
+	}
+	r.Coupon.TimesRedeemed++
+	return r, tx.Commit(ctx)
+}
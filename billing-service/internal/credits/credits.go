@@ -0,0 +1,124 @@
+// Package credits keeps the prepaid credits ledger. Credits are granted to an
+// organization in its billing currency and burned against invoices, soonest expiry
+// first; every burn is recorded as a debit so balances can always be explained.
+package credits
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// Kind records why a credit was granted
+type Kind string
+
+const (
+	Promotional Kind = "promotional"
+	SLA         Kind = "sla"
+	Prepaid     Kind = "prepaid"
+)
+
+var ErrInvalidCredit = errors.New("invalid credit")
+
+// Credit is a grant of credit to an organization
+type Credit struct {
+	ID             uuid.UUID      `json:"id"`
+	OrganizationID uuid.UUID      `json:"organization_id"`
+	Kind           Kind           `json:"kind"`
+	Currency       money.Currency `json:"currency"`
+	AmountCents    int64          `json:"amount_cents"`
+	RemainingCents int64          `json:"remaining_cents"`
+	Description    string         `json:"description,omitempty"`
+	ExpiresAt      *time.Time     `json:"expires_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Validate checks a new grant
+func (c Credit) Validate() error {
+	switch c.Kind {
+	case Promotional, SLA, Prepaid:
+	default:
+		return fmt.Errorf("%w: kind must be %s, %s or %s", ErrInvalidCredit, Promotional, SLA, Prepaid)
+	}
+	if c.AmountCents <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidCredit)
+	}
+	if parsed, err := money.ParseCurrency(string(c.Currency)); err != nil || parsed != c.Currency {
+		return fmt.Errorf("%w: invalid currency %q", ErrInvalidCredit, c.Currency)
+	}
+	if c.ExpiresAt != nil && !c.CreatedAt.IsZero() && !c.ExpiresAt.After(c.CreatedAt) {
+		return fmt.Errorf("%w: expiry must be in the future", ErrInvalidCredit)
+	}
+	return nil
+}
+
+// UsableFor reports whether a credit can pay for a billing period. A credit that was
+// still valid when the period started covers it, even if it expires before the
+// period is invoiced.
+func (c Credit) UsableFor(currency money.Currency, periodStart time.Time) bool {
+	if c.RemainingCents <= 0 || c.Currency != currency {
+		return false
+	}
+	return c.ExpiresAt == nil || c.ExpiresAt.After(periodStart)
+}
+
+// Debit is one credit burned against one invoice
+type Debit struct {
+	ID             uuid.UUID `json:"id"`
+	CreditID       uuid.UUID `json:"credit_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	AmountCents    int64     `json:"amount_cents"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Allocate burns credits against an amount owed. Credits that expire soonest are used
+// first, credits without an expiry last, and older grants before newer ones. The
+// debits never exceed the amount or any credit's remaining balance.
+func Allocate(available []Credit, currency money.Currency, periodStart time.Time, amount int64) []Debit {
+	usable := make([]Credit, 0, len(available))
+	for _, c := range available {
+		if c.UsableFor(currency, periodStart) {
+			usable = append(usable, c)
+		}
+	}
+	sort.SliceStable(usable, func(i, j int) bool {
+		a, b := usable[i], usable[j]
+		if (a.ExpiresAt == nil) != (b.ExpiresAt == nil) {
+			return a.ExpiresAt != nil
+		}
+		if a.ExpiresAt != nil && !a.ExpiresAt.Equal(*b.ExpiresAt) {
+			return a.ExpiresAt.Before(*b.ExpiresAt)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+
+	var debits []Debit
+	for _, c := range usable {
+		if amount <= 0 {
+			break
+		}
+		burn := c.RemainingCents
+		if burn > amount {
+			burn = amount
+		}
+		debits = append(debits, Debit{CreditID: c.ID, OrganizationID: c.OrganizationID, AmountCents: burn})
+		amount -= burn
+	}
+	return debits
+}
+
+// Total returns the sum of a set of debits
+func Total(debits []Debit) int64 {
+	var total int64
+	for _, d := range debits {
+		total += d.AmountCents
+	}
+	return total
+}
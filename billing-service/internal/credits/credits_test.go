@@ -0,0 +1,128 @@
+package credits
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+func at(day int) *time.Time {
+	t := time.Date(2026, 9, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestAllocateBurnsSoonestExpiryFirst(t *testing.T) {
+	periodStart := *at(1)
+	never := Credit{ID: uuid.New(), Currency: money.USD, RemainingCents: 5000, CreatedAt: *at(1)}
+	late := Credit{ID: uuid.New(), Currency: money.USD, RemainingCents: 3000, ExpiresAt: at(30), CreatedAt: *at(1)}
+	soon := Credit{ID: uuid.New(), Currency: money.USD, RemainingCents: 1000, ExpiresAt: at(10), CreatedAt: *at(1)}
+
+	tests := []struct {
+		name     string
+		amount   int64
+		expected []int64
+		order    []uuid.UUID
+	}{
+		{"covered by the soonest credit", 800, []int64{800}, []uuid.UUID{soon.ID}},
+		{"spills into later credits", 4500, []int64{1000, 3000, 500}, []uuid.UUID{soon.ID, late.ID, never.ID}},
+		{"more than every credit", 20000, []int64{1000, 3000, 5000}, []uuid.UUID{soon.ID, late.ID, never.ID}},
+		{"nothing owed", 0, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debits := Allocate([]Credit{never, late, soon}, money.USD, periodStart, tt.amount)
+			if len(debits) != len(tt.expected) {
+				t.Fatalf("Expected %d debits, got %d", len(tt.expected), len(debits))
+			}
+			for i, d := range debits {
+				if d.CreditID != tt.order[i] || d.AmountCents != tt.expected[i] {
+					t.Errorf("Debit %d: expected %d from %s, got %d from %s", i, tt.expected[i], tt.order[i], d.AmountCents, d.CreditID)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateSkipsUnusableCredits(t *testing.T) {
+	periodStart := *at(15)
+	credits := []Credit{
+		{ID: uuid.New(), Currency: money.USD, RemainingCents: 1000, ExpiresAt: at(14)},
+		{ID: uuid.New(), Currency: money.EUR, RemainingCents: 1000},
+		{ID: uuid.New(), Currency: money.USD, RemainingCents: 0},
+	}
+	if debits := Allocate(credits, money.USD, periodStart, 500); len(debits) != 0 {
+		t.Errorf("Expected expired, foreign-currency and spent credits to be skipped, got %+v", debits)
+	}
+
+	// A credit valid when the period started still covers it
+	credits[0].ExpiresAt = at(20)
+	if debits := Allocate(credits, money.USD, periodStart, 500); Total(debits) != 500 {
+		t.Errorf("Expected credit expiring mid-period to apply, got %+v", debits)
+	}
+}
+
+func TestAllocateNeverOverspends(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	bounded := func(balances []uint16, amount uint32) bool {
+		credits := make([]Credit, len(balances))
+		remaining := make(map[uuid.UUID]int64, len(balances))
+		var available int64
+		for i, b := range balances {
+			credits[i] = Credit{ID: uuid.New(), Currency: money.USD, RemainingCents: int64(b)}
+			if r.Intn(2) == 0 {
+				credits[i].ExpiresAt = at(1 + r.Intn(28))
+			}
+			remaining[credits[i].ID] = int64(b)
+			available += int64(b)
+		}
+
+		debits := Allocate(credits, money.USD, time.Time{}, int64(amount))
+		for _, d := range debits {
+			if d.AmountCents <= 0 || d.AmountCents > remaining[d.CreditID] {
+				return false
+			}
+		}
+		expected := int64(amount)
+		if available < expected {
+			expected = available
+		}
+		return Total(debits) == expected
+	}
+	if err := quick.Check(bounded, &quick.Config{Rand: r, MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreditValidate(t *testing.T) {
+	created := *at(1)
+	tests := []struct {
+		name    string
+		credit  Credit
+		wantErr bool
+	}{
+		{"promotional", Credit{Kind: Promotional, Currency: money.USD, AmountCents: 1000}, false},
+		{"sla with expiry", Credit{Kind: SLA, Currency: money.EUR, AmountCents: 1000, ExpiresAt: at(30), CreatedAt: created}, false},
+		{"unknown kind", Credit{Kind: "gift", Currency: money.USD, AmountCents: 1000}, true},
+		{"zero amount", Credit{Kind: Prepaid, Currency: money.USD}, true},
+		{"invalid currency", Credit{Kind: Prepaid, Currency: "usd", AmountCents: 1000}, true},
+		{"already expired", Credit{Kind: Prepaid, Currency: money.USD, AmountCents: 1000, ExpiresAt: &created, CreatedAt: created}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.credit.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
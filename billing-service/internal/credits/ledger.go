@@ -0,0 +1,241 @@
+package credits
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+const creditColumns = `id, organization_id, kind, currency, amount_cents, remaining_cents,
+	COALESCE(description, ''), expires_at, created_at`
+
+const debitColumns = `id, credit_id, organization_id, invoice_number, period_start, period_end, amount_cents, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCredit(row rowScanner) (Credit, error) {
+	var c Credit
+	err := row.Scan(&c.ID, &c.OrganizationID, &c.Kind, &c.Currency, &c.AmountCents, &c.RemainingCents,
+		&c.Description, &c.ExpiresAt, &c.CreatedAt)
+	return c, err
+}
+
+func scanDebit(row rowScanner) (Debit, error) {
+	var d Debit
+	err := row.Scan(&d.ID, &d.CreditID, &d.OrganizationID, &d.InvoiceNumber, &d.PeriodStart, &d.PeriodEnd,
+		&d.AmountCents, &d.CreatedAt)
+	return d, err
+}
+
+// Ledger reads and writes credits and their debits in Postgres. A nil Ledger, or one
+// without a database, holds no credits.
+type Ledger struct {
+	db *pgxpool.Pool
+}
+
+// NewLedger creates a ledger backed by the credits and credit_debits tables
+func NewLedger(db *pgxpool.Pool) *Ledger {
+	return &Ledger{db: db}
+}
+
+func (l *Ledger) available() bool {
+	return l != nil && l.db != nil
+}
+
+// Grant adds a credit to an organization
+func (l *Ledger) Grant(ctx context.Context, c Credit) (Credit, error) {
+	if !l.available() {
+		return Credit{}, fmt.Errorf("credits ledger has no database")
+	}
+	c.ID = uuid.New()
+	c.RemainingCents = c.AmountCents
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	if err := c.Validate(); err != nil {
+		return Credit{}, err
+	}
+
+	_, err := l.db.Exec(ctx,
+		`INSERT INTO credits (id, organization_id, kind, currency, amount_cents, remaining_cents, description, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		c.ID, c.OrganizationID, c.Kind, c.Currency, c.AmountCents, c.RemainingCents, c.Description, c.ExpiresAt, c.CreatedAt)
+	if err != nil {
+		return Credit{}, fmt.Errorf("failed to grant credit: %w", err)
+	}
+	return c, nil
+}
+
+// List returns an organization's credits, soonest expiry first
+func (l *Ledger) List(ctx context.Context, orgID uuid.UUID) ([]Credit, error) {
+	if !l.available() {
+		return nil, nil
+	}
+	rows, err := l.db.Query(ctx,
+		`SELECT `+creditColumns+` FROM credits WHERE organization_id = $1
+		 ORDER BY expires_at ASC NULLS LAST, created_at`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credits: %w", err)
+	}
+	defer rows.Close()
+
+	var credits []Credit
+	for rows.Next() {
+		c, err := scanCredit(rows)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, c)
+	}
+	return credits, rows.Err()
+}
+
+// Debits returns an organization's debits, newest first
+func (l *Ledger) Debits(ctx context.Context, orgID uuid.UUID) ([]Debit, error) {
+	if !l.available() {
+		return nil, nil
+	}
+	rows, err := l.db.Query(ctx,
+		`SELECT `+debitColumns+` FROM credit_debits WHERE organization_id = $1
+		 ORDER BY period_start DESC, created_at`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credit debits: %w", err)
+	}
+	defer rows.Close()
+
+	var debits []Debit
+	for rows.Next() {
+		d, err := scanDebit(rows)
+		if err != nil {
+			return nil, err
+		}
+		debits = append(debits, d)
+	}
+	return debits, rows.Err()
+}
+
+// Apply burns an organization's credits against an invoice, up to the amount owed.
+// Applying credits to the same invoice again returns the debits already recorded, so
+// a billing run can be retried without burning credits twice.
+func (l *Ledger) Apply(ctx context.Context, orgID uuid.UUID, currency money.Currency, invoiceNumber string,
+	periodStart, periodEnd time.Time, amount int64) ([]Debit, error) {
+	if !l.available() || amount <= 0 {
+		return nil, nil
+	}
+
+	tx, err := l.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize burns per organization
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('credits:' || $1))`, orgID.String()); err != nil {
+		return nil, err
+	}
+
+	debits, err := apply(ctx, tx, orgID, currency, invoiceNumber, periodStart, periodEnd, amount, true)
+	if err != nil {
+		return nil, err
+	}
+	return debits, tx.Commit(ctx)
+}
+
+// Preview returns the debits Apply would return, without burning any credits
+func (l *Ledger) Preview(ctx context.Context, orgID uuid.UUID, currency money.Currency, invoiceNumber string,
+	periodStart, periodEnd time.Time, amount int64) ([]Debit, error) {
+	if !l.available() || amount <= 0 {
+		return nil, nil
+	}
+
+	tx, err := l.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	return apply(ctx, tx, orgID, currency, invoiceNumber, periodStart, periodEnd, amount, false)
+}
+
+// apply returns the debits already recorded against an invoice or, if there are none,
+// allocates the organization's credits to it and records the debits when burn is set
+func apply(ctx context.Context, tx pgx.Tx, orgID uuid.UUID, currency money.Currency, invoiceNumber string,
+	periodStart, periodEnd time.Time, amount int64, burn bool) ([]Debit, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT `+debitColumns+` FROM credit_debits WHERE organization_id = $1 AND invoice_number = $2
+		 ORDER BY created_at`, orgID, invoiceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credit debits: %w", err)
+	}
+	var debits []Debit
+	for rows.Next() {
+		d, err := scanDebit(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		debits = append(debits, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(debits) > 0 {
+		return debits, nil
+	}
+
+	lock := " FOR UPDATE"
+	if !burn {
+		lock = ""
+	}
+	rows, err = tx.Query(ctx,
+		`SELECT `+creditColumns+` FROM credits
+		 WHERE organization_id = $1 AND remaining_cents > 0`+lock, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credits: %w", err)
+	}
+	var available []Credit
+	for rows.Next() {
+		c, err := scanCredit(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		available = append(available, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	debits = Allocate(available, currency, periodStart, amount)
+	if !burn {
+		return debits, nil
+	}
+	for i := range debits {
+		d := &debits[i]
+		d.ID = uuid.New()
+		d.InvoiceNumber = invoiceNumber
+		d.PeriodStart = periodStart
+		d.PeriodEnd = periodEnd
+		d.CreatedAt = now
+
+		if _, err := tx.Exec(ctx,
+			`UPDATE credits SET remaining_cents = remaining_cents - $2 WHERE id = $1`, d.CreditID, d.AmountCents); err != nil {
+			return nil, fmt.Errorf("failed to burn credit: %w", err)
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO credit_debits (`+debitColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			d.ID, d.CreditID, d.OrganizationID, d.InvoiceNumber, d.PeriodStart, d.PeriodEnd, d.AmountCents, d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to record credit debit: %w", err)
+		}
+	}
+	return debits, nil
+}
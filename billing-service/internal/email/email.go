@@ -0,0 +1,71 @@
+// Package email sends transactional email through the Resend API.
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://api.resend.com"
+	defaultFrom    = "Temporal Cloud <noreply@temporal.io>"
+)
+
+// Sender delivers email. A nil Sender drops every message, so email is optional in
+// development.
+type Sender struct {
+	apiKey  string
+	baseURL string
+	from    string
+	client  *http.Client
+}
+
+// NewSender creates a sender using a Resend API key and base URL
+func NewSender(apiKey, baseURL string) *Sender {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &Sender{apiKey: apiKey, baseURL: baseURL, from: defaultFrom, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NewSenderFromEnv creates a sender from RESEND_API_KEY, or nil when it is not set
+func NewSenderFromEnv() *Sender {
+	key := os.Getenv("RESEND_API_KEY")
+	if key == "" {
+		return nil
+	}
+	return NewSender(key, "")
+}
+
+// Send delivers one HTML message
+func (s *Sender) Send(ctx context.Context, to []string, subject, html string) error {
+	if s == nil || len(to) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{"from": s.from, "to": to, "subject": subject, "html": html})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/emails", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("failed to send email: status %d", resp.StatusCode)
+	}
+	return nil
+}
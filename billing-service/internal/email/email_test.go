@@ -0,0 +1,45 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/emails" || r.Header.Get("Authorization") != "Bearer re_test" {
+			t.Errorf("Expected an authorized POST to /emails, got %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	err := NewSender("re_test", server.URL).Send(context.Background(), []string{"owner@example.com"}, "Trial ending", "<p>Hi</p>")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got["subject"] != "Trial ending" || got["from"] != defaultFrom {
+		t.Errorf("Expected the message to be sent from %s, got %v", defaultFrom, got)
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	if err := NewSender("re_test", server.URL).Send(context.Background(), []string{"owner@example.com"}, "s", "b"); err == nil {
+		t.Error("Expected error, got nil")
+	}
+
+	var disabled *Sender
+	if err := disabled.Send(context.Background(), []string{"owner@example.com"}, "s", "b"); err != nil {
+		t.Errorf("Expected a nil sender to drop the message, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Package invoices keeps issued invoices and their line items. Invoices are numbered
+// per organization without gaps and can be rendered as HTML or PDF entirely offline,
+// so a download never depends on the payment provider.
+package invoices
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+var ErrNotFound = errors.New("invoice not found")
+
+// Kind records what an invoice bills for
+type Kind string
+
+const (
+	Usage  Kind = "usage"
+	TrueUp Kind = "true_up"
+	// Provider invoices were issued by the payment provider itself, such as for a
+	// subscription's price, and recorded locally by reconciliation
+	Provider Kind = "provider"
+)
+
+// LineKind records what a line item charges or deducts
+type LineKind string
+
+const (
+	LineBase     LineKind = "base"
+	LineUsage    LineKind = "usage"
+	LineDiscount LineKind = "discount"
+	LineCredit   LineKind = "credit"
+	LineTrueUp   LineKind = "true_up"
+	LineTax      LineKind = "tax"
+)
+
+// Line is one line item of an invoice. Discounts and credits are negative; taxes come
+// last, and a reverse-charged tax is a zero line carrying the customer's obligation.
+type Line struct {
+	Kind        LineKind        `json:"kind"`
+	Description string          `json:"description"`
+	Meter       pricing.Meter   `json:"meter,omitempty"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Unit        string          `json:"unit,omitempty"`
+	AmountCents int64           `json:"amount_cents"`
+}
+
+// Invoice is an issued invoice with its line items in display order
+type Invoice struct {
+	ID                uuid.UUID      `json:"id"`
+	OrganizationID    uuid.UUID      `json:"organization_id"`
+	Number            string         `json:"invoice_number"`
+	Kind              Kind           `json:"kind"`
+	ProviderInvoiceID string         `json:"provider_invoice_id,omitempty"`
+	PeriodStart       time.Time      `json:"period_start"`
+	PeriodEnd         time.Time      `json:"period_end"`
+	Currency          money.Currency `json:"currency"`
+	SubtotalCents     int64          `json:"subtotal_cents"`
+	DiscountCents     int64          `json:"discount_cents"`
+	TaxCents          int64          `json:"tax_cents"`
+	TotalCents        int64          `json:"total_cents"`
+	RefundedCents     int64          `json:"refunded_cents,omitempty"`
+	ContractID        *uuid.UUID     `json:"contract_id,omitempty"`
+	Status            string         `json:"status"`
+	DueAt             *time.Time     `json:"due_at,omitempty"`
+	PaidAt            *time.Time     `json:"paid_at,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	Lines             []Line         `json:"lines,omitempty"`
+}
+
+// FormatNumber formats the seq-th invoice of an organization, e.g. INV-1a2b3c4d-00042.
+// Five digits keep these apart from the older INV-<org>-<YYYYMM> numbers.
+func FormatNumber(orgID uuid.UUID, seq int64) string {
+	return fmt.Sprintf("INV-%s-%05d", orgID.String()[:8], seq)
+}
+
+// LinesTotal sums the line items
+func (inv Invoice) LinesTotal() int64 {
+	var total int64
+	for _, l := range inv.Lines {
+		total += l.AmountCents
+	}
+	return total
+}
+
+// Validate checks that an invoice about to be issued adds up
+func (inv Invoice) Validate() error {
+	if inv.OrganizationID == uuid.Nil {
+		return fmt.Errorf("invoice has no organization")
+	}
+	if inv.Currency == "" {
+		return fmt.Errorf("invoice has no currency")
+	}
+	if len(inv.Lines) > 0 && inv.LinesTotal() != inv.TotalCents {
+		return fmt.Errorf("invoice lines total %d, expected %d", inv.LinesTotal(), inv.TotalCents)
+	}
+	var tax int64
+	for _, l := range inv.Lines {
+		if l.Kind == LineTax {
+			tax += l.AmountCents
+		}
+	}
+	if len(inv.Lines) > 0 && tax != inv.TaxCents {
+		return fmt.Errorf("invoice tax lines total %d, expected %d", tax, inv.TaxCents)
+	}
+	return nil
+}
+
+// Party is the issuer or customer named on a rendered invoice
+type Party struct {
+	Name    string   `json:"name"`
+	Address []string `json:"address,omitempty"`
+}
+
+// Document is everything needed to render an invoice
+type Document struct {
+	Invoice  Invoice
+	Issuer   Party
+	Customer Party
+}
+
+// amount formats minor units of the invoice currency
+func (d Document) amount(cents int64) string {
+	return money.FromMinor(d.Invoice.Currency, cents).String()
+}
+
+// quantity formats a line's quantity, leaving out fixed-price lines
+func quantity(l Line) string {
+	if l.Unit == "" {
+		return ""
+	}
+	places := int32(0)
+	if !l.Quantity.IsInteger() {
+		places = 2
+	}
+	return l.Quantity.StringFixed(places) + " " + l.Unit
+}
+
+// period formats the billing period, whose end is exclusive
+func (d Document) period() string {
+	inv := d.Invoice
+	return fmt.Sprintf("%s - %s", inv.PeriodStart.Format("Jan 2, 2006"), inv.PeriodEnd.Add(-time.Nanosecond).Format("Jan 2, 2006"))
+}
@@ -0,0 +1,203 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+func testDocument() Document {
+	orgID := uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000")
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	return Document{
+		Invoice: Invoice{
+			OrganizationID: orgID,
+			Number:         FormatNumber(orgID, 7),
+			Kind:           Usage,
+			PeriodStart:    start,
+			PeriodEnd:      start.AddDate(0, 1, 0),
+			Currency:       money.EUR,
+			SubtotalCents:  11000,
+			DiscountCents:  2200,
+			TotalCents:     7800,
+			Status:         "pending",
+			CreatedAt:      time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC),
+			Lines: []Line{
+				{Kind: LineBase, Description: "Essential Plan - September 2026", Quantity: decimal.NewFromInt(1), AmountCents: 10000},
+				{Kind: LineUsage, Description: "Action Overage", Meter: pricing.MeterActions,
+					Quantity: decimal.NewFromInt(40000), Unit: "actions", AmountCents: 1000},
+				{Kind: LineDiscount, Description: "Discount (LAUNCH20)", Quantity: decimal.NewFromInt(1), AmountCents: -2200},
+				{Kind: LineCredit, Description: "Credits applied", Quantity: decimal.NewFromInt(1), AmountCents: -1000},
+			},
+		},
+		Issuer:   Party{Name: "Temporal Cloud", Address: []string{"1 Market St", "San Francisco, CA"}},
+		Customer: Party{Name: "Acme <Rockets> & Co", Address: []string{"acme-rockets"}},
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	orgID := uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000")
+	if n := FormatNumber(orgID, 42); n != "INV-1a2b3c4d-00042" {
+		t.Errorf("Expected INV-1a2b3c4d-00042, got %s", n)
+	}
+	if n := FormatNumber(orgID, 123456); n != "INV-1a2b3c4d-123456" {
+		t.Errorf("Expected INV-1a2b3c4d-123456, got %s", n)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	inv := testDocument().Invoice
+	if err := inv.Validate(); err != nil {
+		t.Errorf("Expected a balanced invoice to be valid, got %v", err)
+	}
+
+	inv.TotalCents = 8000
+	if err := inv.Validate(); err == nil {
+		t.Error("Expected lines that do not add up to the total to be rejected")
+	}
+
+	inv.TotalCents = 9282
+	inv.TaxCents = 1482
+	inv.Lines = append(inv.Lines, Line{Kind: LineTax, Description: "VAT 19% (DE)", AmountCents: 1482})
+	if err := inv.Validate(); err != nil {
+		t.Errorf("Expected tax lines to add up to the tax, got %v", err)
+	}
+
+	inv.TaxCents = 1000
+	if err := inv.Validate(); err == nil {
+		t.Error("Expected tax lines that do not add up to the tax to be rejected")
+	}
+	inv.TaxCents = 1482
+
+	inv.OrganizationID = uuid.Nil
+	if err := inv.Validate(); err == nil {
+		t.Error("Expected an invoice without an organization to be rejected")
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, testDocument()); err != nil {
+		t.Fatalf("Expected the invoice to render, got %v", err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"INV-1a2b3c4d-00007",
+		"Sep 1, 2026 - Sep 30, 2026",
+		"Acme &lt;Rockets&gt; &amp; Co",
+		"40000 actions",
+		"EUR 100.00",
+		"EUR -22.00",
+		"EUR -10.00",
+		"EUR 78.00",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected the HTML to contain %q", want)
+		}
+	}
+	if strings.Contains(html, "http") {
+		t.Error("Expected the HTML not to load external assets")
+	}
+}
+
+// pdfObjectOffsets checks the cross-reference table of a PDF against the objects it
+// points at and returns the number of objects
+func pdfObjectOffsets(t *testing.T, pdf []byte) int {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("Expected the PDF to end with startxref and EOF")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("Expected startxref to point at the xref table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Errorf("Expected object %d at offset %d", i+1, off)
+		}
+	}
+	return len(entries)
+}
+
+func TestRenderPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, testDocument()); err != nil {
+		t.Fatalf("Expected the invoice to render, got %v", err)
+	}
+	pdf := buf.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) {
+		t.Fatal("Expected a PDF header")
+	}
+	if n := pdfObjectOffsets(t, pdf); n != 7 {
+		t.Errorf("Expected 7 objects for a one-page invoice, got %d", n)
+	}
+	for _, want := range []string{"(INVOICE INV-1a2b3c4d-00007)", "(Acme <Rockets> & Co)", "(EUR 78.00)", "/Count 1"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("Expected the PDF to contain %q", want)
+		}
+	}
+}
+
+func TestRenderPDFPages(t *testing.T) {
+	d := testDocument()
+	d.Invoice.Lines = nil
+	for i := 0; i < 80; i++ {
+		d.Invoice.Lines = append(d.Invoice.Lines, Line{Kind: LineUsage, Description: fmt.Sprintf("Namespace %d actions", i), AmountCents: 100})
+	}
+
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, d); err != nil {
+		t.Fatalf("Expected the invoice to render, got %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("/Count 3")) {
+		t.Error("Expected 80 lines to take 3 pages")
+	}
+	if n := pdfObjectOffsets(t, buf.Bytes()); n != 11 {
+		t.Errorf("Expected 11 objects for a three-page invoice, got %d", n)
+	}
+	if c := bytes.Count(buf.Bytes(), []byte("(Description)")); c != 3 {
+		t.Errorf("Expected the table header on every page, got %d", c)
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{`Plan (annual)`, `Plan \(annual\)`},
+		{`C:\path`, `C:\\path`},
+		{"Caf\u00e9", `Caf\351`},
+		{"\u20ac10", `\20010`},
+		{"\u6771\u4eac", "??"},
+	}
+	for _, c := range cases {
+		if got := pdfEscape(c.in); got != c.want {
+			t.Errorf("Expected %q to escape to %q, got %q", c.in, c.want, got)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap("Action Overage (2000000 actions used, 1000000 included, 1000000 billed)", 30)
+	if len(lines) != 3 || lines[0] != "Action Overage (2000000" {
+		t.Errorf("Expected 3 lines breaking at spaces, got %q", lines)
+	}
+	if lines := wrap("", 30); len(lines) != 1 || lines[0] != "" {
+		t.Errorf("Expected one empty line, got %q", lines)
+	}
+}
@@ -0,0 +1,157 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 in points
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	pageMargin   = 50.0
+	bottomMargin = 60.0
+)
+
+type pdfFont string
+
+// The standard fonts every PDF reader has built in, so no font files are embedded
+const (
+	helvetica     pdfFont = "F1"
+	helveticaBold pdfFont = "F2"
+	courier       pdfFont = "F3"
+)
+
+// pdfWriter lays text out on pages top to bottom. It writes just enough of
+// PDF 1.4 for invoices: text in three fonts and horizontal rules.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newPDFWriter() *pdfWriter {
+	w := &pdfWriter{}
+	w.newPage()
+	return w
+}
+
+func (w *pdfWriter) newPage() {
+	w.pages = append(w.pages, &bytes.Buffer{})
+	w.y = pageHeight - pageMargin
+}
+
+func (w *pdfWriter) page() *bytes.Buffer {
+	return w.pages[len(w.pages)-1]
+}
+
+// need starts a new page unless height points are left on this one
+func (w *pdfWriter) need(height float64) {
+	if w.y-height < bottomMargin {
+		w.newPage()
+	}
+}
+
+// text writes a string with its baseline at the current line
+func (w *pdfWriter) text(x float64, font pdfFont, size float64, s string) {
+	fmt.Fprintf(w.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, w.y, pdfEscape(s))
+}
+
+// textRight writes a Courier string ending at x. Courier glyphs are 0.6 em wide,
+// which keeps amounts aligned without font metrics.
+func (w *pdfWriter) textRight(x float64, size float64, s string) {
+	w.text(x-0.6*size*float64(len([]rune(s))), courier, size, s)
+}
+
+// rule draws a horizontal line below the current line
+func (w *pdfWriter) rule() {
+	fmt.Fprintf(w.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", pageMargin, w.y-4, pageWidth-pageMargin, w.y-4)
+}
+
+// newline moves down by a line of the given height
+func (w *pdfWriter) newline(height float64) {
+	w.y -= height
+}
+
+// writeTo assembles the document: catalog, page tree, fonts, then every page and its
+// content stream, followed by the cross-reference table
+func (w *pdfWriter) writeTo(out io.Writer) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	const firstPage = 6
+	var kids []string
+	for i := range w.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, content := range w.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+// pdfEscape encodes a string for a PDF literal in WinAnsiEncoding. Characters
+// outside it are replaced with '?'.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString(`\200`)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// wrap breaks text into lines of at most width characters at spaces
+func wrap(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
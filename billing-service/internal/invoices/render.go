@@ -0,0 +1,177 @@
+package invoices
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #1a1a1a; max-width: 800px; margin: 40px auto; }
+header { display: flex; justify-content: space-between; }
+h1 { margin: 0; font-size: 28px; }
+.parties { display: flex; justify-content: space-between; margin: 32px 0; }
+.label { color: #666; font-size: 12px; text-transform: uppercase; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 8px 4px; border-bottom: 1px solid #ddd; text-align: left; }
+.num { text-align: right; font-family: Menlo, Consolas, monospace; white-space: nowrap; }
+.totals td { border: none; }
+.total td { font-weight: bold; border-top: 2px solid #1a1a1a; }
+</style>
+</head>
+<body>
+<header>
+<div><h1>{{.Issuer.Name}}</h1>{{range .Issuer.Address}}<div>{{.}}</div>{{end}}</div>
+<div class="num">
+<h1>Invoice</h1>
+<div>{{.Number}}</div>
+<div>Issued {{.Issued}}</div>
+<div>Period {{.Period}}</div>
+<div>Status {{.Status}}</div>
+</div>
+</header>
+<div class="parties">
+<div><div class="label">Bill to</div><strong>{{.Customer.Name}}</strong>{{range .Customer.Address}}<div>{{.}}</div>{{end}}</div>
+</div>
+<table>
+<thead><tr><th>Description</th><th class="num">Quantity</th><th class="num">Amount</th></tr></thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="num">{{.Quantity}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</tbody>
+<tfoot>
+{{range .Totals}}<tr class="totals{{if .Grand}} total{{end}}"><td></td><td class="num">{{.Label}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</tfoot>
+</table>
+</body>
+</html>
+`))
+
+type lineView struct {
+	Description string
+	Quantity    string
+	Amount      string
+}
+
+type totalView struct {
+	Label  string
+	Amount string
+	Grand  bool
+}
+
+// view is a document with every value formatted for display
+type view struct {
+	Number   string
+	Issued   string
+	Period   string
+	Status   string
+	Issuer   Party
+	Customer Party
+	Lines    []lineView
+	Totals   []totalView
+}
+
+func (d Document) view() view {
+	inv := d.Invoice
+	v := view{
+		Number:   inv.Number,
+		Issued:   inv.CreatedAt.Format("Jan 2, 2006"),
+		Period:   d.period(),
+		Status:   strings.ReplaceAll(inv.Status, "_", " "),
+		Issuer:   d.Issuer,
+		Customer: d.Customer,
+	}
+	for _, l := range inv.Lines {
+		v.Lines = append(v.Lines, lineView{Description: l.Description, Quantity: quantity(l), Amount: d.amount(l.AmountCents)})
+	}
+
+	v.Totals = append(v.Totals, totalView{Label: "Subtotal", Amount: d.amount(inv.SubtotalCents)})
+	if inv.DiscountCents != 0 {
+		v.Totals = append(v.Totals, totalView{Label: "Discount", Amount: d.amount(-inv.DiscountCents)})
+	}
+	if credits := inv.TotalCents - inv.TaxCents - inv.SubtotalCents + inv.DiscountCents; credits != 0 {
+		v.Totals = append(v.Totals, totalView{Label: "Credits", Amount: d.amount(credits)})
+	}
+	if inv.TaxCents != 0 {
+		v.Totals = append(v.Totals, totalView{Label: "Tax", Amount: d.amount(inv.TaxCents)})
+	}
+	v.Totals = append(v.Totals, totalView{Label: "Total due", Amount: d.amount(inv.TotalCents), Grand: true})
+	return v
+}
+
+// RenderHTML writes the invoice as a standalone HTML page with no external assets
+func RenderHTML(w io.Writer, d Document) error {
+	return htmlTemplate.Execute(w, d.view())
+}
+
+// RenderPDF writes the invoice as an A4 PDF using only the fonts built into PDF readers
+func RenderPDF(w io.Writer, d Document) error {
+	v := d.view()
+	pdf := newPDFWriter()
+	left, right := pageMargin, pageWidth-pageMargin
+	qtyRight := right - 120
+
+	pdf.text(left, helveticaBold, 18, v.Issuer.Name)
+	pdf.textRight(right, 10, "INVOICE "+v.Number)
+	for _, line := range []string{"Issued " + v.Issued, "Period " + v.Period, "Status " + v.Status} {
+		pdf.newline(14)
+		pdf.textRight(right, 9, line)
+	}
+	for _, line := range v.Issuer.Address {
+		pdf.newline(12)
+		pdf.text(left, helvetica, 9, line)
+	}
+
+	pdf.newline(36)
+	pdf.text(left, helveticaBold, 9, "BILL TO")
+	pdf.newline(14)
+	pdf.text(left, helvetica, 11, v.Customer.Name)
+	for _, line := range v.Customer.Address {
+		pdf.newline(12)
+		pdf.text(left, helvetica, 9, line)
+	}
+
+	header := func() {
+		pdf.text(left, helveticaBold, 9, "Description")
+		pdf.text(qtyRight-60, helveticaBold, 9, "Quantity")
+		pdf.text(right-40, helveticaBold, 9, "Amount")
+		pdf.rule()
+		pdf.newline(18)
+	}
+	pdf.newline(36)
+	header()
+	for _, l := range v.Lines {
+		desc := wrap(l.Description, 55)
+		pages := len(pdf.pages)
+		pdf.need(float64(len(desc)) * 12)
+		if len(pdf.pages) != pages {
+			header()
+		}
+		pdf.textRight(qtyRight, 9, l.Quantity)
+		pdf.textRight(right, 9, l.Amount)
+		for i, part := range desc {
+			if i > 0 {
+				pdf.newline(12)
+			}
+			pdf.text(left, helvetica, 9, part)
+		}
+		pdf.newline(16)
+	}
+
+	pdf.need(float64(len(v.Totals))*16 + 8)
+	pdf.newline(8)
+	for _, t := range v.Totals {
+		font := helvetica
+		if t.Grand {
+			font = helveticaBold
+		}
+		pdf.text(qtyRight-60, font, 10, t.Label)
+		pdf.textRight(right, 10, t.Amount)
+		pdf.newline(16)
+	}
+	return pdf.writeTo(w)
+}
@@ -0,0 +1,283 @@
+package invoices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const invoiceColumns = `id, organization_id, invoice_number, kind, COALESCE(stripe_invoice_id, ''), period_start, period_end,
+	currency, COALESCE(subtotal_cents, 0), COALESCE(discount_cents, 0), COALESCE(tax_cents, 0), COALESCE(total_cents, 0),
+	refunded_cents, contract_id, COALESCE(status, 'draft'), due_at, paid_at, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvoice(row rowScanner) (Invoice, error) {
+	var inv Invoice
+	err := row.Scan(&inv.ID, &inv.OrganizationID, &inv.Number, &inv.Kind, &inv.ProviderInvoiceID, &inv.PeriodStart, &inv.PeriodEnd,
+		&inv.Currency, &inv.SubtotalCents, &inv.DiscountCents, &inv.TaxCents, &inv.TotalCents,
+		&inv.RefundedCents, &inv.ContractID, &inv.Status, &inv.DueAt, &inv.PaidAt, &inv.CreatedAt)
+	return inv, err
+}
+
+// Store reads and writes invoices in Postgres. A nil Store, or one without a
+// database, has no invoices.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the invoices and invoice_line_items tables
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+// Create issues an invoice under the organization's next invoice number. The number
+// is taken in the same transaction as the invoice, so numbers have no gaps.
+func (s *Store) Create(ctx context.Context, inv Invoice) (Invoice, error) {
+	if !s.available() {
+		return Invoice{}, fmt.Errorf("invoice store has no database")
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Invoice{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	inv, err = s.CreateTx(ctx, tx, inv)
+	if err != nil {
+		return Invoice{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Invoice{}, err
+	}
+	return inv, nil
+}
+
+// CreateTx issues an invoice as Create does, inside a transaction the caller commits
+// along with whatever else the invoice records
+func (s *Store) CreateTx(ctx context.Context, tx pgx.Tx, inv Invoice) (Invoice, error) {
+	if err := inv.Validate(); err != nil {
+		return Invoice{}, err
+	}
+	if inv.Kind == "" {
+		inv.Kind = Usage
+	}
+	if inv.Status == "" {
+		inv.Status = "draft"
+	}
+	inv.ID = uuid.New()
+	inv.CreatedAt = time.Now()
+
+	var seq int64
+	err := tx.QueryRow(ctx,
+		`INSERT INTO invoice_sequences (organization_id, last_number) VALUES ($1, 1)
+		 ON CONFLICT (organization_id) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		 RETURNING last_number`, inv.OrganizationID).Scan(&seq)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to number invoice: %w", err)
+	}
+	inv.Number = FormatNumber(inv.OrganizationID, seq)
+
+	var providerID *string
+	if inv.ProviderInvoiceID != "" {
+		providerID = &inv.ProviderInvoiceID
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO invoices (id, organization_id, invoice_number, kind, stripe_invoice_id, period_start, period_end,
+		                       currency, subtotal_cents, discount_cents, tax_cents, total_cents, contract_id, status,
+		                       due_at, paid_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		inv.ID, inv.OrganizationID, inv.Number, inv.Kind, providerID, inv.PeriodStart, inv.PeriodEnd,
+		inv.Currency, inv.SubtotalCents, inv.DiscountCents, inv.TaxCents, inv.TotalCents, inv.ContractID, inv.Status,
+		inv.DueAt, inv.PaidAt, inv.CreatedAt)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to store invoice: %w", err)
+	}
+
+	for i, l := range inv.Lines {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO invoice_line_items (id, invoice_id, position, kind, description, meter, quantity, unit, amount_cents)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			uuid.New(), inv.ID, i, l.Kind, l.Description, l.Meter, l.Quantity, l.Unit, l.AmountCents)
+		if err != nil {
+			return Invoice{}, fmt.Errorf("failed to store invoice line: %w", err)
+		}
+	}
+	return inv, nil
+}
+
+// Get returns an invoice with its line items
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (Invoice, error) {
+	if !s.available() {
+		return Invoice{}, ErrNotFound
+	}
+	inv, err := scanInvoice(s.db.QueryRow(ctx, `SELECT `+invoiceColumns+` FROM invoices WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Invoice{}, ErrNotFound
+	}
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to read invoice: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT kind, description, COALESCE(meter, ''), quantity, COALESCE(unit, ''), amount_cents
+		 FROM invoice_line_items WHERE invoice_id = $1 ORDER BY position`, id)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to read invoice lines: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l Line
+		if err := rows.Scan(&l.Kind, &l.Description, &l.Meter, &l.Quantity, &l.Unit, &l.AmountCents); err != nil {
+			return Invoice{}, err
+		}
+		inv.Lines = append(inv.Lines, l)
+	}
+	return inv, rows.Err()
+}
+
+// ForPeriod returns an organization's invoice of a kind for the period starting at
+// periodStart, without its lines
+func (s *Store) ForPeriod(ctx context.Context, orgID uuid.UUID, kind Kind, periodStart time.Time) (Invoice, error) {
+	if !s.available() {
+		return Invoice{}, ErrNotFound
+	}
+	inv, err := scanInvoice(s.db.QueryRow(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices
+		 WHERE organization_id = $1 AND kind = $2 AND period_start = $3
+		 ORDER BY created_at LIMIT 1`, orgID, kind, periodStart))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Invoice{}, ErrNotFound
+	}
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to read invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// ForContract returns a contract's invoice of a kind, without its lines
+func (s *Store) ForContract(ctx context.Context, contractID uuid.UUID, kind Kind) (Invoice, error) {
+	if !s.available() {
+		return Invoice{}, ErrNotFound
+	}
+	inv, err := scanInvoice(s.db.QueryRow(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices
+		 WHERE contract_id = $1 AND kind = $2
+		 ORDER BY created_at LIMIT 1`, contractID, kind))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Invoice{}, ErrNotFound
+	}
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to read invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// List returns an organization's most recent invoices without their line items
+func (s *Store) List(ctx context.Context, orgID uuid.UUID, limit int) ([]Invoice, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE organization_id = $1 ORDER BY created_at DESC LIMIT $2`, orgID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+// Since returns an organization's invoices created since a time, without their line
+// items, oldest first
+func (s *Store) Since(ctx context.Context, orgID uuid.UUID, since time.Time) ([]Invoice, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE organization_id = $1 AND created_at >= $2 ORDER BY created_at`, orgID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+// SetStatus changes the status of an invoice, recording when it was paid
+func (s *Store) SetStatus(ctx context.Context, id uuid.UUID, status string, paidAt *time.Time) error {
+	if !s.available() {
+		return fmt.Errorf("invoice store has no database")
+	}
+	tag, err := s.db.Exec(ctx,
+		`UPDATE invoices SET status = $2, paid_at = COALESCE($3, paid_at) WHERE id = $1`, id, status, paidAt)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ByProviderID returns the invoice the payment provider knows by an ID, without its lines
+func (s *Store) ByProviderID(ctx context.Context, providerInvoiceID string) (Invoice, error) {
+	if !s.available() {
+		return Invoice{}, fmt.Errorf("invoice store has no database")
+	}
+	inv, err := scanInvoice(s.db.QueryRow(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE stripe_invoice_id = $1 ORDER BY created_at LIMIT 1`, providerInvoiceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Invoice{}, ErrNotFound
+	}
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to read invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// SetRefunded records how much of an invoice has been refunded in all. An invoice
+// refunded in full is marked refunded.
+func (s *Store) SetRefunded(ctx context.Context, id uuid.UUID, refundedCents int64) error {
+	if !s.available() {
+		return fmt.Errorf("invoice store has no database")
+	}
+	tag, err := s.db.Exec(ctx,
+		`UPDATE invoices SET refunded_cents = $2,
+		        status = CASE WHEN $2 >= COALESCE(total_cents, 0) THEN 'refunded' ELSE status END
+		 WHERE id = $1`, id, refundedCents)
+	if err != nil {
+		return fmt.Errorf("failed to record invoice refund: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
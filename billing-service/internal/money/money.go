@@ -0,0 +1,172 @@
+// Package money represents monetary amounts in exact decimal arithmetic. Amounts
+// are held in the minor unit of their currency (cents for USD) and keep sub-cent
+// precision until an invoice rounds them under an explicit rounding policy.
+package money
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+)
+
+// ParseCurrency normalizes a currency code. An empty string selects USD.
+func ParseCurrency(s string) (Currency, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if code == "" {
+		return USD, nil
+	}
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency code %q", s)
+	}
+	return Currency(code), nil
+}
+
+// Currencies whose minor unit is not a hundredth of the major unit
+var minorExponents = map[Currency]int32{
+	"JPY": 0,
+	"KRW": 0,
+}
+
+// Exponent returns the number of decimal places between major and minor units
+func (c Currency) Exponent() int32 {
+	if e, ok := minorExponents[c]; ok {
+		return e
+	}
+	return 2
+}
+
+// Money is an amount in the minor unit of a currency
+type Money struct {
+	Currency Currency        `json:"currency"`
+	Minor    decimal.Decimal `json:"minor"`
+}
+
+// New returns an amount of minor units, which may be fractional
+func New(c Currency, minor decimal.Decimal) Money {
+	return Money{Currency: c, Minor: minor}
+}
+
+// FromMinor returns a whole number of minor units
+func FromMinor(c Currency, minor int64) Money {
+	return Money{Currency: c, Minor: decimal.NewFromInt(minor)}
+}
+
+// Zero returns no money in a currency
+func Zero(c Currency) Money {
+	return Money{Currency: c, Minor: decimal.Zero}
+}
+
+func (m Money) mustMatch(o Money) {
+	if m.Currency != o.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, o.Currency))
+	}
+}
+
+// Add returns m + o. Both amounts must be in the same currency.
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return Money{Currency: m.Currency, Minor: m.Minor.Add(o.Minor)}
+}
+
+// Sub returns m - o. Both amounts must be in the same currency.
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return Money{Currency: m.Currency, Minor: m.Minor.Sub(o.Minor)}
+}
+
+// Mul scales an amount
+func (m Money) Mul(d decimal.Decimal) Money {
+	return Money{Currency: m.Currency, Minor: m.Minor.Mul(d)}
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Minor.IsZero()
+}
+
+// MinorUnits returns the whole minor units of a rounded amount
+func (m Money) MinorUnits() int64 {
+	return m.Minor.IntPart()
+}
+
+// String formats the amount in major units, e.g. "USD 12.34"
+func (m Money) String() string {
+	major := m.Minor.Shift(-m.Currency.Exponent())
+	return fmt.Sprintf("%s %s", m.Currency, major.StringFixed(m.Currency.Exponent()))
+}
+
+// Rounding selects how line amounts are rounded to whole minor units
+type Rounding string
+
+const (
+	// PerLine rounds every line half away from zero
+	PerLine Rounding = "per_line"
+
+	// PerInvoice rounds the exact total once, then allocates it across the lines by
+	// largest remainder so the lines still add up to it
+	PerInvoice Rounding = "per_invoice"
+
+	// Bankers rounds every line half to even
+	Bankers Rounding = "bankers"
+)
+
+// ParseRounding parses a rounding mode. An empty string selects PerLine.
+func ParseRounding(s string) (Rounding, error) {
+	switch r := Rounding(strings.ToLower(strings.TrimSpace(s))); r {
+	case "":
+		return PerLine, nil
+	case PerLine, PerInvoice, Bankers:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown rounding mode %q (want %s, %s or %s)", s, PerLine, PerInvoice, Bankers)
+}
+
+// RoundLines rounds each line to whole minor units. The rounded lines always add
+// up to the returned total.
+func RoundLines(c Currency, lines []Money, mode Rounding) ([]Money, Money) {
+	rounded := make([]Money, len(lines))
+	total := Zero(c)
+
+	if mode != PerInvoice {
+		for i, l := range lines {
+			minor := l.Minor.Round(0)
+			if mode == Bankers {
+				minor = l.Minor.RoundBank(0)
+			}
+			rounded[i] = Money{Currency: c, Minor: minor}
+			total = total.Add(rounded[i])
+		}
+		return rounded, total
+	}
+
+	exact := Zero(c)
+	floored := decimal.Zero
+	order := make([]int, len(lines))
+	for i, l := range lines {
+		exact = exact.Add(l)
+		rounded[i] = Money{Currency: c, Minor: l.Minor.Floor()}
+		floored = floored.Add(rounded[i].Minor)
+		order[i] = i
+	}
+	total = Money{Currency: c, Minor: exact.Minor.Round(0)}
+
+	// Hand the units lost to flooring back to the lines that lost the most
+	remainder := func(i int) decimal.Decimal { return lines[i].Minor.Sub(rounded[i].Minor) }
+	sort.SliceStable(order, func(a, b int) bool { return remainder(order[a]).GreaterThan(remainder(order[b])) })
+	for n, k := total.Minor.Sub(floored).IntPart(), 0; k < int(n) && k < len(order); k++ {
+		i := order[k]
+		rounded[i].Minor = rounded[i].Minor.Add(decimal.NewFromInt(1))
+	}
+	return rounded, total
+}
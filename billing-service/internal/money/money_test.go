@@ -0,0 +1,149 @@
+package money
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/shopspring/decimal"
+)
+
+func cents(values ...string) []Money {
+	lines := make([]Money, len(values))
+	for i, v := range values {
+		lines[i] = New(USD, decimal.RequireFromString(v))
+	}
+	return lines
+}
+
+func TestRoundLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          Rounding
+		lines         []Money
+		expectedLines []int64
+		expectedTotal int64
+	}{
+		{"per line rounds halves up", PerLine, cents("0.5", "0.5", "0.5"), []int64{1, 1, 1}, 3},
+		{"bankers rounds halves to even", Bankers, cents("0.5", "1.5", "2.5"), []int64{0, 2, 2}, 4},
+		{"per invoice rounds the total once", PerInvoice, cents("0.5", "0.5", "0.5"), []int64{1, 1, 0}, 2},
+		{"per invoice favours largest remainder", PerInvoice, cents("10.2", "3.7", "0.4"), []int64{10, 4, 0}, 14},
+		{"whole cents are unchanged", PerInvoice, cents("10000", "2500"), []int64{10000, 2500}, 12500},
+		{"empty invoice", PerLine, nil, []int64{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounded, total := RoundLines(USD, tt.lines, tt.mode)
+			if total.MinorUnits() != tt.expectedTotal {
+				t.Errorf("Expected total %d, got %d", tt.expectedTotal, total.MinorUnits())
+			}
+			for i, expected := range tt.expectedLines {
+				if rounded[i].MinorUnits() != expected {
+					t.Errorf("Line %d: expected %d, got %s", i, expected, rounded[i].Minor)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundLinesTotalsEqualSumOfLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	modes := []Rounding{PerLine, PerInvoice, Bankers}
+
+	sums := func(raw []int32) bool {
+		lines := make([]Money, len(raw))
+		exact := decimal.Zero
+		for i, v := range raw {
+			// Amounts with up to four sub-cent digits, some negative
+			lines[i] = New(USD, decimal.New(int64(v), -4))
+			exact = exact.Add(lines[i].Minor)
+		}
+		mode := modes[r.Intn(len(modes))]
+		rounded, total := RoundLines(USD, lines, mode)
+
+		sum := Zero(USD)
+		for _, l := range rounded {
+			if !l.Minor.Equal(l.Minor.Truncate(0)) {
+				return false
+			}
+			sum = sum.Add(l)
+		}
+		if mode == PerInvoice && !total.Minor.Equal(exact.Round(0)) {
+			return false
+		}
+		return sum.Minor.Equal(total.Minor)
+	}
+	if err := quick.Check(sums, &quick.Config{Rand: r, MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseRounding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Rounding
+		wantErr  bool
+	}{
+		{"", PerLine, false},
+		{"per_invoice", PerInvoice, false},
+		{" Bankers ", Bankers, false},
+		{"truncate", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRounding(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	if s := FromMinor(USD, 12345).String(); s != "USD 123.45" {
+		t.Errorf("Expected USD 123.45, got %s", s)
+	}
+	if s := FromMinor("JPY", 500).String(); s != "JPY 500" {
+		t.Errorf("Expected JPY 500, got %s", s)
+	}
+}
+
+func TestAddRejectsMixedCurrencies(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected adding USD to EUR to panic")
+		}
+	}()
+	FromMinor(USD, 1).Add(FromMinor("EUR", 1))
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Currency
+		wantErr  bool
+	}{
+		{"", USD, false},
+		{"eur", EUR, false},
+		{" GBP ", GBP, false},
+		{"EURO", "", true},
+		{"€", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCurrency(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+)
+
+// FakeSignatureHeader carries the signature of a fake webhook
+const FakeSignatureHeader = "Fake-Signature"
+
+// Fake is an in-memory payment provider. It keeps everything in memory and delivers
+// webhook events synchronously to a handler, so billing flows run without a network.
+// Payments are settled explicitly with PayInvoice, FailInvoice, RefundInvoice,
+// CompleteCheckout and SetSubscriptionStatus.
+type Fake struct {
+	mu            sync.Mutex
+	webhookSecret string
+	handler       http.Handler
+	next          int
+
+	customers     map[string]CustomerParams
+	subscriptions map[string]Subscription
+	invoices      map[string]Invoice
+	invoiceItems  map[string][]InvoiceItem
+	invoiceKeys   map[string]string
+	refunds       map[string]int64
+	checkouts     map[string]CheckoutParams
+	coupons       map[string]coupons.Coupon
+	events        []Event
+}
+
+// NewFake creates an empty fake provider that signs webhooks with a secret
+func NewFake(webhookSecret string) *Fake {
+	return &Fake{
+		webhookSecret: webhookSecret,
+		customers:     map[string]CustomerParams{},
+		subscriptions: map[string]Subscription{},
+		invoices:      map[string]Invoice{},
+		invoiceItems:  map[string][]InvoiceItem{},
+		invoiceKeys:   map[string]string{},
+		refunds:       map[string]int64{},
+		checkouts:     map[string]CheckoutParams{},
+		coupons:       map[string]coupons.Coupon{},
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// SetWebhookHandler sets the handler webhook events are delivered to
+func (f *Fake) SetWebhookHandler(h http.Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handler = h
+}
+
+// Events returns every event the fake has emitted, oldest first
+func (f *Fake) Events() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Event(nil), f.events...)
+}
+
+// InvoiceItems returns the items of an invoice
+func (f *Fake) InvoiceItems(invoiceID string) []InvoiceItem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]InvoiceItem(nil), f.invoiceItems[invoiceID]...)
+}
+
+// id returns a new ID with a Stripe-like prefix. Callers hold f.mu.
+func (f *Fake) id(prefix string) string {
+	f.next++
+	return fmt.Sprintf("%s_fake_%d", prefix, f.next)
+}
+
+// record queues an event for delivery. Callers hold f.mu.
+func (f *Fake) record(e Event) Event {
+	e.ID = f.id("evt")
+	f.events = append(f.events, e)
+	return e
+}
+
+// deliver posts an event to the webhook handler. It is called without f.mu held, so
+// the handler may call back into the fake.
+func (f *Fake) deliver(e Event) error {
+	f.mu.Lock()
+	h := f.handler
+	f.mu.Unlock()
+	if h == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/fake", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(FakeSignatureHeader, f.sign(payload))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code >= 300 {
+		return fmt.Errorf("webhook %s returned %d: %s", e.Type, rec.Code, rec.Body.String())
+	}
+	return nil
+}
+
+func (f *Fake) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.webhookSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) CreateCustomer(ctx context.Context, params CustomerParams) (Customer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.id("cus")
+	f.customers[id] = params
+	return Customer{ID: id}, nil
+}
+
+func (f *Fake) CreateSubscription(ctx context.Context, params SubscriptionParams) (Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.createSubscription(params)
+}
+
+// createSubscription starts a subscription period now. Callers hold f.mu.
+func (f *Fake) createSubscription(params SubscriptionParams) (Subscription, error) {
+	if _, ok := f.customers[params.CustomerID]; !ok {
+		return Subscription{}, fmt.Errorf("%w: customer %s", ErrNotFound, params.CustomerID)
+	}
+	now := time.Now().UTC()
+	sub := Subscription{
+		ID:                 f.id("sub"),
+		CustomerID:         params.CustomerID,
+		PriceID:            params.PriceID,
+		Status:             StatusActive,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   now.AddDate(0, 1, 0),
+		BillingCycleAnchor: now,
+	}
+	if params.TrialEnd != nil {
+		end := *params.TrialEnd
+		sub.Status, sub.TrialEnd = StatusTrialing, &end
+	}
+	f.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (f *Fake) GetSubscription(ctx context.Context, id string) (Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		return Subscription{}, fmt.Errorf("%w: subscription %s", ErrNotFound, id)
+	}
+	return sub, nil
+}
+
+func (f *Fake) UpdateSubscription(ctx context.Context, id string, params SubscriptionParams) (Subscription, error) {
+	f.mu.Lock()
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		f.mu.Unlock()
+		return Subscription{}, fmt.Errorf("%w: subscription %s", ErrNotFound, id)
+	}
+	if sub.Ended() {
+		f.mu.Unlock()
+		return Subscription{}, fmt.Errorf("subscription %s is %s", id, sub.Status)
+	}
+	sub.PriceID = params.PriceID
+	switch {
+	case params.TrialEnd != nil:
+		end := *params.TrialEnd
+		sub.Status, sub.TrialEnd = StatusTrialing, &end
+	case params.EndTrialNow && sub.Status == StatusTrialing:
+		now := time.Now().UTC()
+		sub.Status, sub.TrialEnd = StatusActive, &now
+	}
+	f.subscriptions[id] = sub
+	e := f.record(Event{Type: EventSubscriptionUpdated, Subscription: &sub})
+	f.mu.Unlock()
+
+	f.deliver(e)
+	return sub, nil
+}
+
+func (f *Fake) CancelSubscription(ctx context.Context, id string) error {
+	f.mu.Lock()
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: subscription %s", ErrNotFound, id)
+	}
+	sub.Status = StatusCanceled
+	f.subscriptions[id] = sub
+	e := f.record(Event{Type: EventSubscriptionDeleted, Subscription: &sub})
+	f.mu.Unlock()
+
+	f.deliver(e)
+	return nil
+}
+
+// SetSubscriptionStatus changes a subscription's status, as the provider does when a
+// trial ends or a payment fails, and delivers the update
+func (f *Fake) SetSubscriptionStatus(id, status string) error {
+	f.mu.Lock()
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: subscription %s", ErrNotFound, id)
+	}
+	sub.Status = status
+	f.subscriptions[id] = sub
+	eventType := EventSubscriptionUpdated
+	if status == StatusCanceled {
+		eventType = EventSubscriptionDeleted
+	}
+	e := f.record(Event{Type: eventType, Subscription: &sub})
+	f.mu.Unlock()
+
+	return f.deliver(e)
+}
+
+func (f *Fake) CreateInvoice(ctx context.Context, params InvoiceParams) (Invoice, error) {
+	f.mu.Lock()
+	if _, ok := f.customers[params.CustomerID]; !ok {
+		f.mu.Unlock()
+		return Invoice{}, fmt.Errorf("%w: customer %s", ErrNotFound, params.CustomerID)
+	}
+	if id, ok := f.invoiceKeys[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		inv := f.invoices[id]
+		f.mu.Unlock()
+		return inv, nil
+	}
+	now := time.Now().UTC()
+	inv := Invoice{ID: f.id("in"), CustomerID: params.CustomerID, Currency: params.Currency, Status: "open",
+		PeriodStart: now, PeriodEnd: now, CreatedAt: now}
+	inv.Number = inv.ID
+	if params.IdempotencyKey != "" {
+		f.invoiceKeys[params.IdempotencyKey] = inv.ID
+	}
+	for _, item := range params.Items {
+		inv.TotalCents += item.AmountCents
+	}
+	inv.SubtotalCents = inv.TotalCents
+	f.invoices[inv.ID] = inv
+	f.invoiceItems[inv.ID] = append([]InvoiceItem(nil), params.Items...)
+	e := f.record(Event{Type: EventInvoiceFinalized, Invoice: &inv})
+	f.mu.Unlock()
+
+	f.deliver(e)
+	return inv, nil
+}
+
+// ListInvoices returns the customer's invoices created since a time, newest first
+func (f *Fake) ListInvoices(ctx context.Context, customerID string, since time.Time) ([]Invoice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []Invoice
+	for _, inv := range f.invoices {
+		if inv.CustomerID == customerID && !inv.CreatedAt.Before(since) {
+			list = append(list, inv)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].ID, list[j].ID) })
+	return list, nil
+}
+
+// ListSubscriptions returns every subscription of the customer
+func (f *Fake) ListSubscriptions(ctx context.Context, customerID string) ([]Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []Subscription
+	for _, sub := range f.subscriptions {
+		if sub.CustomerID == customerID {
+			list = append(list, sub)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].ID, list[j].ID) })
+	return list, nil
+}
+
+// newer reports whether the fake created object a after b
+func newer(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// SetInvoice replaces an invoice without delivering a webhook, as if the event was lost
+func (f *Fake) SetInvoice(inv Invoice) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invoices[inv.ID] = inv
+}
+
+// PayInvoice collects an invoice and delivers invoice.paid
+func (f *Fake) PayInvoice(id string) error {
+	return f.settleInvoice(id, "paid", EventInvoicePaid)
+}
+
+// FailInvoice fails to collect an invoice and delivers invoice.payment_failed
+func (f *Fake) FailInvoice(id string) error {
+	return f.settleInvoice(id, "open", EventInvoicePaymentFailed)
+}
+
+// RefundInvoice refunds part or all of a paid invoice and delivers charge.refunded.
+// The invoice stays paid, as at Stripe.
+func (f *Fake) RefundInvoice(id string, amountCents int64) error {
+	f.mu.Lock()
+	inv, ok := f.invoices[id]
+	if !ok || inv.Status != "paid" {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: paid invoice %s", ErrNotFound, id)
+	}
+	f.refunds[id] = min(f.refunds[id]+amountCents, inv.TotalCents)
+	ch := Charge{ID: "ch_" + id, CustomerID: inv.CustomerID, InvoiceID: id, Currency: inv.Currency,
+		AmountCents: inv.TotalCents, RefundedCents: f.refunds[id]}
+	e := f.record(Event{Type: EventChargeRefunded, Charge: &ch})
+	f.mu.Unlock()
+
+	return f.deliver(e)
+}
+
+func (f *Fake) settleInvoice(id, status, eventType string) error {
+	f.mu.Lock()
+	inv, ok := f.invoices[id]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: invoice %s", ErrNotFound, id)
+	}
+	inv.Status = status
+	if status == "paid" {
+		paid := time.Now().UTC()
+		inv.PaidAt = &paid
+	}
+	f.invoices[id] = inv
+	e := f.record(Event{Type: eventType, Invoice: &inv})
+	f.mu.Unlock()
+
+	return f.deliver(e)
+}
+
+func (f *Fake) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (CheckoutSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.id("cs")
+	f.checkouts[id] = params
+	return CheckoutSession{
+		ID:         id,
+		URL:        "https://checkout.fake.invalid/" + id,
+		CustomerID: params.CustomerID,
+		Metadata:   params.Metadata,
+	}, nil
+}
+
+// CompleteCheckout pays for a checkout session, creating its customer if it had none
+// and its subscription, and delivers checkout.session.completed
+func (f *Fake) CompleteCheckout(id string) (CheckoutSession, error) {
+	f.mu.Lock()
+	params, ok := f.checkouts[id]
+	if !ok {
+		f.mu.Unlock()
+		return CheckoutSession{}, fmt.Errorf("%w: checkout session %s", ErrNotFound, id)
+	}
+	delete(f.checkouts, id)
+
+	if params.CustomerID == "" {
+		params.CustomerID = f.id("cus")
+		f.customers[params.CustomerID] = CustomerParams{}
+	}
+	sp := SubscriptionParams{CustomerID: params.CustomerID, PriceID: params.PriceID}
+	if params.TrialDays > 0 {
+		end := time.Now().UTC().AddDate(0, 0, params.TrialDays)
+		sp.TrialEnd = &end
+	}
+	sub, err := f.createSubscription(sp)
+	if err != nil {
+		f.mu.Unlock()
+		return CheckoutSession{}, err
+	}
+	cs := CheckoutSession{
+		ID:             id,
+		URL:            "https://checkout.fake.invalid/" + id,
+		CustomerID:     params.CustomerID,
+		SubscriptionID: sub.ID,
+		Metadata:       params.Metadata,
+	}
+	e := f.record(Event{Type: EventCheckoutCompleted, Checkout: &cs})
+	f.mu.Unlock()
+
+	return cs, f.deliver(e)
+}
+
+func (f *Fake) CreateCoupon(ctx context.Context, c coupons.Coupon) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.coupons[c.Code]; ok {
+		return "", fmt.Errorf("coupon %s already exists", c.Code)
+	}
+	f.coupons[c.Code] = c
+	return c.Code, nil
+}
+
+func (f *Fake) ParseWebhook(payload []byte, header http.Header) (Event, error) {
+	if !hmac.Equal([]byte(header.Get(FakeSignatureHeader)), []byte(f.sign(payload))) {
+		return Event{}, ErrInvalidSignature
+	}
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return Event{}, err
+	}
+	return e, nil
+}
@@ -0,0 +1,250 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// recorder is a webhook endpoint that verifies and keeps the events it receives
+type recorder struct {
+	provider Provider
+	events   []Event
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	payload, _ := io.ReadAll(req.Body)
+	e, err := r.provider.ParseWebhook(payload, req.Header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) types() []string {
+	var types []string
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestFakeSubscriptionFlow(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("whsec_test")
+	hook := &recorder{provider: fake}
+	fake.SetWebhookHandler(hook)
+
+	cust, err := fake.CreateCustomer(ctx, CustomerParams{Name: "Acme", Email: "billing@acme.test"})
+	if err != nil {
+		t.Fatalf("Expected a customer, got %v", err)
+	}
+
+	trialEnd := time.Now().Add(14 * 24 * time.Hour)
+	sub, err := fake.CreateSubscription(ctx, SubscriptionParams{CustomerID: cust.ID, PriceID: "price_essential", TrialEnd: &trialEnd})
+	if err != nil {
+		t.Fatalf("Expected a subscription, got %v", err)
+	}
+	if sub.Status != StatusTrialing {
+		t.Errorf("Expected a trialing subscription, got %s", sub.Status)
+	}
+
+	sub, err = fake.UpdateSubscription(ctx, sub.ID, SubscriptionParams{PriceID: "price_business", EndTrialNow: true})
+	if err != nil {
+		t.Fatalf("Expected the subscription to update, got %v", err)
+	}
+	if sub.Status != StatusActive || sub.PriceID != "price_business" {
+		t.Errorf("Expected an active business subscription, got %s on %s", sub.Status, sub.PriceID)
+	}
+
+	inv, err := fake.CreateInvoice(ctx, InvoiceParams{
+		CustomerID: cust.ID,
+		Currency:   money.USD,
+		Items:      []InvoiceItem{{AmountCents: 50000, Description: "Business Plan"}, {AmountCents: -5000, Description: "Credits applied"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected an invoice, got %v", err)
+	}
+	if inv.TotalCents != 45000 || len(fake.InvoiceItems(inv.ID)) != 2 {
+		t.Errorf("Expected 2 items totalling 45000, got %d items totalling %d", len(fake.InvoiceItems(inv.ID)), inv.TotalCents)
+	}
+	if err := fake.PayInvoice(inv.ID); err != nil {
+		t.Fatalf("Expected the invoice to be paid, got %v", err)
+	}
+
+	if err := fake.CancelSubscription(ctx, sub.ID); err != nil {
+		t.Fatalf("Expected the subscription to cancel, got %v", err)
+	}
+	if _, err := fake.UpdateSubscription(ctx, sub.ID, SubscriptionParams{PriceID: "price_essential"}); err == nil {
+		t.Error("Expected a canceled subscription not to update")
+	}
+
+	expected := []string{EventSubscriptionUpdated, EventInvoiceFinalized, EventInvoicePaid, EventSubscriptionDeleted}
+	got := hook.types()
+	if len(got) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected event %d to be %s, got %s", i, expected[i], got[i])
+		}
+	}
+	if paid := hook.events[2].Invoice; paid == nil || paid.ID != inv.ID || paid.Status != "paid" {
+		t.Errorf("Expected invoice.paid for %s, got %+v", inv.ID, paid)
+	}
+}
+
+func TestFakeCheckout(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("")
+	hook := &recorder{provider: fake}
+	fake.SetWebhookHandler(hook)
+
+	cs, err := fake.CreateCheckoutSession(ctx, CheckoutParams{PriceID: "price_essential", TrialDays: 14,
+		Metadata: map[string]string{"organization_id": "org-1"}})
+	if err != nil {
+		t.Fatalf("Expected a checkout session, got %v", err)
+	}
+	if cs.URL == "" {
+		t.Error("Expected a checkout URL")
+	}
+
+	done, err := fake.CompleteCheckout(cs.ID)
+	if err != nil {
+		t.Fatalf("Expected checkout to complete, got %v", err)
+	}
+	sub, err := fake.GetSubscription(ctx, done.SubscriptionID)
+	if err != nil {
+		t.Fatalf("Expected the checkout's subscription, got %v", err)
+	}
+	if sub.Status != StatusTrialing || sub.CustomerID != done.CustomerID {
+		t.Errorf("Expected a trialing subscription for %s, got %s for %s", done.CustomerID, sub.Status, sub.CustomerID)
+	}
+	if len(hook.events) != 1 || hook.events[0].Checkout == nil || hook.events[0].Checkout.Metadata["organization_id"] != "org-1" {
+		t.Errorf("Expected checkout.session.completed with its metadata, got %+v", hook.events)
+	}
+	if _, err := fake.CompleteCheckout(cs.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a completed checkout to be gone, got %v", err)
+	}
+}
+
+func TestFakeWebhookSignature(t *testing.T) {
+	fake := NewFake("whsec_test")
+	payload := []byte(`{"ID":"evt_1","Type":"invoice.paid"}`)
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, fake.sign(payload))
+	if _, err := fake.ParseWebhook(payload, header); err != nil {
+		t.Errorf("Expected a signed webhook to parse, got %v", err)
+	}
+
+	other := NewFake("whsec_other")
+	header.Set(FakeSignatureHeader, other.sign(payload))
+	if _, err := fake.ParseWebhook(payload, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestFakeWebhookFailure(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("")
+	fake.SetWebhookHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusInternalServerError)
+	}))
+
+	cust, _ := fake.CreateCustomer(ctx, CustomerParams{})
+	inv, err := fake.CreateInvoice(ctx, InvoiceParams{CustomerID: cust.ID, Currency: money.USD})
+	if err != nil {
+		t.Fatalf("Expected an invoice even when the webhook fails, got %v", err)
+	}
+	if err := fake.PayInvoice(inv.ID); err == nil {
+		t.Error("Expected a failed webhook delivery to be reported")
+	}
+}
+
+func TestFakeInvoiceIdempotency(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("")
+	cust, _ := fake.CreateCustomer(ctx, CustomerParams{})
+
+	params := InvoiceParams{CustomerID: cust.ID, Currency: money.USD,
+		Items: []InvoiceItem{{AmountCents: 5000, Description: "Essential Plan"}}, IdempotencyKey: "billing-1"}
+	first, err := fake.CreateInvoice(ctx, params)
+	if err != nil {
+		t.Fatalf("Expected an invoice, got %v", err)
+	}
+	retry, err := fake.CreateInvoice(ctx, params)
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if retry.ID != first.ID || len(fake.Events()) != 1 {
+		t.Errorf("Expected the retry to return %s without a new event, got %s and %d events", first.ID, retry.ID, len(fake.Events()))
+	}
+
+	params.IdempotencyKey = "billing-2"
+	if other, _ := fake.CreateInvoice(ctx, params); other.ID == first.ID {
+		t.Errorf("Expected a new key to create a new invoice, got %s again", other.ID)
+	}
+}
+
+func TestFakeLists(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("")
+	cust, _ := fake.CreateCustomer(ctx, CustomerParams{})
+	other, _ := fake.CreateCustomer(ctx, CustomerParams{})
+
+	params := InvoiceParams{CustomerID: cust.ID, Currency: money.USD, Items: []InvoiceItem{{AmountCents: 5000}}}
+	var created []Invoice
+	for i := 0; i < 11; i++ {
+		inv, _ := fake.CreateInvoice(ctx, params)
+		created = append(created, inv)
+	}
+	fake.CreateInvoice(ctx, InvoiceParams{CustomerID: other.ID, Currency: money.USD})
+
+	list, err := fake.ListInvoices(ctx, cust.ID, time.Time{})
+	if err != nil || len(list) != len(created) {
+		t.Fatalf("Expected %d invoices, got %d (%v)", len(created), len(list), err)
+	}
+	if list[0].ID != created[len(created)-1].ID {
+		t.Errorf("Expected the newest invoice %s first, got %s", created[len(created)-1].ID, list[0].ID)
+	}
+	if list, _ := fake.ListInvoices(ctx, cust.ID, time.Now().Add(time.Hour)); len(list) != 0 {
+		t.Errorf("Expected no invoices created in the future, got %d", len(list))
+	}
+
+	fake.CreateSubscription(ctx, SubscriptionParams{CustomerID: cust.ID, PriceID: "price_1"})
+	subs, err := fake.ListSubscriptions(ctx, cust.ID)
+	if err != nil || len(subs) != 1 || subs[0].CustomerID != cust.ID {
+		t.Errorf("Expected the customer's subscription, got %+v (%v)", subs, err)
+	}
+}
+
+func TestFakeRefundInvoice(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("")
+	cust, _ := fake.CreateCustomer(ctx, CustomerParams{})
+	inv, _ := fake.CreateInvoice(ctx, InvoiceParams{CustomerID: cust.ID, Currency: money.USD,
+		Items: []InvoiceItem{{AmountCents: 5000}}})
+
+	if err := fake.RefundInvoice(inv.ID, 1000); err == nil {
+		t.Error("Expected an unpaid invoice not to be refunded")
+	}
+	fake.PayInvoice(inv.ID)
+	fake.RefundInvoice(inv.ID, 1000)
+	fake.RefundInvoice(inv.ID, 9000)
+
+	events := fake.Events()
+	last := events[len(events)-1]
+	if last.Type != EventChargeRefunded || last.Charge == nil || last.Charge.InvoiceID != inv.ID {
+		t.Fatalf("Expected charge.refunded for %s, got %+v", inv.ID, last)
+	}
+	if last.Charge.RefundedCents != 5000 || last.Charge.AmountCents != 5000 {
+		t.Errorf("Expected the refunds to add up to the whole 5000, got %d of %d", last.Charge.RefundedCents, last.Charge.AmountCents)
+	}
+}
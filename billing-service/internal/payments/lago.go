@@ -0,0 +1,374 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// DefaultLagoAddOnCode is the Lago add-on that one-off invoice fees are charged under
+const DefaultLagoAddOnCode = "temporal_cloud"
+
+// Lago is a provider for a self-hosted Lago billing server. The billing service stays
+// the pricing engine: plans are mirrored to Lago without prices so subscriptions can
+// reference them, usage is mirrored as events for reporting, and each bill is sent as
+// a one-off invoice that Lago collects.
+type Lago struct {
+	baseURL       string
+	apiKey        string
+	webhookSecret string
+	addOnCode     string
+	client        *http.Client
+}
+
+// NewLago creates a Lago provider for an API URL such as http://lago-api:3000, an API
+// key, and the HMAC key Lago signs webhooks with
+func NewLago(baseURL, apiKey, webhookSecret string) *Lago {
+	return &Lago{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		apiKey:        apiKey,
+		webhookSecret: webhookSecret,
+		addOnCode:     DefaultLagoAddOnCode,
+		client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (l *Lago) Name() string {
+	return "lago"
+}
+
+// do sends a request to the Lago API and decodes its response into out, if not nil
+func (l *Lago) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, l.baseURL+"/api/v1"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+l.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("lago %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: lago %s", ErrNotFound, path)
+	}
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("lago %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type lagoSubscription struct {
+	ExternalID         string     `json:"external_id"`
+	ExternalCustomerID string     `json:"external_customer_id"`
+	PlanCode           string     `json:"plan_code"`
+	BillingTime        string     `json:"billing_time,omitempty"`
+	Status             string     `json:"status,omitempty"`
+	PeriodStartedAt    *time.Time `json:"current_billing_period_started_at,omitempty"`
+	PeriodEndingAt     *time.Time `json:"current_billing_period_ending_at,omitempty"`
+}
+
+type lagoInvoice struct {
+	LagoID           string `json:"lago_id"`
+	Status           string `json:"status"`
+	PaymentStatus    string `json:"payment_status"`
+	Currency         string `json:"currency"`
+	TotalAmountCents int64  `json:"total_amount_cents"`
+	Customer         struct {
+		ExternalID string `json:"external_id"`
+	} `json:"customer"`
+	Subscriptions []struct {
+		ExternalID string `json:"external_id"`
+	} `json:"subscriptions"`
+}
+
+func (s lagoSubscription) subscription() Subscription {
+	sub := Subscription{
+		ID:         s.ExternalID,
+		CustomerID: s.ExternalCustomerID,
+		PriceID:    s.PlanCode,
+		Status:     StatusActive,
+	}
+	switch s.Status {
+	case "terminated", "canceled":
+		sub.Status = StatusCanceled
+	}
+	if s.PeriodStartedAt != nil {
+		// Subscriptions are billed by calendar month, so every period starts on the 1st
+		sub.CurrentPeriodStart, sub.BillingCycleAnchor = *s.PeriodStartedAt, *s.PeriodStartedAt
+	}
+	if s.PeriodEndingAt != nil {
+		sub.CurrentPeriodEnd = *s.PeriodEndingAt
+	}
+	return sub
+}
+
+func (i lagoInvoice) invoice() Invoice {
+	inv := Invoice{
+		ID:         i.LagoID,
+		CustomerID: i.Customer.ExternalID,
+		Currency:   money.Currency(strings.ToUpper(i.Currency)),
+		TotalCents: i.TotalAmountCents,
+		Status:     i.Status,
+	}
+	if i.PaymentStatus == "succeeded" {
+		inv.Status = "paid"
+	}
+	if len(i.Subscriptions) > 0 {
+		inv.SubscriptionID = i.Subscriptions[0].ExternalID
+	}
+	return inv
+}
+
+// CreateCustomer creates a Lago customer under a new external ID, which the billing
+// service stores as the organization's customer ID
+func (l *Lago) CreateCustomer(ctx context.Context, params CustomerParams) (Customer, error) {
+	id := uuid.NewString()
+	in := map[string]any{"customer": map[string]any{"external_id": id, "name": params.Name, "email": params.Email}}
+	if err := l.do(ctx, http.MethodPost, "/customers", in, nil); err != nil {
+		return Customer{}, err
+	}
+	return Customer{ID: id}, nil
+}
+
+// CreateSubscription subscribes a customer to a Lago plan. PriceID is the plan code;
+// trials are left to the billing service.
+func (l *Lago) CreateSubscription(ctx context.Context, params SubscriptionParams) (Subscription, error) {
+	return l.subscribe(ctx, uuid.NewString(), params)
+}
+
+// subscribe creates a subscription, or changes the plan of an existing one
+func (l *Lago) subscribe(ctx context.Context, externalID string, params SubscriptionParams) (Subscription, error) {
+	in := map[string]any{"subscription": lagoSubscription{
+		ExternalID:         externalID,
+		ExternalCustomerID: params.CustomerID,
+		PlanCode:           params.PriceID,
+		BillingTime:        "calendar",
+	}}
+	var out struct {
+		Subscription lagoSubscription `json:"subscription"`
+	}
+	if err := l.do(ctx, http.MethodPost, "/subscriptions", in, &out); err != nil {
+		return Subscription{}, err
+	}
+	return out.Subscription.subscription(), nil
+}
+
+func (l *Lago) GetSubscription(ctx context.Context, id string) (Subscription, error) {
+	var out struct {
+		Subscription lagoSubscription `json:"subscription"`
+	}
+	if err := l.do(ctx, http.MethodGet, "/subscriptions/"+id, nil, &out); err != nil {
+		return Subscription{}, err
+	}
+	return out.Subscription.subscription(), nil
+}
+
+// UpdateSubscription moves a subscription to another plan. Lago changes plans by
+// subscribing the same external ID again.
+func (l *Lago) UpdateSubscription(ctx context.Context, id string, params SubscriptionParams) (Subscription, error) {
+	current, err := l.GetSubscription(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if current.Ended() {
+		return Subscription{}, fmt.Errorf("lago subscription %s is %s", id, current.Status)
+	}
+	params.CustomerID = current.CustomerID
+	return l.subscribe(ctx, id, params)
+}
+
+func (l *Lago) CancelSubscription(ctx context.Context, id string) error {
+	return l.do(ctx, http.MethodDelete, "/subscriptions/"+id, nil, nil)
+}
+
+// CreateInvoice sends a one-off invoice. Lago fees cannot be negative, so a bill with
+// discounts or credits is sent as one net fee describing every line. Lago has no
+// idempotency keys for one-off invoices, so params.IdempotencyKey is not sent; billing
+// runs rely on their own record of invoiced organizations instead.
+func (l *Lago) CreateInvoice(ctx context.Context, params InvoiceParams) (Invoice, error) {
+	type fee struct {
+		AddOnCode       string `json:"add_on_code"`
+		UnitAmountCents int64  `json:"unit_amount_cents"`
+		Units           string `json:"units"`
+		Description     string `json:"description"`
+	}
+
+	var fees []fee
+	var net int64
+	var lines []string
+	negative := false
+	for _, item := range params.Items {
+		net += item.AmountCents
+		lines = append(lines, fmt.Sprintf("%s: %s", item.Description, money.FromMinor(params.Currency, item.AmountCents)))
+		negative = negative || item.AmountCents < 0
+		fees = append(fees, fee{AddOnCode: l.addOnCode, UnitAmountCents: item.AmountCents, Units: "1", Description: item.Description})
+	}
+	if negative {
+		fees = []fee{{AddOnCode: l.addOnCode, UnitAmountCents: net, Units: "1", Description: strings.Join(lines, "; ")}}
+	}
+
+	in := map[string]any{"invoice": map[string]any{
+		"external_customer_id": params.CustomerID,
+		"currency":             string(params.Currency),
+		"fees":                 fees,
+	}}
+	var out struct {
+		Invoice lagoInvoice `json:"invoice"`
+	}
+	if err := l.do(ctx, http.MethodPost, "/invoices", in, &out); err != nil {
+		return Invoice{}, err
+	}
+	return out.Invoice.invoice(), nil
+}
+
+// CreateCheckoutSession is not supported: Lago has no hosted checkout
+func (l *Lago) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (CheckoutSession, error) {
+	return CheckoutSession{}, fmt.Errorf("%w: lago has no hosted checkout", ErrUnsupported)
+}
+
+func (l *Lago) CreateCoupon(ctx context.Context, c coupons.Coupon) (string, error) {
+	coupon := map[string]any{
+		"name":       c.Name,
+		"code":       c.Code,
+		"reusable":   false,
+		"expiration": "no_expiration",
+		"frequency":  map[coupons.Duration]string{coupons.Once: "once", coupons.Repeating: "recurring", coupons.Forever: "forever"}[c.Duration],
+	}
+	if c.PercentOff.IsZero() {
+		coupon["coupon_type"] = "fixed_amount"
+		coupon["amount_cents"] = c.AmountOffCents
+		coupon["amount_currency"] = string(c.Currency)
+	} else {
+		coupon["coupon_type"] = "percentage"
+		coupon["percentage_rate"] = c.PercentOff.String()
+	}
+	if c.Duration == coupons.Repeating {
+		coupon["frequency_duration"] = c.DurationInMonths
+	}
+	if c.RedeemBy != nil {
+		coupon["expiration"] = "time_limit"
+		coupon["expiration_at"] = c.RedeemBy.UTC().Format(time.RFC3339)
+	}
+	if err := l.do(ctx, http.MethodPost, "/coupons", map[string]any{"coupon": coupon}, nil); err != nil {
+		return "", err
+	}
+	return c.Code, nil
+}
+
+// SyncPlan creates or updates a Lago plan. Plans carry no prices: the billing service
+// prices usage and invoices it.
+func (l *Lago) SyncPlan(ctx context.Context, p PlanParams) error {
+	plan := map[string]any{
+		"name":            p.Name,
+		"code":            p.Code,
+		"description":     p.Description,
+		"interval":        "monthly",
+		"amount_cents":    0,
+		"amount_currency": string(p.Currency),
+		"pay_in_advance":  false,
+	}
+	err := l.do(ctx, http.MethodPut, "/plans/"+p.Code, map[string]any{"plan": plan}, nil)
+	if errors.Is(err, ErrNotFound) {
+		err = l.do(ctx, http.MethodPost, "/plans", map[string]any{"plan": plan}, nil)
+	}
+	return err
+}
+
+// ReportUsage sends usage events in one batch. Lago deduplicates events by
+// transaction ID, so a batch can be retried.
+func (l *Lago) ReportUsage(ctx context.Context, events []UsageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	batch := make([]map[string]any, 0, len(events))
+	for _, e := range events {
+		batch = append(batch, map[string]any{
+			"transaction_id":           e.TransactionID,
+			"external_subscription_id": e.SubscriptionID,
+			"code":                     e.Code,
+			"timestamp":                e.Timestamp.Unix(),
+			"properties":               map[string]string{"value": e.Value.String()},
+		})
+	}
+	return l.do(ctx, http.MethodPost, "/events/batch", map[string]any{"events": batch}, nil)
+}
+
+// ParseWebhook verifies a webhook signed with Lago's HMAC signature
+func (l *Lago) ParseWebhook(payload []byte, header http.Header) (Event, error) {
+	if l.webhookSecret == "" {
+		return Event{}, ErrWebhookNotConfigured
+	}
+	mac := hmac.New(sha256.New, []byte(l.webhookSecret))
+	mac.Write(payload)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Lago-Signature")), []byte(expected)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var body struct {
+		WebhookType  string            `json:"webhook_type"`
+		Invoice      *lagoInvoice      `json:"invoice"`
+		Subscription *lagoSubscription `json:"subscription"`
+	}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return Event{}, err
+	}
+
+	e := Event{ID: header.Get("X-Lago-Unique-Key"), Type: body.WebhookType}
+	if body.Invoice != nil {
+		inv := body.Invoice.invoice()
+		e.Invoice = &inv
+	}
+	if body.Subscription != nil {
+		sub := body.Subscription.subscription()
+		e.Subscription = &sub
+	}
+
+	// Translate Lago's webhook types to the provider-neutral ones
+	switch body.WebhookType {
+	case "invoice.created":
+		e.Type = EventInvoiceFinalized
+	case "invoice.payment_status_updated":
+		switch {
+		case body.Invoice == nil:
+		case body.Invoice.PaymentStatus == "succeeded":
+			e.Type = EventInvoicePaid
+		case body.Invoice.PaymentStatus == "failed":
+			e.Type = EventInvoicePaymentFailed
+		}
+	case "subscription.started":
+		e.Type = EventSubscriptionUpdated
+	case "subscription.terminated":
+		e.Type = EventSubscriptionDeleted
+	}
+	return e, nil
+}
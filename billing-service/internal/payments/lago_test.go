@@ -0,0 +1,294 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// lagoStub is a minimal Lago API that keeps subscriptions and plans in memory and
+// records every request it receives
+type lagoStub struct {
+	mu            sync.Mutex
+	requests      []string
+	subscriptions map[string]lagoSubscription
+	plans         map[string]map[string]any
+	invoices      []map[string]any
+	events        []map[string]any
+}
+
+func newLagoStub(t *testing.T) (*lagoStub, *Lago) {
+	stub := &lagoStub{subscriptions: map[string]lagoSubscription{}, plans: map[string]map[string]any{}}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	return stub, NewLago(srv.URL+"/", "lago_key", "lago_secret")
+}
+
+func (s *lagoStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer lago_key" {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if r.Body != nil && r.ContentLength != 0 {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+
+	switch {
+	case r.Method == http.MethodPost && path == "/customers":
+		w.Write([]byte(`{"customer":{}}`))
+
+	case r.Method == http.MethodPost && path == "/subscriptions":
+		var sub lagoSubscription
+		json.Unmarshal(body["subscription"], &sub)
+		start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+		sub.Status, sub.PeriodStartedAt, sub.PeriodEndingAt = "active", &start, &end
+		s.subscriptions[sub.ExternalID] = sub
+		json.NewEncoder(w).Encode(map[string]any{"subscription": sub})
+
+	case strings.HasPrefix(path, "/subscriptions/"):
+		id := strings.TrimPrefix(path, "/subscriptions/")
+		sub, ok := s.subscriptions[id]
+		if !ok {
+			http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			sub.Status = "terminated"
+			s.subscriptions[id] = sub
+		}
+		json.NewEncoder(w).Encode(map[string]any{"subscription": sub})
+
+	case r.Method == http.MethodPost && path == "/invoices":
+		var inv map[string]any
+		json.Unmarshal(body["invoice"], &inv)
+		s.invoices = append(s.invoices, inv)
+		var total float64
+		for _, f := range inv["fees"].([]any) {
+			total += f.(map[string]any)["unit_amount_cents"].(float64)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"invoice": map[string]any{
+			"lago_id": "inv_1", "status": "finalized", "payment_status": "pending",
+			"currency": inv["currency"], "total_amount_cents": total,
+			"customer": map[string]any{"external_id": inv["external_customer_id"]},
+		}})
+
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/plans/"):
+		code := strings.TrimPrefix(path, "/plans/")
+		if _, ok := s.plans[code]; !ok {
+			http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		var plan map[string]any
+		json.Unmarshal(body["plan"], &plan)
+		s.plans[code] = plan
+
+	case r.Method == http.MethodPost && path == "/plans":
+		var plan map[string]any
+		json.Unmarshal(body["plan"], &plan)
+		s.plans[plan["code"].(string)] = plan
+
+	case r.Method == http.MethodPost && path == "/events/batch":
+		var events []map[string]any
+		json.Unmarshal(body["events"], &events)
+		s.events = append(s.events, events...)
+
+	default:
+		http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+	}
+}
+
+func TestLagoSubscriptionFlow(t *testing.T) {
+	ctx := context.Background()
+	stub, lago := newLagoStub(t)
+
+	cust, err := lago.CreateCustomer(ctx, CustomerParams{Name: "Acme", Email: "billing@acme.test"})
+	if err != nil || cust.ID == "" {
+		t.Fatalf("Expected a customer, got %+v, %v", cust, err)
+	}
+
+	sub, err := lago.CreateSubscription(ctx, SubscriptionParams{CustomerID: cust.ID, PriceID: "price_essential_monthly_usd"})
+	if err != nil {
+		t.Fatalf("Expected a subscription, got %v", err)
+	}
+	if sub.Status != StatusActive || sub.CustomerID != cust.ID || sub.CurrentPeriodEnd.IsZero() {
+		t.Errorf("Expected an active subscription for %s, got %+v", cust.ID, sub)
+	}
+
+	sub, err = lago.UpdateSubscription(ctx, sub.ID, SubscriptionParams{PriceID: "price_business_monthly_usd"})
+	if err != nil {
+		t.Fatalf("Expected the subscription to update, got %v", err)
+	}
+	if sub.PriceID != "price_business_monthly_usd" || sub.CustomerID != cust.ID {
+		t.Errorf("Expected the business plan for %s, got %+v", cust.ID, sub)
+	}
+	if len(stub.subscriptions) != 1 {
+		t.Errorf("Expected the plan change to keep one subscription, got %d", len(stub.subscriptions))
+	}
+
+	if err := lago.CancelSubscription(ctx, sub.ID); err != nil {
+		t.Fatalf("Expected the subscription to cancel, got %v", err)
+	}
+	if got, _ := lago.GetSubscription(ctx, sub.ID); !got.Ended() {
+		t.Errorf("Expected a canceled subscription, got %s", got.Status)
+	}
+	if _, err := lago.UpdateSubscription(ctx, sub.ID, SubscriptionParams{PriceID: "price_essential_monthly_usd"}); err == nil {
+		t.Error("Expected a canceled subscription not to update")
+	}
+
+	if _, err := lago.GetSubscription(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := NewLago(strings.TrimSuffix(lago.baseURL, "/"), "wrong", "").GetSubscription(ctx, sub.ID); err == nil {
+		t.Error("Expected a request with the wrong API key to fail")
+	}
+}
+
+func TestLagoCreateInvoice(t *testing.T) {
+	ctx := context.Background()
+	stub, lago := newLagoStub(t)
+
+	inv, err := lago.CreateInvoice(ctx, InvoiceParams{
+		CustomerID: "cust_1",
+		Currency:   money.EUR,
+		Items:      []InvoiceItem{{AmountCents: 50000, Description: "Business Plan"}, {AmountCents: 1200, Description: "Actions overage"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected an invoice, got %v", err)
+	}
+	if inv.ID != "inv_1" || inv.Currency != money.EUR || inv.TotalCents != 51200 || inv.CustomerID != "cust_1" {
+		t.Errorf("Expected invoice inv_1 for 51200 EUR, got %+v", inv)
+	}
+	if fees := stub.invoices[0]["fees"].([]any); len(fees) != 2 {
+		t.Errorf("Expected one fee per line, got %d", len(fees))
+	}
+
+	inv, err = lago.CreateInvoice(ctx, InvoiceParams{
+		CustomerID: "cust_1",
+		Currency:   money.USD,
+		Items:      []InvoiceItem{{AmountCents: 50000, Description: "Business Plan"}, {AmountCents: -5000, Description: "Credits applied"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected an invoice, got %v", err)
+	}
+	fees := stub.invoices[1]["fees"].([]any)
+	if len(fees) != 1 || inv.TotalCents != 45000 {
+		t.Fatalf("Expected a single net fee of 45000, got %d fees totalling %d", len(fees), inv.TotalCents)
+	}
+	if desc := fees[0].(map[string]any)["description"].(string); !strings.Contains(desc, "Credits applied") {
+		t.Errorf("Expected the net fee to describe every line, got %q", desc)
+	}
+}
+
+func TestLagoSyncPlanAndUsage(t *testing.T) {
+	ctx := context.Background()
+	stub, lago := newLagoStub(t)
+
+	plan := PlanParams{Code: "price_essential_monthly_usd", Name: "Essential", Currency: money.USD}
+	if err := lago.SyncPlan(ctx, plan); err != nil {
+		t.Fatalf("Expected the plan to be created, got %v", err)
+	}
+	plan.Name = "Essentials"
+	if err := lago.SyncPlan(ctx, plan); err != nil {
+		t.Fatalf("Expected the plan to be updated, got %v", err)
+	}
+	if got := stub.plans[plan.Code]; got["name"] != "Essentials" || got["amount_cents"] != float64(0) {
+		t.Errorf("Expected an unpriced Essentials plan, got %v", got)
+	}
+	expected := []string{
+		"PUT /api/v1/plans/price_essential_monthly_usd",
+		"POST /api/v1/plans",
+		"PUT /api/v1/plans/price_essential_monthly_usd",
+	}
+	if strings.Join(stub.requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected requests %v, got %v", expected, stub.requests)
+	}
+
+	at := time.Date(2026, 9, 30, 23, 59, 59, 0, time.UTC)
+	err := lago.ReportUsage(ctx, []UsageEvent{
+		{TransactionID: "org-1-2026-09-actions", SubscriptionID: "sub_1", Code: "actions", Timestamp: at, Value: decimal.NewFromInt(1500000)},
+		{TransactionID: "org-1-2026-09-active_storage", SubscriptionID: "sub_1", Code: "active_storage", Timestamp: at, Value: decimal.RequireFromString("2.5")},
+	})
+	if err != nil {
+		t.Fatalf("Expected usage to be reported, got %v", err)
+	}
+	if len(stub.events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(stub.events))
+	}
+	e := stub.events[1]
+	if e["external_subscription_id"] != "sub_1" || e["timestamp"] != float64(at.Unix()) ||
+		e["properties"].(map[string]any)["value"] != "2.5" {
+		t.Errorf("Expected 2.5 GB of active storage for sub_1, got %v", e)
+	}
+}
+
+func TestLagoParseWebhook(t *testing.T) {
+	lago := NewLago("http://lago", "lago_key", "lago_secret")
+	sign := func(secret string, payload []byte) http.Header {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		header := http.Header{}
+		header.Set("X-Lago-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		header.Set("X-Lago-Unique-Key", "whk_1")
+		return header
+	}
+
+	payload := []byte(`{"webhook_type":"invoice.payment_status_updated","invoice":{"lago_id":"inv_1","status":"finalized",
+		"payment_status":"succeeded","currency":"EUR","total_amount_cents":4200,"customer":{"external_id":"cust_1"},
+		"subscriptions":[{"external_id":"sub_1"}]}}`)
+	e, err := lago.ParseWebhook(payload, sign("lago_secret", payload))
+	if err != nil {
+		t.Fatalf("Expected the webhook to parse, got %v", err)
+	}
+	if e.ID != "whk_1" || e.Type != EventInvoicePaid || e.Invoice == nil {
+		t.Fatalf("Expected an invoice.paid event, got %+v", e)
+	}
+	if e.Invoice.ID != "inv_1" || e.Invoice.CustomerID != "cust_1" || e.Invoice.SubscriptionID != "sub_1" ||
+		e.Invoice.Currency != money.EUR || e.Invoice.TotalCents != 4200 || e.Invoice.Status != "paid" {
+		t.Errorf("Expected paid invoice inv_1 for 4200 EUR, got %+v", e.Invoice)
+	}
+
+	payload = []byte(`{"webhook_type":"invoice.payment_status_updated","invoice":{"lago_id":"inv_1","payment_status":"failed"}}`)
+	if e, _ := lago.ParseWebhook(payload, sign("lago_secret", payload)); e.Type != EventInvoicePaymentFailed {
+		t.Errorf("Expected invoice.payment_failed, got %s", e.Type)
+	}
+
+	payload = []byte(`{"webhook_type":"subscription.terminated","subscription":{"external_id":"sub_1","status":"terminated"}}`)
+	e, _ = lago.ParseWebhook(payload, sign("lago_secret", payload))
+	if e.Type != EventSubscriptionDeleted || e.Subscription == nil || !e.Subscription.Ended() {
+		t.Errorf("Expected an ended subscription, got %+v", e)
+	}
+
+	if _, err := lago.ParseWebhook(payload, sign("other_secret", payload)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+	if _, err := NewLago("http://lago", "lago_key", "").ParseWebhook(payload, sign("", payload)); !errors.Is(err, ErrWebhookNotConfigured) {
+		t.Errorf("Expected ErrWebhookNotConfigured, got %v", err)
+	}
+}
+
+func TestLagoCheckoutUnsupported(t *testing.T) {
+	_, lago := newLagoStub(t)
+	if _, err := lago.CreateCheckoutSession(context.Background(), CheckoutParams{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
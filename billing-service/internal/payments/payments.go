@@ -0,0 +1,238 @@
+// Package payments abstracts the payment provider that holds customers, subscriptions
+// and invoices, so billing runs against Stripe in production and an in-memory fake in
+// tests and local development.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+var (
+	ErrNotFound             = errors.New("not found at payment provider")
+	ErrInvalidSignature     = errors.New("invalid webhook signature")
+	ErrWebhookNotConfigured = errors.New("webhook secret not configured")
+	ErrUnknownProvider      = errors.New("unknown payment provider")
+	ErrUnsupported          = errors.New("not supported by payment provider")
+)
+
+// Subscription statuses reported by providers
+const (
+	StatusTrialing          = "trialing"
+	StatusActive            = "active"
+	StatusPastDue           = "past_due"
+	StatusCanceled          = "canceled"
+	StatusIncompleteExpired = "incomplete_expired"
+)
+
+// Webhook event types, named as Stripe names them
+const (
+	EventCheckoutCompleted    = "checkout.session.completed"
+	EventInvoiceFinalized     = "invoice.finalized"
+	EventInvoicePaid          = "invoice.paid"
+	EventInvoicePaymentFailed = "invoice.payment_failed"
+	EventSubscriptionUpdated  = "customer.subscription.updated"
+	EventSubscriptionDeleted  = "customer.subscription.deleted"
+	EventChargeRefunded       = "charge.refunded"
+)
+
+// Provider is a payment provider
+type Provider interface {
+	// Name identifies the provider in logs and configuration
+	Name() string
+
+	CreateCustomer(ctx context.Context, params CustomerParams) (Customer, error)
+
+	CreateSubscription(ctx context.Context, params SubscriptionParams) (Subscription, error)
+	GetSubscription(ctx context.Context, id string) (Subscription, error)
+	// UpdateSubscription moves a subscription to another price without prorating it
+	UpdateSubscription(ctx context.Context, id string, params SubscriptionParams) (Subscription, error)
+	CancelSubscription(ctx context.Context, id string) error
+
+	// CreateInvoice invoices a customer for the given items and collects it automatically.
+	// Either the whole invoice is created or none of it is left to be charged later.
+	CreateInvoice(ctx context.Context, params InvoiceParams) (Invoice, error)
+
+	CreateCheckoutSession(ctx context.Context, params CheckoutParams) (CheckoutSession, error)
+
+	// CreateCoupon mirrors a coupon to the provider and returns the provider's ID for it
+	CreateCoupon(ctx context.Context, c coupons.Coupon) (string, error)
+
+	// ParseWebhook verifies a webhook request and decodes its event
+	ParseWebhook(payload []byte, header http.Header) (Event, error)
+}
+
+// PlanSyncer is a provider that keeps a copy of the plan catalog
+type PlanSyncer interface {
+	SyncPlan(ctx context.Context, p PlanParams) error
+}
+
+// UsageReporter is a provider that receives metered usage
+type UsageReporter interface {
+	ReportUsage(ctx context.Context, events []UsageEvent) error
+}
+
+// Lister is a provider that lists a customer's invoices and subscriptions, so they can
+// be reconciled with local records
+type Lister interface {
+	// ListInvoices returns the invoices created for a customer since a time, newest first
+	ListInvoices(ctx context.Context, customerID string, since time.Time) ([]Invoice, error)
+	// ListSubscriptions returns every subscription of a customer, ended ones included
+	ListSubscriptions(ctx context.Context, customerID string) ([]Subscription, error)
+}
+
+// PlanParams describes a plan in one currency. Code is the plan's price ID.
+type PlanParams struct {
+	Code        string
+	Name        string
+	Description string
+	Currency    money.Currency
+}
+
+// UsageEvent is the usage of one meter by a subscription. Providers deduplicate events
+// by TransactionID.
+type UsageEvent struct {
+	TransactionID  string
+	SubscriptionID string
+	Code           string
+	Timestamp      time.Time
+	Value          decimal.Decimal
+}
+
+// CustomerParams describes a new customer
+type CustomerParams struct {
+	Name  string
+	Email string
+}
+
+// Customer is a customer at the provider
+type Customer struct {
+	ID string
+}
+
+// SubscriptionParams describes a subscription to one price. When TrialEnd is set the
+// subscription trials until then and is canceled if no payment method is added.
+type SubscriptionParams struct {
+	CustomerID  string
+	PriceID     string
+	TrialEnd    *time.Time
+	EndTrialNow bool
+}
+
+// Subscription is a subscription at the provider
+type Subscription struct {
+	ID                 string
+	CustomerID         string
+	PriceID            string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	// BillingCycleAnchor is when periods start: each starts on its day of the month
+	BillingCycleAnchor time.Time
+	TrialEnd           *time.Time
+}
+
+// Ended reports whether the subscription can no longer be modified
+func (s Subscription) Ended() bool {
+	return s.Status == StatusCanceled || s.Status == StatusIncompleteExpired
+}
+
+// InvoiceItem is one line of an invoice. Discounts and credits are negative.
+type InvoiceItem struct {
+	AmountCents int64
+	Description string
+}
+
+// InvoiceParams describes an invoice to create. Creating an invoice again with the same
+// IdempotencyKey returns the invoice created the first time instead of a second one.
+type InvoiceParams struct {
+	CustomerID     string
+	Currency       money.Currency
+	Items          []InvoiceItem
+	IdempotencyKey string
+}
+
+// Invoice is an invoice at the provider. Invoices the provider issues itself, for a
+// subscription's price, have a SubscriptionID.
+type Invoice struct {
+	ID             string
+	Number         string
+	CustomerID     string
+	SubscriptionID string
+	Currency       money.Currency
+	SubtotalCents  int64
+	TaxCents       int64
+	TotalCents     int64
+	Status         string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	CreatedAt      time.Time
+	PaidAt         *time.Time
+}
+
+// CheckoutParams describes a hosted checkout for a subscription
+type CheckoutParams struct {
+	CustomerID string
+	PriceID    string
+	SuccessURL string
+	CancelURL  string
+	TrialDays  int
+	Metadata   map[string]string
+}
+
+// CheckoutSession is a hosted checkout page
+type CheckoutSession struct {
+	ID             string
+	URL            string
+	CustomerID     string
+	SubscriptionID string
+	Metadata       map[string]string
+}
+
+// Charge is a payment of an invoice at the provider
+type Charge struct {
+	ID            string
+	CustomerID    string
+	InvoiceID     string
+	Currency      money.Currency
+	AmountCents   int64
+	RefundedCents int64
+}
+
+// Event is a webhook event. Exactly one of the objects is set, depending on Type.
+type Event struct {
+	ID           string
+	Type         string
+	Invoice      *Invoice
+	Subscription *Subscription
+	Checkout     *CheckoutSession
+	Charge       *Charge
+}
+
+// NewFromEnv creates the provider named by PAYMENT_PROVIDER: stripe (the default),
+// configured by STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET; lago, configured by
+// LAGO_API_URL, LAGO_API_KEY and LAGO_WEBHOOK_SECRET; or fake for local development,
+// signed with FAKE_WEBHOOK_SECRET
+func NewFromEnv() (Provider, error) {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "", "stripe":
+		return NewStripe(os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET")), nil
+	case "lago":
+		if os.Getenv("LAGO_API_URL") == "" {
+			return nil, fmt.Errorf("LAGO_API_URL is required for the lago payment provider")
+		}
+		return NewLago(os.Getenv("LAGO_API_URL"), os.Getenv("LAGO_API_KEY"), os.Getenv("LAGO_WEBHOOK_SECRET")), nil
+	case "fake":
+		return NewFake(os.Getenv("FAKE_WEBHOOK_SECRET")), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+}
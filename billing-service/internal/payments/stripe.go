@@ -0,0 +1,389 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+	"github.com/stripe/stripe-go/v76/webhook"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// Stripe is the Stripe payment provider
+type Stripe struct {
+	api           *client.API
+	webhookSecret string
+}
+
+// NewStripe creates a Stripe provider from a secret key and webhook signing secret
+func NewStripe(secretKey, webhookSecret string) *Stripe {
+	return &Stripe{api: client.New(secretKey, nil), webhookSecret: webhookSecret}
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+// stripeError maps missing Stripe resources to ErrNotFound
+func stripeError(err error) error {
+	var se *stripe.Error
+	if errors.As(err, &se) && se.Code == stripe.ErrorCodeResourceMissing {
+		return fmt.Errorf("%w: %s", ErrNotFound, se.Msg)
+	}
+	return err
+}
+
+func (s *Stripe) CreateCustomer(ctx context.Context, params CustomerParams) (Customer, error) {
+	p := &stripe.CustomerParams{Name: stripe.String(params.Name), Email: stripe.String(params.Email)}
+	p.Context = ctx
+	cust, err := s.api.Customers.New(p)
+	if err != nil {
+		return Customer{}, err
+	}
+	return Customer{ID: cust.ID}, nil
+}
+
+// subscriptionParams sets the trial of a subscription. Stripe cancels a trial that ends
+// without a payment method.
+func subscriptionParams(ctx context.Context, params SubscriptionParams) *stripe.SubscriptionParams {
+	p := &stripe.SubscriptionParams{}
+	p.Context = ctx
+	switch {
+	case params.TrialEnd != nil:
+		p.TrialEnd = stripe.Int64(params.TrialEnd.Unix())
+		p.TrialSettings = &stripe.SubscriptionTrialSettingsParams{
+			EndBehavior: &stripe.SubscriptionTrialSettingsEndBehaviorParams{
+				MissingPaymentMethod: stripe.String(string(stripe.SubscriptionTrialSettingsEndBehaviorMissingPaymentMethodCancel)),
+			},
+		}
+	case params.EndTrialNow:
+		p.TrialEndNow = stripe.Bool(true)
+	}
+	return p
+}
+
+func (s *Stripe) CreateSubscription(ctx context.Context, params SubscriptionParams) (Subscription, error) {
+	p := subscriptionParams(ctx, params)
+	p.Customer = stripe.String(params.CustomerID)
+	p.Items = []*stripe.SubscriptionItemsParams{{Price: stripe.String(params.PriceID)}}
+	sub, err := s.api.Subscriptions.New(p)
+	if err != nil {
+		return Subscription{}, err
+	}
+	return fromStripeSubscription(sub), nil
+}
+
+func (s *Stripe) GetSubscription(ctx context.Context, id string) (Subscription, error) {
+	p := &stripe.SubscriptionParams{}
+	p.Context = ctx
+	sub, err := s.api.Subscriptions.Get(id, p)
+	if err != nil {
+		return Subscription{}, stripeError(err)
+	}
+	return fromStripeSubscription(sub), nil
+}
+
+func (s *Stripe) UpdateSubscription(ctx context.Context, id string, params SubscriptionParams) (Subscription, error) {
+	get := &stripe.SubscriptionParams{}
+	get.Context = ctx
+	sub, err := s.api.Subscriptions.Get(id, get)
+	if err != nil {
+		return Subscription{}, stripeError(err)
+	}
+	if sub.Items == nil || len(sub.Items.Data) == 0 {
+		return Subscription{}, fmt.Errorf("Stripe subscription %s has no items", id)
+	}
+
+	p := subscriptionParams(ctx, params)
+	p.Items = []*stripe.SubscriptionItemsParams{
+		{ID: stripe.String(sub.Items.Data[0].ID), Price: stripe.String(params.PriceID)},
+	}
+	p.ProrationBehavior = stripe.String("none")
+	sub, err = s.api.Subscriptions.Update(id, p)
+	if err != nil {
+		return Subscription{}, stripeError(err)
+	}
+	return fromStripeSubscription(sub), nil
+}
+
+func (s *Stripe) CancelSubscription(ctx context.Context, id string) error {
+	p := &stripe.SubscriptionCancelParams{}
+	p.Context = ctx
+	_, err := s.api.Subscriptions.Cancel(id, p)
+	return stripeError(err)
+}
+
+// CreateInvoice creates a draft invoice, adds the items to it and finalizes it. Items are
+// attached to the draft rather than left pending on the customer, so a failure halfway
+// leaves a draft that is never finalized, instead of items Stripe would sweep into the
+// next invoice. Only finalizing turns on automatic collection.
+// Every request carries an idempotency key derived from params.IdempotencyKey.
+func (s *Stripe) CreateInvoice(ctx context.Context, params InvoiceParams) (Invoice, error) {
+	key := func(p *stripe.Params, suffix string) {
+		p.Context = ctx
+		if params.IdempotencyKey != "" {
+			p.SetIdempotencyKey(params.IdempotencyKey + "-" + suffix)
+		}
+	}
+	currency := strings.ToLower(string(params.Currency))
+
+	p := &stripe.InvoiceParams{
+		Customer:                    stripe.String(params.CustomerID),
+		Currency:                    stripe.String(currency),
+		AutoAdvance:                 stripe.Bool(false),
+		CollectionMethod:            stripe.String("charge_automatically"),
+		PendingInvoiceItemsBehavior: stripe.String("exclude"),
+	}
+	key(&p.Params, "invoice")
+	inv, err := s.api.Invoices.New(p)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	for i, item := range params.Items {
+		p := &stripe.InvoiceItemParams{
+			Customer:    stripe.String(params.CustomerID),
+			Invoice:     stripe.String(inv.ID),
+			Amount:      stripe.Int64(item.AmountCents),
+			Currency:    stripe.String(currency),
+			Description: stripe.String(item.Description),
+		}
+		key(&p.Params, fmt.Sprintf("item-%d", i))
+		if _, err := s.api.InvoiceItems.New(p); err != nil {
+			return Invoice{}, fmt.Errorf("failed to add item %q to invoice %s: %w", item.Description, inv.ID, err)
+		}
+	}
+
+	fp := &stripe.InvoiceFinalizeInvoiceParams{AutoAdvance: stripe.Bool(true)}
+	key(&fp.Params, "finalize")
+	inv, err = s.api.Invoices.FinalizeInvoice(inv.ID, fp)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("failed to finalize invoice: %w", err)
+	}
+	return fromStripeInvoice(inv), nil
+}
+
+// ListInvoices pages through the customer's invoices since a time
+func (s *Stripe) ListInvoices(ctx context.Context, customerID string, since time.Time) ([]Invoice, error) {
+	p := &stripe.InvoiceListParams{
+		Customer:     stripe.String(customerID),
+		CreatedRange: &stripe.RangeQueryParams{GreaterThanOrEqual: since.Unix()},
+	}
+	p.Context = ctx
+	var list []Invoice
+	it := s.api.Invoices.List(p)
+	for it.Next() {
+		list = append(list, fromStripeInvoice(it.Invoice()))
+	}
+	if err := it.Err(); err != nil {
+		return nil, stripeError(err)
+	}
+	return list, nil
+}
+
+// ListSubscriptions pages through the customer's subscriptions, canceled ones included
+func (s *Stripe) ListSubscriptions(ctx context.Context, customerID string) ([]Subscription, error) {
+	p := &stripe.SubscriptionListParams{Customer: stripe.String(customerID), Status: stripe.String("all")}
+	p.Context = ctx
+	var list []Subscription
+	it := s.api.Subscriptions.List(p)
+	for it.Next() {
+		list = append(list, fromStripeSubscription(it.Subscription()))
+	}
+	if err := it.Err(); err != nil {
+		return nil, stripeError(err)
+	}
+	return list, nil
+}
+
+func (s *Stripe) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (CheckoutSession, error) {
+	p := &stripe.CheckoutSessionParams{
+		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{Price: stripe.String(params.PriceID), Quantity: stripe.Int64(1)},
+		},
+		SuccessURL: stripe.String(params.SuccessURL),
+		CancelURL:  stripe.String(params.CancelURL),
+		Metadata:   params.Metadata,
+	}
+	p.Context = ctx
+	if params.CustomerID != "" {
+		p.Customer = stripe.String(params.CustomerID)
+	}
+	if params.TrialDays > 0 {
+		p.SubscriptionData = &stripe.CheckoutSessionSubscriptionDataParams{
+			TrialPeriodDays: stripe.Int64(int64(params.TrialDays)),
+			TrialSettings: &stripe.CheckoutSessionSubscriptionDataTrialSettingsParams{
+				EndBehavior: &stripe.CheckoutSessionSubscriptionDataTrialSettingsEndBehaviorParams{
+					MissingPaymentMethod: stripe.String(string(stripe.SubscriptionTrialSettingsEndBehaviorMissingPaymentMethodCancel)),
+				},
+			},
+		}
+	}
+	cs, err := s.api.CheckoutSessions.New(p)
+	if err != nil {
+		return CheckoutSession{}, err
+	}
+	return fromStripeCheckoutSession(cs), nil
+}
+
+func (s *Stripe) CreateCoupon(ctx context.Context, c coupons.Coupon) (string, error) {
+	p := couponParams(c)
+	p.Context = ctx
+	sc, err := s.api.Coupons.New(p)
+	if err != nil {
+		return "", err
+	}
+	return sc.ID, nil
+}
+
+// couponParams describes a coupon to Stripe, using its promotion code as the ID
+func couponParams(c coupons.Coupon) *stripe.CouponParams {
+	params := &stripe.CouponParams{
+		ID:       stripe.String(c.Code),
+		Name:     stripe.String(c.Name),
+		Duration: stripe.String(string(c.Duration)),
+	}
+	if c.PercentOff.IsZero() {
+		params.AmountOff = stripe.Int64(c.AmountOffCents)
+		params.Currency = stripe.String(strings.ToLower(string(c.Currency)))
+	} else {
+		params.PercentOff = stripe.Float64(c.PercentOff.InexactFloat64())
+	}
+	if c.Duration == coupons.Repeating {
+		params.DurationInMonths = stripe.Int64(int64(c.DurationInMonths))
+	}
+	if c.MaxRedemptions > 0 {
+		params.MaxRedemptions = stripe.Int64(int64(c.MaxRedemptions))
+	}
+	if c.RedeemBy != nil {
+		params.RedeemBy = stripe.Int64(c.RedeemBy.Unix())
+	}
+	return params
+}
+
+func (s *Stripe) ParseWebhook(payload []byte, header http.Header) (Event, error) {
+	if s.webhookSecret == "" {
+		return Event{}, ErrWebhookNotConfigured
+	}
+	event, err := webhook.ConstructEvent(payload, header.Get("Stripe-Signature"), s.webhookSecret)
+	if err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	e := Event{ID: event.ID, Type: string(event.Type)}
+	switch {
+	case strings.HasPrefix(e.Type, "invoice."):
+		var inv stripe.Invoice
+		if err := json.Unmarshal(event.Data.Raw, &inv); err != nil {
+			return Event{}, err
+		}
+		i := fromStripeInvoice(&inv)
+		e.Invoice = &i
+	case strings.HasPrefix(e.Type, "customer.subscription."):
+		var sub stripe.Subscription
+		if err := json.Unmarshal(event.Data.Raw, &sub); err != nil {
+			return Event{}, err
+		}
+		sb := fromStripeSubscription(&sub)
+		e.Subscription = &sb
+	case strings.HasPrefix(e.Type, "checkout.session."):
+		var cs stripe.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &cs); err != nil {
+			return Event{}, err
+		}
+		c := fromStripeCheckoutSession(&cs)
+		e.Checkout = &c
+	case strings.HasPrefix(e.Type, "charge."):
+		var ch stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &ch); err != nil {
+			return Event{}, err
+		}
+		c := fromStripeCharge(&ch)
+		e.Charge = &c
+	}
+	return e, nil
+}
+
+func fromStripeSubscription(sub *stripe.Subscription) Subscription {
+	s := Subscription{
+		ID:                 sub.ID,
+		Status:             string(sub.Status),
+		CurrentPeriodStart: time.Unix(sub.CurrentPeriodStart, 0),
+		CurrentPeriodEnd:   time.Unix(sub.CurrentPeriodEnd, 0),
+	}
+	if sub.Customer != nil {
+		s.CustomerID = sub.Customer.ID
+	}
+	if sub.Items != nil && len(sub.Items.Data) > 0 && sub.Items.Data[0].Price != nil {
+		s.PriceID = sub.Items.Data[0].Price.ID
+	}
+	if sub.BillingCycleAnchor > 0 {
+		s.BillingCycleAnchor = time.Unix(sub.BillingCycleAnchor, 0)
+	}
+	if sub.TrialEnd > 0 {
+		end := time.Unix(sub.TrialEnd, 0)
+		s.TrialEnd = &end
+	}
+	return s
+}
+
+func fromStripeInvoice(inv *stripe.Invoice) Invoice {
+	i := Invoice{
+		ID:            inv.ID,
+		Number:        inv.Number,
+		Currency:      money.Currency(strings.ToUpper(string(inv.Currency))),
+		SubtotalCents: inv.Subtotal,
+		TaxCents:      inv.Tax,
+		TotalCents:    inv.Total,
+		Status:        string(inv.Status),
+		PeriodStart:   time.Unix(inv.PeriodStart, 0),
+		PeriodEnd:     time.Unix(inv.PeriodEnd, 0),
+		CreatedAt:     time.Unix(inv.Created, 0),
+	}
+	if inv.StatusTransitions != nil && inv.StatusTransitions.PaidAt > 0 {
+		paid := time.Unix(inv.StatusTransitions.PaidAt, 0)
+		i.PaidAt = &paid
+	}
+	if inv.Customer != nil {
+		i.CustomerID = inv.Customer.ID
+	}
+	if inv.Subscription != nil {
+		i.SubscriptionID = inv.Subscription.ID
+	}
+	return i
+}
+
+func fromStripeCheckoutSession(cs *stripe.CheckoutSession) CheckoutSession {
+	c := CheckoutSession{ID: cs.ID, URL: cs.URL, Metadata: cs.Metadata}
+	if cs.Customer != nil {
+		c.CustomerID = cs.Customer.ID
+	}
+	if cs.Subscription != nil {
+		c.SubscriptionID = cs.Subscription.ID
+	}
+	return c
+}
+
+func fromStripeCharge(ch *stripe.Charge) Charge {
+	c := Charge{
+		ID:            ch.ID,
+		Currency:      money.Currency(strings.ToUpper(string(ch.Currency))),
+		AmountCents:   ch.Amount,
+		RefundedCents: ch.AmountRefunded,
+	}
+	if ch.Customer != nil {
+		c.CustomerID = ch.Customer.ID
+	}
+	if ch.Invoice != nil {
+		c.InvoiceID = ch.Invoice.ID
+	}
+	return c
+}
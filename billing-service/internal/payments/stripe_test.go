@@ -0,0 +1,189 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+	"github.com/stripe/stripe-go/v76/webhook"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+func signedStripeEvent(secret, eventType, object string) ([]byte, http.Header) {
+	payload := []byte(fmt.Sprintf(`{"id":"evt_1","object":"event","api_version":%q,"type":%q,"data":{"object":%s}}`,
+		stripe.APIVersion, eventType, object))
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: secret})
+	header := http.Header{}
+	header.Set("Stripe-Signature", signed.Header)
+	return payload, header
+}
+
+func TestStripeParseWebhook(t *testing.T) {
+	s := NewStripe("sk_test", "whsec_test")
+
+	payload, header := signedStripeEvent("whsec_test", EventInvoicePaid,
+		`{"id":"in_1","object":"invoice","customer":"cus_1","subscription":"sub_1","currency":"eur","total":4200,"status":"paid"}`)
+	e, err := s.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("Expected the event to parse, got %v", err)
+	}
+	if e.Type != EventInvoicePaid || e.Invoice == nil {
+		t.Fatalf("Expected an invoice.paid event, got %+v", e)
+	}
+	if e.Invoice.ID != "in_1" || e.Invoice.CustomerID != "cus_1" || e.Invoice.SubscriptionID != "sub_1" ||
+		e.Invoice.Currency != money.EUR || e.Invoice.TotalCents != 4200 {
+		t.Errorf("Expected invoice in_1 for 4200 EUR, got %+v", e.Invoice)
+	}
+
+	payload, header = signedStripeEvent("whsec_test", EventSubscriptionUpdated,
+		`{"id":"sub_1","object":"subscription","status":"past_due","current_period_start":1788220800,"current_period_end":1790812800,"billing_cycle_anchor":1785542400}`)
+	e, err = s.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("Expected the event to parse, got %v", err)
+	}
+	if e.Subscription == nil || e.Subscription.Status != StatusPastDue ||
+		!e.Subscription.CurrentPeriodStart.Equal(time.Unix(1788220800, 0)) ||
+		!e.Subscription.BillingCycleAnchor.Equal(time.Unix(1785542400, 0)) {
+		t.Errorf("Expected a past_due subscription, got %+v", e.Subscription)
+	}
+
+	payload, header = signedStripeEvent("whsec_test", EventChargeRefunded,
+		`{"id":"ch_1","object":"charge","customer":"cus_1","invoice":"in_1","currency":"usd","amount":4500,"amount_refunded":1500,"refunded":false}`)
+	e, err = s.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("Expected the event to parse, got %v", err)
+	}
+	if e.Charge == nil || e.Charge.InvoiceID != "in_1" || e.Charge.AmountCents != 4500 || e.Charge.RefundedCents != 1500 ||
+		e.Charge.Currency != money.USD {
+		t.Errorf("Expected 1500 of 4500 USD refunded for in_1, got %+v", e.Charge)
+	}
+
+	payload, header = signedStripeEvent("whsec_test", EventCheckoutCompleted,
+		`{"id":"cs_1","object":"checkout.session","customer":"cus_1","subscription":"sub_1","metadata":{"plan_id":"essential"}}`)
+	e, err = s.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("Expected the event to parse, got %v", err)
+	}
+	if e.Checkout == nil || e.Checkout.SubscriptionID != "sub_1" || e.Checkout.Metadata["plan_id"] != "essential" {
+		t.Errorf("Expected a checkout of sub_1 for essential, got %+v", e.Checkout)
+	}
+
+	payload, header = signedStripeEvent("whsec_other", EventInvoicePaid, `{"id":"in_1","object":"invoice"}`)
+	if _, err := s.ParseWebhook(payload, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	if _, err := NewStripe("sk_test", "").ParseWebhook(payload, header); !errors.Is(err, ErrWebhookNotConfigured) {
+		t.Errorf("Expected ErrWebhookNotConfigured, got %v", err)
+	}
+}
+
+func TestCouponParams(t *testing.T) {
+	percent := couponParams(coupons.Coupon{Code: "LAUNCH20", Name: "Launch", PercentOff: decimal.NewFromInt(20),
+		Duration: coupons.Repeating, DurationInMonths: 3})
+	if *percent.ID != "LAUNCH20" || *percent.PercentOff != 20 || *percent.DurationInMonths != 3 || percent.AmountOff != nil {
+		t.Errorf("Expected 20%% off for 3 months, got %+v", percent)
+	}
+
+	amount := couponParams(coupons.Coupon{Code: "TENOFF", AmountOffCents: 1000, Currency: money.GBP, Duration: coupons.Once})
+	if *amount.AmountOff != 1000 || *amount.Currency != "gbp" || amount.PercentOff != nil || amount.DurationInMonths != nil {
+		t.Errorf("Expected 10 GBP off once, got %+v", amount)
+	}
+}
+
+// stripeStub records the requests made to a fake Stripe API
+type stripeStub struct {
+	requests []string
+	keys     []string
+	failItem bool
+}
+
+func (s *stripeStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path+" "+r.Form.Get("invoice"))
+	s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/v1/invoiceitems" && s.failItem:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"type":"invalid_request_error","message":"no"}}`)
+	case r.URL.Path == "/v1/invoiceitems":
+		fmt.Fprint(w, `{"id":"ii_1","object":"invoiceitem"}`)
+	case r.URL.Path == "/v1/invoices/in_1/finalize":
+		fmt.Fprint(w, `{"id":"in_1","object":"invoice","customer":"cus_1","currency":"usd","total":4500,"status":"open"}`)
+	default:
+		fmt.Fprint(w, `{"id":"in_1","object":"invoice","customer":"cus_1","currency":"usd","status":"draft"}`)
+	}
+}
+
+func stubbedStripe(t *testing.T, stub *stripeStub) *Stripe {
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	retries := int64(0)
+	backend := stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(srv.URL),
+		MaxNetworkRetries: &retries,
+		LeveledLogger:     &stripe.LeveledLogger{Level: stripe.LevelNull},
+	})
+	return &Stripe{api: client.New("sk_test", &stripe.Backends{API: backend, Connect: backend, Uploads: backend})}
+}
+
+func TestStripeCreateInvoice(t *testing.T) {
+	stub := &stripeStub{}
+	s := stubbedStripe(t, stub)
+
+	inv, err := s.CreateInvoice(context.Background(), InvoiceParams{
+		CustomerID:     "cus_1",
+		Currency:       money.USD,
+		Items:          []InvoiceItem{{AmountCents: 5000, Description: "Essential Plan"}, {AmountCents: -500, Description: "Credits applied"}},
+		IdempotencyKey: "billing-1",
+	})
+	if err != nil {
+		t.Fatalf("Expected an invoice, got %v", err)
+	}
+	if inv.ID != "in_1" || inv.Status != "open" || inv.TotalCents != 4500 {
+		t.Errorf("Expected finalized invoice in_1 for 4500, got %+v", inv)
+	}
+
+	expected := []string{"POST /v1/invoices ", "POST /v1/invoiceitems in_1", "POST /v1/invoiceitems in_1", "POST /v1/invoices/in_1/finalize "}
+	expectedKeys := []string{"billing-1-invoice", "billing-1-item-0", "billing-1-item-1", "billing-1-finalize"}
+	if len(stub.requests) != len(expected) {
+		t.Fatalf("Expected requests %q, got %q", expected, stub.requests)
+	}
+	for i := range expected {
+		if stub.requests[i] != expected[i] || stub.keys[i] != expectedKeys[i] {
+			t.Errorf("Expected request %d to be %q keyed %q, got %q keyed %q", i, expected[i], expectedKeys[i], stub.requests[i], stub.keys[i])
+		}
+	}
+}
+
+func TestStripeCreateInvoiceItemFails(t *testing.T) {
+	stub := &stripeStub{failItem: true}
+	s := stubbedStripe(t, stub)
+
+	_, err := s.CreateInvoice(context.Background(), InvoiceParams{
+		CustomerID: "cus_1",
+		Currency:   money.USD,
+		Items:      []InvoiceItem{{AmountCents: 5000, Description: "Essential Plan"}},
+	})
+	if err == nil {
+		t.Fatal("Expected the failed item to fail the invoice")
+	}
+	for _, r := range stub.requests {
+		if strings.Contains(r, "finalize") {
+			t.Errorf("Expected the draft not to be finalized, got %q", stub.requests)
+		}
+	}
+	if stub.requests[1] != "POST /v1/invoiceitems in_1" {
+		t.Errorf("Expected the item to be added to the draft, got %q", stub.requests[1])
+	}
+}
@@ -0,0 +1,255 @@
+package plans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+var (
+	ErrVersionInPast      = errors.New("plan versions cannot take effect in the past")
+	ErrVersionNotAfter    = errors.New("plan version must take effect after the latest version")
+	ErrVersionInEffect    = errors.New("plan version has already taken effect")
+	ErrVersionNotFound    = errors.New("plan version not found")
+	errCatalogUnavailable = errors.New("plan catalog has no database")
+)
+
+const planColumns = `plan_id, version, name, description, base_price_cents, base_price_display, actions_included,
+	active_storage_gb, retained_storage_gb, COALESCE(stripe_price_id, ''), trial_days, prices, currency_prices, max_namespaces, max_retention_days,
+	namespace_rps, namespace_global_rps, replication, public, self_serve, sort_order, features,
+	effective_from, created_at`
+
+// Catalog reads and writes plan versions in Postgres. A nil Catalog, or one without a
+// database, serves the built-in defaults read-only.
+type Catalog struct {
+	db *pgxpool.Pool
+}
+
+// NewCatalog creates a catalog backed by the plan_versions table
+func NewCatalog(db *pgxpool.Pool) *Catalog {
+	return &Catalog{db: db}
+}
+
+// List returns every version of every plan
+func (c *Catalog) List(ctx context.Context) ([]Plan, error) {
+	if c == nil || c.db == nil {
+		return Defaults(), nil
+	}
+
+	rows, err := c.db.Query(ctx, `SELECT `+planColumns+` FROM plan_versions ORDER BY sort_order, plan_id, version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []Plan
+	for rows.Next() {
+		var p Plan
+		if err := rows.Scan(&p.ID, &p.Version, &p.Name, &p.Description, &p.BasePriceCents, &p.BasePriceDisplay,
+			&p.ActionsIncluded, &p.ActiveStorageGB, &p.RetainedStorageGB, &p.StripePriceID, &p.TrialDays, &p.Prices, &p.CurrencyPrices, &p.MaxNamespaces,
+			&p.MaxRetentionDays, &p.NamespaceRPS, &p.NamespaceGlobalRPS, &p.Replication, &p.Public,
+			&p.SelfServe, &p.SortOrder, &p.Features, &p.EffectiveFrom, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, p)
+	}
+	return versions, rows.Err()
+}
+
+// Versions returns all versions of one plan, oldest first
+func (c *Catalog) Versions(ctx context.Context, id string) ([]Plan, error) {
+	all, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var versions []Plan
+	for _, p := range all {
+		if p.ID == id {
+			versions = append(versions, p)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, ErrPlanNotFound
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// Get returns the version of a plan in effect at the given time
+func (c *Catalog) Get(ctx context.Context, id string, at time.Time) (Plan, error) {
+	all, err := c.List(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	p, ok := Find(all, id, at)
+	if !ok {
+		return Plan{}, ErrPlanNotFound
+	}
+	return p, nil
+}
+
+// Lookup is Get for existing subscriptions: unknown plans resolve to the free plan
+func (c *Catalog) Lookup(ctx context.Context, id string, at time.Time) (Plan, error) {
+	all, err := c.List(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	return Effective(all, id, at), nil
+}
+
+// Create adds a new plan as version 1
+func (c *Catalog) Create(ctx context.Context, p Plan, now time.Time) (Plan, error) {
+	if c == nil || c.db == nil {
+		return Plan{}, errCatalogUnavailable
+	}
+	if p.EffectiveFrom.IsZero() {
+		p.EffectiveFrom = now
+	}
+	if p.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return Plan{}, ErrVersionInPast
+	}
+
+	var exists bool
+	if err := c.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM plan_versions WHERE plan_id = $1)`, p.ID).Scan(&exists); err != nil {
+		return Plan{}, err
+	}
+	if exists {
+		return Plan{}, ErrPlanExists
+	}
+
+	p.Version = 1
+	return p, insertPlan(ctx, c.db, &p)
+}
+
+// AddVersion schedules a new version of an existing plan. Versions take effect in order
+// and never retroactively, so billed periods keep the terms they were billed under.
+func (c *Catalog) AddVersion(ctx context.Context, p Plan, now time.Time) (Plan, error) {
+	if c == nil || c.db == nil {
+		return Plan{}, errCatalogUnavailable
+	}
+	if p.EffectiveFrom.IsZero() {
+		p.EffectiveFrom = now
+	}
+	if p.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return Plan{}, ErrVersionInPast
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize version numbering per plan
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('plan_versions:' || $1))`, p.ID); err != nil {
+		return Plan{}, err
+	}
+
+	var latestVersion int
+	var latestFrom time.Time
+	err = tx.QueryRow(ctx,
+		`SELECT version, effective_from FROM plan_versions WHERE plan_id = $1 ORDER BY version DESC LIMIT 1`, p.ID).
+		Scan(&latestVersion, &latestFrom)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Plan{}, ErrPlanNotFound
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+	if !p.EffectiveFrom.After(latestFrom) {
+		return Plan{}, ErrVersionNotAfter
+	}
+
+	p.Version = latestVersion + 1
+	if err := insertPlan(ctx, tx, &p); err != nil {
+		return Plan{}, err
+	}
+	return p, tx.Commit(ctx)
+}
+
+// DeleteVersion removes a version that has not taken effect yet
+func (c *Catalog) DeleteVersion(ctx context.Context, id string, version int, now time.Time) error {
+	if c == nil || c.db == nil {
+		return errCatalogUnavailable
+	}
+
+	var effectiveFrom time.Time
+	err := c.db.QueryRow(ctx,
+		`SELECT effective_from FROM plan_versions WHERE plan_id = $1 AND version = $2`, id, version).
+		Scan(&effectiveFrom)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !effectiveFrom.After(now) {
+		return ErrVersionInEffect
+	}
+
+	_, err = c.db.Exec(ctx,
+		`DELETE FROM plan_versions WHERE plan_id = $1 AND version = $2 AND effective_from > $3`, id, version, now)
+	return err
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertPlan(ctx context.Context, q execer, p *Plan) error {
+	if p.Features == nil {
+		p.Features = []string{}
+	}
+	if p.Prices == nil {
+		p.Prices = pricing.PriceBook{}
+	}
+	if p.CurrencyPrices == nil {
+		p.CurrencyPrices = map[money.Currency]CurrencyPrice{}
+	}
+	p.CreatedAt = time.Now()
+	_, err := q.Exec(ctx,
+		`INSERT INTO plan_versions (plan_id, version, name, description, base_price_cents, base_price_display,
+		 actions_included, active_storage_gb, retained_storage_gb, stripe_price_id, trial_days, prices, currency_prices, max_namespaces, max_retention_days,
+		 namespace_rps, namespace_global_rps, replication, public, self_serve, sort_order, features,
+		 effective_from, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)`,
+		p.ID, p.Version, p.Name, p.Description, p.BasePriceCents, p.BasePriceDisplay, p.ActionsIncluded,
+		p.ActiveStorageGB, p.RetainedStorageGB, p.StripePriceID, p.TrialDays, p.Prices, p.CurrencyPrices, p.MaxNamespaces, p.MaxRetentionDays,
+		p.NamespaceRPS, p.NamespaceGlobalRPS, p.Replication, p.Public, p.SelfServe, p.SortOrder, p.Features,
+		p.EffectiveFrom, p.CreatedAt)
+	return err
+}
+
+// Seed inserts the default catalog for plans that have no versions yet
+func Seed(ctx context.Context, db *pgxpool.Pool) error {
+	for _, p := range Defaults() {
+		var exists bool
+		if err := db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM plan_versions WHERE plan_id = $1)`, p.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check plan %s: %w", p.ID, err)
+		}
+		if exists {
+			continue
+		}
+		if err := insertPlan(ctx, db, &p); err != nil {
+			return fmt.Errorf("failed to seed plan %s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+func sortPlans(plans []Plan) {
+	sort.SliceStable(plans, func(i, j int) bool {
+		if plans[i].SortOrder != plans[j].SortOrder {
+			return plans[i].SortOrder < plans[j].SortOrder
+		}
+		return plans[i].ID < plans[j].ID
+	})
+}
@@ -0,0 +1,414 @@
+// Package plans holds the subscription plan catalog. Plans are stored as
+// effective-dated versions so price and entitlement changes can be scheduled
+// without rewriting the terms of periods that were already billed.
+package plans
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+// FreePlanID is the plan organizations start on and fall back to
+const FreePlanID = "free"
+
+var (
+	ErrPlanNotFound       = errors.New("plan not found")
+	ErrPlanExists         = errors.New("plan already exists")
+	ErrCurrencyNotOffered = errors.New("plan is not offered in this currency")
+)
+
+var planIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// Plan is one version of a subscription plan
+type Plan struct {
+	ID                string          `json:"id"`
+	Version           int             `json:"version"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	BasePriceCents    int64           `json:"base_price_cents"`
+	BasePriceDisplay  string          `json:"base_price_display"`
+	ActionsIncluded   int64           `json:"actions_included"`
+	ActiveStorageGB   decimal.Decimal `json:"active_storage_gb"`
+	RetainedStorageGB decimal.Decimal `json:"retained_storage_gb"`
+	StripePriceID     string          `json:"stripe_price_id,omitempty"`
+
+	// Days an organization upgrading from the free plan can try the plan without paying
+	TrialDays int `json:"trial_days"`
+
+	// Usage prices that differ from the list prices
+	Prices pricing.PriceBook `json:"prices,omitempty"`
+
+	// Fixed prices in currencies other than USD. A plan is only sold in USD and
+	// the currencies listed here.
+	CurrencyPrices map[money.Currency]CurrencyPrice `json:"currency_prices,omitempty"`
+
+	// Namespace entitlements
+	MaxNamespaces      int  `json:"max_namespaces"` // 0 means unlimited
+	MaxRetentionDays   int  `json:"max_retention_days"`
+	NamespaceRPS       int  `json:"namespace_rps"`        // per frontend
+	NamespaceGlobalRPS int  `json:"namespace_global_rps"` // across the cluster's frontends
+	Replication        bool `json:"replication"`
+
+	// Public plans are listed on /plans; self-serve plans can be bought through checkout
+	Public    bool     `json:"public"`
+	SelfServe bool     `json:"self_serve"`
+	SortOrder int      `json:"sort_order"`
+	Features  []string `json:"features"`
+
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CurrencyPrice is a plan's price in one non-USD currency
+type CurrencyPrice struct {
+	BasePriceMinor   int64             `json:"base_price_minor"`
+	BasePriceDisplay string            `json:"base_price_display"`
+	StripePriceID    string            `json:"stripe_price_id,omitempty"`
+	Prices           pricing.PriceBook `json:"prices,omitempty"`
+}
+
+// Validate checks the fields an administrator can set
+func (p Plan) Validate() error {
+	if !planIDPattern.MatchString(p.ID) {
+		return fmt.Errorf("plan id must be lowercase letters, digits and underscores")
+	}
+	if p.Name == "" {
+		return fmt.Errorf("plan name is required")
+	}
+	if p.BasePriceCents < 0 || p.ActionsIncluded < 0 {
+		return fmt.Errorf("base price and included actions must not be negative")
+	}
+	if p.ActiveStorageGB.IsNegative() || p.RetainedStorageGB.IsNegative() {
+		return fmt.Errorf("included storage must not be negative")
+	}
+	if p.TrialDays < 0 || p.TrialDays > 90 {
+		return fmt.Errorf("trial days must be between 0 and 90")
+	}
+	if p.TrialDays > 0 && p.ID == FreePlanID {
+		return fmt.Errorf("the free plan cannot have a trial")
+	}
+	if err := p.Prices.Validate(); err != nil {
+		return fmt.Errorf("invalid prices: %w", err)
+	}
+	for c, cp := range p.CurrencyPrices {
+		if parsed, err := money.ParseCurrency(string(c)); err != nil || parsed != c || c == money.USD {
+			return fmt.Errorf("currency prices must use non-USD ISO currency codes, got %q", c)
+		}
+		if _, ok := pricing.ListPrices(c); !ok && len(cp.Prices) < len(pricing.Meters) {
+			return fmt.Errorf("%s has no list prices, so every meter must be priced", c)
+		}
+		if cp.BasePriceMinor < 0 {
+			return fmt.Errorf("%s base price must not be negative", c)
+		}
+		if err := cp.Prices.Validate(); err != nil {
+			return fmt.Errorf("invalid %s prices: %w", c, err)
+		}
+	}
+	if p.MaxNamespaces < 0 || p.MaxRetentionDays < 0 || p.NamespaceRPS < 0 || p.NamespaceGlobalRPS < 0 {
+		return fmt.Errorf("namespace entitlements must not be negative")
+	}
+	return nil
+}
+
+// PriceBook returns the list prices with the plan's overrides applied
+func (p Plan) PriceBook() pricing.PriceBook {
+	return pricing.Default().With(p.Prices)
+}
+
+// Offers reports whether the plan can be billed in a currency
+func (p Plan) Offers(c money.Currency) bool {
+	if c == money.USD {
+		return true
+	}
+	_, ok := p.CurrencyPrices[c]
+	return ok
+}
+
+// PricesIn returns the plan's base price and usage prices in a currency
+func (p Plan) PricesIn(c money.Currency) (CurrencyPrice, pricing.PriceBook, error) {
+	if c == money.USD {
+		usd := CurrencyPrice{
+			BasePriceMinor:   p.BasePriceCents,
+			BasePriceDisplay: p.BasePriceDisplay,
+			StripePriceID:    p.StripePriceID,
+			Prices:           p.Prices,
+		}
+		return usd, p.PriceBook(), nil
+	}
+	cp, ok := p.CurrencyPrices[c]
+	if !ok {
+		return CurrencyPrice{}, nil, fmt.Errorf("%w: %s %s", ErrCurrencyNotOffered, p.ID, c)
+	}
+	list, _ := pricing.ListPrices(c)
+	if list == nil {
+		list = pricing.PriceBook{}
+	}
+	return cp, list.With(cp.Prices), nil
+}
+
+// Allowance returns the usage included in the base price for a billing period.
+// Storage allowances are in GB, so they become GB-hours over the length of the period.
+func (p Plan) Allowance(periodStart, periodEnd time.Time) pricing.Usage {
+	hours := decimal.Zero
+	if periodEnd.After(periodStart) {
+		hours = decimal.NewFromInt(int64(periodEnd.Sub(periodStart) / time.Second)).Div(decimal.NewFromInt(3600))
+	}
+	return pricing.Usage{
+		Actions:            p.ActionsIncluded,
+		ActiveStorageGBH:   p.ActiveStorageGB.Mul(hours),
+		RetainedStorageGBH: p.RetainedStorageGB.Mul(hours),
+	}
+}
+
+// Find returns the version of plan id in effect at the given time
+func Find(versions []Plan, id string, at time.Time) (Plan, bool) {
+	var found Plan
+	ok := false
+	for _, v := range versions {
+		if v.ID != id || v.EffectiveFrom.After(at) {
+			continue
+		}
+		if !ok || v.EffectiveFrom.After(found.EffectiveFrom) ||
+			(v.EffectiveFrom.Equal(found.EffectiveFrom) && v.Version > found.Version) {
+			found = v
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// Effective returns the version of plan id in effect at the given time, falling back to
+// the free plan for unknown plans. Subscriptions on a removed plan keep free entitlements.
+func Effective(versions []Plan, id string, at time.Time) Plan {
+	if p, ok := Find(versions, id, at); ok {
+		return p
+	}
+	p, _ := Find(versions, FreePlanID, at)
+	return p
+}
+
+// Current returns the version of every plan in effect at the given time, in display order
+func Current(versions []Plan, at time.Time) []Plan {
+	seen := make(map[string]bool)
+	var current []Plan
+	for _, v := range versions {
+		if seen[v.ID] {
+			continue
+		}
+		if p, ok := Find(versions, v.ID, at); ok {
+			seen[v.ID] = true
+			current = append(current, p)
+		}
+	}
+	sortPlans(current)
+	return current
+}
+
+// Defaults returns the initial catalog seeded into an empty database
+func Defaults() []Plan {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []Plan{
+		{
+			ID:                 "free",
+			Version:            1,
+			Name:               "Free",
+			Description:        "For trying out Temporal",
+			BasePriceDisplay:   "Free",
+			ActionsIncluded:    100000,
+			ActiveStorageGB:    decimal.NewFromFloat(0.1),
+			RetainedStorageGB:  decimal.NewFromInt(4),
+			MaxNamespaces:      1,
+			MaxRetentionDays:   7,
+			NamespaceRPS:       100,
+			NamespaceGlobalRPS: 50,
+			SortOrder:          0,
+			Features:           []string{},
+			CurrencyPrices: map[money.Currency]CurrencyPrice{
+				money.EUR: {
+					BasePriceMinor:   0,
+					BasePriceDisplay: "Free",
+				},
+				money.GBP: {
+					BasePriceMinor:   0,
+					BasePriceDisplay: "Free",
+				},
+			},
+			EffectiveFrom: epoch,
+		},
+		{
+			ID:                 "essential",
+			Version:            1,
+			Name:               "Essential",
+			Description:        "For basic workflows",
+			BasePriceCents:     10000, // $100/month
+			BasePriceDisplay:   "Starting at $100/mo",
+			ActionsIncluded:    1000000,
+			ActiveStorageGB:    decimal.NewFromInt(1),
+			RetainedStorageGB:  decimal.NewFromInt(40),
+			StripePriceID:      "price_essential_monthly",
+			TrialDays:          14,
+			MaxNamespaces:      10,
+			MaxRetentionDays:   30,
+			NamespaceRPS:       800,
+			NamespaceGlobalRPS: 400,
+			Replication:        true,
+			Public:             true,
+			SelfServe:          true,
+			SortOrder:          1,
+			Features: []string{
+				"1M Actions included",
+				"1 GB Active Storage",
+				"40 GB Retained Storage",
+				"99.9% SLA, 99.99% HA Options",
+				"Multi-Cloud & Multi-Region",
+				"User Roles & API Keys",
+				"Audit Logging",
+				"1 Business Day P0 Response",
+			},
+			CurrencyPrices: map[money.Currency]CurrencyPrice{
+				money.EUR: {
+					BasePriceMinor:   9200,
+					BasePriceDisplay: "Starting at €92/mo",
+					StripePriceID:    "price_essential_monthly_eur",
+				},
+				money.GBP: {
+					BasePriceMinor:   8000,
+					BasePriceDisplay: "Starting at £80/mo",
+					StripePriceID:    "price_essential_monthly_gbp",
+				},
+			},
+			EffectiveFrom: epoch,
+		},
+		{
+			ID:                 "business",
+			Version:            1,
+			Name:               "Business",
+			Description:        "For teams scaling Temporal",
+			BasePriceCents:     50000, // $500/month
+			BasePriceDisplay:   "Starting at $500/mo",
+			ActionsIncluded:    2500000,
+			ActiveStorageGB:    decimal.NewFromFloat(2.5),
+			RetainedStorageGB:  decimal.NewFromInt(100),
+			StripePriceID:      "price_business_monthly",
+			TrialDays:          14,
+			MaxNamespaces:      50,
+			MaxRetentionDays:   90,
+			NamespaceRPS:       1600,
+			NamespaceGlobalRPS: 800,
+			Replication:        true,
+			Public:             true,
+			SelfServe:          true,
+			SortOrder:          2,
+			Features: []string{
+				"2.5M Actions included",
+				"2.5 GB Active Storage",
+				"100 GB Retained Storage",
+				"Everything in Essentials",
+				"SAML SSO Included",
+				"SCIM Add-on",
+				"2 Business Hours P0 Response",
+				"Workflow Troubleshooting",
+			},
+			CurrencyPrices: map[money.Currency]CurrencyPrice{
+				money.EUR: {
+					BasePriceMinor:   46000,
+					BasePriceDisplay: "Starting at €460/mo",
+					StripePriceID:    "price_business_monthly_eur",
+				},
+				money.GBP: {
+					BasePriceMinor:   40000,
+					BasePriceDisplay: "Starting at £400/mo",
+					StripePriceID:    "price_business_monthly_gbp",
+				},
+			},
+			EffectiveFrom: epoch,
+		},
+		{
+			ID:                 "enterprise",
+			Version:            1,
+			Name:               "Enterprise",
+			Description:        "For enterprise and mission critical",
+			BasePriceCents:     0, // Custom pricing
+			BasePriceDisplay:   "Contact Sales",
+			ActionsIncluded:    10000000,
+			ActiveStorageGB:    decimal.NewFromInt(10),
+			RetainedStorageGB:  decimal.NewFromInt(400),
+			StripePriceID:      "price_enterprise_monthly",
+			MaxNamespaces:      100,
+			MaxRetentionDays:   90,
+			NamespaceRPS:       4000,
+			NamespaceGlobalRPS: 2000,
+			Replication:        true,
+			Public:             true,
+			SortOrder:          3,
+			Features: []string{
+				"10M Actions included",
+				"10 GB Active Storage",
+				"400 GB Retained Storage",
+				"Everything in Business",
+				"SCIM Included",
+				"24/7, 30 Minute P0 Response",
+				"Technical Onboarding",
+				"Design Review",
+			},
+			CurrencyPrices: map[money.Currency]CurrencyPrice{
+				money.EUR: {
+					BasePriceMinor:   0,
+					BasePriceDisplay: "Contact Sales",
+					StripePriceID:    "price_enterprise_monthly_eur",
+				},
+				money.GBP: {
+					BasePriceMinor:   0,
+					BasePriceDisplay: "Contact Sales",
+					StripePriceID:    "price_enterprise_monthly_gbp",
+				},
+			},
+			EffectiveFrom: epoch,
+		},
+		{
+			ID:                 "mission_critical",
+			Version:            1,
+			Name:               "Mission Critical",
+			Description:        "For mission critical workloads",
+			BasePriceCents:     0, // Custom pricing
+			BasePriceDisplay:   "Contact Sales",
+			ActionsIncluded:    10000000,
+			ActiveStorageGB:    decimal.NewFromInt(10),
+			RetainedStorageGB:  decimal.NewFromInt(400),
+			MaxNamespaces:      100,
+			MaxRetentionDays:   90,
+			NamespaceRPS:       4000,
+			NamespaceGlobalRPS: 2000,
+			Replication:        true,
+			Public:             true,
+			SortOrder:          4,
+			Features: []string{
+				"10M+ Actions",
+				"10+ GB Active Storage",
+				"400+ GB Retained Storage",
+				"Everything in Enterprise",
+				"Designated Support Engineer",
+				"Worker Tuning",
+				"Cost Reviews",
+				"Security Reviews",
+			},
+			CurrencyPrices: map[money.Currency]CurrencyPrice{
+				money.EUR: {
+					BasePriceMinor:   0,
+					BasePriceDisplay: "Contact Sales",
+				},
+				money.GBP: {
+					BasePriceMinor:   0,
+					BasePriceDisplay: "Contact Sales",
+				},
+			},
+			EffectiveFrom: epoch,
+		},
+	}
+}
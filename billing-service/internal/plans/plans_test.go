@@ -0,0 +1,219 @@
+package plans
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+)
+
+func TestFindEffectiveVersion(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	versions := []Plan{
+		{ID: "essential", Version: 1, BasePriceCents: 10000, EffectiveFrom: jan},
+		{ID: "essential", Version: 2, BasePriceCents: 12000, EffectiveFrom: mar},
+		{ID: "free", Version: 1, EffectiveFrom: jan},
+	}
+
+	tests := []struct {
+		name            string
+		at              time.Time
+		expectedFound   bool
+		expectedVersion int
+	}{
+		{"before first version", jan.Add(-time.Hour), false, 0},
+		{"at first version", jan, true, 1},
+		{"between versions", mar.Add(-time.Second), true, 1},
+		{"at second version", mar, true, 2},
+		{"after second version", mar.AddDate(1, 0, 0), true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := Find(versions, "essential", tt.at)
+			if ok != tt.expectedFound {
+				t.Fatalf("Expected found=%v, got %v", tt.expectedFound, ok)
+			}
+			if ok && p.Version != tt.expectedVersion {
+				t.Errorf("Expected version %d, got %d", tt.expectedVersion, p.Version)
+			}
+		})
+	}
+}
+
+func TestEffectiveFallsBackToFree(t *testing.T) {
+	p := Effective(Defaults(), "retired_plan", time.Now())
+	if p.ID != FreePlanID {
+		t.Errorf("Expected unknown plan to fall back to free, got %s", p.ID)
+	}
+}
+
+func TestCurrentOrdersPlans(t *testing.T) {
+	now := time.Now()
+	versions := append(Defaults(), Plan{
+		ID: "essential", Version: 2, Name: "Essential", SortOrder: 1, EffectiveFrom: now.Add(time.Hour),
+	})
+
+	current := Current(versions, now)
+	expected := []string{"free", "essential", "business", "enterprise", "mission_critical"}
+	if len(current) != len(expected) {
+		t.Fatalf("Expected %d plans, got %d", len(expected), len(current))
+	}
+	for i, id := range expected {
+		if current[i].ID != id {
+			t.Errorf("Position %d: expected %s, got %s", i, id, current[i].ID)
+		}
+		if current[i].Version != 1 {
+			t.Errorf("%s: scheduled version should not be current, got version %d", id, current[i].Version)
+		}
+	}
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, p := range Defaults() {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: %v", p.ID, err)
+		}
+		if seen[p.ID] {
+			t.Errorf("Duplicate default plan %s", p.ID)
+		}
+		seen[p.ID] = true
+	}
+	for _, id := range []string{"free", "essential", "business", "enterprise", "mission_critical"} {
+		if !seen[id] {
+			t.Errorf("Default catalog is missing %s", id)
+		}
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    Plan
+		wantErr bool
+	}{
+		{"valid", Plan{ID: "startup", Name: "Startup", BasePriceCents: 5000}, false},
+		{"uppercase id", Plan{ID: "Startup", Name: "Startup"}, true},
+		{"missing name", Plan{ID: "startup"}, true},
+		{"negative price", Plan{ID: "startup", Name: "Startup", BasePriceCents: -1}, true},
+		{"negative storage", Plan{ID: "startup", Name: "Startup", ActiveStorageGB: decimal.NewFromInt(-1)}, true},
+		{"negative namespaces", Plan{ID: "startup", Name: "Startup", MaxNamespaces: -1}, true},
+		{"trial", Plan{ID: "startup", Name: "Startup", TrialDays: 30}, false},
+		{"overlong trial", Plan{ID: "startup", Name: "Startup", TrialDays: 365}, true},
+		{"trial on free", Plan{ID: "free", Name: "Free", TrialDays: 14}, true},
+		{"invalid prices", Plan{ID: "startup", Name: "Startup", Prices: pricing.PriceBook{pricing.MeterActions: {Mode: "flat"}}}, true},
+		{"euro prices", Plan{ID: "startup", Name: "Startup", CurrencyPrices: map[money.Currency]CurrencyPrice{money.EUR: {BasePriceMinor: 4600}}}, false},
+		{"usd currency price", Plan{ID: "startup", Name: "Startup", CurrencyPrices: map[money.Currency]CurrencyPrice{money.USD: {}}}, true},
+		{"lowercase currency", Plan{ID: "startup", Name: "Startup", CurrencyPrices: map[money.Currency]CurrencyPrice{"eur": {}}}, true},
+		{"negative currency price", Plan{ID: "startup", Name: "Startup", CurrencyPrices: map[money.Currency]CurrencyPrice{money.GBP: {BasePriceMinor: -1}}}, true},
+		{"currency without list prices", Plan{ID: "startup", Name: "Startup", CurrencyPrices: map[money.Currency]CurrencyPrice{"CHF": {}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plan.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAllowanceConvertsStorageToGBHours(t *testing.T) {
+	essential := Effective(Defaults(), "essential", time.Now())
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		end              time.Time
+		expectedActive   int64
+		expectedRetained int64
+	}{
+		{"28-day month", start.AddDate(0, 1, 0), 672, 26880},
+		{"31-day month", start.AddDate(0, 0, 31), 744, 29760},
+		{"empty period", start, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := essential.Allowance(start, tt.end)
+			if a.Actions != essential.ActionsIncluded {
+				t.Errorf("Expected %d included actions, got %d", essential.ActionsIncluded, a.Actions)
+			}
+			if !a.ActiveStorageGBH.Equal(decimal.NewFromInt(tt.expectedActive)) {
+				t.Errorf("Expected %d active GB-hours, got %s", tt.expectedActive, a.ActiveStorageGBH)
+			}
+			if !a.RetainedStorageGBH.Equal(decimal.NewFromInt(tt.expectedRetained)) {
+				t.Errorf("Expected %d retained GB-hours, got %s", tt.expectedRetained, a.RetainedStorageGBH)
+			}
+		})
+	}
+}
+
+func TestPricesIn(t *testing.T) {
+	business := Effective(Defaults(), "business", time.Now())
+
+	tests := []struct {
+		currency        money.Currency
+		expectedBase    int64
+		expectedActions int64
+	}{
+		{money.USD, business.BasePriceCents, pricing.CentsPerMillionActions},
+		{money.EUR, 46000, 2300},
+		{money.GBP, 40000, 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.currency), func(t *testing.T) {
+			if !business.Offers(tt.currency) {
+				t.Fatalf("Expected business to be offered in %s", tt.currency)
+			}
+			price, book, err := business.PricesIn(tt.currency)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if price.BasePriceMinor != tt.expectedBase {
+				t.Errorf("Expected base %d, got %d", tt.expectedBase, price.BasePriceMinor)
+			}
+			if actions := book[pricing.MeterActions].Tiers[0].UnitCents.IntPart(); actions != tt.expectedActions {
+				t.Errorf("Expected %d per million actions, got %d", tt.expectedActions, actions)
+			}
+			if len(book) != len(pricing.Meters) {
+				t.Errorf("Expected every meter priced, got %d", len(book))
+			}
+		})
+	}
+
+	if business.Offers("JPY") {
+		t.Error("Expected business not to be offered in JPY")
+	}
+	if _, _, err := business.PricesIn("JPY"); !errors.Is(err, ErrCurrencyNotOffered) {
+		t.Errorf("Expected ErrCurrencyNotOffered, got %v", err)
+	}
+}
+
+func TestPricesInAppliesCurrencyOverrides(t *testing.T) {
+	plan := Effective(Defaults(), "essential", time.Now())
+	eur := plan.CurrencyPrices[money.EUR]
+	eur.Prices = pricing.PriceBook{pricing.MeterActions: pricing.Flat(1000000, true, decimal.NewFromInt(1800))}
+	plan.CurrencyPrices = map[money.Currency]CurrencyPrice{money.EUR: eur}
+
+	_, book, err := plan.PricesIn(money.EUR)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !book[pricing.MeterActions].Tiers[0].UnitCents.Equal(decimal.NewFromInt(1800)) {
+		t.Errorf("Expected EUR override, got %s", book[pricing.MeterActions].Tiers[0].UnitCents)
+	}
+	if !book[pricing.MeterActiveStorage].Tiers[0].UnitCents.Equal(decimal.RequireFromString("3.9")) {
+		t.Errorf("Expected EUR list price for other meters, got %s", book[pricing.MeterActiveStorage].Tiers[0].UnitCents)
+	}
+}
@@ -0,0 +1,323 @@
+// Package pricing prices metered usage. Each meter has a tiered price, either
+// graduated (every tier prices the units that fall inside it) or volume (the
+// tier reached by the total prices every unit). The billing service and the
+// billing cron both price usage here so estimates and invoices always agree.
+package pricing
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+// Meter identifies a billed usage dimension
+type Meter string
+
+const (
+	MeterActions           Meter = "actions"
+	MeterReplicatedActions Meter = "replicated_actions"
+	MeterActiveStorage     Meter = "active_storage"
+	MeterRetainedStorage   Meter = "retained_storage"
+	MeterArchivedStorage   Meter = "archived_storage"
+)
+
+// Meters lists every meter in invoice order
+var Meters = []Meter{MeterActions, MeterReplicatedActions, MeterActiveStorage, MeterRetainedStorage, MeterArchivedStorage}
+
+// Mode selects how tiers apply to a quantity
+type Mode string
+
+const (
+	Graduated Mode = "graduated"
+	Volume    Mode = "volume"
+)
+
+// Default list prices (in cents)
+const (
+	// Actions: $25 per million
+	CentsPerMillionActions = 2500
+
+	// Replicated actions (standby clusters of replicated namespaces): $25 per million
+	CentsPerMillionReplicatedActions = 2500
+
+	// Active storage: $0.042 per GB-hour
+	CentsPerActiveStorageGBH = 4.2
+
+	// Retained storage: $0.00105 per GB-hour
+	CentsPerRetainedStorageGBH = 0.105
+
+	// Archived storage: $0.000105 per GB-hour
+	CentsPerArchivedStorageGBH = 0.0105
+)
+
+// Tier prices the billing units up to UpTo. The last tier has no upper bound.
+type Tier struct {
+	UpTo      *decimal.Decimal `json:"up_to,omitempty"`
+	UnitCents decimal.Decimal  `json:"unit_cents"`
+}
+
+// MeterPrice is the tiered price of one meter. Quantities are divided by UnitSize
+// into billing units (for example one million actions), rounded up to whole units
+// when RoundUp is set.
+type MeterPrice struct {
+	Mode     Mode            `json:"mode"`
+	UnitSize decimal.Decimal `json:"unit_size"`
+	RoundUp  bool            `json:"round_up,omitempty"`
+	Tiers    []Tier          `json:"tiers"`
+}
+
+// Flat returns a single-tier price
+func Flat(unitSize int64, roundUp bool, unitCents decimal.Decimal) MeterPrice {
+	return MeterPrice{
+		Mode:     Graduated,
+		UnitSize: decimal.NewFromInt(unitSize),
+		RoundUp:  roundUp,
+		Tiers:    []Tier{{UnitCents: unitCents}},
+	}
+}
+
+// Validate checks that tiers are ascending and only the last tier is unbounded
+func (p MeterPrice) Validate() error {
+	if p.Mode != Graduated && p.Mode != Volume {
+		return fmt.Errorf("mode must be %s or %s", Graduated, Volume)
+	}
+	if !p.UnitSize.IsPositive() {
+		return fmt.Errorf("unit size must be positive")
+	}
+	if len(p.Tiers) == 0 {
+		return fmt.Errorf("at least one tier is required")
+	}
+	prev := decimal.Zero
+	for i, t := range p.Tiers {
+		if t.UnitCents.IsNegative() {
+			return fmt.Errorf("tier %d: unit price must not be negative", i+1)
+		}
+		last := i == len(p.Tiers)-1
+		if last != (t.UpTo == nil) {
+			return fmt.Errorf("tier %d: only the last tier may be unbounded", i+1)
+		}
+		if t.UpTo != nil {
+			if !t.UpTo.GreaterThan(prev) {
+				return fmt.Errorf("tier %d: bounds must be ascending", i+1)
+			}
+			prev = *t.UpTo
+		}
+	}
+	return nil
+}
+
+// Scale returns a copy of the price with every tier's unit price multiplied by factor
+func (p MeterPrice) Scale(factor decimal.Decimal) MeterPrice {
+	tiers := make([]Tier, len(p.Tiers))
+	for i, t := range p.Tiers {
+		tiers[i] = Tier{UpTo: t.UpTo, UnitCents: t.UnitCents.Mul(factor)}
+	}
+	p.Tiers = tiers
+	return p
+}
+
+// Units converts a quantity to billing units
+func (p MeterPrice) Units(quantity decimal.Decimal) decimal.Decimal {
+	units := quantity.Div(p.UnitSize)
+	if p.RoundUp {
+		units = units.Ceil()
+	}
+	return units
+}
+
+// Amount prices a quantity in exact, unrounded cents
+func (p MeterPrice) Amount(quantity decimal.Decimal) decimal.Decimal {
+	if !quantity.IsPositive() || len(p.Tiers) == 0 {
+		return decimal.Zero
+	}
+	units := p.Units(quantity)
+
+	if p.Mode == Volume {
+		return units.Mul(p.tierFor(units).UnitCents)
+	}
+
+	total := decimal.Zero
+	lower := decimal.Zero
+	for _, t := range p.Tiers {
+		upper := units
+		if t.UpTo != nil && t.UpTo.LessThan(units) {
+			upper = *t.UpTo
+		}
+		if upper.GreaterThan(lower) {
+			total = total.Add(upper.Sub(lower).Mul(t.UnitCents))
+		}
+		if t.UpTo == nil || !t.UpTo.LessThan(units) {
+			break
+		}
+		lower = *t.UpTo
+	}
+	return total
+}
+
+// tierFor returns the tier whose range contains the given number of units
+func (p MeterPrice) tierFor(units decimal.Decimal) Tier {
+	for _, t := range p.Tiers {
+		if t.UpTo == nil || units.LessThanOrEqual(*t.UpTo) {
+			return t
+		}
+	}
+	return p.Tiers[len(p.Tiers)-1]
+}
+
+// PriceBook maps meters to their prices
+type PriceBook map[Meter]MeterPrice
+
+// Default returns the USD list prices every plan starts from
+func Default() PriceBook {
+	return PriceBook{
+		MeterActions:           Flat(1000000, true, decimal.NewFromInt(CentsPerMillionActions)),
+		MeterReplicatedActions: Flat(1000000, true, decimal.NewFromInt(CentsPerMillionReplicatedActions)),
+		MeterActiveStorage:     Flat(1, false, decimal.RequireFromString("4.2")),
+		MeterRetainedStorage:   Flat(1, false, decimal.RequireFromString("0.105")),
+		MeterArchivedStorage:   Flat(1, false, decimal.RequireFromString("0.0105")),
+	}
+}
+
+// ListPrices returns the list prices of a billing currency. Prices are set per
+// currency rather than converted, so an invoice never depends on an exchange rate.
+func ListPrices(c money.Currency) (PriceBook, bool) {
+	switch c {
+	case money.USD:
+		return Default(), true
+	case money.EUR:
+		return PriceBook{
+			MeterActions:           Flat(1000000, true, decimal.NewFromInt(2300)),
+			MeterReplicatedActions: Flat(1000000, true, decimal.NewFromInt(2300)),
+			MeterActiveStorage:     Flat(1, false, decimal.RequireFromString("3.9")),
+			MeterRetainedStorage:   Flat(1, false, decimal.RequireFromString("0.097")),
+			MeterArchivedStorage:   Flat(1, false, decimal.RequireFromString("0.0097")),
+		}, true
+	case money.GBP:
+		return PriceBook{
+			MeterActions:           Flat(1000000, true, decimal.NewFromInt(2000)),
+			MeterReplicatedActions: Flat(1000000, true, decimal.NewFromInt(2000)),
+			MeterActiveStorage:     Flat(1, false, decimal.RequireFromString("3.3")),
+			MeterRetainedStorage:   Flat(1, false, decimal.RequireFromString("0.083")),
+			MeterArchivedStorage:   Flat(1, false, decimal.RequireFromString("0.0083")),
+		}, true
+	}
+	return nil, false
+}
+
+// With returns a copy of the book with the given meters replaced
+func (b PriceBook) With(overrides PriceBook) PriceBook {
+	merged := make(PriceBook, len(b)+len(overrides))
+	for m, p := range b {
+		merged[m] = p
+	}
+	for m, p := range overrides {
+		merged[m] = p
+	}
+	return merged
+}
+
+// Validate checks every meter price in the book
+func (b PriceBook) Validate() error {
+	known := make(map[Meter]bool, len(Meters))
+	for _, m := range Meters {
+		known[m] = true
+	}
+	meters := make([]Meter, 0, len(b))
+	for m := range b {
+		meters = append(meters, m)
+	}
+	sort.Slice(meters, func(i, j int) bool { return meters[i] < meters[j] })
+	for _, m := range meters {
+		if !known[m] {
+			return fmt.Errorf("unknown meter %s", m)
+		}
+		if err := b[m].Validate(); err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// Usage holds the metered quantities of a billing period
+type Usage struct {
+	Actions            int64
+	ReplicatedActions  int64
+	ActiveStorageGBH   decimal.Decimal
+	RetainedStorageGBH decimal.Decimal
+	ArchivedStorageGBH decimal.Decimal
+}
+
+func (u Usage) quantity(m Meter) decimal.Decimal {
+	switch m {
+	case MeterActions:
+		return decimal.NewFromInt(u.Actions)
+	case MeterReplicatedActions:
+		return decimal.NewFromInt(u.ReplicatedActions)
+	case MeterActiveStorage:
+		return u.ActiveStorageGBH
+	case MeterRetainedStorage:
+		return u.RetainedStorageGBH
+	case MeterArchivedStorage:
+		return u.ArchivedStorageGBH
+	}
+	return decimal.Zero
+}
+
+// Charge is the priced usage of one meter. Amount is exact; Cents is the amount
+// rounded under the quote's rounding mode.
+type Charge struct {
+	Meter    Meter           `json:"meter"`
+	Used     decimal.Decimal `json:"used"`
+	Included decimal.Decimal `json:"included"`
+	Overage  decimal.Decimal `json:"overage"`
+	Amount   money.Money     `json:"amount"`
+	Cents    int64           `json:"cents"`
+}
+
+// Quote is the priced usage of a billing period. TotalCents is always the sum of
+// the charges' Cents.
+type Quote struct {
+	Currency   money.Currency `json:"currency"`
+	Rounding   money.Rounding `json:"rounding"`
+	Charges    []Charge       `json:"charges"`
+	TotalCents int64          `json:"total_cents"`
+}
+
+// Charge returns the charge for a meter
+func (q Quote) Charge(m Meter) Charge {
+	for _, c := range q.Charges {
+		if c.Meter == m {
+			return c
+		}
+	}
+	return Charge{Meter: m}
+}
+
+// Price prices usage against a book in the book's currency. Included quantities are
+// deducted per meter before tiers apply, so tiers count overage only.
+func Price(currency money.Currency, book PriceBook, usage, included Usage, rounding money.Rounding) Quote {
+	q := Quote{Currency: currency, Rounding: rounding}
+	amounts := make([]money.Money, 0, len(Meters))
+	for _, m := range Meters {
+		used, free := usage.quantity(m), included.quantity(m)
+		overage := used.Sub(free)
+		if overage.IsNegative() {
+			overage = decimal.Zero
+		}
+		c := Charge{Meter: m, Used: used, Included: free, Overage: overage, Amount: money.Zero(q.Currency)}
+		if p, ok := book[m]; ok {
+			c.Amount = money.New(q.Currency, p.Amount(overage))
+		}
+		q.Charges = append(q.Charges, c)
+		amounts = append(amounts, c.Amount)
+	}
+
+	rounded, total := money.RoundLines(q.Currency, amounts, rounding)
+	for i := range q.Charges {
+		q.Charges[i].Cents = rounded[i].MinorUnits()
+	}
+	q.TotalCents = total.MinorUnits()
+	return q
+}
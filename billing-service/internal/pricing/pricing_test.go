@@ -0,0 +1,246 @@
+package pricing
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+func bound(n int64) *decimal.Decimal {
+	d := decimal.NewFromInt(n)
+	return &d
+}
+
+// actionTiers is a graduated $50 → $25 per million schedule
+func actionTiers(mode Mode) MeterPrice {
+	return MeterPrice{
+		Mode:     mode,
+		UnitSize: decimal.NewFromInt(1000000),
+		RoundUp:  true,
+		Tiers: []Tier{
+			{UpTo: bound(5), UnitCents: decimal.NewFromInt(5000)},
+			{UpTo: bound(10), UnitCents: decimal.NewFromInt(4500)},
+			{UpTo: bound(20), UnitCents: decimal.NewFromInt(4000)},
+			{UnitCents: decimal.NewFromInt(2500)},
+		},
+	}
+}
+
+func TestGraduatedTiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  int64
+		expected int64
+	}{
+		{"zero", 0, 0},
+		{"rounds up to one million", 1, 5000},
+		{"first tier boundary", 5000000, 25000},
+		{"into second tier", 6000000, 25000 + 4500},
+		{"into last tier", 25000000, 25000 + 22500 + 40000 + 5*2500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cents := actionTiers(Graduated).Amount(decimal.NewFromInt(tt.actions)).IntPart(); cents != tt.expected {
+				t.Errorf("Expected %d cents, got %d", tt.expected, cents)
+			}
+		})
+	}
+}
+
+func TestVolumeTiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  int64
+		expected int64
+	}{
+		{"first tier", 5000000, 5 * 5000},
+		{"second tier prices every unit", 6000000, 6 * 4500},
+		{"last tier prices every unit", 25000000, 25 * 2500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cents := actionTiers(Volume).Amount(decimal.NewFromInt(tt.actions)).IntPart(); cents != tt.expected {
+				t.Errorf("Expected %d cents, got %d", tt.expected, cents)
+			}
+		})
+	}
+}
+
+func TestMeterPriceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   MeterPrice
+		wantErr bool
+	}{
+		{"graduated", actionTiers(Graduated), false},
+		{"flat", Flat(1, false, decimal.NewFromFloat(4.2)), false},
+		{"unknown mode", MeterPrice{Mode: "stairstep", UnitSize: decimal.NewFromInt(1), Tiers: []Tier{{}}}, true},
+		{"no tiers", MeterPrice{Mode: Graduated, UnitSize: decimal.NewFromInt(1)}, true},
+		{"zero unit size", MeterPrice{Mode: Graduated, Tiers: []Tier{{}}}, true},
+		{"bounded last tier", MeterPrice{Mode: Graduated, UnitSize: decimal.NewFromInt(1), Tiers: []Tier{{UpTo: bound(5)}}}, true},
+		{"unbounded middle tier", MeterPrice{Mode: Graduated, UnitSize: decimal.NewFromInt(1), Tiers: []Tier{{}, {}}}, true},
+		{"descending bounds", MeterPrice{Mode: Volume, UnitSize: decimal.NewFromInt(1), Tiers: []Tier{{UpTo: bound(5)}, {UpTo: bound(3)}, {}}}, true},
+		{"negative price", MeterPrice{Mode: Graduated, UnitSize: decimal.NewFromInt(1), Tiers: []Tier{{UnitCents: decimal.NewFromInt(-1)}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.price.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+
+	if err := (PriceBook{"egress": Flat(1, false, decimal.Zero)}).Validate(); err == nil {
+		t.Error("Expected unknown meter to be rejected")
+	}
+}
+
+func TestPriceBookWith(t *testing.T) {
+	book := Default().With(PriceBook{MeterActions: actionTiers(Volume)})
+	if book[MeterActions].Mode != Volume {
+		t.Error("Expected override to replace the actions price")
+	}
+	if len(book[MeterActiveStorage].Tiers) != 1 {
+		t.Error("Expected other meters to keep list prices")
+	}
+	if Default()[MeterActions].Mode != Graduated {
+		t.Error("Expected With to leave the original book unchanged")
+	}
+}
+
+func TestScale(t *testing.T) {
+	p := actionTiers(Graduated)
+	discounted := p.Scale(decimal.RequireFromString("0.8"))
+	if cents := discounted.Amount(decimal.NewFromInt(6000000)).IntPart(); cents != (25000+4500)*8/10 {
+		t.Errorf("Expected %d cents, got %d", (25000+4500)*8/10, cents)
+	}
+	if !p.Tiers[0].UnitCents.Equal(decimal.NewFromInt(5000)) {
+		t.Error("Expected Scale to leave the original price unchanged")
+	}
+}
+
+func TestPriceDeductsIncluded(t *testing.T) {
+	usage := Usage{Actions: 1500000, ActiveStorageGBH: decimal.NewFromInt(720)}
+	q := Price(money.USD, Default(), usage, Usage{Actions: 1000000}, money.PerLine)
+
+	actions := q.Charge(MeterActions)
+	if !actions.Overage.Equal(decimal.NewFromInt(500000)) {
+		t.Errorf("Expected 500000 overage actions, got %s", actions.Overage)
+	}
+	if actions.Cents != CentsPerMillionActions {
+		t.Errorf("Expected overage to round up to one million, got %d cents", actions.Cents)
+	}
+	if q.TotalCents != CentsPerMillionActions+3024 {
+		t.Errorf("Expected %d cents, got %d", CentsPerMillionActions+3024, q.TotalCents)
+	}
+
+	under := Price(money.USD, Default(), Usage{Actions: 10}, Usage{Actions: 1000000}, money.PerLine)
+	if c := under.Charge(MeterActions); !c.Overage.IsZero() || c.Cents != 0 {
+		t.Errorf("Expected no overage under the allowance, got %+v", c)
+	}
+}
+
+// randomTiers builds a valid tier schedule with random bounds and prices
+func randomTiers(r *rand.Rand, mode Mode) MeterPrice {
+	p := MeterPrice{Mode: mode, UnitSize: decimal.NewFromInt(1000000), RoundUp: r.Intn(2) == 0}
+	upTo := int64(0)
+	for i, n := 0, r.Intn(4); i < n; i++ {
+		upTo += 1 + r.Int63n(50)
+		p.Tiers = append(p.Tiers, Tier{UpTo: bound(upTo), UnitCents: decimal.NewFromInt(r.Int63n(10000))})
+	}
+	p.Tiers = append(p.Tiers, Tier{UnitCents: decimal.NewFromInt(r.Int63n(10000))})
+	return p
+}
+
+func TestGraduatedPriceProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	// More usage never costs less
+	monotonic := func(a, b uint32) bool {
+		p := randomTiers(r, Graduated)
+		lo, hi := int64(a), int64(a)+int64(b)
+		return p.Amount(decimal.NewFromInt(lo)).LessThanOrEqual(p.Amount(decimal.NewFromInt(hi)))
+	}
+	if err := quick.Check(monotonic, &quick.Config{Rand: r, MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	// Cost lies between pricing every unit at the cheapest and the dearest tier
+	bounded := func(q uint32) bool {
+		p := randomTiers(r, Graduated)
+		quantity := decimal.NewFromInt(int64(q))
+		min, max := p.Tiers[0].UnitCents, p.Tiers[0].UnitCents
+		for _, tier := range p.Tiers {
+			min = decimal.Min(min, tier.UnitCents)
+			max = decimal.Max(max, tier.UnitCents)
+		}
+		units := p.Units(quantity)
+		amount := p.Amount(quantity)
+		return amount.GreaterThanOrEqual(units.Mul(min)) && amount.LessThanOrEqual(units.Mul(max))
+	}
+	if err := quick.Check(bounded, &quick.Config{Rand: r, MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUniformTiersMatchFlatPrice(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	// With one price in every tier, graduated, volume and flat pricing agree
+	uniform := func(q uint32) bool {
+		tiered := randomTiers(r, Graduated)
+		price := decimal.NewFromInt(r.Int63n(10000))
+		for i := range tiered.Tiers {
+			tiered.Tiers[i].UnitCents = price
+		}
+		volume := tiered
+		volume.Mode = Volume
+		flat := Flat(1000000, tiered.RoundUp, price)
+
+		quantity := decimal.NewFromInt(int64(q))
+		expected := flat.Amount(quantity)
+		return tiered.Amount(quantity).Equal(expected) && volume.Amount(quantity).Equal(expected)
+	}
+	if err := quick.Check(uniform, &quick.Config{Rand: r, MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPriceTotalsCharges(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	modes := []money.Rounding{money.PerLine, money.PerInvoice, money.Bankers}
+	totals := func(actions, replicated uint32, active, retained, archived uint16, included uint32) bool {
+		usage := Usage{
+			Actions:            int64(actions),
+			ReplicatedActions:  int64(replicated),
+			ActiveStorageGBH:   decimal.NewFromInt(int64(active)).Div(decimal.NewFromInt(7)),
+			RetainedStorageGBH: decimal.NewFromInt(int64(retained)).Div(decimal.NewFromInt(3)),
+			ArchivedStorageGBH: decimal.NewFromInt(int64(archived)),
+		}
+		book := Default().With(PriceBook{MeterActions: randomTiers(r, Graduated)})
+		q := Price(money.USD, book, usage, Usage{Actions: int64(included)}, modes[r.Intn(len(modes))])
+
+		var sum int64
+		for _, c := range q.Charges {
+			if c.Overage.IsNegative() || c.Overage.GreaterThan(c.Used) {
+				return false
+			}
+			sum += c.Cents
+		}
+		return sum == q.TotalCents && len(q.Charges) == len(Meters)
+	}
+	if err := quick.Check(totals, &quick.Config{Rand: r, MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,215 @@
+// Package proration prorates base prices and included allowances per day when an
+// organization changes plan partway through a billing period.
+package proration
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+// Statuses of a plan change
+const (
+	Scheduled = "scheduled"
+	Applied   = "applied"
+	Canceled  = "canceled"
+)
+
+var ErrNoChangeScheduled = errors.New("no plan change scheduled")
+
+const day = 24 * time.Hour
+
+// Change moves an organization from one plan to another
+type Change struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	FromPlan       string    `json:"from_plan"`
+	ToPlan         string    `json:"to_plan"`
+	EffectiveAt    time.Time `json:"effective_at"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Segment is the part of a billing period spent on one plan
+type Segment struct {
+	Plan plans.Plan
+	From time.Time
+	To   time.Time
+}
+
+// Days returns the whole days between two times, rounded to the nearest day
+func Days(from, to time.Time) int {
+	return int(math.Round(float64(to.Sub(from)) / float64(day)))
+}
+
+// boundary returns the start of the period day a change falls on. A change takes
+// effect for the whole day it is made on.
+func boundary(periodStart, at time.Time) time.Time {
+	return periodStart.Add(at.Sub(periodStart).Truncate(day))
+}
+
+// Segments splits a billing period at the plan changes applied during it. current is
+// the organization's plan now; lookup resolves the plans it was on before. Changes
+// after the period tell which plan was in effect at its end.
+func Segments(current plans.Plan, changes []Change, periodStart, periodEnd time.Time,
+	lookup func(id string) (plans.Plan, error)) ([]Segment, error) {
+	sorted := append([]Change(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].EffectiveAt.Before(sorted[j].EffectiveAt) })
+
+	var during []Change
+	atEnd := current
+	for _, c := range sorted {
+		if !c.EffectiveAt.Before(periodEnd) {
+			p, err := lookup(c.FromPlan)
+			if err != nil {
+				return nil, err
+			}
+			atEnd = p
+			break
+		}
+		if c.EffectiveAt.After(periodStart) {
+			during = append(during, c)
+		}
+	}
+	if len(during) == 0 {
+		return []Segment{{Plan: atEnd, From: periodStart, To: periodEnd}}, nil
+	}
+
+	var segments []Segment
+	from := periodStart
+	for _, c := range during {
+		to := boundary(periodStart, c.EffectiveAt)
+		if to.After(from) {
+			p, err := lookup(c.FromPlan)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, Segment{Plan: p, From: from, To: to})
+			from = to
+		}
+	}
+	return append(segments, Segment{Plan: atEnd, From: from, To: periodEnd}), nil
+}
+
+// share returns the part of a base price or allowance earned over some days of a period
+func share(amount int64, days, periodDays int) int64 {
+	return decimal.NewFromInt(amount).Mul(decimal.NewFromInt(int64(days))).
+		Div(decimal.NewFromInt(int64(periodDays))).Round(0).IntPart()
+}
+
+// Blend returns the plan a billing period is charged on. Base prices and included
+// allowances are prorated per day across the segments; usage is priced at the rates
+// of the last segment's plan.
+func Blend(segments []Segment, periodStart, periodEnd time.Time) plans.Plan {
+	last := segments[len(segments)-1].Plan
+	periodDays := Days(periodStart, periodEnd)
+	if len(segments) == 1 || periodDays <= 0 {
+		return last
+	}
+
+	blended := last
+	blended.BasePriceCents, blended.ActionsIncluded = 0, 0
+	blended.ActiveStorageGB, blended.RetainedStorageGB = decimal.Zero, decimal.Zero
+	blended.CurrencyPrices = make(map[money.Currency]plans.CurrencyPrice, len(last.CurrencyPrices))
+	for c, cp := range last.CurrencyPrices {
+		cp.BasePriceMinor = 0
+		blended.CurrencyPrices[c] = cp
+	}
+
+	for _, s := range segments {
+		days := Days(s.From, s.To)
+		weight := decimal.NewFromInt(int64(days)).Div(decimal.NewFromInt(int64(periodDays)))
+		blended.BasePriceCents += share(s.Plan.BasePriceCents, days, periodDays)
+		blended.ActionsIncluded += share(s.Plan.ActionsIncluded, days, periodDays)
+		blended.ActiveStorageGB = blended.ActiveStorageGB.Add(s.Plan.ActiveStorageGB.Mul(weight))
+		blended.RetainedStorageGB = blended.RetainedStorageGB.Add(s.Plan.RetainedStorageGB.Mul(weight))
+		for c, cp := range blended.CurrencyPrices {
+			if price, _, err := s.Plan.PricesIn(c); err == nil {
+				cp.BasePriceMinor += share(price.BasePriceMinor, days, periodDays)
+				blended.CurrencyPrices[c] = cp
+			}
+		}
+	}
+	return blended
+}
+
+// Preview shows what a plan change does to the current billing period
+type Preview struct {
+	FromPlan      string         `json:"from_plan"`
+	ToPlan        string         `json:"to_plan"`
+	Currency      money.Currency `json:"currency"`
+	EffectiveAt   time.Time      `json:"effective_at"`
+	PeriodStart   time.Time      `json:"period_start"`
+	PeriodEnd     time.Time      `json:"period_end"`
+	PeriodDays    int            `json:"period_days"`
+	DaysRemaining int            `json:"days_remaining"`
+
+	// Unused base price of the current plan and the new plan's base price for the
+	// rest of the period
+	UnusedCreditCents int64 `json:"unused_credit_cents"`
+	NewChargeCents    int64 `json:"new_charge_cents"`
+
+	// Change to this period's base price, and the base price the period ends up with
+	AdjustmentCents int64 `json:"adjustment_cents"`
+	BaseCostCents   int64 `json:"base_cost_cents"`
+
+	// Included actions for this period after proration
+	ActionsIncluded int64 `json:"actions_included"`
+}
+
+// NewPreview prorates moving from one plan to another at a time within a billing
+// period. A change at or after the period end leaves the period unchanged.
+func NewPreview(from, to plans.Plan, currency money.Currency, periodStart, periodEnd, at time.Time) (Preview, error) {
+	fromPrice, _, err := from.PricesIn(currency)
+	if err != nil {
+		return Preview{}, err
+	}
+	toPrice, _, err := to.PricesIn(currency)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	p := Preview{
+		FromPlan:        from.ID,
+		ToPlan:          to.ID,
+		Currency:        currency,
+		EffectiveAt:     at,
+		PeriodStart:     periodStart,
+		PeriodEnd:       periodEnd,
+		PeriodDays:      Days(periodStart, periodEnd),
+		BaseCostCents:   fromPrice.BasePriceMinor,
+		ActionsIncluded: from.ActionsIncluded,
+	}
+	if !at.Before(periodEnd) {
+		p.EffectiveAt = periodEnd
+		return p, nil
+	}
+	if at.Before(periodStart) {
+		at = periodStart
+	}
+
+	change := boundary(periodStart, at)
+	p.DaysRemaining = Days(change, periodEnd)
+	p.UnusedCreditCents = share(fromPrice.BasePriceMinor, p.DaysRemaining, p.PeriodDays)
+	p.NewChargeCents = share(toPrice.BasePriceMinor, p.DaysRemaining, p.PeriodDays)
+
+	segments := []Segment{{Plan: to, From: change, To: periodEnd}}
+	if change.After(periodStart) {
+		segments = append([]Segment{{Plan: from, From: periodStart, To: change}}, segments...)
+	}
+	blended := Blend(segments, periodStart, periodEnd)
+	price, _, err := blended.PricesIn(currency)
+	if err != nil {
+		return Preview{}, err
+	}
+	p.BaseCostCents = price.BasePriceMinor
+	p.AdjustmentCents = price.BasePriceMinor - fromPrice.BasePriceMinor
+	p.ActionsIncluded = blended.ActionsIncluded
+	return p, nil
+}
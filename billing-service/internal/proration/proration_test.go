@@ -0,0 +1,144 @@
+package proration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+var (
+	start = time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end   = start.AddDate(0, 1, 0)
+
+	free      = plans.Plan{ID: "free", ActionsIncluded: 100000}
+	essential = plans.Plan{ID: "essential", BasePriceCents: 10000, ActionsIncluded: 1000000, ActiveStorageGB: decimal.NewFromInt(1)}
+	business  = plans.Plan{ID: "business", BasePriceCents: 50000, ActionsIncluded: 2500000, ActiveStorageGB: decimal.NewFromInt(4)}
+)
+
+func lookup(id string) (plans.Plan, error) {
+	for _, p := range []plans.Plan{free, essential, business} {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return plans.Plan{}, fmt.Errorf("unknown plan %s", id)
+}
+
+func change(from, to string, at time.Time) Change {
+	return Change{FromPlan: from, ToPlan: to, EffectiveAt: at, Status: Applied}
+}
+
+func TestSegments(t *testing.T) {
+	midMonth := start.AddDate(0, 0, 15).Add(12 * time.Hour)
+
+	tests := []struct {
+		name     string
+		current  plans.Plan
+		changes  []Change
+		expected []string
+		days     []int
+	}{
+		{"no changes", essential, nil, []string{"essential"}, []int{30}},
+		{"upgrade mid-month", business, []Change{change("essential", "business", midMonth)}, []string{"essential", "business"}, []int{15, 15}},
+		{"two changes", free, []Change{
+			change("essential", "business", start.AddDate(0, 0, 10)),
+			change("business", "free", start.AddDate(0, 0, 20)),
+		}, []string{"essential", "business", "free"}, []int{10, 10, 10}},
+		{"change after the period", business, []Change{change("essential", "business", end.AddDate(0, 0, 2))}, []string{"essential"}, []int{30}},
+		{"change at the period end", business, []Change{change("essential", "business", end)}, []string{"essential"}, []int{30}},
+		{"same-day changes", business, []Change{
+			change("essential", "free", midMonth),
+			change("free", "business", midMonth.Add(time.Hour)),
+		}, []string{"essential", "business"}, []int{15, 15}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := Segments(tt.current, tt.changes, start, end, lookup)
+			if err != nil {
+				t.Fatalf("Expected segments, got %v", err)
+			}
+			if len(segments) != len(tt.expected) {
+				t.Fatalf("Expected %d segments, got %d", len(tt.expected), len(segments))
+			}
+			for i, s := range segments {
+				if s.Plan.ID != tt.expected[i] || Days(s.From, s.To) != tt.days[i] {
+					t.Errorf("Expected %d days on %s, got %d days on %s", tt.days[i], tt.expected[i], Days(s.From, s.To), s.Plan.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestBlend(t *testing.T) {
+	split := start.AddDate(0, 0, 15)
+	blended := Blend([]Segment{
+		{Plan: essential, From: start, To: split},
+		{Plan: business, From: split, To: end},
+	}, start, end)
+
+	if blended.ID != "business" {
+		t.Errorf("Expected usage priced on business, got %s", blended.ID)
+	}
+	if blended.BasePriceCents != 30000 {
+		t.Errorf("Expected half of each base price, got %d", blended.BasePriceCents)
+	}
+	if blended.ActionsIncluded != 1750000 {
+		t.Errorf("Expected half of each allowance, got %d", blended.ActionsIncluded)
+	}
+	if !blended.ActiveStorageGB.Equal(decimal.RequireFromString("2.5")) {
+		t.Errorf("Expected 2.5 GB of active storage, got %s", blended.ActiveStorageGB)
+	}
+
+	single := Blend([]Segment{{Plan: essential, From: start, To: end}}, start, end)
+	if single.BasePriceCents != essential.BasePriceCents || single.ActionsIncluded != essential.ActionsIncluded {
+		t.Errorf("Expected an unchanged plan, got base %d and %d actions", single.BasePriceCents, single.ActionsIncluded)
+	}
+}
+
+func TestNewPreview(t *testing.T) {
+	tests := []struct {
+		name       string
+		from, to   plans.Plan
+		at         time.Time
+		remaining  int
+		adjustment int64
+		base       int64
+		actions    int64
+	}{
+		{"upgrade mid-month", essential, business, start.AddDate(0, 0, 15).Add(9 * time.Hour), 15, 20000, 30000, 1750000},
+		{"downgrade mid-month", business, essential, start.AddDate(0, 0, 20), 10, -13334, 36666, 2000000},
+		{"upgrade at the period start", essential, business, start, 30, 40000, 50000, 2500000},
+		{"change at the period end", business, free, end, 0, 0, 50000, 2500000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPreview(tt.from, tt.to, money.USD, start, end, tt.at)
+			if err != nil {
+				t.Fatalf("Expected a preview, got %v", err)
+			}
+			if p.PeriodDays != 30 || p.DaysRemaining != tt.remaining {
+				t.Errorf("Expected %d of 30 days remaining, got %d of %d", tt.remaining, p.DaysRemaining, p.PeriodDays)
+			}
+			if p.AdjustmentCents != tt.adjustment || p.BaseCostCents != tt.base {
+				t.Errorf("Expected adjustment %d and base %d, got %d and %d", tt.adjustment, tt.base, p.AdjustmentCents, p.BaseCostCents)
+			}
+			if p.ActionsIncluded != tt.actions {
+				t.Errorf("Expected %d actions included, got %d", tt.actions, p.ActionsIncluded)
+			}
+			if p.NewChargeCents-p.UnusedCreditCents != p.AdjustmentCents {
+				t.Errorf("Expected the adjustment to be the new charge less the unused credit, got %d - %d != %d",
+					p.NewChargeCents, p.UnusedCreditCents, p.AdjustmentCents)
+			}
+		})
+	}
+
+	if _, err := NewPreview(essential, business, money.EUR, start, end, start); err == nil {
+		t.Error("Expected an error for a currency the plans are not offered in")
+	}
+}
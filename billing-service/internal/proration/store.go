@@ -0,0 +1,133 @@
+package proration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const changeColumns = `id, organization_id, from_plan, to_plan, effective_at, status, created_at`
+
+// Execer runs a statement on a pool or inside a transaction
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Store reads and writes plan changes in Postgres. A nil Store, or one without a
+// database, has no plan changes.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the subscription_changes table
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+func (s *Store) query(ctx context.Context, where string, args ...any) ([]Change, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx, `SELECT `+changeColumns+` FROM subscription_changes WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan changes: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Change
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.ID, &c.OrganizationID, &c.FromPlan, &c.ToPlan, &c.EffectiveAt, &c.Status, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Record stores an applied plan change, in a transaction when q is one
+func (s *Store) Record(ctx context.Context, q Execer, c Change) (Change, error) {
+	c.ID = uuid.New()
+	c.Status = Applied
+	c.CreatedAt = time.Now()
+	_, err := q.Exec(ctx,
+		`INSERT INTO subscription_changes (id, organization_id, from_plan, to_plan, effective_at, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.ID, c.OrganizationID, c.FromPlan, c.ToPlan, c.EffectiveAt, c.Status, c.CreatedAt)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to record plan change: %w", err)
+	}
+	return c, nil
+}
+
+// MarkApplied records that a scheduled change took effect, in a transaction when q is one
+func (s *Store) MarkApplied(ctx context.Context, q Execer, id uuid.UUID) error {
+	_, err := q.Exec(ctx, `UPDATE subscription_changes SET status = 'applied' WHERE id = $1 AND status = 'scheduled'`, id)
+	return err
+}
+
+// Schedule replaces an organization's scheduled plan change
+func (s *Store) Schedule(ctx context.Context, c Change) (Change, error) {
+	if !s.available() {
+		return Change{}, fmt.Errorf("plan change store has no database")
+	}
+	c.ID = uuid.New()
+	c.Status = Scheduled
+	c.CreatedAt = time.Now()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Change{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.CancelScheduled(ctx, tx, c.OrganizationID); err != nil {
+		return Change{}, err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO subscription_changes (id, organization_id, from_plan, to_plan, effective_at, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.ID, c.OrganizationID, c.FromPlan, c.ToPlan, c.EffectiveAt, c.Status, c.CreatedAt)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to schedule plan change: %w", err)
+	}
+	return c, tx.Commit(ctx)
+}
+
+// CancelScheduled cancels an organization's scheduled plan change, in a transaction when q is one
+func (s *Store) CancelScheduled(ctx context.Context, q Execer, orgID uuid.UUID) error {
+	_, err := q.Exec(ctx,
+		`UPDATE subscription_changes SET status = 'canceled' WHERE organization_id = $1 AND status = 'scheduled'`, orgID)
+	return err
+}
+
+// Scheduled returns an organization's scheduled plan change
+func (s *Store) Scheduled(ctx context.Context, orgID uuid.UUID) (Change, error) {
+	list, err := s.query(ctx, `organization_id = $1 AND status = 'scheduled' ORDER BY created_at DESC LIMIT 1`, orgID)
+	if err != nil {
+		return Change{}, err
+	}
+	if len(list) == 0 {
+		return Change{}, ErrNoChangeScheduled
+	}
+	return list[0], nil
+}
+
+// Due returns scheduled changes that take effect by now
+func (s *Store) Due(ctx context.Context, now time.Time) ([]Change, error) {
+	return s.query(ctx, `status = 'scheduled' AND effective_at <= $1 ORDER BY effective_at`, now)
+}
+
+// Since returns the changes applied to an organization after a time, oldest first
+func (s *Store) Since(ctx context.Context, orgID uuid.UUID, after time.Time) ([]Change, error) {
+	return s.query(ctx,
+		`organization_id = $1 AND status = 'applied' AND effective_at > $2 ORDER BY effective_at`, orgID, after)
+}
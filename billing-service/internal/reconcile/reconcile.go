@@ -0,0 +1,251 @@
+// Package reconcile compares the invoices and subscriptions the payment provider holds
+// for each customer with the local records, which drift when webhooks are lost, and
+// fixes the local records that can be fixed from the provider's.
+package reconcile
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+)
+
+// Object is what a discrepancy is about
+type Object string
+
+const (
+	ObjectInvoice      Object = "invoice"
+	ObjectSubscription Object = "subscription"
+	ObjectCustomer     Object = "customer"
+)
+
+// Issue is how a local record differs from the provider's
+type Issue string
+
+const (
+	// MissingLocally objects exist at the provider but not locally
+	MissingLocally Issue = "missing_locally"
+	// MissingAtProvider objects are recorded locally with a provider ID the provider
+	// does not have
+	MissingAtProvider Issue = "missing_at_provider"
+	Status            Issue = "status"
+	Amount            Issue = "amount"
+	Currency          Issue = "currency"
+	Period            Issue = "period"
+	// Unchecked customers could not be listed at the provider
+	Unchecked Issue = "unchecked"
+)
+
+// Discrepancy is one difference between a local record and the provider's. Local and
+// Provider hold the differing values. Fixable discrepancies are fixed by updating the
+// local record to match the provider; the others need someone to look at them.
+type Discrepancy struct {
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	Object         Object     `json:"object"`
+	ProviderID     string     `json:"provider_id,omitempty"`
+	LocalID        *uuid.UUID `json:"local_id,omitempty"`
+	Issue          Issue      `json:"issue"`
+	Local          string     `json:"local,omitempty"`
+	Provider       string     `json:"provider,omitempty"`
+	Fixable        bool       `json:"fixable"`
+	Fixed          bool       `json:"fixed"`
+	Error          string     `json:"error,omitempty"`
+
+	// The provider's object a fix copies from
+	invoice      *payments.Invoice
+	subscription *payments.Subscription
+}
+
+// Subscription is an organization's local subscription
+type Subscription struct {
+	ProviderID  string
+	Status      string
+	PeriodStart *time.Time
+	PeriodEnd   *time.Time
+}
+
+// agreeingStatuses lists, for each provider invoice status, the local statuses that
+// agree with it; the first is the one a fix sets. Failed payments are recorded locally
+// while the provider keeps the invoice open to retry it, and refunded invoices stay paid
+// at the provider.
+var agreeingStatuses = map[string][]string{
+	"draft":         {"draft", "pending"},
+	"open":          {"pending", "payment_failed", "open"},
+	"paid":          {"paid", "refunded"},
+	"void":          {"void"},
+	"uncollectible": {"uncollectible"},
+}
+
+// InvoiceStatus returns the local status of an invoice in a provider status, and
+// whether a local status already agrees with it
+func InvoiceStatus(provider, local string) (string, bool) {
+	statuses, ok := agreeingStatuses[provider]
+	if !ok {
+		return provider, provider == local
+	}
+	for _, s := range statuses {
+		if s == local {
+			return statuses[0], true
+		}
+	}
+	return statuses[0], false
+}
+
+// CompareInvoices compares an organization's local invoices created since a time with
+// the provider's. remote may start earlier, so local invoices stored just after the
+// provider created them still find their match. Local invoices never sent to the
+// provider are left out.
+func CompareInvoices(orgID uuid.UUID, local []invoices.Invoice, remote []payments.Invoice, since time.Time) []Discrepancy {
+	byID := make(map[string]payments.Invoice, len(remote))
+	for _, r := range remote {
+		byID[r.ID] = r
+	}
+
+	var found []Discrepancy
+	matched := map[string]bool{}
+	for _, l := range local {
+		if l.ProviderInvoiceID == "" {
+			continue
+		}
+		d := Discrepancy{OrganizationID: orgID, Object: ObjectInvoice, ProviderID: l.ProviderInvoiceID, LocalID: &l.ID}
+		r, ok := byID[l.ProviderInvoiceID]
+		if !ok {
+			d.Issue, d.Local = MissingAtProvider, l.Status
+			found = append(found, d)
+			continue
+		}
+		matched[r.ID] = true
+		d.invoice = &r
+
+		if status, ok := InvoiceStatus(r.Status, l.Status); !ok {
+			d := d
+			d.Issue, d.Local, d.Provider, d.Fixable = Status, l.Status, status, true
+			found = append(found, d)
+		}
+		if r.Currency != l.Currency {
+			d := d
+			d.Issue, d.Local, d.Provider = Currency, string(l.Currency), string(r.Currency)
+			found = append(found, d)
+		}
+		if r.TotalCents != l.TotalCents {
+			d := d
+			d.Issue, d.Local, d.Provider = Amount, strconv.FormatInt(l.TotalCents, 10), strconv.FormatInt(r.TotalCents, 10)
+			found = append(found, d)
+		}
+	}
+
+	for i := range remote {
+		r := remote[i]
+		if matched[r.ID] || r.CreatedAt.Before(since) {
+			continue
+		}
+		// Drafts are invoices the provider has not issued, such as one whose creation
+		// failed halfway, so there is nothing to record
+		found = append(found, Discrepancy{OrganizationID: orgID, Object: ObjectInvoice, ProviderID: r.ID,
+			Issue: MissingLocally, Provider: r.Status, Fixable: r.Status != "draft", invoice: &r})
+	}
+	return found
+}
+
+// CompareSubscription compares an organization's local subscription with the
+// provider's subscriptions of its customer. A live subscription the local one does not
+// know about is only fixable when the local one has none and it is the only one.
+func CompareSubscription(orgID uuid.UUID, local Subscription, remote []payments.Subscription) []Discrepancy {
+	var found []Discrepancy
+	if local.ProviderID != "" {
+		d := Discrepancy{OrganizationID: orgID, Object: ObjectSubscription, ProviderID: local.ProviderID}
+		var r *payments.Subscription
+		for i := range remote {
+			if remote[i].ID == local.ProviderID {
+				r = &remote[i]
+			}
+		}
+		switch {
+		case r == nil:
+			d.Issue, d.Local = MissingAtProvider, local.Status
+			found = append(found, d)
+		default:
+			d.subscription, d.Fixable = r, true
+			if r.Status != local.Status {
+				d := d
+				d.Issue, d.Local, d.Provider = Status, local.Status, r.Status
+				found = append(found, d)
+			}
+			if !sameTime(local.PeriodStart, r.CurrentPeriodStart) || !sameTime(local.PeriodEnd, r.CurrentPeriodEnd) {
+				d := d
+				d.Issue = Period
+				d.Local = period(local.PeriodStart, local.PeriodEnd)
+				d.Provider = period(&r.CurrentPeriodStart, &r.CurrentPeriodEnd)
+				found = append(found, d)
+			}
+		}
+	}
+
+	var live []*payments.Subscription
+	for i := range remote {
+		if remote[i].ID != local.ProviderID && !remote[i].Ended() {
+			live = append(live, &remote[i])
+		}
+	}
+	for _, r := range live {
+		found = append(found, Discrepancy{OrganizationID: orgID, Object: ObjectSubscription, ProviderID: r.ID,
+			Issue: MissingLocally, Provider: r.Status, Fixable: local.ProviderID == "" && len(live) == 1, subscription: r})
+	}
+	return found
+}
+
+// sameTime compares a local time with the provider's to the second, which is as
+// precise as providers are
+func sameTime(local *time.Time, provider time.Time) bool {
+	return local != nil && local.Truncate(time.Second).Equal(provider.Truncate(time.Second))
+}
+
+func period(start, end *time.Time) string {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s/%s", format(start), format(end))
+}
+
+var one = decimal.NewFromInt(1)
+
+// ImportedInvoice records a provider invoice that has no local record, with a line for
+// what the provider charged before tax, one for any discount and one for the tax
+func ImportedInvoice(orgID uuid.UUID, provider string, r payments.Invoice) invoices.Invoice {
+	number := r.Number
+	if number == "" {
+		number = r.ID
+	}
+	status, _ := InvoiceStatus(r.Status, "")
+	inv := invoices.Invoice{
+		OrganizationID:    orgID,
+		Kind:              invoices.Provider,
+		ProviderInvoiceID: r.ID,
+		PeriodStart:       r.PeriodStart,
+		PeriodEnd:         r.PeriodEnd,
+		Currency:          r.Currency,
+		SubtotalCents:     r.SubtotalCents,
+		DiscountCents:     r.SubtotalCents + r.TaxCents - r.TotalCents,
+		TaxCents:          r.TaxCents,
+		TotalCents:        r.TotalCents,
+		Status:            status,
+		PaidAt:            r.PaidAt,
+		Lines: []invoices.Line{{Kind: invoices.LineBase, Description: fmt.Sprintf("%s invoice %s", provider, number),
+			Quantity: one, AmountCents: r.SubtotalCents}},
+	}
+	if inv.DiscountCents != 0 {
+		inv.Lines = append(inv.Lines, invoices.Line{Kind: invoices.LineDiscount, Description: "Discounts and credits",
+			Quantity: one, AmountCents: -inv.DiscountCents})
+	}
+	if inv.TaxCents != 0 {
+		inv.Lines = append(inv.Lines, invoices.Line{Kind: invoices.LineTax, Description: "Tax", Quantity: one, AmountCents: inv.TaxCents})
+	}
+	return inv
+}
@@ -0,0 +1,215 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+)
+
+func TestInvoiceStatus(t *testing.T) {
+	cases := []struct {
+		provider, local string
+		expected        string
+		agrees          bool
+	}{
+		{"paid", "paid", "paid", true},
+		{"paid", "pending", "paid", false},
+		{"paid", "refunded", "paid", true},
+		{"open", "payment_failed", "pending", true},
+		{"open", "paid", "pending", false},
+		{"draft", "pending", "draft", true},
+		{"void", "pending", "void", false},
+		{"refunded", "refunded", "refunded", true},
+	}
+	for _, c := range cases {
+		status, agrees := InvoiceStatus(c.provider, c.local)
+		if status != c.expected || agrees != c.agrees {
+			t.Errorf("Expected %s at the provider and %s locally to give %s, %v, got %s, %v",
+				c.provider, c.local, c.expected, c.agrees, status, agrees)
+		}
+	}
+}
+
+// issues returns the issues found, in order
+func issues(found []Discrepancy) []Issue {
+	var list []Issue
+	for _, d := range found {
+		list = append(list, d.Issue)
+	}
+	return list
+}
+
+func sameIssues(a, b []Issue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompareInvoices(t *testing.T) {
+	org := uuid.New()
+	since := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	local := func(providerID, status string, total int64) invoices.Invoice {
+		return invoices.Invoice{ID: uuid.New(), OrganizationID: org, ProviderInvoiceID: providerID, Status: status,
+			Currency: money.USD, TotalCents: total}
+	}
+	remote := func(id, status string, total int64, created time.Time) payments.Invoice {
+		return payments.Invoice{ID: id, Status: status, Currency: money.USD, TotalCents: total, CreatedAt: created}
+	}
+	day := since.AddDate(0, 0, 5)
+
+	cases := []struct {
+		name     string
+		local    []invoices.Invoice
+		remote   []payments.Invoice
+		expected []Issue
+		fixable  []bool
+	}{
+		{"in agreement", []invoices.Invoice{local("in_1", "pending", 5000)},
+			[]payments.Invoice{remote("in_1", "open", 5000, day)}, nil, nil},
+		{"paid webhook lost", []invoices.Invoice{local("in_1", "pending", 5000)},
+			[]payments.Invoice{remote("in_1", "paid", 5000, day)}, []Issue{Status}, []bool{true}},
+		{"amount differs", []invoices.Invoice{local("in_1", "paid", 5000)},
+			[]payments.Invoice{remote("in_1", "paid", 4500, day)}, []Issue{Amount}, []bool{false}},
+		{"deleted at the provider", []invoices.Invoice{local("in_1", "pending", 5000)}, nil,
+			[]Issue{MissingAtProvider}, []bool{false}},
+		{"never stored locally", nil, []payments.Invoice{remote("in_2", "paid", 5000, day)},
+			[]Issue{MissingLocally}, []bool{true}},
+		{"draft never issued", nil, []payments.Invoice{remote("in_2", "draft", 5000, day)},
+			[]Issue{MissingLocally}, []bool{false}},
+		{"created before the window", nil, []payments.Invoice{remote("in_2", "paid", 5000, since.Add(-time.Hour))}, nil, nil},
+		{"never sent to the provider", []invoices.Invoice{local("", "pending", 5000)}, nil, nil, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			found := CompareInvoices(org, c.local, c.remote, since)
+			if !sameIssues(issues(found), c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, issues(found))
+			}
+			for i, d := range found {
+				if d.Fixable != c.fixable[i] || d.OrganizationID != org || d.Object != ObjectInvoice {
+					t.Errorf("Expected %s of %s to be fixable %v, got %+v", d.Issue, org, c.fixable[i], d)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareInvoicesReportsValues(t *testing.T) {
+	org := uuid.New()
+	l := invoices.Invoice{ID: uuid.New(), ProviderInvoiceID: "in_1", Status: "pending", Currency: money.USD, TotalCents: 5000}
+	r := payments.Invoice{ID: "in_1", Status: "paid", Currency: money.EUR, TotalCents: 4600}
+	found := CompareInvoices(org, []invoices.Invoice{l}, []payments.Invoice{r}, time.Time{})
+	expected := []Discrepancy{
+		{Issue: Status, Local: "pending", Provider: "paid"},
+		{Issue: Currency, Local: "USD", Provider: "EUR"},
+		{Issue: Amount, Local: "5000", Provider: "4600"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d discrepancies, got %+v", len(expected), found)
+	}
+	for i, d := range found {
+		if d.Issue != expected[i].Issue || d.Local != expected[i].Local || d.Provider != expected[i].Provider {
+			t.Errorf("Expected %+v, got %+v", expected[i], d)
+		}
+		if d.LocalID == nil || *d.LocalID != l.ID || d.ProviderID != "in_1" {
+			t.Errorf("Expected the discrepancy to name %s and in_1, got %+v", l.ID, d)
+		}
+	}
+}
+
+func TestCompareSubscription(t *testing.T) {
+	org := uuid.New()
+	start := time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	remote := func(id, status string) payments.Subscription {
+		return payments.Subscription{ID: id, Status: status, CurrentPeriodStart: start, CurrentPeriodEnd: end}
+	}
+	local := func(id, status string) Subscription {
+		return Subscription{ProviderID: id, Status: status, PeriodStart: &start, PeriodEnd: &end}
+	}
+	lastMonth := start.AddDate(0, -1, 0)
+
+	cases := []struct {
+		name     string
+		local    Subscription
+		remote   []payments.Subscription
+		expected []Issue
+		fixable  []bool
+	}{
+		{"in agreement", local("sub_1", "active"), []payments.Subscription{remote("sub_1", "active")}, nil, nil},
+		{"canceled webhook lost", local("sub_1", "active"), []payments.Subscription{remote("sub_1", "canceled")},
+			[]Issue{Status}, []bool{true}},
+		{"renewal missed", Subscription{ProviderID: "sub_1", Status: "active", PeriodStart: &lastMonth, PeriodEnd: &start},
+			[]payments.Subscription{remote("sub_1", "active")}, []Issue{Period}, []bool{true}},
+		{"deleted at the provider", local("sub_1", "active"), nil, []Issue{MissingAtProvider}, []bool{false}},
+		{"checkout never recorded", Subscription{Status: "trialing"}, []payments.Subscription{remote("sub_2", "active")},
+			[]Issue{MissingLocally}, []bool{true}},
+		{"two unknown subscriptions", Subscription{}, []payments.Subscription{remote("sub_2", "active"), remote("sub_3", "active")},
+			[]Issue{MissingLocally, MissingLocally}, []bool{false, false}},
+		{"another live subscription", local("sub_1", "active"),
+			[]payments.Subscription{remote("sub_1", "active"), remote("sub_2", "active")}, []Issue{MissingLocally}, []bool{false}},
+		{"old canceled subscription", local("sub_1", "active"),
+			[]payments.Subscription{remote("sub_1", "active"), remote("sub_0", "canceled")}, nil, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			found := CompareSubscription(org, c.local, c.remote)
+			if !sameIssues(issues(found), c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, issues(found))
+			}
+			for i, d := range found {
+				if d.Fixable != c.fixable[i] || d.Object != ObjectSubscription {
+					t.Errorf("Expected %s to be fixable %v, got %+v", d.Issue, c.fixable[i], d)
+				}
+				if d.Fixable && d.subscription == nil {
+					t.Errorf("Expected fixable %s to carry the provider's subscription", d.Issue)
+				}
+			}
+		})
+	}
+}
+
+func TestImportedInvoice(t *testing.T) {
+	org := uuid.New()
+	paidAt := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	r := payments.Invoice{ID: "in_9", Number: "ACME-0009", Status: "paid", Currency: money.EUR, SubtotalCents: 10000,
+		TaxCents: 1900, TotalCents: 10900, PaidAt: &paidAt,
+		PeriodStart: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), PeriodEnd: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+
+	inv := ImportedInvoice(org, "stripe", r)
+	if err := inv.Validate(); err != nil {
+		t.Fatalf("Expected the imported invoice to add up, got %v", err)
+	}
+	if inv.Kind != invoices.Provider || inv.ProviderInvoiceID != "in_9" || inv.Status != "paid" || inv.PaidAt != &paidAt {
+		t.Errorf("Expected a paid provider invoice in_9, got %+v", inv)
+	}
+	if inv.DiscountCents != 1000 || len(inv.Lines) != 3 {
+		t.Errorf("Expected a 1000 discount over base, discount and tax lines, got %d over %+v", inv.DiscountCents, inv.Lines)
+	}
+	if inv.Lines[0].Description != "stripe invoice ACME-0009" {
+		t.Errorf("Expected the base line to name the provider's number, got %q", inv.Lines[0].Description)
+	}
+
+	r.Number, r.TaxCents, r.TotalCents, r.Status = "", 0, 10000, "open"
+	inv = ImportedInvoice(org, "stripe", r)
+	if err := inv.Validate(); err != nil || len(inv.Lines) != 1 || inv.Status != "pending" {
+		t.Errorf("Expected a single pending line, got %+v (%v)", inv, err)
+	}
+}
+
+func TestReportUnresolved(t *testing.T) {
+	r := Report{Discrepancies: []Discrepancy{{Fixed: true}, {Fixable: true}, {}}}
+	if r.Unresolved() != 2 {
+		t.Errorf("Expected 2 unresolved discrepancies, got %d", r.Unresolved())
+	}
+}
@@ -0,0 +1,191 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+)
+
+// matchWindow is how much earlier than the checked window provider invoices are listed,
+// so a local invoice stored just after the provider created it still finds its match
+const matchWindow = 24 * time.Hour
+
+// Options controls a reconciliation
+type Options struct {
+	// OrgID reconciles a single organization when set
+	OrgID uuid.UUID
+	// Since is when the oldest invoice checked was created
+	Since time.Time
+	// Fix updates the local records of fixable discrepancies to match the provider
+	Fix bool
+}
+
+// Report lists the discrepancies a reconciliation found, and which it fixed
+type Report struct {
+	Provider      string        `json:"provider"`
+	Since         time.Time     `json:"since"`
+	Fix           bool          `json:"fix"`
+	Customers     int           `json:"customers"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Unresolved counts the discrepancies left after the reconciliation
+func (r *Report) Unresolved() int {
+	n := 0
+	for _, d := range r.Discrepancies {
+		if !d.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// Reconciler reconciles local invoices and subscriptions with the payment provider's
+type Reconciler struct {
+	db       *pgxpool.Pool
+	provider payments.Provider
+	invoices *invoices.Store
+}
+
+// New creates a reconciler for the records in db and the provider's
+func New(db *pgxpool.Pool, provider payments.Provider) *Reconciler {
+	return &Reconciler{db: db, provider: provider, invoices: invoices.NewStore(db)}
+}
+
+// customer is an organization with a customer at the provider, and its subscription
+type customer struct {
+	orgID        uuid.UUID
+	customerID   string
+	subscription Subscription
+}
+
+// Run reconciles every organization with a customer at the provider, one at a time.
+// A customer the provider fails to list is reported as unchecked and the others are
+// still reconciled.
+func (r *Reconciler) Run(ctx context.Context, opts Options) (*Report, error) {
+	lister, ok := r.provider.(payments.Lister)
+	if !ok {
+		return nil, fmt.Errorf("%w: listing %s invoices and subscriptions", payments.ErrUnsupported, r.provider.Name())
+	}
+	customers, err := r.customers(ctx, opts.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OrgID != uuid.Nil && len(customers) == 0 {
+		return nil, fmt.Errorf("organization %s has no %s customer", opts.OrgID, r.provider.Name())
+	}
+
+	report := &Report{Provider: r.provider.Name(), Since: opts.Since, Fix: opts.Fix, Customers: len(customers),
+		Discrepancies: []Discrepancy{}}
+	for _, c := range customers {
+		found, err := r.compare(ctx, lister, c, opts.Since)
+		if err != nil {
+			log.Printf("Failed to reconcile org %s: %v", c.orgID, err)
+			found = []Discrepancy{{OrganizationID: c.orgID, Object: ObjectCustomer, ProviderID: c.customerID,
+				Issue: Unchecked, Error: err.Error()}}
+		}
+		for _, d := range found {
+			if opts.Fix && d.Fixable {
+				if err := r.fix(ctx, d); err != nil {
+					log.Printf("Failed to fix %s %s of org %s: %v", d.Issue, d.Object, c.orgID, err)
+					d.Error = err.Error()
+				} else {
+					d.Fixed = true
+				}
+			}
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+	}
+	return report, nil
+}
+
+// customers returns the organizations with a customer at the provider, only one when
+// orgID is set
+func (r *Reconciler) customers(ctx context.Context, orgID uuid.UUID) ([]customer, error) {
+	query := `SELECT o.id, o.stripe_customer_id, COALESCE(s.stripe_subscription_id, ''), COALESCE(s.status, ''),
+		        s.current_period_start, s.current_period_end
+		 FROM organizations o
+		 LEFT JOIN subscriptions s ON s.organization_id = o.id
+		 WHERE COALESCE(o.stripe_customer_id, '') != ''`
+	var args []any
+	if orgID != uuid.Nil {
+		query += ` AND o.id = $1`
+		args = append(args, orgID)
+	}
+	rows, err := r.db.Query(ctx, query+` ORDER BY o.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customers: %w", err)
+	}
+	defer rows.Close()
+
+	var list []customer
+	for rows.Next() {
+		var c customer
+		s := &c.subscription
+		if err := rows.Scan(&c.orgID, &c.customerID, &s.ProviderID, &s.Status, &s.PeriodStart, &s.PeriodEnd); err != nil {
+			return nil, fmt.Errorf("failed to get customers: %w", err)
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// compare lists a customer at the provider and compares it with the local records
+func (r *Reconciler) compare(ctx context.Context, lister payments.Lister, c customer, since time.Time) ([]Discrepancy, error) {
+	remoteInvoices, err := lister.ListInvoices(ctx, c.customerID, since.Add(-matchWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s invoices: %w", r.provider.Name(), err)
+	}
+	remoteSubscriptions, err := lister.ListSubscriptions(ctx, c.customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s subscriptions: %w", r.provider.Name(), err)
+	}
+	localInvoices, err := r.invoices.Since(ctx, c.orgID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	found := CompareInvoices(c.orgID, localInvoices, remoteInvoices, since)
+	return append(found, CompareSubscription(c.orgID, c.subscription, remoteSubscriptions)...), nil
+}
+
+// fix updates the local record of a fixable discrepancy to match the provider's
+func (r *Reconciler) fix(ctx context.Context, d Discrepancy) error {
+	switch {
+	case d.Object == ObjectInvoice && d.Issue == Status:
+		return r.invoices.SetStatus(ctx, *d.LocalID, d.Provider, d.invoice.PaidAt)
+	case d.Object == ObjectInvoice && d.Issue == MissingLocally:
+		_, err := r.invoices.Create(ctx, ImportedInvoice(d.OrganizationID, r.provider.Name(), *d.invoice))
+		return err
+	case d.Object == ObjectSubscription && d.subscription != nil:
+		return r.syncSubscription(ctx, d.OrganizationID, *d.subscription)
+	default:
+		return fmt.Errorf("%s %s discrepancies cannot be fixed", d.Object, d.Issue)
+	}
+}
+
+// syncSubscription copies the provider's subscription to an organization's local one
+func (r *Reconciler) syncSubscription(ctx context.Context, orgID uuid.UUID, sub payments.Subscription) error {
+	var anchor *time.Time
+	if !sub.BillingCycleAnchor.IsZero() {
+		anchor = &sub.BillingCycleAnchor
+	}
+	tag, err := r.db.Exec(ctx,
+		`UPDATE subscriptions SET stripe_subscription_id = $2, status = $3, current_period_start = $4,
+		        current_period_end = $5, billing_anchor = COALESCE($6, billing_anchor), updated_at = NOW()
+		 WHERE organization_id = $1`,
+		orgID, sub.ID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, anchor)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization %s has no local subscription", orgID)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package runs
+
+import (
+	"time"
+	// Billing time zones load in images without zoneinfo, like the alpine ones
+	_ "time/tzdata"
+)
+
+// Period is one billing period of a subscription, from Start up to but excluding End
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Schedule is the monthly billing schedule of a subscription. Periods start on the
+// anchor's day of the month, at its time of day in the organization's time zone, or on
+// the last day of months too short for it: a subscription anchored on January 31st
+// renews on February 28th and then on March 31st.
+type Schedule struct {
+	Anchor   time.Time
+	Location *time.Location
+}
+
+// Boundary returns the start of the n-th period after the anchor's; n may be negative
+func (s Schedule) Boundary(n int) time.Time {
+	a := s.Anchor.In(s.location())
+	first := time.Date(a.Year(), a.Month()+time.Month(n), 1, 0, 0, 0, 0, a.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(a.Day(), lastDay), a.Hour(), a.Minute(), a.Second(), a.Nanosecond(), a.Location())
+}
+
+// index returns n such that t is in the n-th period after the anchor's
+func (s Schedule) index(t time.Time) int {
+	a, t := s.Anchor.In(s.location()), t.In(s.location())
+	n := (t.Year()-a.Year())*12 + int(t.Month()-a.Month())
+	if t.Before(s.Boundary(n)) {
+		n--
+	}
+	return n
+}
+
+// Due returns the periods that ended by cutoff and have not been billed, oldest first.
+// billedThrough is the end of the last period billed. The first period due starts
+// there, and may be shorter than a month if the schedule was anchored again since.
+// A subscription that was never billed owes only the last period that ended.
+func (s Schedule) Due(billedThrough, cutoff time.Time) []Period {
+	if billedThrough.IsZero() {
+		n := s.index(cutoff) - 1
+		if n < 0 {
+			return nil
+		}
+		return []Period{{Start: s.Boundary(n), End: s.Boundary(n + 1)}}
+	}
+
+	var due []Period
+	for start := billedThrough; ; {
+		end := s.Boundary(s.index(start) + 1)
+		if end.After(cutoff) {
+			return due
+		}
+		due = append(due, Period{Start: start, End: end})
+		start = end
+	}
+}
+
+func (s Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
@@ -0,0 +1,94 @@
+package runs
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("Expected time zone %s, got %v", name, err)
+	}
+	return loc
+}
+
+func TestScheduleBoundary(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+	cases := []struct {
+		name     string
+		anchor   time.Time
+		n        int
+		expected time.Time
+	}{
+		{"next month", time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC), 1, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{"previous month", time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC), -1, time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC)},
+		{"end of February", time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC), 1, time.Date(2027, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{"leap year", time.Date(2028, 1, 31, 0, 0, 0, 0, time.UTC), 1, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"back to the 31st", time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC), 2, time.Date(2027, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"next year", time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC), 3, time.Date(2027, 2, 28, 0, 0, 0, 0, time.UTC)},
+		// Midnight in New York is 04:00 UTC in summer and 05:00 UTC in winter
+		{"across daylight saving", time.Date(2026, 10, 1, 0, 0, 0, 0, newYork), 2, time.Date(2026, 12, 1, 5, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := Schedule{Anchor: c.anchor, Location: c.anchor.Location()}
+			if got := s.Boundary(c.n); !got.Equal(c.expected) {
+				t.Errorf("Expected %s, got %s", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestScheduleUsesTimeZone(t *testing.T) {
+	tokyo := mustLocation(t, "Asia/Tokyo")
+	// The 1st in Tokyo starts on the 30th or 31st in UTC
+	anchor := time.Date(2026, 8, 31, 15, 0, 0, 0, time.UTC)
+	s := Schedule{Anchor: anchor, Location: tokyo}
+	if got, expected := s.Boundary(1), time.Date(2026, 9, 30, 15, 0, 0, 0, time.UTC); !got.Equal(expected) {
+		t.Errorf("Expected periods to start on the 1st in Tokyo (%s), got %s", expected, got)
+	}
+	utc := Schedule{Anchor: anchor}
+	if got, expected := utc.Boundary(1), time.Date(2026, 9, 30, 15, 0, 0, 0, time.UTC); !got.Equal(expected) {
+		t.Errorf("Expected periods to start on the 30th, the last day of September in UTC (%s), got %s", expected, got)
+	}
+	if got, expected := utc.Boundary(2), time.Date(2026, 10, 31, 15, 0, 0, 0, time.UTC); !got.Equal(expected) {
+		t.Errorf("Expected the 31st in UTC (%s), got %s", expected, got)
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	anchor := time.Date(2026, 1, 15, 9, 30, 0, 0, time.UTC)
+	s := Schedule{Anchor: anchor}
+	at := func(month time.Month, day int) time.Time { return time.Date(2026, month, day, 9, 30, 0, 0, time.UTC) }
+
+	cases := []struct {
+		name          string
+		billedThrough time.Time
+		cutoff        time.Time
+		expected      []Period
+	}{
+		{"never billed", time.Time{}, at(10, 20), []Period{{at(9, 15), at(10, 15)}}},
+		{"never billed, first period not over", time.Time{}, at(2, 1), nil},
+		{"period ended at the cutoff", at(9, 15), at(10, 15), []Period{{at(9, 15), at(10, 15)}}},
+		{"period not over", at(10, 15), at(11, 14), nil},
+		{"catching up", at(7, 15), at(10, 16), []Period{{at(7, 15), at(8, 15)}, {at(8, 15), at(9, 15)}, {at(9, 15), at(10, 15)}}},
+		// Billed by calendar month before the subscription had its own periods
+		{"from a calendar month", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), at(11, 15),
+			[]Period{{time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), at(10, 15)}, {at(10, 15), at(11, 15)}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			due := s.Due(c.billedThrough, c.cutoff)
+			if len(due) != len(c.expected) {
+				t.Fatalf("Expected %d periods, got %v", len(c.expected), due)
+			}
+			for i, p := range due {
+				if !p.Start.Equal(c.expected[i].Start) || !p.End.Equal(c.expected[i].End) {
+					t.Errorf("Expected period %d to be %v, got %v", i, c.expected[i], p)
+				}
+			}
+		})
+	}
+}
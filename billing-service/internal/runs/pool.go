@@ -0,0 +1,142 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pool bills organizations concurrently
+type Pool struct {
+	// Concurrency is how many organizations are billed at once; less than 1 means 1
+	Concurrency int
+	// Timeout bounds the billing of each organization; zero means no limit
+	Timeout time.Duration
+}
+
+// Each calls fn for every organization, at most p.Concurrency at a time and each under
+// its own timeout, and returns the results in the order of orgIDs. Once ctx is done the
+// remaining organizations are still passed to fn, with the done context, so every one
+// of them has a result.
+func Each[T any](ctx context.Context, p Pool, orgIDs []uuid.UUID, fn func(ctx context.Context, orgID uuid.UUID) T) []T {
+	results := make([]T, len(orgIDs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(p.Concurrency, 1), len(orgIDs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = call(ctx, p.Timeout, orgIDs[i], fn)
+			}
+		}()
+	}
+	for i := range orgIDs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// call calls fn for one organization under a timeout
+func call[T any](ctx context.Context, timeout time.Duration, orgID uuid.UUID, fn func(ctx context.Context, orgID uuid.UUID) T) T {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx, orgID)
+}
+
+// Progress counts the organizations of a billing run as they are billed. It is safe
+// for concurrent use, and a nil Progress counts nothing.
+type Progress struct {
+	mu       sync.Mutex
+	started  time.Time
+	total    int
+	byStatus map[OrgStatus]int
+}
+
+// NewProgress starts counting a billing run
+func NewProgress() *Progress {
+	return &Progress{started: time.Now(), byStatus: map[OrgStatus]int{}}
+}
+
+// SetTotal sets how many organizations the run bills
+func (p *Progress) SetTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// Record counts an organization as billed with a status
+func (p *Progress) Record(status OrgStatus) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byStatus[status]++
+}
+
+// Done returns how many organizations have been billed, and how many of them failed
+func (p *Progress) Done() (done, failed int) {
+	if p == nil {
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, n := range p.byStatus {
+		done += n
+	}
+	return done, p.byStatus[OrgFailed]
+}
+
+func (p *Progress) String() string {
+	if p == nil {
+		return "no billing run"
+	}
+	done, failed := p.Done()
+	p.mu.Lock()
+	total := p.total
+	p.mu.Unlock()
+	return fmt.Sprintf("%d/%d organizations billed, %d failed", done, total, failed)
+}
+
+// WriteMetrics writes the progress in the Prometheus text format
+func (p *Progress) WriteMetrics(w io.Writer) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP billing_run_organizations Organizations to bill in the current billing run\n")
+	fmt.Fprintf(w, "# TYPE billing_run_organizations gauge\n")
+	fmt.Fprintf(w, "billing_run_organizations %d\n", p.total)
+
+	statuses := make([]string, 0, len(p.byStatus))
+	for s := range p.byStatus {
+		statuses = append(statuses, string(s))
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(w, "# HELP billing_run_organizations_billed_total Organizations billed so far, by outcome\n")
+	fmt.Fprintf(w, "# TYPE billing_run_organizations_billed_total counter\n")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "billing_run_organizations_billed_total{status=%q} %d\n", s, p.byStatus[OrgStatus(s)])
+	}
+
+	fmt.Fprintf(w, "# HELP billing_run_duration_seconds Time since the billing run started\n")
+	fmt.Fprintf(w, "# TYPE billing_run_duration_seconds gauge\n")
+	fmt.Fprintf(w, "billing_run_duration_seconds %.3f\n", time.Since(p.started).Seconds())
+}
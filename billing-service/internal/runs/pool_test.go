@@ -0,0 +1,120 @@
+package runs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func orgIDs(n int) []uuid.UUID {
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	return ids
+}
+
+func TestEachBillsTenThousandOrgs(t *testing.T) {
+	ids := orgIDs(10000)
+	progress := NewProgress()
+	progress.SetTotal(len(ids))
+
+	var running, peak atomic.Int64
+	start := time.Now()
+	results := Each(context.Background(), Pool{Concurrency: 100, Timeout: time.Second}, ids,
+		func(ctx context.Context, orgID uuid.UUID) uuid.UUID {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			// Each organization takes a millisecond of database and provider calls
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			progress.Record(OrgInvoiced)
+			return orgID
+		})
+	elapsed := time.Since(start)
+
+	// Serially this takes over 10s; 100 at a time should take about 100ms
+	if elapsed > 3*time.Second {
+		t.Errorf("Expected 10k organizations to be billed within 3s, took %s", elapsed)
+	}
+	if peak.Load() > 100 {
+		t.Errorf("Expected at most 100 organizations at once, got %d", peak.Load())
+	}
+	for i, id := range results {
+		if id != ids[i] {
+			t.Fatalf("Expected result %d to be for %s, got %s", i, ids[i], id)
+		}
+	}
+	if done, failed := progress.Done(); done != 10000 || failed != 0 {
+		t.Errorf("Expected 10000 billed and none failed, got %d and %d", done, failed)
+	}
+}
+
+func TestEachTimesOutSlowOrgs(t *testing.T) {
+	ids := orgIDs(4)
+	slow := ids[1]
+	results := Each(context.Background(), Pool{Concurrency: 2, Timeout: 20 * time.Millisecond}, ids,
+		func(ctx context.Context, orgID uuid.UUID) error {
+			if orgID != slow {
+				return nil
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	for i, err := range results {
+		if (ids[i] == slow) != errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected only the slow organization to time out, got %v for %d", err, i)
+		}
+	}
+}
+
+func TestEachAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls atomic.Int64
+	results := Each(ctx, Pool{Concurrency: 4}, orgIDs(10), func(ctx context.Context, orgID uuid.UUID) error {
+		calls.Add(1)
+		return ctx.Err()
+	})
+	if calls.Load() != 10 || !errors.Is(results[9], context.Canceled) {
+		t.Errorf("Expected every organization to see the canceled run, got %d calls and %v", calls.Load(), results[9])
+	}
+}
+
+func TestProgressMetrics(t *testing.T) {
+	p := NewProgress()
+	p.SetTotal(3)
+	p.Record(OrgInvoiced)
+	p.Record(OrgInvoiced)
+	p.Record(OrgFailed)
+
+	var b strings.Builder
+	p.WriteMetrics(&b)
+	for _, want := range []string{
+		"billing_run_organizations 3\n",
+		`billing_run_organizations_billed_total{status="failed"} 1` + "\n",
+		`billing_run_organizations_billed_total{status="invoiced"} 2` + "\n",
+		"billing_run_duration_seconds ",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, b.String())
+		}
+	}
+	if p.String() != "3/3 organizations billed, 1 failed" {
+		t.Errorf("Expected 3/3 billed with 1 failed, got %q", p.String())
+	}
+
+	var none *Progress
+	none.Record(OrgInvoiced)
+	none.WriteMetrics(&b)
+}
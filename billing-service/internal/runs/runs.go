@@ -0,0 +1,87 @@
+// Package runs records billing runs and the state of every organization billed in them,
+// so a run that stops halfway can be resumed without invoicing anyone twice, and works
+// out which periods of each subscription are due.
+package runs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRunExists = errors.New("billing run already exists for day")
+	ErrNotFound  = errors.New("billing run not found")
+	ErrLocked    = errors.New("another billing run is in progress")
+)
+
+// Status is the state of a billing run
+type Status string
+
+const (
+	Running   Status = "running"
+	Completed Status = "completed"
+	Failed    Status = "failed"
+)
+
+// OrgStatus is the state of one organization in a billing run
+type OrgStatus string
+
+const (
+	// OrgInvoiced organizations have an invoice for the period
+	OrgInvoiced OrgStatus = "invoiced"
+	// OrgSkipped organizations owed nothing for the period
+	OrgSkipped OrgStatus = "skipped"
+	// OrgFailed organizations are billed again when the run is resumed
+	OrgFailed OrgStatus = "failed"
+	// OrgDryRun organizations would be invoiced; dry runs are never recorded
+	OrgDryRun OrgStatus = "dry_run"
+)
+
+// Run is the billing run of one day: it bills the subscription periods that ended by
+// PeriodEnd. There is at most one run per day; resuming it counts another attempt.
+type Run struct {
+	ID          uuid.UUID  `json:"id"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Status      Status     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	Processed   int        `json:"processed"`
+	Failed      int        `json:"failed"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// Org is the state of one organization in a billing run. PeriodStart is the start of
+// the subscription period billed last, and ProviderInvoiceID its invoice at the provider,
+// recorded as soon as the provider has it even if storing it locally then failed.
+type Org struct {
+	RunID             uuid.UUID  `json:"run_id"`
+	OrganizationID    uuid.UUID  `json:"organization_id"`
+	Status            OrgStatus  `json:"status"`
+	PeriodStart       *time.Time `json:"period_start,omitempty"`
+	ProviderInvoiceID string     `json:"provider_invoice_id,omitempty"`
+	InvoiceID         *uuid.UUID `json:"invoice_id,omitempty"`
+	Error             string     `json:"error,omitempty"`
+	Attempts          int        `json:"attempts"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Done reports whether the organization needs no more billing in this run
+func (o Org) Done() bool {
+	return o.Status == OrgInvoiced || o.Status == OrgSkipped
+}
+
+// IdempotencyKey keys the provider calls that bill an organization for a period. Every
+// attempt at the same bill sends the same key, so the provider returns what the first
+// attempt created instead of charging again.
+func IdempotencyKey(orgID uuid.UUID, periodStart time.Time) string {
+	return fmt.Sprintf("billing-%s-%s", orgID, periodStart.UTC().Format("20060102T150405Z"))
+}
+
+// TrueUpIdempotencyKey keys the provider calls that invoice a contract's true-up
+func TrueUpIdempotencyKey(contractID uuid.UUID) string {
+	return fmt.Sprintf("true-up-%s", contractID)
+}
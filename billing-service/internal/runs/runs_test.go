@@ -0,0 +1,53 @@
+package runs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	org := uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000")
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	key := IdempotencyKey(org, start)
+	if key != "billing-1a2b3c4d-0000-0000-0000-000000000000-20260901T000000Z" {
+		t.Errorf("Expected a key for the period starting 2026-09-01, got %s", key)
+	}
+	if IdempotencyKey(org, start.Add(time.Hour)) == key {
+		t.Error("Expected another period to have another key")
+	}
+	if IdempotencyKey(uuid.New(), start) == key {
+		t.Error("Expected another organization to have another key")
+	}
+}
+
+func TestOrgDone(t *testing.T) {
+	cases := []struct {
+		status OrgStatus
+		done   bool
+	}{
+		{OrgInvoiced, true},
+		{OrgSkipped, true},
+		{OrgFailed, false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if (Org{Status: c.status}).Done() != c.done {
+			t.Errorf("Expected %q done to be %v", c.status, c.done)
+		}
+	}
+}
+
+func TestStoreWithoutDatabase(t *testing.T) {
+	var s *Store
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.Start(context.Background(), start, start.AddDate(0, 1, 0), false); err == nil {
+		t.Error("Expected a run not to start without a database")
+	}
+	orgs, err := s.Orgs(context.Background(), uuid.New())
+	if err != nil || len(orgs) != 0 {
+		t.Errorf("Expected no organizations, got %v, %v", orgs, err)
+	}
+}
@@ -0,0 +1,195 @@
+package runs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const runColumns = `id, period_start, period_end, status, attempts, processed, failed, started_at, finished_at`
+
+// Store reads and writes billing runs in Postgres. A nil Store, or one without a
+// database, has no runs and cannot start one.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the billing_runs and billing_run_organizations tables
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+func scanRun(row pgx.Row) (Run, error) {
+	var r Run
+	err := row.Scan(&r.ID, &r.PeriodStart, &r.PeriodEnd, &r.Status, &r.Attempts, &r.Processed, &r.Failed, &r.StartedAt, &r.FinishedAt)
+	return r, err
+}
+
+// Lock takes the lock that lets one process bill at a time, on a connection it holds
+// until release is called. The lock is a Postgres session advisory lock, so it is also
+// released if the process dies. Lock fails with ErrLocked if another process holds it.
+func (s *Store) Lock(ctx context.Context) (release func(), err error) {
+	if !s.available() {
+		return nil, fmt.Errorf("billing run store has no database")
+	}
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock billing runs: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext('billing-runs'))`).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to lock billing runs: %w", err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, ErrLocked
+	}
+	return func() {
+		// The run's context may be done by now
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext('billing-runs'))`); err != nil {
+			// Closing the connection ends the session, which releases the lock
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, nil
+}
+
+// Start starts the billing run of a day. If the day already has a run, Start fails
+// with ErrRunExists unless resume is set, in which case the run is started again.
+func (s *Store) Start(ctx context.Context, periodStart, periodEnd time.Time, resume bool) (Run, error) {
+	if !s.available() {
+		return Run{}, fmt.Errorf("billing run store has no database")
+	}
+
+	run, err := scanRun(s.db.QueryRow(ctx,
+		`INSERT INTO billing_runs (id, period_start, period_end, status)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (period_start) DO NOTHING
+		 RETURNING `+runColumns,
+		uuid.New(), periodStart, periodEnd, Running))
+	if err == nil {
+		return run, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Run{}, fmt.Errorf("failed to start billing run: %w", err)
+	}
+
+	existing, err := s.ForPeriod(ctx, periodStart)
+	if err != nil {
+		return Run{}, err
+	}
+	if !resume {
+		return Run{}, fmt.Errorf("%w: %s run %s started %s", ErrRunExists,
+			existing.Status, existing.ID, existing.StartedAt.UTC().Format(time.RFC3339))
+	}
+
+	run, err = scanRun(s.db.QueryRow(ctx,
+		`UPDATE billing_runs SET status = $2, attempts = attempts + 1, finished_at = NULL
+		 WHERE id = $1
+		 RETURNING `+runColumns,
+		existing.ID, Running))
+	if err != nil {
+		return Run{}, fmt.Errorf("failed to resume billing run: %w", err)
+	}
+	return run, nil
+}
+
+// ForPeriod returns the billing run of the day starting at periodStart
+func (s *Store) ForPeriod(ctx context.Context, periodStart time.Time) (Run, error) {
+	if !s.available() {
+		return Run{}, ErrNotFound
+	}
+	run, err := scanRun(s.db.QueryRow(ctx,
+		`SELECT `+runColumns+` FROM billing_runs WHERE period_start = $1`, periodStart))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Run{}, ErrNotFound
+	}
+	if err != nil {
+		return Run{}, fmt.Errorf("failed to read billing run: %w", err)
+	}
+	return run, nil
+}
+
+// Orgs returns the state of every organization billed so far in a run
+func (s *Store) Orgs(ctx context.Context, runID uuid.UUID) (map[uuid.UUID]Org, error) {
+	orgs := map[uuid.UUID]Org{}
+	if !s.available() {
+		return orgs, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT run_id, organization_id, status, period_start, COALESCE(provider_invoice_id, ''), invoice_id,
+		        COALESCE(error, ''), attempts, updated_at
+		 FROM billing_run_organizations WHERE run_id = $1`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read billing run organizations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o Org
+		if err := rows.Scan(&o.RunID, &o.OrganizationID, &o.Status, &o.PeriodStart, &o.ProviderInvoiceID, &o.InvoiceID,
+			&o.Error, &o.Attempts, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		orgs[o.OrganizationID] = o
+	}
+	return orgs, rows.Err()
+}
+
+// SetOrg records the state of an organization in a run. A provider invoice ID, once
+// recorded, is kept when a later attempt at the same period fails before reaching the
+// provider.
+func (s *Store) SetOrg(ctx context.Context, o Org) error {
+	if !s.available() {
+		return fmt.Errorf("billing run store has no database")
+	}
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO billing_run_organizations
+		   (run_id, organization_id, status, period_start, provider_invoice_id, invoice_id, error, updated_at)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NOW())
+		 ON CONFLICT (run_id, organization_id) DO UPDATE SET
+		   status = EXCLUDED.status,
+		   provider_invoice_id = CASE
+		     WHEN billing_run_organizations.period_start IS DISTINCT FROM EXCLUDED.period_start THEN EXCLUDED.provider_invoice_id
+		     ELSE COALESCE(EXCLUDED.provider_invoice_id, billing_run_organizations.provider_invoice_id) END,
+		   invoice_id = CASE
+		     WHEN billing_run_organizations.period_start IS DISTINCT FROM EXCLUDED.period_start THEN EXCLUDED.invoice_id
+		     ELSE COALESCE(EXCLUDED.invoice_id, billing_run_organizations.invoice_id) END,
+		   period_start = EXCLUDED.period_start,
+		   error = EXCLUDED.error,
+		   attempts = billing_run_organizations.attempts + 1,
+		   updated_at = NOW()`,
+		o.RunID, o.OrganizationID, o.Status, o.PeriodStart, o.ProviderInvoiceID, o.InvoiceID, o.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record billing run organization: %w", err)
+	}
+	return nil
+}
+
+// Finish records the outcome of a run attempt. The run failed if any organization did.
+func (s *Store) Finish(ctx context.Context, runID uuid.UUID, processed, failed int) error {
+	if !s.available() {
+		return fmt.Errorf("billing run store has no database")
+	}
+	status := Completed
+	if failed > 0 {
+		status = Failed
+	}
+	_, err := s.db.Exec(ctx,
+		`UPDATE billing_runs SET status = $2, processed = $3, failed = $4, finished_at = NOW() WHERE id = $1`,
+		runID, status, processed, failed)
+	if err != nil {
+		return fmt.Errorf("failed to finish billing run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package tax
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Formats of EU VAT numbers after the country prefix. Greece uses EL, not GR.
+var euVATFormats = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U\d{8}$`),
+	"BE": regexp.MustCompile(`^[01]\d{9}$`),
+	"BG": regexp.MustCompile(`^\d{9,10}$`),
+	"CY": regexp.MustCompile(`^\d{8}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^\d{8,10}$`),
+	"DE": regexp.MustCompile(`^\d{9}$`),
+	"DK": regexp.MustCompile(`^\d{8}$`),
+	"EE": regexp.MustCompile(`^\d{9}$`),
+	"EL": regexp.MustCompile(`^\d{9}$`),
+	"ES": regexp.MustCompile(`^[A-Z0-9]\d{7}[A-Z0-9]$`),
+	"FI": regexp.MustCompile(`^\d{8}$`),
+	"FR": regexp.MustCompile(`^[A-HJ-NP-Z0-9]{2}\d{9}$`),
+	"HR": regexp.MustCompile(`^\d{11}$`),
+	"HU": regexp.MustCompile(`^\d{8}$`),
+	"IE": regexp.MustCompile(`^(\d{7}[A-W][A-I]?|\d[A-Z+*]\d{5}[A-W])$`),
+	"IT": regexp.MustCompile(`^\d{11}$`),
+	"LT": regexp.MustCompile(`^(\d{9}|\d{12})$`),
+	"LU": regexp.MustCompile(`^\d{8}$`),
+	"LV": regexp.MustCompile(`^\d{11}$`),
+	"MT": regexp.MustCompile(`^\d{8}$`),
+	"NL": regexp.MustCompile(`^\d{9}B\d{2}$`),
+	"PL": regexp.MustCompile(`^\d{10}$`),
+	"PT": regexp.MustCompile(`^\d{9}$`),
+	"RO": regexp.MustCompile(`^[1-9]\d{1,9}$`),
+	"SE": regexp.MustCompile(`^\d{10}01$`),
+	"SI": regexp.MustCompile(`^\d{8}$`),
+	"SK": regexp.MustCompile(`^\d{10}$`),
+}
+
+var (
+	gbVATFormat = regexp.MustCompile(`^GB(\d{9}|\d{12}|GD[0-4]\d{2}|HA[5-9]\d{2})$`)
+	abnFormat   = regexp.MustCompile(`^[1-9]\d{10}$`)
+)
+
+// normalizeID strips the spaces, dots and dashes people type into tax IDs
+func normalizeID(value string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", ".", "", "-", "").Replace(value))
+}
+
+// ParseID normalizes a tax ID and checks its format, returning it with its country
+func ParseID(t IDType, value string) (ID, error) {
+	v := normalizeID(value)
+	id := ID{Type: t, Value: v}
+
+	switch t {
+	case EUVAT:
+		if len(v) < 3 {
+			return ID{}, fmt.Errorf("%w: %q is not an EU VAT number", ErrInvalidTaxID, value)
+		}
+		prefix := v[:2]
+		format, ok := euVATFormats[prefix]
+		if !ok {
+			return ID{}, fmt.Errorf("%w: %q does not start with an EU country code", ErrInvalidTaxID, value)
+		}
+		if !format.MatchString(v[2:]) {
+			return ID{}, fmt.Errorf("%w: %q is not a valid %s VAT number", ErrInvalidTaxID, value, prefix)
+		}
+		id.Country = prefix
+		if prefix == "EL" {
+			id.Country = "GR"
+		}
+
+	case GBVAT:
+		if !strings.HasPrefix(v, "GB") {
+			v = "GB" + v
+			id.Value = v
+		}
+		if !gbVATFormat.MatchString(v) || !gbVATChecksum(v[2:]) {
+			return ID{}, fmt.Errorf("%w: %q is not a valid GB VAT number", ErrInvalidTaxID, value)
+		}
+		id.Country = "GB"
+
+	case AUABN:
+		if !abnFormat.MatchString(v) || !abnChecksum(v) {
+			return ID{}, fmt.Errorf("%w: %q is not a valid ABN", ErrInvalidTaxID, value)
+		}
+		id.Country = "AU"
+
+	default:
+		return ID{}, fmt.Errorf("%w: type must be %s, %s or %s", ErrInvalidTaxID, EUVAT, GBVAT, AUABN)
+	}
+	return id, nil
+}
+
+// gbVATChecksum checks the mod 97 check digits of a standard GB VAT number. Branch
+// (12 digit) numbers are checked on their first 9 digits; government department
+// and health authority numbers have no check digits.
+func gbVATChecksum(digits string) bool {
+	if strings.HasPrefix(digits, "GD") || strings.HasPrefix(digits, "HA") {
+		return true
+	}
+	sum := 0
+	for i := 0; i < 7; i++ {
+		sum += int(digits[i]-'0') * (8 - i)
+	}
+	check := int(digits[7]-'0')*10 + int(digits[8]-'0')
+	return (sum+check)%97 == 0 || (sum+check+55)%97 == 0
+}
+
+// abnChecksum checks an Australian Business Number: subtract 1 from the first digit,
+// weight the digits, and the sum must divide by 89
+func abnChecksum(digits string) bool {
+	weights := []int{10, 1, 3, 5, 7, 9, 11, 13, 15, 17, 19}
+	sum := 0
+	for i, w := range weights {
+		d := int(digits[i] - '0')
+		if i == 0 {
+			d--
+		}
+		sum += d * w
+	}
+	return sum%89 == 0
+}
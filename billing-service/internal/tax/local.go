@@ -0,0 +1,90 @@
+package tax
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Standard VAT rates of the EU member states, in percent
+var euVATRates = map[string]string{
+	"AT": "20", "BE": "21", "BG": "20", "CY": "19", "CZ": "21", "DE": "19", "DK": "25",
+	"EE": "24", "ES": "21", "FI": "25.5", "FR": "20", "GR": "24", "HR": "25", "HU": "27",
+	"IE": "23", "IT": "22", "LT": "21", "LU": "17", "LV": "21", "MT": "18", "NL": "21",
+	"PL": "23", "PT": "23", "RO": "21", "SE": "25", "SI": "22", "SK": "23",
+}
+
+const (
+	gbVATRate = "20"
+	auGSTRate = "10"
+)
+
+// IsEU reports whether a country is an EU member state
+func IsEU(country string) bool {
+	_, ok := euVATRates[country]
+	return ok
+}
+
+// Local applies VAT and GST from rules, for a seller established in one country.
+//
+//   - EU customers pay their own country's VAT, except businesses with a VAT number
+//     from another member state than the seller's, which reverse charge it.
+//   - UK customers pay 20% VAT, except VAT-registered businesses buying from abroad.
+//   - Australian customers pay 10% GST, except businesses with an ABN buying from abroad.
+//   - Customers elsewhere, or without a known country, are not taxed.
+type Local struct {
+	sellerCountry string
+}
+
+// NewLocal creates a rules-based engine for a seller established in a country
+func NewLocal(sellerCountry string) *Local {
+	return &Local{sellerCountry: sellerCountry}
+}
+
+func (l *Local) Calculate(ctx context.Context, req Request) (Result, error) {
+	if req.AmountCents <= 0 {
+		return Result{}, nil
+	}
+
+	country := req.Customer.Country()
+	var line Line
+	switch {
+	case IsEU(country):
+		id := req.Customer.taxID(EUVAT, country)
+		line = l.line("VAT", country, euVATRates[country], req.AmountCents, id,
+			"customer to account for VAT under Article 196 of Directive 2006/112/EC")
+	case country == "GB":
+		line = l.line("VAT", country, gbVATRate, req.AmountCents, req.Customer.taxID(GBVAT, country),
+			"customer to account for VAT to HMRC")
+	case country == "AU":
+		line = l.line("GST", country, auGSTRate, req.AmountCents, req.Customer.taxID(AUABN, country),
+			"customer to account for GST to the ATO")
+	default:
+		return Result{}, nil
+	}
+	return Result{Lines: []Line{line}}, nil
+}
+
+// line taxes an amount at a rate, or reverse charges it for a business registered in
+// a different country from the seller
+func (l *Local) line(name, country, rate string, amountCents int64, id *ID, reverseNote string) Line {
+	if id != nil && country != l.sellerCountry {
+		return Line{
+			Name:          name,
+			Country:       country,
+			RatePercent:   decimal.Zero,
+			ReverseCharge: true,
+			Description:   fmt.Sprintf("%s reverse charge (%s) - %s, %s", name, country, reverseNote, id),
+		}
+	}
+
+	percent := decimal.RequireFromString(rate)
+	return Line{
+		Name:        name,
+		Country:     country,
+		RatePercent: percent,
+		AmountCents: decimal.NewFromInt(amountCents).Mul(percent).Div(decimal.NewFromInt(100)).Round(0).IntPart(),
+		Description: fmt.Sprintf("%s %s%% (%s)", name, percent, country),
+	}
+}
@@ -0,0 +1,127 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const idColumns = `id, organization_id, type, value, country, created_at`
+
+// Store reads and writes billing addresses and tax IDs in Postgres. A nil Store, or
+// one without a database, knows nothing about any customer.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by organizations.billing_address and the
+// organization_tax_ids table
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+// Customer returns an organization's billing address and tax IDs
+func (s *Store) Customer(ctx context.Context, orgID uuid.UUID) (Customer, error) {
+	c := Customer{TaxIDs: []ID{}}
+	if !s.available() {
+		return c, nil
+	}
+	err := s.db.QueryRow(ctx, `SELECT billing_address FROM organizations WHERE id = $1`, orgID).Scan(&c.Address)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Customer{}, fmt.Errorf("failed to read billing address: %w", err)
+	}
+	c.TaxIDs, err = s.IDs(ctx, orgID)
+	return c, err
+}
+
+// SetAddress replaces an organization's billing address
+func (s *Store) SetAddress(ctx context.Context, orgID uuid.UUID, a Address) (Address, error) {
+	a = a.Normalize()
+	if err := a.Validate(); err != nil {
+		return Address{}, err
+	}
+	if !s.available() {
+		return Address{}, fmt.Errorf("tax store has no database")
+	}
+	tag, err := s.db.Exec(ctx, `UPDATE organizations SET billing_address = $2, updated_at = NOW() WHERE id = $1`, orgID, a)
+	if err != nil {
+		return Address{}, fmt.Errorf("failed to store billing address: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return Address{}, pgx.ErrNoRows
+	}
+	return a, nil
+}
+
+// IDs returns an organization's tax IDs in the order they were added
+func (s *Store) IDs(ctx context.Context, orgID uuid.UUID) ([]ID, error) {
+	ids := []ID{}
+	if !s.available() {
+		return ids, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT `+idColumns+` FROM organization_tax_ids WHERE organization_id = $1 ORDER BY created_at`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax IDs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id ID
+		if err := rows.Scan(&id.ID, &id.OrganizationID, &id.Type, &id.Value, &id.Country, &id.CreatedAt); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AddID validates and adds a tax ID to an organization
+func (s *Store) AddID(ctx context.Context, orgID uuid.UUID, t IDType, value string) (ID, error) {
+	id, err := ParseID(t, value)
+	if err != nil {
+		return ID{}, err
+	}
+	if !s.available() {
+		return ID{}, fmt.Errorf("tax store has no database")
+	}
+	id.ID = uuid.New()
+	id.OrganizationID = orgID
+	id.CreatedAt = time.Now()
+
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO organization_tax_ids (`+idColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+		id.ID, id.OrganizationID, id.Type, id.Value, id.Country, id.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ID{}, ErrTaxIDExists
+	}
+	if err != nil {
+		return ID{}, fmt.Errorf("failed to store tax ID: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteID removes a tax ID from an organization
+func (s *Store) DeleteID(ctx context.Context, orgID, id uuid.UUID) error {
+	if !s.available() {
+		return ErrNotFound
+	}
+	tag, err := s.db.Exec(ctx, `DELETE FROM organization_tax_ids WHERE id = $1 AND organization_id = $2`, id, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tax ID: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package tax calculates the taxes charged on invoices. An Engine decides which taxes
+// apply from the customer's billing address and tax IDs; the local engine applies the
+// VAT and GST rules of the EU, the UK and Australia, including reverse charge for
+// registered businesses.
+package tax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+var (
+	ErrInvalidTaxID   = errors.New("invalid tax ID")
+	ErrInvalidAddress = errors.New("invalid billing address")
+	ErrTaxIDExists    = errors.New("tax ID already added")
+	ErrNotFound       = errors.New("tax ID not found")
+	ErrUnknownEngine  = errors.New("unknown tax engine")
+)
+
+// IDType is the kind of a tax ID, named as Stripe names them
+type IDType string
+
+const (
+	EUVAT IDType = "eu_vat"
+	GBVAT IDType = "gb_vat"
+	AUABN IDType = "au_abn"
+)
+
+// ID is a tax ID registered to an organization
+type ID struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Type           IDType    `json:"type"`
+	Value          string    `json:"value"`
+	Country        string    `json:"country"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// String formats a tax ID for an invoice, e.g. "VAT DE123456789"
+func (id ID) String() string {
+	switch id.Type {
+	case AUABN:
+		return "ABN " + id.Value
+	default:
+		return "VAT " + id.Value
+	}
+}
+
+// Address is an organization's billing address. Country is an ISO 3166-1 alpha-2 code.
+type Address struct {
+	Line1      string `json:"line1,omitempty"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+// Normalize trims the fields and upper-cases the country
+func (a Address) Normalize() Address {
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.TrimSpace(a.State)
+	a.PostalCode = strings.TrimSpace(a.PostalCode)
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	return a
+}
+
+// Validate checks a normalized address
+func (a Address) Validate() error {
+	if len(a.Country) != 2 || strings.Trim(a.Country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("%w: country must be a two-letter ISO code", ErrInvalidAddress)
+	}
+	return nil
+}
+
+// Lines formats the address for an invoice
+func (a Address) Lines() []string {
+	var lines []string
+	for _, l := range []string{a.Line1, a.Line2, strings.TrimSpace(strings.Join([]string{a.PostalCode, a.City, a.State}, " ")), a.Country} {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// Customer is what a tax engine knows about the buyer
+type Customer struct {
+	Address Address `json:"address"`
+	TaxIDs  []ID    `json:"tax_ids"`
+}
+
+// Country is where the customer is for tax purposes: the billing address, or
+// the country of its first tax ID
+func (c Customer) Country() string {
+	if c.Address.Country != "" {
+		return c.Address.Country
+	}
+	if len(c.TaxIDs) > 0 {
+		return c.TaxIDs[0].Country
+	}
+	return ""
+}
+
+// taxID returns the customer's tax ID of a type registered in a country
+func (c Customer) taxID(t IDType, country string) *ID {
+	for i, id := range c.TaxIDs {
+		if id.Type == t && id.Country == country {
+			return &c.TaxIDs[i]
+		}
+	}
+	return nil
+}
+
+// Request asks for the taxes on an amount charged to a customer
+type Request struct {
+	Customer    Customer
+	Currency    money.Currency
+	AmountCents int64
+}
+
+// Line is one tax charged on an invoice. Reverse-charged lines are zero and tell the
+// customer to account for the tax themselves.
+type Line struct {
+	Name          string          `json:"name"`
+	Country       string          `json:"country"`
+	RatePercent   decimal.Decimal `json:"rate_percent"`
+	AmountCents   int64           `json:"amount_cents"`
+	ReverseCharge bool            `json:"reverse_charge,omitempty"`
+	Description   string          `json:"description"`
+}
+
+// Result is the taxes on a request
+type Result struct {
+	Lines []Line `json:"lines"`
+}
+
+// TotalCents sums the tax lines
+func (r Result) TotalCents() int64 {
+	var total int64
+	for _, l := range r.Lines {
+		total += l.AmountCents
+	}
+	return total
+}
+
+// Engine calculates taxes
+type Engine interface {
+	Calculate(ctx context.Context, req Request) (Result, error)
+}
+
+// None is an engine that charges no tax
+type None struct{}
+
+func (None) Calculate(ctx context.Context, req Request) (Result, error) {
+	return Result{}, nil
+}
+
+// NewFromEnv creates the engine named by TAX_ENGINE: local (the default), selling
+// from TAX_SELLER_COUNTRY (US when unset), or none.
+func NewFromEnv() (Engine, error) {
+	switch name := os.Getenv("TAX_ENGINE"); name {
+	case "", "local":
+		seller := strings.ToUpper(strings.TrimSpace(os.Getenv("TAX_SELLER_COUNTRY")))
+		if seller == "" {
+			seller = "US"
+		}
+		return NewLocal(seller), nil
+	case "none":
+		return None{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEngine, name)
+	}
+}
@@ -0,0 +1,172 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+)
+
+func TestParseID(t *testing.T) {
+	cases := []struct {
+		name    string
+		typ     IDType
+		value   string
+		want    string
+		country string
+	}{
+		{"german VAT", EUVAT, "DE 123 456 789", "DE123456789", "DE"},
+		{"dutch VAT", EUVAT, "nl123456789b01", "NL123456789B01", "NL"},
+		{"austrian VAT", EUVAT, "ATU12345678", "ATU12345678", "AT"},
+		{"greek VAT", EUVAT, "EL123456789", "EL123456789", "GR"},
+		{"irish VAT", EUVAT, "IE1234567WA", "IE1234567WA", "IE"},
+		{"french VAT", EUVAT, "FR-AB-123456789", "FRAB123456789", "FR"},
+		{"GB VAT", GBVAT, "GB 999 9999 73", "GB999999973", "GB"},
+		{"GB VAT without prefix", GBVAT, "999999973", "GB999999973", "GB"},
+		{"GB branch VAT", GBVAT, "GB999999973001", "GB999999973001", "GB"},
+		{"GB government department", GBVAT, "GBGD001", "GBGD001", "GB"},
+		{"ABN", AUABN, "51 824 753 556", "51824753556", "AU"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			id, err := ParseID(c.typ, c.value)
+			if err != nil {
+				t.Fatalf("Expected %q to be valid, got %v", c.value, err)
+			}
+			if id.Value != c.want || id.Country != c.country {
+				t.Errorf("Expected %s in %s, got %s in %s", c.want, c.country, id.Value, id.Country)
+			}
+		})
+	}
+}
+
+func TestParseIDInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		typ   IDType
+		value string
+	}{
+		{"german VAT too short", EUVAT, "DE12345678"},
+		{"dutch VAT without B", EUVAT, "NL12345678901"},
+		{"greek VAT with GR prefix", EUVAT, "GR123456789"},
+		{"GB VAT as EU VAT", EUVAT, "GB999999973"},
+		{"US EIN", EUVAT, "12-3456789"},
+		{"empty", EUVAT, ""},
+		{"GB VAT bad checksum", GBVAT, "GB999999974"},
+		{"GB VAT too short", GBVAT, "GB12345678"},
+		{"ABN bad checksum", AUABN, "51824753557"},
+		{"ABN too short", AUABN, "5182475355"},
+		{"unknown type", IDType("us_ein"), "123456789"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseID(c.typ, c.value); !errors.Is(err, ErrInvalidTaxID) {
+				t.Errorf("Expected ErrInvalidTaxID for %q, got %v", c.value, err)
+			}
+		})
+	}
+}
+
+func customer(country string, ids ...string) Customer {
+	c := Customer{Address: Address{Country: country}}
+	for _, v := range ids {
+		typ := EUVAT
+		switch {
+		case strings.HasPrefix(v, "GB"):
+			typ = GBVAT
+		case v[0] >= '0' && v[0] <= '9':
+			typ = AUABN
+		}
+		id, err := ParseID(typ, v)
+		if err != nil {
+			panic(err)
+		}
+		c.TaxIDs = append(c.TaxIDs, id)
+	}
+	return c
+}
+
+func TestLocalCalculate(t *testing.T) {
+	cases := []struct {
+		name          string
+		seller        string
+		customer      Customer
+		amount        int64
+		expectedTax   int64
+		reverseCharge bool
+		noTax         bool
+	}{
+		{"EU consumer from US seller", "US", customer("DE"), 10000, 1900, false, false},
+		{"EU business from US seller", "US", customer("DE", "DE123456789"), 10000, 0, true, false},
+		{"EU business in another member state", "IE", customer("FR", "FRAB123456789"), 10000, 0, true, false},
+		{"EU business in seller's country", "DE", customer("DE", "DE123456789"), 10000, 1900, false, false},
+		{"VAT ID of another country", "US", customer("FR", "DE123456789"), 10000, 2000, false, false},
+		{"fractional rate rounds half up", "US", customer("FI"), 1002, 256, false, false},
+		{"UK consumer", "US", customer("GB"), 10000, 2000, false, false},
+		{"UK business from abroad", "US", customer("GB", "GB999999973"), 10000, 0, true, false},
+		{"UK business from UK seller", "GB", customer("GB", "GB999999973"), 10000, 2000, false, false},
+		{"Australian consumer", "US", customer("AU"), 10000, 1000, false, false},
+		{"Australian business", "US", customer("AU", "51824753556"), 10000, 0, true, false},
+		{"US customer", "US", customer("US"), 10000, 0, false, true},
+		{"no address, tax ID country", "US", customer("", "NL123456789B01"), 10000, 0, true, false},
+		{"unknown country", "US", customer(""), 10000, 0, false, true},
+		{"nothing charged", "US", customer("DE"), 0, 0, false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := NewLocal(c.seller).Calculate(context.Background(),
+				Request{Customer: c.customer, Currency: money.EUR, AmountCents: c.amount})
+			if err != nil {
+				t.Fatalf("Expected taxes, got %v", err)
+			}
+			if c.noTax {
+				if len(result.Lines) != 0 {
+					t.Errorf("Expected no tax lines, got %+v", result.Lines)
+				}
+				return
+			}
+			if len(result.Lines) != 1 {
+				t.Fatalf("Expected one tax line, got %+v", result.Lines)
+			}
+			line := result.Lines[0]
+			if result.TotalCents() != c.expectedTax || line.ReverseCharge != c.reverseCharge {
+				t.Errorf("Expected tax %d (reverse charge %v), got %d (%v)", c.expectedTax, c.reverseCharge, result.TotalCents(), line.ReverseCharge)
+			}
+			if c.reverseCharge && !strings.Contains(line.Description, "reverse charge") {
+				t.Errorf("Expected a reverse charge note, got %q", line.Description)
+			}
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	a := Address{Line1: " 1 Main St ", City: "Berlin", PostalCode: "10115", Country: "de"}.Normalize()
+	if err := a.Validate(); err != nil {
+		t.Fatalf("Expected a valid address, got %v", err)
+	}
+	if lines := a.Lines(); len(lines) != 3 || lines[0] != "1 Main St" || lines[1] != "10115 Berlin" || lines[2] != "DE" {
+		t.Errorf("Expected 3 address lines, got %q", lines)
+	}
+	if err := (Address{Country: "Germany"}).Validate(); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("TAX_ENGINE", "")
+	t.Setenv("TAX_SELLER_COUNTRY", "de")
+	engine, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("Expected the local engine, got %v", err)
+	}
+	if l, ok := engine.(*Local); !ok || l.sellerCountry != "DE" {
+		t.Errorf("Expected a local engine selling from DE, got %+v", engine)
+	}
+
+	t.Setenv("TAX_ENGINE", "avalara")
+	if _, err := NewFromEnv(); !errors.Is(err, ErrUnknownEngine) {
+		t.Errorf("Expected ErrUnknownEngine, got %v", err)
+	}
+}
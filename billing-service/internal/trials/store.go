@@ -0,0 +1,111 @@
+package trials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+// Trial is a subscription in its trial
+type Trial struct {
+	OrganizationID       uuid.UUID `json:"organization_id"`
+	OrganizationName     string    `json:"organization_name"`
+	Plan                 string    `json:"plan"`
+	StripeSubscriptionID string    `json:"stripe_subscription_id,omitempty"`
+	EndsAt               time.Time `json:"ends_at"`
+}
+
+// Store reads subscription trials from Postgres. A nil Store, or one without a
+// database, has no trials.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the subscriptions table
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+// Get returns an organization's trial state. Organizations without a subscription
+// are on the free plan and have never trialed.
+func (s *Store) Get(ctx context.Context, orgID uuid.UUID) (State, error) {
+	state := State{Plan: plans.FreePlanID, Status: StatusActive}
+	if !s.available() {
+		return state, nil
+	}
+	err := s.db.QueryRow(ctx,
+		`SELECT plan, status, trial_ends_at FROM subscriptions WHERE organization_id = $1`, orgID).
+		Scan(&state.Plan, &state.Status, &state.EndsAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return State{}, fmt.Errorf("failed to get trial: %w", err)
+	}
+	return state, nil
+}
+
+func (s *Store) query(ctx context.Context, where string, args ...any) ([]Trial, error) {
+	if !s.available() {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT s.organization_id, o.name, s.plan, COALESCE(s.stripe_subscription_id, ''), s.trial_ends_at
+		 FROM subscriptions s JOIN organizations o ON o.id = s.organization_id
+		 WHERE s.status = 'trialing' AND `+where+` ORDER BY s.trial_ends_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trials: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Trial
+	for rows.Next() {
+		var t Trial
+		if err := rows.Scan(&t.OrganizationID, &t.OrganizationName, &t.Plan, &t.StripeSubscriptionID, &t.EndsAt); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// DueForReminder returns trials ending within lead of now that have not been reminded
+func (s *Store) DueForReminder(ctx context.Context, now time.Time, lead time.Duration) ([]Trial, error) {
+	return s.query(ctx, `s.trial_reminded_at IS NULL AND s.trial_ends_at > $1 AND s.trial_ends_at <= $2`,
+		now, now.Add(lead))
+}
+
+// MarkReminded records that a trial's reminder was sent
+func (s *Store) MarkReminded(ctx context.Context, orgID uuid.UUID, at time.Time) error {
+	if !s.available() {
+		return fmt.Errorf("trial store has no database")
+	}
+	_, err := s.db.Exec(ctx, `UPDATE subscriptions SET trial_reminded_at = $2 WHERE organization_id = $1`, orgID, at)
+	return err
+}
+
+// Ended returns trials that ended by now
+func (s *Store) Ended(ctx context.Context, now time.Time) ([]Trial, error) {
+	return s.query(ctx, `s.trial_ends_at <= $1`, now)
+}
+
+// Convert starts paid billing for a subscription whose trial ended
+func (s *Store) Convert(ctx context.Context, orgID uuid.UUID) error {
+	if !s.available() {
+		return fmt.Errorf("trial store has no database")
+	}
+	_, err := s.db.Exec(ctx,
+		`UPDATE subscriptions SET status = 'active', updated_at = NOW() WHERE organization_id = $1 AND status = 'trialing'`,
+		orgID)
+	return err
+}
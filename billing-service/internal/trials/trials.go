@@ -0,0 +1,113 @@
+// Package trials decides when organizations upgrading from the free plan try a
+// paid plan for free, and what happens when the trial ends.
+package trials
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+// Subscription statuses used by trials
+const (
+	StatusTrialing = "trialing"
+	StatusActive   = "active"
+)
+
+// DefaultReminderLead is how long before a trial ends its reminder email goes out
+const DefaultReminderLead = 3 * 24 * time.Hour
+
+// State is the trial state of a subscription
+type State struct {
+	Plan   string
+	Status string
+
+	// When the organization's trial ends or ended. Nil if it never trialed.
+	EndsAt *time.Time
+}
+
+// Trialing reports whether the subscription is in a trial that has not ended at t
+func (s State) Trialing(t time.Time) bool {
+	return s.Status == StatusTrialing && s.EndsAt != nil && t.Before(*s.EndsAt)
+}
+
+// Change returns the trial state after moving a subscription to a plan at now.
+// Organizations get one trial, when they first upgrade from the free plan. A
+// trial carries over to another plan with a trial; any other change, or
+// skipTrial, ends it and starts paid billing immediately.
+func Change(current State, to plans.Plan, skipTrial bool, now time.Time) State {
+	next := State{Plan: to.ID, Status: StatusActive, EndsAt: current.EndsAt}
+	if current.Trialing(now) {
+		// Leaving a trial early ends it now
+		ended := now
+		next.EndsAt = &ended
+	}
+	if to.TrialDays == 0 || skipTrial {
+		return next
+	}
+
+	switch {
+	case current.Trialing(now):
+		next.Status, next.EndsAt = StatusTrialing, current.EndsAt
+	case Eligible(current):
+		ends := now.AddDate(0, 0, to.TrialDays)
+		next.Status, next.EndsAt = StatusTrialing, &ends
+	}
+	return next
+}
+
+// Eligible reports whether an organization can still start a trial
+func Eligible(current State) bool {
+	return current.EndsAt == nil && current.Plan == plans.FreePlanID
+}
+
+// PaidFrom returns when paid usage starts in a billing period: the period start,
+// or the end of a trial that overlaps it
+func PaidFrom(periodStart time.Time, trialEndsAt *time.Time) time.Time {
+	if trialEndsAt != nil && trialEndsAt.After(periodStart) {
+		return *trialEndsAt
+	}
+	return periodStart
+}
+
+// PaidShare returns the fraction of a billing period after a trial, between 0 and 1
+func PaidShare(periodStart, periodEnd time.Time, trialEndsAt *time.Time) decimal.Decimal {
+	from := PaidFrom(periodStart, trialEndsAt)
+	if !from.Before(periodEnd) {
+		return decimal.Zero
+	}
+	total := periodEnd.Sub(periodStart)
+	if total <= 0 {
+		return decimal.Zero
+	}
+	return decimal.NewFromInt(int64(periodEnd.Sub(from))).Div(decimal.NewFromInt(int64(total)))
+}
+
+// Outcome is what happens to a subscription whose trial has ended
+type Outcome int
+
+const (
+	// Pending waits for the payment provider to settle the trial
+	Pending Outcome = iota
+	// Convert keeps the organization on its plan and starts paid billing
+	Convert
+	// Downgrade moves the organization back to the free plan
+	Downgrade
+)
+
+// OutcomeFor maps the payment provider's subscription status after the trial ended
+// to an outcome. Subscriptions without a provider subscription have no payment
+// method and are downgraded. Providers cancel trials that end without a payment
+// method; a subscription still trialing has not been settled yet.
+func OutcomeFor(providerStatus string) Outcome {
+	switch providerStatus {
+	case "":
+		return Downgrade
+	case StatusTrialing:
+		return Pending
+	case StatusActive, "past_due":
+		return Convert
+	}
+	return Downgrade
+}
@@ -0,0 +1,100 @@
+package trials
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+var now = time.Date(2026, 9, 10, 12, 0, 0, 0, time.UTC)
+
+func at(t time.Time) *time.Time {
+	return &t
+}
+
+func TestChange(t *testing.T) {
+	essential := plans.Plan{ID: "essential", TrialDays: 14}
+	business := plans.Plan{ID: "business", TrialDays: 14}
+	enterprise := plans.Plan{ID: "enterprise"}
+	free := plans.Plan{ID: plans.FreePlanID}
+	trialEnd := now.AddDate(0, 0, 5)
+	pastTrial := now.AddDate(0, -2, 0)
+
+	tests := []struct {
+		name           string
+		current        State
+		to             plans.Plan
+		skipTrial      bool
+		expectedStatus string
+		expectedEnd    *time.Time
+	}{
+		{"first upgrade from free", State{Plan: "free", Status: StatusActive}, essential, false, StatusTrialing, at(now.AddDate(0, 0, 14))},
+		{"trial skipped", State{Plan: "free", Status: StatusActive}, essential, true, StatusActive, nil},
+		{"plan without trial", State{Plan: "free", Status: StatusActive}, enterprise, false, StatusActive, nil},
+		{"already trialed", State{Plan: "free", Status: StatusActive, EndsAt: &pastTrial}, essential, false, StatusActive, &pastTrial},
+		{"paid plan change", State{Plan: "essential", Status: StatusActive}, business, false, StatusActive, nil},
+		{"trial carries over", State{Plan: "essential", Status: StatusTrialing, EndsAt: &trialEnd}, business, false, StatusTrialing, &trialEnd},
+		{"trial ended early", State{Plan: "essential", Status: StatusTrialing, EndsAt: &trialEnd}, business, true, StatusActive, at(now)},
+		{"trial abandoned", State{Plan: "essential", Status: StatusTrialing, EndsAt: &trialEnd}, free, false, StatusActive, at(now)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := Change(tt.current, tt.to, tt.skipTrial, now)
+			if next.Plan != tt.to.ID || next.Status != tt.expectedStatus {
+				t.Errorf("Expected %s on %s, got %s on %s", tt.expectedStatus, tt.to.ID, next.Status, next.Plan)
+			}
+			if (next.EndsAt == nil) != (tt.expectedEnd == nil) || (next.EndsAt != nil && !next.EndsAt.Equal(*tt.expectedEnd)) {
+				t.Errorf("Expected trial end %v, got %v", tt.expectedEnd, next.EndsAt)
+			}
+		})
+	}
+}
+
+func TestPaidShare(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name     string
+		trialEnd *time.Time
+		expected decimal.Decimal
+		paidFrom time.Time
+	}{
+		{"no trial", nil, decimal.NewFromInt(1), start},
+		{"trial ended before the period", at(start.AddDate(0, 0, -3)), decimal.NewFromInt(1), start},
+		{"trial ends mid-period", at(start.AddDate(0, 0, 12)), decimal.NewFromInt(18).Div(decimal.NewFromInt(30)), start.AddDate(0, 0, 12)},
+		{"trial covers the period", at(end.AddDate(0, 0, 2)), decimal.Zero, end.AddDate(0, 0, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PaidShare(start, end, tt.trialEnd); !got.Equal(tt.expected) {
+				t.Errorf("Expected share %s, got %s", tt.expected, got)
+			}
+			if got := PaidFrom(start, tt.trialEnd); !got.Equal(tt.paidFrom) {
+				t.Errorf("Expected paid usage from %s, got %s", tt.paidFrom, got)
+			}
+		})
+	}
+}
+
+func TestOutcomeFor(t *testing.T) {
+	tests := map[string]Outcome{
+		"":                   Downgrade,
+		"trialing":           Pending,
+		"active":             Convert,
+		"past_due":           Convert,
+		"canceled":           Downgrade,
+		"incomplete_expired": Downgrade,
+		"unpaid":             Downgrade,
+	}
+
+	for status, expected := range tests {
+		if got := OutcomeFor(status); got != expected {
+			t.Errorf("Expected outcome %d for %q, got %d", expected, status, got)
+		}
+	}
+}
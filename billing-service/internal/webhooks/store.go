@@ -0,0 +1,112 @@
+// Package webhooks records the payment provider webhook events already processed.
+// Providers deliver an event at least once and retry it until it is acknowledged, so a
+// redelivered event is skipped rather than handled twice.
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+)
+
+// Store records processed events in Postgres. A nil Store, or one without a database,
+// remembers no events, so every delivery is processed.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a store backed by the webhook_events table
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) available() bool {
+	return s != nil && s.db != nil
+}
+
+// claimLease is how long a claim holds an event. A delivery after the lease expires
+// takes the event over, so one whose handler died without releasing it is not lost.
+const claimLease = 10 * time.Minute
+
+// ErrInProgress is returned when another delivery of the event is being handled
+var ErrInProgress = errors.New("webhook event is being handled by another delivery")
+
+// Claim is a provider event claimed for handling. The claim is recorded as processing
+// until Commit records the event as processed, or Release gives it up for a redelivery
+// to handle. A Claim without a store claims nothing.
+type Claim struct {
+	store     *Store
+	provider  string
+	eventID   string
+	claimedAt time.Time
+}
+
+// Commit records the claimed event as processed
+func (c *Claim) Commit(ctx context.Context) error {
+	if c.store == nil {
+		return nil
+	}
+	_, err := c.store.db.Exec(ctx,
+		`UPDATE webhook_events SET status = 'processed', processed_at = NOW()
+		 WHERE provider = $1 AND event_id = $2 AND claimed_at = $3`,
+		c.provider, c.eventID, c.claimedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook event %s: %w", c.eventID, err)
+	}
+	return nil
+}
+
+// Release gives up the claim without recording the event. It does nothing after Commit.
+func (c *Claim) Release(ctx context.Context) {
+	if c.store == nil {
+		return
+	}
+	c.store.db.Exec(ctx,
+		`DELETE FROM webhook_events
+		 WHERE provider = $1 AND event_id = $2 AND claimed_at = $3 AND status = 'processing'`,
+		c.provider, c.eventID, c.claimedAt)
+}
+
+// Claim claims a provider's event for handling, and reports false if it was already
+// processed. The claim is committed at once, so no connection is held while the event
+// is handled; a delivery of an event claimed by another in progress returns
+// ErrInProgress until that is committed, released or its lease expires. Events without
+// an ID cannot be told apart and are always claimed.
+func (s *Store) Claim(ctx context.Context, provider string, e payments.Event) (*Claim, bool, error) {
+	if !s.available() || e.ID == "" {
+		return &Claim{}, true, nil
+	}
+	var claimedAt time.Time
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO webhook_events (provider, event_id, type, status, claimed_at)
+		 VALUES ($1, $2, $3, 'processing', NOW())
+		 ON CONFLICT (provider, event_id) DO UPDATE SET claimed_at = NOW()
+		 WHERE webhook_events.status = 'processing' AND webhook_events.claimed_at < NOW() - $4 * INTERVAL '1 second'
+		 RETURNING claimed_at`,
+		provider, e.ID, e.Type, int64(claimLease.Seconds())).Scan(&claimedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var status string
+		err = s.db.QueryRow(ctx,
+			`SELECT status FROM webhook_events WHERE provider = $1 AND event_id = $2`,
+			provider, e.ID).Scan(&status)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			// Released between the two statements, so the redelivery can claim it
+			return nil, false, ErrInProgress
+		case err != nil:
+			return nil, false, fmt.Errorf("failed to claim webhook event %s: %w", e.ID, err)
+		case status == "processing":
+			return nil, false, ErrInProgress
+		}
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim webhook event %s: %w", e.ID, err)
+	}
+	return &Claim{store: s, provider: provider, eventID: e.ID, claimedAt: claimedAt}, true, nil
+}
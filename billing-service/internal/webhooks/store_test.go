@@ -0,0 +1,22 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+)
+
+func TestStoreWithoutDatabase(t *testing.T) {
+	var s *Store
+	e := payments.Event{ID: "evt_1", Type: payments.EventInvoicePaid}
+	for i := 0; i < 2; i++ {
+		claim, claimed, err := s.Claim(context.Background(), "stripe", e)
+		if err != nil || !claimed {
+			t.Fatalf("Expected every delivery to be claimed, got %v, %v", claimed, err)
+		}
+		if err := claim.Commit(context.Background()); err != nil {
+			t.Errorf("Expected committing without a database to do nothing, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+)
+
+// invoiceIssuerFromEnv reads the seller named on rendered invoices from
+// INVOICE_ISSUER_NAME and INVOICE_ISSUER_ADDRESS, whose lines are separated by ";"
+func invoiceIssuerFromEnv() invoices.Party {
+	issuer := invoices.Party{Name: os.Getenv("INVOICE_ISSUER_NAME")}
+	if issuer.Name == "" {
+		issuer.Name = "Temporal Cloud"
+	}
+	for _, line := range strings.Split(os.Getenv("INVOICE_ISSUER_ADDRESS"), ";") {
+		if line = strings.TrimSpace(line); line != "" {
+			issuer.Address = append(issuer.Address, line)
+		}
+	}
+	return issuer
+}
+
+// ListInvoices lists invoices for an organization
+func (s *BillingService) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, err := uuid.Parse(vars["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.invoices.List(r.Context(), orgID, 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []invoices.Invoice{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// getInvoice loads the invoice named in the request path, writing the error response
+// if it cannot
+func (s *BillingService) getInvoice(w http.ResponseWriter, r *http.Request) (invoices.Invoice, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return invoices.Invoice{}, false
+	}
+
+	inv, err := s.invoices.Get(r.Context(), id)
+	if errors.Is(err, invoices.ErrNotFound) {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return invoices.Invoice{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return invoices.Invoice{}, false
+	}
+	return inv, true
+}
+
+// GetInvoice retrieves a specific invoice with its line items
+func (s *BillingService) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	inv, ok := s.getInvoice(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
+
+// DownloadInvoice renders an invoice as a PDF, or as HTML with ?format=html
+func (s *BillingService) DownloadInvoice(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "pdf"
+	}
+	render, contentType := invoices.RenderPDF, "application/pdf"
+	switch format {
+	case "pdf":
+	case "html":
+		render, contentType = invoices.RenderHTML, "text/html; charset=utf-8"
+	default:
+		http.Error(w, "Format must be pdf or html", http.StatusBadRequest)
+		return
+	}
+
+	inv, ok := s.getInvoice(w, r)
+	if !ok {
+		return
+	}
+
+	doc := invoices.Document{Invoice: inv, Issuer: s.issuer}
+	err := s.db.QueryRow(r.Context(), `SELECT name FROM organizations WHERE id = $1`, inv.OrganizationID).
+		Scan(&doc.Customer.Name)
+	if err != nil {
+		log.Printf("Failed to get organization %s for invoice %s: %v", inv.OrganizationID, inv.Number, err)
+	}
+
+	// Invoices name the customer's address and tax IDs, which reverse charge relies on
+	customer, err := s.taxes.Customer(r.Context(), inv.OrganizationID)
+	if err != nil {
+		log.Printf("Failed to get tax details of organization %s: %v", inv.OrganizationID, err)
+	}
+	doc.Customer.Address = customer.Address.Lines()
+	for _, id := range customer.TaxIDs {
+		doc.Customer.Address = append(doc.Customer.Address, id.String())
+	}
+
+	// Render fully before writing so a failure can still be reported
+	var buf bytes.Buffer
+	if err := render(&buf, doc); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render invoice: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", inv.Number+"."+format))
+	w.Write(buf.Bytes())
+}
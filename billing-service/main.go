@@ -14,13 +14,16 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/cors"
-	"github.com/stripe/stripe-go/v76"
 	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/bootstrap"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
 )
 
 func main() {
-	// Initialize Stripe
-	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	// Payment provider: Stripe, or an in-memory fake for local development
+	provider, err := payments.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure payments: %v", err)
+	}
 
 	// Connect to database
 	dbURL := os.Getenv("DATABASE_URL")
@@ -39,7 +42,38 @@ func main() {
 	}
 
 	// Create service
-	svc := NewBillingService(pool)
+	svc := NewBillingService(pool, provider)
+
+	// The fake provider delivers its webhooks in process
+	if fake, ok := provider.(*payments.Fake); ok {
+		fake.SetWebhookHandler(http.HandlerFunc(svc.HandlePaymentWebhook))
+	}
+
+	// Mirror the plan catalog to providers that keep their own
+	go svc.syncProviderPlansAsync()
+
+	// Probe registered Temporal clusters in the background
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	healthInterval, err := time.ParseDuration(os.Getenv("CLUSTER_HEALTH_CHECK_INTERVAL"))
+	if err != nil || healthInterval <= 0 {
+		healthInterval = time.Minute
+	}
+	go svc.RunClusterHealthChecks(bgCtx, healthInterval)
+
+	// Send trial reminders and settle ended trials in the background
+	trialInterval, err := time.ParseDuration(os.Getenv("TRIAL_CHECK_INTERVAL"))
+	if err != nil || trialInterval <= 0 {
+		trialInterval = time.Hour
+	}
+	go svc.RunTrialTransitions(bgCtx, trialInterval)
+
+	// Apply plan changes scheduled for the end of a billing period
+	changeInterval, err := time.ParseDuration(os.Getenv("PLAN_CHANGE_CHECK_INTERVAL"))
+	if err != nil || changeInterval <= 0 {
+		changeInterval = 15 * time.Minute
+	}
+	go svc.RunScheduledPlanChanges(bgCtx, changeInterval)
 
 	// Create auth middleware
 	authMiddleware := NewAuthMiddleware(pool)
@@ -60,8 +94,12 @@ func main() {
 		w.Write([]byte("billing_requests_total 0\n"))
 	}).Methods("GET")
 
-	// Stripe webhook (no auth - uses Stripe signature verification)
-	r.HandleFunc("/webhooks/stripe", svc.HandleStripeWebhook).Methods("POST")
+	// Webhooks of the configured payment provider only, e.g. /webhooks/stripe (no auth -
+	// uses the provider's signature verification)
+	r.HandleFunc("/webhooks/"+provider.Name(), svc.HandlePaymentWebhook).Methods("POST")
+
+	// Operator API, mounted ahead of the tenant API so organization keys never reach it
+	registerAdminRoutes(r, svc, NewAdminAuthFromEnv())
 
 	// API routes (with optional auth based on environment)
 	api := r.PathPrefix("/api/v1").Subrouter()
@@ -79,6 +117,8 @@ func main() {
 	// Subscription endpoints
 	api.HandleFunc("/organizations/{org_id}/subscription", svc.GetSubscription).Methods("GET")
 	api.HandleFunc("/organizations/{org_id}/subscription", svc.UpdateSubscription).Methods("PUT")
+	api.HandleFunc("/organizations/{org_id}/subscription/preview", svc.PreviewSubscriptionChange).Methods("POST")
+	api.HandleFunc("/organizations/{org_id}/subscription/scheduled-change", svc.CancelScheduledChange).Methods("DELETE")
 
 	// Usage endpoints
 	api.HandleFunc("/organizations/{org_id}/usage", svc.GetUsage).Methods("GET")
@@ -87,10 +127,39 @@ func main() {
 	// Invoice endpoints
 	api.HandleFunc("/organizations/{org_id}/invoices", svc.ListInvoices).Methods("GET")
 	api.HandleFunc("/invoices/{id}", svc.GetInvoice).Methods("GET")
+	api.HandleFunc("/invoices/{id}/download", svc.DownloadInvoice).Methods("GET")
+
+	// Credit endpoints
+	api.HandleFunc("/organizations/{org_id}/credits", svc.ListCredits).Methods("GET")
+	api.HandleFunc("/organizations/{org_id}/credits/ledger", svc.ListCreditDebits).Methods("GET")
+
+	// Billing address and tax ID endpoints
+	api.HandleFunc("/organizations/{org_id}/tax", svc.GetTaxDetails).Methods("GET")
+	api.HandleFunc("/organizations/{org_id}/billing-address", svc.UpdateBillingAddress).Methods("PUT")
+	api.HandleFunc("/organizations/{org_id}/tax-ids", svc.AddTaxID).Methods("POST")
+	api.HandleFunc("/organizations/{org_id}/tax-ids/{id}", svc.DeleteTaxID).Methods("DELETE")
+
+	// Contract endpoints
+	api.HandleFunc("/organizations/{org_id}/contracts", svc.ListContracts).Methods("GET")
+
+	// Coupon endpoints
+	api.HandleFunc("/organizations/{org_id}/coupons", svc.ListRedemptions).Methods("GET")
+	api.HandleFunc("/organizations/{org_id}/coupons", svc.RedeemCoupon).Methods("POST")
 
 	// Namespace endpoints
 	api.HandleFunc("/organizations/{org_id}/namespaces", svc.ListNamespaces).Methods("GET")
 	api.HandleFunc("/organizations/{org_id}/namespaces", svc.CreateNamespace).Methods("POST")
+	api.HandleFunc("/organizations/{org_id}/namespaces/import", svc.ImportNamespace).Methods("POST")
+	api.HandleFunc("/namespaces/{id}/failover", svc.FailoverNamespace).Methods("POST")
+	api.HandleFunc("/namespaces/{id}/failovers", svc.ListNamespaceFailovers).Methods("GET")
+	api.HandleFunc("/namespaces/{id}/archival", svc.GetNamespaceArchival).Methods("GET")
+	api.HandleFunc("/namespaces/{id}/archival", svc.UpdateNamespaceArchival).Methods("PUT")
+	api.HandleFunc("/namespaces/{id}/access", svc.GetNamespaceAccess).Methods("GET")
+	api.HandleFunc("/namespaces/{id}/access", svc.UpdateNamespaceAccess).Methods("PUT")
+	api.HandleFunc("/namespaces/{id}/export", svc.ExportNamespace).Methods("GET")
+
+	// Cluster registry, registered and updated through the operator API
+	api.HandleFunc("/clusters", svc.ListClusters).Methods("GET")
 
 	// API Key management endpoints
 	api.HandleFunc("/organizations/{org_id}/api-keys", svc.ListAPIKeys).Methods("GET")
@@ -124,7 +193,7 @@ func main() {
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-API-Key"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-API-Key", "X-Admin-Token"},
 		AllowCredentials: true,
 	})
 
@@ -160,3 +229,31 @@ func main() {
 	srv.Shutdown(ctx)
 	log.Println("Server shutdown complete")
 }
+
+// registerAdminRoutes mounts the operator API under /api/v1/admin, behind the operator
+// token rather than organization API keys
+func registerAdminRoutes(r *mux.Router, svc *BillingService, auth *AdminAuth) {
+	admin := r.PathPrefix("/api/v1/admin").Subrouter()
+	admin.Use(auth.Authenticate)
+
+	// Plan catalog administration
+	admin.HandleFunc("/plans", svc.ListPlanVersions).Methods("GET")
+	admin.HandleFunc("/plans", svc.CreatePlan).Methods("POST")
+	admin.HandleFunc("/plans/{id}", svc.GetPlanVersions).Methods("GET")
+	admin.HandleFunc("/plans/{id}/versions", svc.AddPlanVersion).Methods("POST")
+	admin.HandleFunc("/plans/{id}/versions/{version}", svc.DeletePlanVersion).Methods("DELETE")
+
+	// Credit grants (promotional, SLA and prepaid)
+	admin.HandleFunc("/organizations/{org_id}/credits", svc.GrantCredit).Methods("POST")
+
+	// Committed-spend contracts
+	admin.HandleFunc("/organizations/{org_id}/contracts", svc.CreateContract).Methods("POST")
+
+	// Coupons and promotion codes
+	admin.HandleFunc("/coupons", svc.ListCoupons).Methods("GET")
+	admin.HandleFunc("/coupons", svc.CreateCoupon).Methods("POST")
+
+	// Temporal cluster registry, which health checks dial
+	admin.HandleFunc("/clusters", svc.CreateCluster).Methods("POST")
+	admin.HandleFunc("/clusters/{id}", svc.UpdateCluster).Methods("PUT")
+}
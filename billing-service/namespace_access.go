@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+// Permissions an identity can be granted on a namespace
+var namespacePermissions = map[string]bool{
+	"read":  true,
+	"write": true,
+	"admin": true,
+}
+
+// NamespaceGrant gives an identity of the owning organization access to a namespace
+type NamespaceGrant struct {
+	IdentityID *uuid.UUID `json:"identity_id,omitempty"`
+	Email      string     `json:"email"`
+	Permission string     `json:"permission"` // "read", "write", "admin"
+}
+
+// CertificateFilter restricts which mTLS client certificates may connect to a namespace.
+// A certificate matches when every non-empty field matches.
+type CertificateFilter struct {
+	CommonName             string `json:"common_name,omitempty"`
+	Organization           string `json:"organization,omitempty"`
+	OrganizationalUnit     string `json:"organizational_unit,omitempty"`
+	SubjectAlternativeName string `json:"subject_alternative_name,omitempty"`
+}
+
+// NamespaceAccess is the access configuration of a namespace
+type NamespaceAccess struct {
+	Grants             []NamespaceGrant    `json:"grants"`
+	CertificateFilters []CertificateFilter `json:"certificate_filters"`
+}
+
+func validateNamespaceAccess(access NamespaceAccess) error {
+	seen := make(map[string]bool)
+	for _, g := range access.Grants {
+		if g.Email == "" {
+			return errors.New("grant email is required")
+		}
+		if !namespacePermissions[g.Permission] {
+			return fmt.Errorf("grant permission for %s must be read, write or admin", g.Email)
+		}
+		if seen[g.Email] {
+			return fmt.Errorf("duplicate grant for %s", g.Email)
+		}
+		seen[g.Email] = true
+	}
+	for _, f := range access.CertificateFilters {
+		if f == (CertificateFilter{}) {
+			return errors.New("certificate filter must set at least one field")
+		}
+	}
+	return nil
+}
+
+// namespaceGrants returns the grants of a namespace, ordered by email
+func (s *BillingService) namespaceGrants(ctx context.Context, nsID uuid.UUID) ([]NamespaceGrant, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT g.identity_id, i.email, g.permission
+		 FROM namespace_grants g JOIN identities i ON i.id = g.identity_id
+		 WHERE g.namespace_id = $1 ORDER BY i.email`, nsID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := make([]NamespaceGrant, 0)
+	for rows.Next() {
+		var g NamespaceGrant
+		if err := rows.Scan(&g.IdentityID, &g.Email, &g.Permission); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// resolveGrantIdentities matches grants to identities of the organization by email.
+// Emails without an identity are returned separately.
+func (s *BillingService) resolveGrantIdentities(ctx context.Context, orgID uuid.UUID, grants []NamespaceGrant) ([]NamespaceGrant, []string, error) {
+	resolved := make([]NamespaceGrant, 0, len(grants))
+	var unmatched []string
+	for _, g := range grants {
+		var identityID uuid.UUID
+		err := s.db.QueryRow(ctx,
+			`SELECT id FROM identities WHERE organization_id = $1 AND email = $2`, orgID, g.Email).Scan(&identityID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			unmatched = append(unmatched, g.Email)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		g.IdentityID = &identityID
+		resolved = append(resolved, g)
+	}
+	return resolved, unmatched, nil
+}
+
+// setNamespaceAccess replaces the grants and certificate filters of a namespace.
+// Grants must already be resolved to identities.
+func (s *BillingService) setNamespaceAccess(ctx context.Context, nsID uuid.UUID, access NamespaceAccess) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM namespace_grants WHERE namespace_id = $1`, nsID); err != nil {
+		return err
+	}
+	if err := insertNamespaceGrants(ctx, tx, nsID, access.Grants); err != nil {
+		return err
+	}
+
+	filters := access.CertificateFilters
+	if filters == nil {
+		filters = []CertificateFilter{}
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE namespaces SET certificate_filters = $1, updated_at = NOW() WHERE id = $2`, filters, nsID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertNamespaceGrants adds resolved grants to a namespace within a transaction
+func insertNamespaceGrants(ctx context.Context, tx pgx.Tx, nsID uuid.UUID, grants []NamespaceGrant) error {
+	for _, g := range grants {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO namespace_grants (namespace_id, identity_id, permission) VALUES ($1, $2, $3)`,
+			nsID, g.IdentityID, g.Permission)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNamespaceAccess returns the grants and certificate filters of a namespace
+func (s *BillingService) GetNamespaceAccess(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	ns, err := s.getNamespace(r.Context(), nsID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	grants, err := s.namespaceGrants(r.Context(), nsID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	access := NamespaceAccess{Grants: grants, CertificateFilters: ns.CertificateFilters}
+	if access.CertificateFilters == nil {
+		access.CertificateFilters = []CertificateFilter{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(access)
+}
+
+// UpdateNamespaceAccess replaces the grants and certificate filters of a namespace
+func (s *BillingService) UpdateNamespaceAccess(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	var req NamespaceAccess
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateNamespaceAccess(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ns, err := s.getNamespace(r.Context(), nsID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	grants, unmatched, err := s.resolveGrantIdentities(r.Context(), ns.OrganizationID, req.Grants)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(unmatched) > 0 {
+		http.Error(w, fmt.Sprintf("No identity in organization for %v", unmatched), http.StatusBadRequest)
+		return
+	}
+
+	req.Grants = grants
+	if err := s.setNamespaceAccess(r.Context(), nsID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	"google.golang.org/grpc"
+)
+
+// namespaceExportVersion is the version of the export format written by ExportNamespace
+const namespaceExportVersion = 1
+
+// NamespaceExport is the portable definition of a namespace, used to recreate it
+// under another organization or cluster
+type NamespaceExport struct {
+	Version            int                 `json:"version"`
+	ExportedAt         time.Time           `json:"exported_at"`
+	Name               string              `json:"name"`
+	Region             string              `json:"region"`
+	RetentionDays      int                 `json:"retention_days"`
+	IsGlobal           bool                `json:"is_global"`
+	StandbyRegions     []string            `json:"standby_regions,omitempty"`
+	Archival           ArchivalConfig      `json:"archival"`
+	SearchAttributes   map[string]string   `json:"search_attributes"` // name to type, e.g. "Keyword"
+	Grants             []NamespaceGrant    `json:"grants"`
+	CertificateFilters []CertificateFilter `json:"certificate_filters"`
+}
+
+// NamespaceImportRequest is the request body for importing a namespace
+type NamespaceImportRequest struct {
+	Namespace NamespaceExport `json:"namespace"`
+	Name      string          `json:"name"`       // optional new name
+	Region    string          `json:"region"`     // optional region override
+	ClusterID *uuid.UUID      `json:"cluster_id"` // optional target cluster, implies its region
+	DryRun    bool            `json:"dry_run"`
+}
+
+// NamespaceChange is a single difference between the target and the imported namespace
+type NamespaceChange struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// NamespaceImportPlan describes what an import does or, in dry-run mode, would do
+type NamespaceImportPlan struct {
+	DryRun            bool              `json:"dry_run"`
+	Action            string            `json:"action"` // "create" or "conflict"
+	TemporalNamespace string            `json:"temporal_namespace"`
+	ClusterID         *uuid.UUID        `json:"cluster_id,omitempty"`
+	Changes           []NamespaceChange `json:"changes"`
+	Warnings          []string          `json:"warnings,omitempty"`
+	Namespace         *Namespace        `json:"namespace,omitempty"`
+}
+
+func validateSearchAttributes(attrs map[string]string) error {
+	for name, typ := range attrs {
+		if name == "" {
+			return errors.New("search attribute name is required")
+		}
+		t, err := enumspb.IndexedValueTypeFromString(typ)
+		if err != nil || t == enumspb.INDEXED_VALUE_TYPE_UNSPECIFIED {
+			return fmt.Errorf("search attribute %s has invalid type %q", name, typ)
+		}
+	}
+	return nil
+}
+
+// listSearchAttributes returns the custom search attributes of a namespace
+func listSearchAttributes(ctx context.Context, conn *grpc.ClientConn, namespace string) (map[string]string, error) {
+	resp, err := operatorservice.NewOperatorServiceClient(conn).ListSearchAttributes(ctx,
+		&operatorservice.ListSearchAttributesRequest{Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string]string, len(resp.CustomAttributes))
+	for name, typ := range resp.CustomAttributes {
+		attrs[name] = typ.String()
+	}
+	return attrs, nil
+}
+
+// addSearchAttributes registers custom search attributes on a namespace
+func addSearchAttributes(ctx context.Context, conn *grpc.ClientConn, namespace string, attrs map[string]string) error {
+	req := &operatorservice.AddSearchAttributesRequest{
+		Namespace:        namespace,
+		SearchAttributes: make(map[string]enumspb.IndexedValueType, len(attrs)),
+	}
+	for name, typ := range attrs {
+		t, err := enumspb.IndexedValueTypeFromString(typ)
+		if err != nil {
+			return err
+		}
+		req.SearchAttributes[name] = t
+	}
+
+	_, err := operatorservice.NewOperatorServiceClient(conn).AddSearchAttributes(ctx, req)
+	return err
+}
+
+// exportNamespace builds the export of a namespace. Search attributes are read from
+// the namespace's Temporal cluster.
+func (s *BillingService) exportNamespace(ctx context.Context, ns Namespace) (NamespaceExport, error) {
+	export := NamespaceExport{
+		Version:            namespaceExportVersion,
+		ExportedAt:         time.Now().UTC(),
+		Name:               ns.Name,
+		Region:             ns.Region,
+		RetentionDays:      ns.RetentionDays,
+		IsGlobal:           ns.IsGlobal,
+		Archival:           ns.Archival,
+		SearchAttributes:   map[string]string{},
+		CertificateFilters: ns.CertificateFilters,
+	}
+	if export.CertificateFilters == nil {
+		export.CertificateFilters = []CertificateFilter{}
+	}
+
+	if len(ns.StandbyClusterIDs) > 0 {
+		rows, err := s.db.Query(ctx,
+			`SELECT region FROM clusters WHERE id = ANY($1) ORDER BY region`, ns.StandbyClusterIDs)
+		if err != nil {
+			return export, err
+		}
+		for rows.Next() {
+			var region string
+			if err := rows.Scan(&region); err != nil {
+				rows.Close()
+				return export, err
+			}
+			export.StandbyRegions = append(export.StandbyRegions, region)
+		}
+		rows.Close()
+	}
+
+	grants, err := s.namespaceGrants(ctx, ns.ID)
+	if err != nil {
+		return export, fmt.Errorf("failed to get grants: %w", err)
+	}
+	// Identities are organization specific, grants travel by email
+	for i := range grants {
+		grants[i].IdentityID = nil
+	}
+	export.Grants = grants
+
+	if ns.Status == "active" {
+		endpoint, err := s.clusterEndpoint(ctx, ns.ClusterID)
+		if err != nil {
+			return export, err
+		}
+		conn, err := dialTemporal(ctx, endpoint)
+		if err != nil {
+			return export, fmt.Errorf("failed to connect to Temporal: %w", err)
+		}
+		defer conn.Close()
+
+		export.SearchAttributes, err = listSearchAttributes(ctx, conn, ns.TemporalNamespace)
+		if err != nil {
+			return export, fmt.Errorf("failed to list search attributes: %w", err)
+		}
+	}
+
+	return export, nil
+}
+
+// flattenNamespaceExport turns an export into comparable field values
+func flattenNamespaceExport(e NamespaceExport) map[string]string {
+	fields := map[string]string{
+		"region":                    e.Region,
+		"retention_days":            strconv.Itoa(e.RetentionDays),
+		"is_global":                 strconv.FormatBool(e.IsGlobal),
+		"standby_regions":           strings.Join(e.StandbyRegions, ","),
+		"archival.history_state":    e.Archival.HistoryState,
+		"archival.history_uri":      e.Archival.HistoryURI,
+		"archival.visibility_state": e.Archival.VisibilityState,
+		"archival.visibility_uri":   e.Archival.VisibilityURI,
+	}
+	for name, typ := range e.SearchAttributes {
+		fields["search_attributes."+name] = typ
+	}
+	for _, g := range e.Grants {
+		fields["grants."+g.Email] = g.Permission
+	}
+	for _, f := range e.CertificateFilters {
+		var parts []string
+		for _, p := range [][2]string{
+			{"common_name", f.CommonName},
+			{"organization", f.Organization},
+			{"organizational_unit", f.OrganizationalUnit},
+			{"subject_alternative_name", f.SubjectAlternativeName},
+		} {
+			if p[1] != "" {
+				parts = append(parts, p[0]+"="+p[1])
+			}
+		}
+		fields["certificate_filters."+strings.Join(parts, ";")] = "present"
+	}
+	return fields
+}
+
+// diffNamespaceExports lists the field changes that turn current into desired.
+// A nil current means the namespace does not exist yet.
+func diffNamespaceExports(current *NamespaceExport, desired NamespaceExport) []NamespaceChange {
+	from := map[string]string{}
+	if current != nil {
+		from = flattenNamespaceExport(*current)
+	}
+	to := flattenNamespaceExport(desired)
+
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := make([]NamespaceChange, 0)
+	for _, k := range keys {
+		if from[k] != to[k] {
+			changes = append(changes, NamespaceChange{Field: k, From: from[k], To: to[k]})
+		}
+	}
+	return changes
+}
+
+// ExportNamespace returns the portable definition of a namespace
+func (s *BillingService) ExportNamespace(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	ns, err := s.getNamespace(r.Context(), nsID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	export, err := s.exportNamespace(r.Context(), ns)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export namespace: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ns.Name+".json"))
+	json.NewEncoder(w).Encode(export)
+}
+
+// ImportNamespace recreates an exported namespace under an organization. In dry-run
+// mode nothing is created and the plan lists the differences against the target.
+func (s *BillingService) ImportNamespace(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, err := uuid.Parse(vars["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req NamespaceImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		req.DryRun = true
+	}
+
+	desired := req.Namespace
+	if desired.Version != namespaceExportVersion {
+		http.Error(w, fmt.Sprintf("Unsupported export version %d", desired.Version), http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" {
+		desired.Name = req.Name
+	}
+	if req.Region != "" {
+		desired.Region = req.Region
+	}
+	if desired.Name == "" {
+		http.Error(w, "Namespace name is required", http.StatusBadRequest)
+		return
+	}
+	if desired.RetentionDays == 0 {
+		desired.RetentionDays = 7
+	}
+	if desired.Region == "" {
+		desired.Region = "us-east-1"
+	}
+	desired.Archival = desired.Archival.withDefaults()
+	if err := desired.Archival.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSearchAttributes(desired.SearchAttributes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	access := NamespaceAccess{Grants: desired.Grants, CertificateFilters: desired.CertificateFilters}
+	if err := validateNamespaceAccess(access); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// An explicit target cluster decides the region
+	if req.ClusterID != nil {
+		clusters, err := s.listClusters(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		target, err := targetCluster(clusters, *req.ClusterID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Target %v", err), http.StatusBadRequest)
+			return
+		}
+		desired.Region = target.Region
+	}
+
+	plan := NamespaceImportPlan{
+		DryRun:            req.DryRun,
+		Action:            "create",
+		TemporalNamespace: fmt.Sprintf("%s.%s", orgID.String()[:8], desired.Name),
+		ClusterID:         req.ClusterID,
+	}
+
+	// Compare against a namespace of the same name in the target organization
+	var current *NamespaceExport
+	var existingID uuid.UUID
+	err = s.db.QueryRow(r.Context(),
+		`SELECT id FROM namespaces WHERE organization_id = $1 AND name = $2 AND status != 'failed'`,
+		orgID, desired.Name).Scan(&existingID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		existing, err := s.getNamespace(r.Context(), existingID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		export, err := s.exportNamespace(r.Context(), existing)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read existing namespace: %v", err), http.StatusBadGateway)
+			return
+		}
+		current = &export
+		plan.Action = "conflict"
+		plan.ClusterID = existing.ClusterID
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("namespace %s already exists in the organization", desired.Name))
+	}
+	plan.Changes = diffNamespaceExports(current, desired)
+
+	grants, unmatched, err := s.resolveGrantIdentities(r.Context(), orgID, desired.Grants)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, email := range unmatched {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("grant for %s skipped: no identity in organization", email))
+	}
+
+	ns := Namespace{
+		ID:                 uuid.New(),
+		OrganizationID:     orgID,
+		Name:               desired.Name,
+		TemporalNamespace:  plan.TemporalNamespace,
+		Region:             desired.Region,
+		ClusterID:          req.ClusterID,
+		IsGlobal:           desired.IsGlobal,
+		Status:             "provisioning",
+		RetentionDays:      desired.RetentionDays,
+		Archival:           desired.Archival,
+		SearchAttributes:   desired.SearchAttributes,
+		CertificateFilters: desired.CertificateFilters,
+		CreatedAt:          time.Now(),
+	}
+
+	if req.DryRun {
+		if plan.Action == "create" {
+			plan.Warnings = append(plan.Warnings, s.checkNamespaceImport(r.Context(), ns, desired.StandbyRegions)...)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+		return
+	}
+
+	if plan.Action == "conflict" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(plan)
+		return
+	}
+
+	// The grants are stored with the namespace, so a failed import leaves nothing behind
+	if err := s.createNamespace(r.Context(), &ns, desired.StandbyRegions, grants); err != nil {
+		writeNamespaceError(w, err)
+		return
+	}
+
+	go s.provisionNamespace(context.Background(), ns)
+
+	plan.ClusterID = ns.ClusterID
+	plan.Namespace = &ns
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// checkNamespaceImport reports why creating the namespace would fail, without creating it
+func (s *BillingService) checkNamespaceImport(ctx context.Context, ns Namespace, standbyRegions []string) []string {
+	var warnings []string
+
+	limits, count, err := s.namespaceQuotaUsage(ctx, s.db, ns.OrganizationID, false)
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	} else if err := validateNamespaceQuota(limits, count, ns); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+
+	if ns.ClusterID == nil {
+		if _, err := s.placeNamespace(ctx, ns.Region); err != nil {
+			warnings = append(warnings, fmt.Sprintf("region %s: %v", ns.Region, err))
+		}
+	}
+	if ns.IsGlobal {
+		if _, err := s.placeStandbyClusters(ctx, ns.Region, standbyRegions); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+	return warnings
+}
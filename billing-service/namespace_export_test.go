@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func sampleNamespaceExport() NamespaceExport {
+	return NamespaceExport{
+		Version:          namespaceExportVersion,
+		Name:             "orders",
+		Region:           "us-east-1",
+		RetentionDays:    30,
+		Archival:         ArchivalConfig{HistoryState: "enabled", HistoryURI: "file:///tmp/temporal_archival/orders", VisibilityState: "disabled"},
+		SearchAttributes: map[string]string{"CustomerId": "Keyword"},
+		Grants:           []NamespaceGrant{{Email: "alice@example.com", Permission: "admin"}},
+		CertificateFilters: []CertificateFilter{
+			{CommonName: "worker.orders"},
+		},
+	}
+}
+
+func TestDiffNamespaceExportsForNewNamespace(t *testing.T) {
+	changes := diffNamespaceExports(nil, sampleNamespaceExport())
+
+	expected := []NamespaceChange{
+		{Field: "archival.history_state", To: "enabled"},
+		{Field: "archival.history_uri", To: "file:///tmp/temporal_archival/orders"},
+		{Field: "archival.visibility_state", To: "disabled"},
+		{Field: "certificate_filters.common_name=worker.orders", To: "present"},
+		{Field: "grants.alice@example.com", To: "admin"},
+		{Field: "is_global", To: "false"},
+		{Field: "region", To: "us-east-1"},
+		{Field: "retention_days", To: "30"},
+		{Field: "search_attributes.CustomerId", To: "Keyword"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes:\n%+v", changes)
+	}
+}
+
+func TestDiffNamespaceExportsAgainstExisting(t *testing.T) {
+	current := sampleNamespaceExport()
+	desired := sampleNamespaceExport()
+	desired.RetentionDays = 7
+	desired.SearchAttributes = map[string]string{"CustomerId": "Keyword", "OrderTotal": "Double"}
+	desired.Grants = nil
+
+	changes := diffNamespaceExports(&current, desired)
+
+	expected := []NamespaceChange{
+		{Field: "grants.alice@example.com", From: "admin"},
+		{Field: "retention_days", From: "30", To: "7"},
+		{Field: "search_attributes.OrderTotal", To: "Double"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes:\n%+v", changes)
+	}
+
+	if changes := diffNamespaceExports(&current, current); len(changes) != 0 {
+		t.Errorf("Expected no changes for identical exports, got %+v", changes)
+	}
+}
+
+func TestNamespaceExportRoundTrip(t *testing.T) {
+	export := sampleNamespaceExport()
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded NamespaceExport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if changes := diffNamespaceExports(&export, decoded); len(changes) != 0 {
+		t.Errorf("Expected export to survive a round trip, got %+v", changes)
+	}
+}
+
+func TestValidateSearchAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   map[string]string
+		wantErr bool
+	}{
+		{"shorthand types", map[string]string{"CustomerId": "Keyword", "Total": "Double", "Tags": "KeywordList"}, false},
+		{"canonical type", map[string]string{"CustomerId": "INDEXED_VALUE_TYPE_KEYWORD"}, false},
+		{"unknown type", map[string]string{"CustomerId": "String"}, true},
+		{"unspecified type", map[string]string{"CustomerId": "Unspecified"}, true},
+		{"empty name", map[string]string{"": "Keyword"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSearchAttributes(tt.attrs)
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateNamespaceAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		access  NamespaceAccess
+		wantErr bool
+	}{
+		{"valid", NamespaceAccess{
+			Grants:             []NamespaceGrant{{Email: "a@example.com", Permission: "read"}, {Email: "b@example.com", Permission: "write"}},
+			CertificateFilters: []CertificateFilter{{Organization: "Example Inc"}},
+		}, false},
+		{"invalid permission", NamespaceAccess{Grants: []NamespaceGrant{{Email: "a@example.com", Permission: "owner"}}}, true},
+		{"duplicate grant", NamespaceAccess{Grants: []NamespaceGrant{{Email: "a@example.com", Permission: "read"}, {Email: "a@example.com", Permission: "admin"}}}, true},
+		{"empty certificate filter", NamespaceAccess{CertificateFilters: []CertificateFilter{{}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNamespaceAccess(tt.access)
+			if tt.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/proration"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/trials"
+)
+
+// PreviewSubscriptionChange shows how a plan change would prorate the current period
+func (s *BillingService) PreviewSubscriptionChange(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, err := uuid.Parse(vars["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Plan        string `json:"plan"`
+		AtPeriodEnd bool   `json:"at_period_end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, plan, ok := s.planChangeTarget(w, r, orgID, req.Plan)
+	if !ok {
+		return
+	}
+	periodStart, periodEnd := s.currentPeriod(r.Context(), orgID)
+	from, err := s.catalog.Lookup(r.Context(), current.Plan, periodStart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var currency money.Currency
+	if err := s.db.QueryRow(r.Context(),
+		`SELECT billing_currency FROM organizations WHERE id = $1`, orgID).Scan(&currency); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	at := time.Now()
+	if req.AtPeriodEnd {
+		at = periodEnd
+	}
+	preview, err := proration.NewPreview(from, plan, currency, periodStart, periodEnd, at)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// CancelScheduledChange cancels a plan change scheduled for the end of the period
+func (s *BillingService) CancelScheduledChange(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, err := uuid.Parse(vars["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.changes.Scheduled(r.Context(), orgID); errors.Is(err, proration.ErrNoChangeScheduled) {
+		http.Error(w, "No plan change scheduled", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.changes.CancelScheduled(r.Context(), s.db, orgID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// planChangeTarget validates moving an organization to a plan, writing an error
+// response if it cannot
+func (s *BillingService) planChangeTarget(w http.ResponseWriter, r *http.Request, orgID uuid.UUID, planID string) (trials.State, plans.Plan, bool) {
+	plan, err := s.catalog.Get(r.Context(), planID, time.Now())
+	if errors.Is(err, plans.ErrPlanNotFound) {
+		http.Error(w, "Invalid plan", http.StatusBadRequest)
+		return trials.State{}, plans.Plan{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return trials.State{}, plans.Plan{}, false
+	}
+
+	var currency money.Currency
+	err = s.db.QueryRow(r.Context(), `SELECT billing_currency FROM organizations WHERE id = $1`, orgID).Scan(&currency)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return trials.State{}, plans.Plan{}, false
+	}
+	if !plan.Offers(currency) {
+		http.Error(w, fmt.Sprintf("Plan is not offered in %s", currency), http.StatusBadRequest)
+		return trials.State{}, plans.Plan{}, false
+	}
+
+	current, err := s.trials.Get(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return trials.State{}, plans.Plan{}, false
+	}
+	if current.Plan == plan.ID {
+		http.Error(w, "Organization is already on this plan", http.StatusBadRequest)
+		return trials.State{}, plans.Plan{}, false
+	}
+	return current, plan, true
+}
+
+// changePlan moves an organization to a plan and records the change for proration.
+// A scheduled change is marked applied; any other change replaces the scheduled one.
+// The organization's provider subscription is modified in place, so it is never
+// billed for two plans at once.
+func (s *BillingService) changePlan(ctx context.Context, c proration.Change, plan plans.Plan, skipTrial bool) (trials.State, error) {
+	var stripeCustomerID, stripeSubID string
+	var currency money.Currency
+	err := s.db.QueryRow(ctx,
+		`SELECT o.stripe_customer_id, o.billing_currency, COALESCE(s.stripe_subscription_id, '')
+		 FROM organizations o LEFT JOIN subscriptions s ON s.organization_id = o.id
+		 WHERE o.id = $1`, c.OrganizationID).
+		Scan(&stripeCustomerID, &currency, &stripeSubID)
+	if err != nil {
+		return trials.State{}, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	// Organizations upgrading from free start with the plan's trial
+	current, err := s.trials.Get(ctx, c.OrganizationID)
+	if err != nil {
+		return trials.State{}, err
+	}
+	next := trials.Change(current, plan, skipTrial, time.Now())
+
+	stripeSubID, err = s.updateProviderSubscription(ctx, stripeCustomerID, stripeSubID, plan, currency, current, next)
+	if err != nil {
+		return trials.State{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return trials.State{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE subscriptions SET plan = $1, stripe_subscription_id = $2,
+		 actions_included = $3, active_storage_gb = $4, retained_storage_gb = $5,
+		 status = $6, trial_ends_at = $7, updated_at = NOW()
+		 WHERE organization_id = $8`,
+		plan.ID, stripeSubID, plan.ActionsIncluded, plan.ActiveStorageGB, plan.RetainedStorageGB,
+		next.Status, next.EndsAt, c.OrganizationID)
+	if err != nil {
+		return trials.State{}, err
+	}
+	if c.Status == proration.Scheduled {
+		err = s.changes.MarkApplied(ctx, tx, c.ID)
+	} else if err = s.changes.CancelScheduled(ctx, tx, c.OrganizationID); err == nil {
+		_, err = s.changes.Record(ctx, tx, c)
+	}
+	if err != nil {
+		return trials.State{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return trials.State{}, err
+	}
+
+	// Namespace rate limits follow the plan
+	s.syncDynamicConfigAsync(c.OrganizationID)
+	return next, nil
+}
+
+// updateProviderSubscription moves a provider subscription to a plan and returns its
+// ID, empty for the free plan. The provider does not prorate: the monthly bill
+// prorates base prices itself.
+func (s *BillingService) updateProviderSubscription(ctx context.Context, customerID, subID string, plan plans.Plan,
+	currency money.Currency, current, next trials.State) (string, error) {
+	var existing payments.Subscription
+	if subID != "" {
+		var err error
+		existing, err = s.payments.GetSubscription(ctx, subID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get %s subscription: %w", s.payments.Name(), err)
+		}
+	}
+
+	if plan.ID == plans.FreePlanID {
+		if subID != "" && !existing.Ended() {
+			if err := s.payments.CancelSubscription(ctx, subID); err != nil {
+				return "", fmt.Errorf("failed to cancel %s subscription: %w", s.payments.Name(), err)
+			}
+		}
+		return "", nil
+	}
+
+	priceID := stripePriceID(plan, currency)
+	if priceID == "" {
+		return "", fmt.Errorf("Stripe price not configured for plan %s", plan.ID)
+	}
+	params := payments.SubscriptionParams{CustomerID: customerID, PriceID: priceID}
+	if next.Status == trials.StatusTrialing {
+		params.TrialEnd = next.EndsAt
+	}
+
+	if subID != "" && !existing.Ended() {
+		params.EndTrialNow = current.Trialing(time.Now()) && next.Status != trials.StatusTrialing
+		if _, err := s.payments.UpdateSubscription(ctx, subID, params); err != nil {
+			return "", fmt.Errorf("failed to update %s subscription: %w", s.payments.Name(), err)
+		}
+		return subID, nil
+	}
+
+	sub, err := s.payments.CreateSubscription(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s subscription: %w", s.payments.Name(), err)
+	}
+	return sub.ID, nil
+}
+
+// currentPeriod returns an organization's current billing period, defaulting to the
+// current calendar month
+func (s *BillingService) currentPeriod(ctx context.Context, orgID uuid.UUID) (time.Time, time.Time) {
+	var periodStart, periodEnd time.Time
+	err := s.db.QueryRow(ctx,
+		`SELECT current_period_start, current_period_end FROM subscriptions WHERE organization_id = $1`, orgID).
+		Scan(&periodStart, &periodEnd)
+	if err != nil {
+		now := time.Now()
+		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodEnd = periodStart.AddDate(0, 1, 0)
+	}
+	return periodStart, periodEnd
+}
+
+// RunScheduledPlanChanges periodically applies plan changes scheduled for the end of
+// a billing period until ctx is done
+func (s *BillingService) RunScheduledPlanChanges(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.applyScheduledChanges(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyScheduledChanges applies the scheduled plan changes that are due
+func (s *BillingService) applyScheduledChanges(ctx context.Context, now time.Time) {
+	due, err := s.changes.Due(ctx, now)
+	if err != nil {
+		log.Printf("Scheduled plan changes failed to list changes: %v", err)
+		return
+	}
+
+	for _, c := range due {
+		plan, err := s.catalog.Get(ctx, c.ToPlan, now)
+		if err != nil {
+			log.Printf("Scheduled plan change for org %s failed to get plan %s: %v", c.OrganizationID, c.ToPlan, err)
+			continue
+		}
+		if _, err := s.changePlan(ctx, c, plan, false); err != nil {
+			log.Printf("Scheduled plan change for org %s failed: %v", c.OrganizationID, err)
+		}
+	}
+}
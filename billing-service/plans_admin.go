@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+// writePlanError maps catalog errors to HTTP statuses
+func writePlanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, plans.ErrPlanNotFound), errors.Is(err, plans.ErrVersionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, plans.ErrPlanExists), errors.Is(err, plans.ErrVersionInEffect):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, plans.ErrVersionInPast), errors.Is(err, plans.ErrVersionNotAfter):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ListPlanVersions returns every version of every plan, including private and scheduled ones
+func (s *BillingService) ListPlanVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.catalog.List(r.Context())
+	if err != nil {
+		writePlanError(w, err)
+		return
+	}
+	if versions == nil {
+		versions = []plans.Plan{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// GetPlanVersions returns all versions of one plan, oldest first
+func (s *BillingService) GetPlanVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.catalog.Versions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writePlanError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// CreatePlan adds a new plan to the catalog
+func (s *BillingService) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req plans.Plan
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := s.catalog.Create(r.Context(), req, time.Now())
+	if err != nil {
+		writePlanError(w, err)
+		return
+	}
+	go s.syncProviderPlansAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// AddPlanVersion schedules a new version of a plan. Subscriptions pick it up for
+// billing periods that start on or after its effective date.
+func (s *BillingService) AddPlanVersion(w http.ResponseWriter, r *http.Request) {
+	var req plans.Plan
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ID = mux.Vars(r)["id"]
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := s.catalog.AddVersion(r.Context(), req, time.Now())
+	if err != nil {
+		writePlanError(w, err)
+		return
+	}
+
+	// Rate limits change when the new version is already in effect
+	if !plan.EffectiveFrom.After(time.Now()) {
+		go func() {
+			if err := s.syncDynamicConfig(context.Background()); err != nil {
+				log.Printf("Failed to sync dynamic config after %s v%d: %v", plan.ID, plan.Version, err)
+			}
+		}()
+		go s.syncProviderPlansAsync()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// DeletePlanVersion cancels a scheduled plan version that has not taken effect
+func (s *BillingService) DeletePlanVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		http.Error(w, "Invalid plan version", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.catalog.DeleteVersion(r.Context(), vars["id"], version, time.Now()); err != nil {
+		writePlanError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// syncProviderPlans mirrors the plans in effect to payment providers that keep their
+// own plan catalog, one plan per currency under the plan's price ID
+func (s *BillingService) syncProviderPlans(ctx context.Context) error {
+	syncer, ok := s.payments.(payments.PlanSyncer)
+	if !ok {
+		return nil
+	}
+	versions, err := s.catalog.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans.Current(versions, time.Now()) {
+		currencies := []money.Currency{money.USD}
+		for currency := range plan.CurrencyPrices {
+			currencies = append(currencies, currency)
+		}
+		for _, currency := range currencies {
+			code := stripePriceID(plan, currency)
+			if code == "" {
+				continue
+			}
+			err := syncer.SyncPlan(ctx, payments.PlanParams{
+				Code:        code,
+				Name:        plan.Name,
+				Description: plan.Description,
+				Currency:    currency,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to sync plan %s in %s: %w", plan.ID, currency, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *BillingService) syncProviderPlansAsync() {
+	if err := s.syncProviderPlans(context.Background()); err != nil {
+		log.Printf("Failed to sync plans to %s: %v", s.payments.Name(), err)
+	}
+}
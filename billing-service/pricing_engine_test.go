@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/billing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/proration"
+)
+
+func defaultPlan(id string) plans.Plan {
+	return plans.Effective(plans.Defaults(), id, time.Now())
+}
+
+// mustBill prices usage with per-line rounding, failing the test if the plan is not
+// offered in the currency
+func mustBill(t testing.TB, plan plans.Plan, currency money.Currency, periodStart, periodEnd time.Time, usage pricing.Usage) *billing.MonthlyBill {
+	t.Helper()
+	bill, err := billing.NewMonthlyBill(uuid.New(), periodStart, periodEnd, plan, currency, usage, money.PerLine)
+	if err != nil {
+		t.Fatalf("Expected a bill, got %v", err)
+	}
+	return bill
+}
+
+// mustCost estimates a usage summary in USD with per-line rounding
+func mustCost(t testing.TB, usage UsageSummary, plan plans.Plan) int64 {
+	t.Helper()
+	cost, err := calculateCost(usage, plan, money.USD, money.PerLine, nil)
+	if err != nil {
+		t.Fatalf("Expected a cost, got %v", err)
+	}
+	return cost
+}
+
+func TestCalculateCostMatchesMonthlyBill(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ids := []string{"free", "essential", "business", "enterprise"}
+
+	firstTier := decimal.NewFromInt(5)
+	tiered := defaultPlan("business")
+	tiered.Prices = pricing.PriceBook{
+		pricing.MeterActions: {
+			Mode:     pricing.Graduated,
+			UnitSize: decimal.NewFromInt(1000000),
+			RoundUp:  true,
+			Tiers: []pricing.Tier{
+				{UpTo: &firstTier, UnitCents: decimal.NewFromInt(5000)},
+				{UnitCents: decimal.NewFromInt(2500)},
+			},
+		},
+	}
+
+	matches := func(actions, replicated uint32, active, retained, archived uint16, planIndex uint8) bool {
+		plan := tiered
+		if i := int(planIndex) % (len(ids) + 1); i < len(ids) {
+			plan = defaultPlan(ids[i])
+		}
+		summary := UsageSummary{
+			OrganizationID:     uuid.New(),
+			PeriodStart:        time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+			PeriodEnd:          time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			TotalActions:       int64(actions) * 10,
+			ReplicatedActions:  int64(replicated),
+			ActiveStorageGBH:   decimal.NewFromInt(int64(active)).Div(decimal.NewFromInt(7)),
+			RetainedStorageGBH: decimal.NewFromInt(int64(retained)),
+			ArchivedStorageGBH: decimal.NewFromInt(int64(archived)),
+		}
+
+		bill := mustBill(t, plan, money.USD, summary.PeriodStart, summary.PeriodEnd, summary.pricingUsage())
+		lines := bill.BaseCostCents + bill.ActionOverageCents + bill.ReplicatedActionsCents +
+			bill.ActiveStorageCents + bill.RetainedStorageCents + bill.ArchivedStorageCents
+		return mustCost(t, summary, plan) == bill.TotalCents && lines == bill.TotalCents
+	}
+	if err := quick.Check(matches, &quick.Config{Rand: r, MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStripePriceIDPerCurrency(t *testing.T) {
+	plan := defaultPlan("essential")
+	if id := stripePriceID(plan, money.EUR); id != "price_essential_monthly_eur" {
+		t.Errorf("Expected EUR catalog price, got %q", id)
+	}
+
+	t.Setenv("STRIPE_PRICE_ESSENTIAL_GBP", "price_override_gbp")
+	if id := stripePriceID(plan, money.GBP); id != "price_override_gbp" {
+		t.Errorf("Expected GBP override, got %q", id)
+	}
+	if id := stripePriceID(plan, "JPY"); id != "" {
+		t.Errorf("Expected no price for an unoffered currency, got %q", id)
+	}
+}
+
+func TestCalculateCostRejectsUnofferedCurrency(t *testing.T) {
+	if _, err := calculateCost(UsageSummary{}, defaultPlan("free"), "JPY", money.PerLine, nil); !errors.Is(err, plans.ErrCurrencyNotOffered) {
+		t.Errorf("Expected ErrCurrencyNotOffered, got %v", err)
+	}
+}
+
+func TestCalculateCostOnContract(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	contract := contracts.Contract{
+		Currency:        money.USD,
+		StartsAt:        start.AddDate(0, -8, 0),
+		EndsAt:          start.AddDate(0, 4, 0),
+		CommitCents:     6000000,
+		MonthlyFeeCents: 250000,
+		Discounts:       map[pricing.Meter]decimal.Decimal{pricing.MeterActions: decimal.NewFromInt(20)},
+		TrueUp:          contracts.TrueUpShortfall,
+	}
+	plan := contract.Apply(defaultPlan("enterprise"))
+	usage := UsageSummary{TotalActions: plan.ActionsIncluded + 10000000, PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0)}
+
+	bill := mustBill(t, plan, money.USD, usage.PeriodStart, usage.PeriodEnd, usage.pricingUsage())
+	if expected := int64(250000 + 10*PricePerMillionActions*8/10); bill.TotalCents != expected {
+		t.Errorf("Expected the contract fee and discounted overage (%d), got %d", expected, bill.TotalCents)
+	}
+	if estimate := mustCost(t, usage, plan); estimate != bill.TotalCents {
+		t.Errorf("Expected estimate %d to match the bill, got %d", bill.TotalCents, estimate)
+	}
+}
+
+func TestCalculateCostAppliesCoupon(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	redemption := &coupons.Redemption{
+		Coupon:     coupons.Coupon{Code: "LAUNCH20", PercentOff: decimal.NewFromInt(20), Duration: coupons.Forever},
+		RedeemedAt: start.AddDate(0, -2, 0),
+	}
+	plan := defaultPlan("essential")
+	usage := UsageSummary{TotalActions: 2000000, PeriodStart: start, PeriodEnd: end}
+
+	estimate, err := calculateCost(usage, plan, money.USD, money.PerLine, redemption)
+	if err != nil {
+		t.Fatalf("Expected an estimate, got %v", err)
+	}
+	if estimate != 10000 {
+		t.Errorf("Expected LAUNCH20 to take 2500 off 12500, got %d", estimate)
+	}
+}
+
+func TestCalculateCostExcludesTrial(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	trialEnd := start.AddDate(0, 0, 12)
+
+	// Usage is already limited to the time after the trial
+	usage := UsageSummary{TotalActions: 2000000, PeriodStart: start, PeriodEnd: end, TrialEndsAt: &trialEnd}
+	if cost := mustCost(t, usage, defaultPlan("essential")); cost != 8500 {
+		t.Errorf("Expected 18 of 30 days of the base price and the overage (8500), got %d", cost)
+	}
+}
+
+func TestCalculateCostProratesPlanChange(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	split := start.AddDate(0, 0, 10)
+	plan := proration.Blend([]proration.Segment{
+		{Plan: defaultPlan("essential"), From: start, To: split},
+		{Plan: defaultPlan("business"), From: split, To: end},
+	}, start, end)
+
+	usage := UsageSummary{TotalActions: 2000000, PeriodStart: start, PeriodEnd: end}
+	if cost := mustCost(t, usage, plan); cost != 36666 {
+		t.Errorf("Expected the prorated base with 2M actions inside the prorated allowance, got %d", cost)
+	}
+}
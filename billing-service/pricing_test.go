@@ -2,8 +2,12 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
 )
 
 func TestPricingConstants(t *testing.T) {
@@ -42,7 +46,7 @@ func TestPlanLimits(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.plan, func(t *testing.T) {
-			plan, ok := Plans[tt.plan]
+			plan, ok := plans.Find(plans.Defaults(), tt.plan, time.Now())
 			if !ok {
 				t.Fatalf("Plan %s not found", tt.plan)
 			}
@@ -159,7 +163,8 @@ func TestRetainedStorageCostCalculation(t *testing.T) {
 }
 
 func TestMonthlyBillScenarios(t *testing.T) {
-	// Test realistic monthly billing scenarios
+	// Test realistic monthly billing scenarios over a 30-day (720 hour) period.
+	// Included storage is converted to GB-hours and deducted before pricing.
 	tests := []struct {
 		name               string
 		plan               string
@@ -174,7 +179,7 @@ func TestMonthlyBillScenarios(t *testing.T) {
 			actions:            50000,
 			activeStorageGBH:   72,   // 0.1GB for 720 hours
 			retainedStorageGBH: 2880, // 4GB for 720 hours
-			expectedTotal:      605,  // 0 base + 0 overage + 302 active + 302 retained
+			expectedTotal:      0,    // everything within the free allowance
 		},
 		{
 			name:               "Essential - within limits",
@@ -182,7 +187,7 @@ func TestMonthlyBillScenarios(t *testing.T) {
 			actions:            800000,
 			activeStorageGBH:   720,   // 1GB for month
 			retainedStorageGBH: 28800, // 40GB for month
-			expectedTotal:      16048, // $100 base + 0 overage + $30.24 active + $30.24 retained
+			expectedTotal:      10000, // $100 base, storage within allowance
 		},
 		{
 			name:               "Essential - with action overage",
@@ -190,62 +195,37 @@ func TestMonthlyBillScenarios(t *testing.T) {
 			actions:            2500000, // 1.5M over limit
 			activeStorageGBH:   720,
 			retainedStorageGBH: 28800,
-			expectedTotal:      21048, // $100 base + $50 overage (2M) + $30.24 active + $30.24 retained
+			expectedTotal:      15000, // $100 base + $50 overage (2M)
 		},
 		{
 			name:               "Business - heavy usage",
 			plan:               "business",
 			actions:            5000000, // 2.5M over limit
-			activeStorageGBH:   3600,    // 5GB for month
-			retainedStorageGBH: 144000,  // 200GB for month
-			expectedTotal:      87740,   // $500 base + $75 overage (3M) + $151.20 active + $151.20 retained
+			activeStorageGBH:   3600,    // 5GB for month, 2.5GB included
+			retainedStorageGBH: 144000,  // 200GB for month, 100GB included
+			expectedTotal:      72620,   // $500 base + $75 overage (3M) + $75.60 active + $75.60 retained
 		},
 	}
 
+	periodStart := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := periodStart.AddDate(0, 0, 30)
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			plan := Plans[tt.plan]
-
-			// Calculate base cost
-			baseCost := plan.BasePriceCents
-
-			// Calculate action overage
-			var actionOverage int64 = 0
-			if tt.actions > plan.ActionsIncluded {
-				overageActions := tt.actions - plan.ActionsIncluded
-				overageMillions := overageActions / 1000000
-				if overageActions%1000000 > 0 {
-					overageMillions++
-				}
-				actionOverage = overageMillions * PricePerMillionActions
-			}
-
-			// Calculate storage costs
-			activeGBH := decimal.NewFromFloat(tt.activeStorageGBH)
-			activeCost := activeGBH.Mul(decimal.NewFromFloat(PricePerActiveStorageGBH)).IntPart()
-
-			retainedGBH := decimal.NewFromFloat(tt.retainedStorageGBH)
-			retainedCost := retainedGBH.Mul(decimal.NewFromFloat(PricePerRetainedStorageGBH)).IntPart()
-
-			total := baseCost + actionOverage + activeCost + retainedCost
-
-			// Allow 1% tolerance for rounding
-			tolerance := tt.expectedTotal / 100
-			if tolerance < 10 {
-				tolerance = 10
-			}
-
-			diff := total - tt.expectedTotal
-			if diff < 0 {
-				diff = -diff
+			plan := plans.Effective(plans.Defaults(), tt.plan, periodStart)
+			usage := pricing.Usage{
+				Actions:            tt.actions,
+				ActiveStorageGBH:   decimal.NewFromFloat(tt.activeStorageGBH),
+				RetainedStorageGBH: decimal.NewFromFloat(tt.retainedStorageGBH),
 			}
+			bill := mustBill(t, plan, money.USD, periodStart, periodEnd, usage)
 
-			if diff > tolerance {
-				t.Errorf("%s: expected ~%d cents ($%.2f), got %d cents ($%.2f)\n"+
+			if bill.TotalCents != tt.expectedTotal {
+				t.Errorf("%s: expected %d cents ($%.2f), got %d cents ($%.2f)\n"+
 					"  Base: %d, Action Overage: %d, Active Storage: %d, Retained Storage: %d",
 					tt.name, tt.expectedTotal, float64(tt.expectedTotal)/100,
-					total, float64(total)/100,
-					baseCost, actionOverage, activeCost, retainedCost)
+					bill.TotalCents, float64(bill.TotalCents)/100,
+					bill.BaseCostCents, bill.ActionOverageCents, bill.ActiveStorageCents, bill.RetainedStorageCents)
 			}
 		})
 	}
@@ -253,7 +233,7 @@ func TestMonthlyBillScenarios(t *testing.T) {
 
 func TestEdgeCases(t *testing.T) {
 	t.Run("zero usage", func(t *testing.T) {
-		plan := Plans["essential"]
+		plan := plans.Effective(plans.Defaults(), "essential", time.Now())
 		total := plan.BasePriceCents // Just base cost
 		if total != 10000 {
 			t.Errorf("Zero usage should be $100 base, got %d cents", total)
@@ -261,7 +241,7 @@ func TestEdgeCases(t *testing.T) {
 	})
 
 	t.Run("exactly at action limit", func(t *testing.T) {
-		plan := Plans["essential"]
+		plan := plans.Effective(plans.Defaults(), "essential", time.Now())
 		actions := plan.ActionsIncluded // Exactly 1M
 
 		var overage int64 = 0
@@ -275,7 +255,7 @@ func TestEdgeCases(t *testing.T) {
 	})
 
 	t.Run("one action over limit", func(t *testing.T) {
-		plan := Plans["essential"]
+		plan := plans.Effective(plans.Defaults(), "essential", time.Now())
 		actions := plan.ActionsIncluded + 1 // 1M + 1
 
 		overageActions := actions - plan.ActionsIncluded
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+// Temporal dynamic config keys used for per-namespace rate limits
+const (
+	// Requests per second accepted by each frontend for a namespace
+	dynamicConfigNamespaceRPS = "frontend.namespaceRPS"
+
+	// Requests per second accepted across all frontends of the cluster for a namespace.
+	// Temporal has no per-namespace action limit, so plans cap requests rather than actions.
+	dynamicConfigNamespaceGlobalRPS = "frontend.globalNamespaceRPS"
+)
+
+var (
+	errNamespaceLimitReached = errors.New("namespace limit reached for plan")
+	errRetentionTooLong      = errors.New("retention exceeds plan maximum")
+)
+
+// validateNamespaceQuota checks a namespace creation against the plan entitlements
+func validateNamespaceQuota(limits plans.Plan, existingNamespaces int, ns Namespace) error {
+	if limits.MaxNamespaces > 0 && existingNamespaces >= limits.MaxNamespaces {
+		return fmt.Errorf("%w (%d of %d used)", errNamespaceLimitReached, existingNamespaces, limits.MaxNamespaces)
+	}
+	if limits.MaxRetentionDays > 0 && ns.RetentionDays > limits.MaxRetentionDays {
+		return fmt.Errorf("%w (%d days requested, %d allowed)", errRetentionTooLong, ns.RetentionDays, limits.MaxRetentionDays)
+	}
+	if ns.IsGlobal && !limits.Replication {
+		return errReplicationNotInPlan
+	}
+	return nil
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// namespaceQuotaUsage returns the organization's plan limits and its current namespace count.
+// With forUpdate the subscription row stays locked until the transaction ends.
+func (s *BillingService) namespaceQuotaUsage(ctx context.Context, q rowQuerier, orgID uuid.UUID, forUpdate bool) (plans.Plan, int, error) {
+	query := `SELECT plan FROM subscriptions WHERE organization_id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	plan := plans.FreePlanID
+	err := q.QueryRow(ctx, query, orgID).Scan(&plan)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return plans.Plan{}, 0, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	var count int
+	err = q.QueryRow(ctx,
+		`SELECT COUNT(*) FROM namespaces WHERE organization_id = $1 AND status != 'failed'`, orgID).Scan(&count)
+	if err != nil {
+		return plans.Plan{}, 0, fmt.Errorf("failed to count namespaces: %w", err)
+	}
+
+	limits, err := s.catalog.Lookup(ctx, plan, time.Now())
+	if err != nil {
+		return plans.Plan{}, 0, err
+	}
+	return limits, count, nil
+}
+
+// insertNamespaceWithinQuota stores a namespace and its grants if the organization's plan
+// allows it. The subscription row is locked so concurrent creations cannot both pass the check.
+func (s *BillingService) insertNamespaceWithinQuota(ctx context.Context, ns Namespace, grants []NamespaceGrant) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	limits, count, err := s.namespaceQuotaUsage(ctx, tx, ns.OrganizationID, true)
+	if err != nil {
+		return err
+	}
+	if err := validateNamespaceQuota(limits, count, ns); err != nil {
+		return err
+	}
+	// The cluster was chosen outside the transaction, so its capacity is checked again
+	if ns.ClusterID != nil {
+		if err := lockClusterCapacity(ctx, tx, *ns.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	certificateFilters := ns.CertificateFilters
+	if certificateFilters == nil {
+		certificateFilters = []CertificateFilter{}
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO namespaces (id, organization_id, name, temporal_namespace, region, cluster_id, is_global, status, retention_days,
+		                         history_archival_state, history_archival_uri, visibility_archival_state, visibility_archival_uri,
+		                         certificate_filters, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, NULLIF($13, ''), $14, $15)`,
+		ns.ID, ns.OrganizationID, ns.Name, ns.TemporalNamespace, ns.Region, ns.ClusterID, ns.IsGlobal, ns.Status, ns.RetentionDays,
+		ns.Archival.HistoryState, ns.Archival.HistoryURI, ns.Archival.VisibilityState, ns.Archival.VisibilityURI,
+		certificateFilters, ns.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Record the replication set, active cluster included
+	if ns.ClusterID != nil {
+		for _, clusterID := range append([]uuid.UUID{*ns.ClusterID}, ns.StandbyClusterIDs...) {
+			_, err = tx.Exec(ctx,
+				`INSERT INTO namespace_clusters (namespace_id, cluster_id) VALUES ($1, $2)`, ns.ID, clusterID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := insertNamespaceGrants(ctx, tx, ns.ID, grants); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// NamespaceRateLimit is the rate limit pushed to Temporal for a single namespace
+type NamespaceRateLimit struct {
+	Namespace string
+	RPS       int
+	GlobalRPS int
+}
+
+// DynamicConfigWriter renders namespace rate limits into a Temporal dynamic config file
+type DynamicConfigWriter struct {
+	// BasePath is an optional dynamic config file copied verbatim ahead of the overrides
+	BasePath string
+	// OutputPath is the file Temporal is configured to read
+	OutputPath string
+
+	mu sync.Mutex
+}
+
+// NewDynamicConfigWriterFromEnv returns a writer configured from the environment,
+// or nil when TEMPORAL_DYNAMIC_CONFIG_PATH is not set.
+func NewDynamicConfigWriterFromEnv() *DynamicConfigWriter {
+	outputPath := os.Getenv("TEMPORAL_DYNAMIC_CONFIG_PATH")
+	if outputPath == "" {
+		return nil
+	}
+	return &DynamicConfigWriter{
+		BasePath:   os.Getenv("TEMPORAL_DYNAMIC_CONFIG_BASE"),
+		OutputPath: outputPath,
+	}
+}
+
+// Write renders the limits and atomically replaces the output file
+func (d *DynamicConfigWriter) Write(limits []NamespaceRateLimit) error {
+	if d == nil {
+		return nil
+	}
+
+	var base string
+	if d.BasePath != "" {
+		data, err := os.ReadFile(d.BasePath)
+		if err != nil {
+			return fmt.Errorf("failed to read base dynamic config: %w", err)
+		}
+		base = string(data)
+	}
+
+	content, err := renderDynamicConfig(base, limits)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(d.OutputPath), ".dynamicconfig-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.OutputPath)
+}
+
+// renderDynamicConfig produces a dynamic config document in the same format as
+// deploy/dynamicconfig/*.yaml, with one constrained value per namespace.
+func renderDynamicConfig(base string, limits []NamespaceRateLimit) (string, error) {
+	for _, key := range []string{dynamicConfigNamespaceRPS, dynamicConfigNamespaceGlobalRPS} {
+		for _, line := range strings.Split(base, "\n") {
+			if strings.HasPrefix(line, key+":") {
+				return "", fmt.Errorf("base dynamic config already defines %s", key)
+			}
+		}
+	}
+
+	sorted := make([]NamespaceRateLimit, len(limits))
+	copy(sorted, limits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Namespace < sorted[j].Namespace })
+
+	var b strings.Builder
+	b.WriteString("# Generated by billing-service from plan entitlements. Do not edit.\n")
+	if base != "" {
+		b.WriteString(strings.TrimRight(base, "\n"))
+		b.WriteString("\n")
+	}
+	if len(sorted) == 0 {
+		return b.String(), nil
+	}
+
+	writeKey := func(key string, value func(NamespaceRateLimit) int) {
+		b.WriteString(key + ":\n")
+		for _, l := range sorted {
+			fmt.Fprintf(&b, "  - value: %d\n", value(l))
+			b.WriteString("    constraints:\n")
+			fmt.Fprintf(&b, "      namespace: %q\n", l.Namespace)
+		}
+	}
+	writeKey(dynamicConfigNamespaceRPS, func(l NamespaceRateLimit) int { return l.RPS })
+	writeKey(dynamicConfigNamespaceGlobalRPS, func(l NamespaceRateLimit) int { return l.GlobalRPS })
+
+	return b.String(), nil
+}
+
+// syncDynamicConfig regenerates the dynamic config overrides for all active namespaces
+func (s *BillingService) syncDynamicConfig(ctx context.Context) error {
+	if s.dynamicConfig == nil {
+		return nil
+	}
+	s.dynamicConfig.mu.Lock()
+	defer s.dynamicConfig.mu.Unlock()
+
+	versions, err := s.catalog.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list plans: %w", err)
+	}
+
+	now := time.Now()
+	rows, err := s.db.Query(ctx,
+		`SELECT n.temporal_namespace, COALESCE(s.plan, 'free')
+		 FROM namespaces n
+		 LEFT JOIN subscriptions s ON s.organization_id = n.organization_id
+		 WHERE n.status = 'active'`)
+	if err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	var limits []NamespaceRateLimit
+	for rows.Next() {
+		var namespace, plan string
+		if err := rows.Scan(&namespace, &plan); err != nil {
+			return err
+		}
+		planLimits := plans.Effective(versions, plan, now)
+		limits = append(limits, NamespaceRateLimit{
+			Namespace: namespace,
+			RPS:       planLimits.NamespaceRPS,
+			GlobalRPS: planLimits.NamespaceGlobalRPS,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return s.dynamicConfig.Write(limits)
+}
+
+// syncDynamicConfigAsync regenerates the overrides in the background, logging failures
+func (s *BillingService) syncDynamicConfigAsync(orgID uuid.UUID) {
+	if s.dynamicConfig == nil {
+		return
+	}
+	go func() {
+		if err := s.syncDynamicConfig(context.Background()); err != nil {
+			log.Printf("Failed to sync dynamic config after change for org %s: %v", orgID, err)
+		}
+	}()
+}
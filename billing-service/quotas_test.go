@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+)
+
+func TestValidateNamespaceQuota(t *testing.T) {
+	tests := []struct {
+		name          string
+		plan          string
+		existing      int
+		retentionDays int
+		global        bool
+		expectedErr   error
+	}{
+		{"free first namespace", "free", 0, 7, false, nil},
+		{"free second namespace", "free", 1, 7, false, errNamespaceLimitReached},
+		{"free retention too long", "free", 0, 30, false, errRetentionTooLong},
+		{"free replicated namespace", "free", 0, 7, true, errReplicationNotInPlan},
+		{"essential within limits", "essential", 9, 30, false, nil},
+		{"essential replicated namespace", "essential", 0, 7, true, nil},
+		{"essential at namespace limit", "essential", 10, 7, false, errNamespaceLimitReached},
+		{"business max retention", "business", 0, 90, false, nil},
+		{"business retention over max", "business", 0, 91, false, errRetentionTooLong},
+		{"unknown plan falls back to free", "unknown", 1, 7, false, errNamespaceLimitReached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := Namespace{RetentionDays: tt.retentionDays, IsGlobal: tt.global}
+			err := validateNamespaceQuota(plans.Effective(plans.Defaults(), tt.plan, time.Now()), tt.existing, ns)
+			if tt.expectedErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPlanNamespaceEntitlements(t *testing.T) {
+	catalog, now := plans.Defaults(), time.Now()
+	planIDs := []string{"free", "essential", "business", "enterprise", "mission_critical"}
+	for i := 1; i < len(planIDs); i++ {
+		lower, higher := plans.Effective(catalog, planIDs[i-1], now), plans.Effective(catalog, planIDs[i], now)
+		if higher.MaxNamespaces < lower.MaxNamespaces {
+			t.Errorf("%s allows fewer namespaces than %s", planIDs[i], planIDs[i-1])
+		}
+		if higher.NamespaceRPS < lower.NamespaceRPS {
+			t.Errorf("%s has lower namespace RPS than %s", planIDs[i], planIDs[i-1])
+		}
+		if higher.NamespaceGlobalRPS < lower.NamespaceGlobalRPS {
+			t.Errorf("%s has lower global namespace RPS than %s", planIDs[i], planIDs[i-1])
+		}
+	}
+}
+
+func TestRenderDynamicConfig(t *testing.T) {
+	base := "# Temporal Dynamic Config for Cloud Offering\nlimit.maxIDLength:\n  - value: 255\n"
+	limits := []NamespaceRateLimit{
+		{Namespace: "bbbbbbbb.payments", RPS: 800, GlobalRPS: 400},
+		{Namespace: "aaaaaaaa.orders", RPS: 100, GlobalRPS: 50},
+	}
+
+	out, err := renderDynamicConfig(base, limits)
+	if err != nil {
+		t.Fatalf("renderDynamicConfig failed: %v", err)
+	}
+
+	expected := `# Generated by billing-service from plan entitlements. Do not edit.
+# Temporal Dynamic Config for Cloud Offering
+limit.maxIDLength:
+  - value: 255
+frontend.namespaceRPS:
+  - value: 100
+    constraints:
+      namespace: "aaaaaaaa.orders"
+  - value: 800
+    constraints:
+      namespace: "bbbbbbbb.payments"
+frontend.globalNamespaceRPS:
+  - value: 50
+    constraints:
+      namespace: "aaaaaaaa.orders"
+  - value: 400
+    constraints:
+      namespace: "bbbbbbbb.payments"
+`
+	if out != expected {
+		t.Errorf("Unexpected dynamic config:\n%s", out)
+	}
+}
+
+func TestRenderDynamicConfigRejectsDuplicateKeys(t *testing.T) {
+	base := "frontend.namespaceRPS:\n  - value: 1200\n"
+	_, err := renderDynamicConfig(base, []NamespaceRateLimit{{Namespace: "ns", RPS: 1, GlobalRPS: 1}})
+	if err == nil || !strings.Contains(err.Error(), "frontend.namespaceRPS") {
+		t.Errorf("Expected duplicate key error, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	replicationpb "go.temporal.io/api/replication/v1"
+	"go.temporal.io/api/workflowservice/v1"
+)
+
+var (
+	errReplicationNotInPlan    = errors.New("replicated namespaces are not available on this plan")
+	errInvalidStandbyRegions   = errors.New("standby regions must be distinct and differ from the active region")
+	errReplicationNeedsCluster = errors.New("replicated namespaces require registered clusters")
+)
+
+// NamespaceFailover records a change of a replicated namespace's active cluster
+type NamespaceFailover struct {
+	ID            uuid.UUID  `json:"id"`
+	NamespaceID   uuid.UUID  `json:"namespace_id"`
+	FromClusterID uuid.UUID  `json:"from_cluster_id"`
+	ToClusterID   uuid.UUID  `json:"to_cluster_id"`
+	Reason        string     `json:"reason,omitempty"`
+	Status        string     `json:"status"` // "in_progress", "completed", "failed"
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
+
+// placeReplicas chooses one standby cluster per standby region
+func placeReplicas(clusters []Cluster, activeRegion string, standbyRegions []string) ([]Cluster, error) {
+	if len(standbyRegions) == 0 {
+		return nil, errInvalidStandbyRegions
+	}
+
+	seen := map[string]bool{activeRegion: true}
+	standbys := make([]Cluster, 0, len(standbyRegions))
+	for _, region := range standbyRegions {
+		if seen[region] {
+			return nil, errInvalidStandbyRegions
+		}
+		seen[region] = true
+
+		c, err := selectCluster(clusters, region)
+		if err != nil {
+			return nil, fmt.Errorf("standby region %s: %w", region, err)
+		}
+		standbys = append(standbys, *c)
+	}
+	return standbys, nil
+}
+
+// placeStandbyClusters chooses the standby clusters for a new replicated namespace
+func (s *BillingService) placeStandbyClusters(ctx context.Context, activeRegion string, standbyRegions []string) ([]uuid.UUID, error) {
+	clusters, err := s.listClusters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+	if len(clusters) == 0 {
+		return nil, errReplicationNeedsCluster
+	}
+
+	standbys, err := placeReplicas(clusters, activeRegion, standbyRegions)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(standbys))
+	for _, c := range standbys {
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
+}
+
+// replicationClusterNames returns the Temporal cluster names for a replicated namespace.
+// Registered cluster names must match the clusterMetadata names of the Temporal clusters.
+func (s *BillingService) replicationClusterNames(ctx context.Context, ns Namespace) (string, []string, error) {
+	if ns.ClusterID == nil {
+		return "", nil, errReplicationNeedsCluster
+	}
+
+	ids := append([]uuid.UUID{*ns.ClusterID}, ns.StandbyClusterIDs...)
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		var name string
+		if err := s.db.QueryRow(ctx, `SELECT name FROM clusters WHERE id = $1`, id).Scan(&name); err != nil {
+			return "", nil, fmt.Errorf("failed to get cluster %s: %w", id, err)
+		}
+		names = append(names, name)
+	}
+	return names[0], names, nil
+}
+
+// FailoverNamespace switches the active cluster of a replicated namespace
+func (s *BillingService) FailoverNamespace(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		TargetClusterID *uuid.UUID `json:"target_cluster_id"`
+		TargetRegion    string     `json:"target_region"`
+		Reason          string     `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TargetClusterID == nil && req.TargetRegion == "" {
+		http.Error(w, "target_cluster_id or target_region is required", http.StatusBadRequest)
+		return
+	}
+
+	var temporalNamespace string
+	var isGlobal bool
+	var activeClusterID *uuid.UUID
+	err = s.db.QueryRow(r.Context(),
+		`SELECT temporal_namespace, is_global, cluster_id FROM namespaces WHERE id = $1`, nsID).
+		Scan(&temporalNamespace, &isGlobal, &activeClusterID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isGlobal || activeClusterID == nil {
+		http.Error(w, "Namespace is not replicated", http.StatusBadRequest)
+		return
+	}
+
+	// The target must be a standby in the namespace's replication set
+	var target Cluster
+	err = s.db.QueryRow(r.Context(),
+		`SELECT c.id, c.name, c.region, c.grpc_endpoint
+		 FROM namespace_clusters nc JOIN clusters c ON c.id = nc.cluster_id
+		 WHERE nc.namespace_id = $1 AND c.id != $2
+		   AND (c.id = $3 OR ($3::uuid IS NULL AND c.region = $4))
+		 ORDER BY c.name LIMIT 1`,
+		nsID, *activeClusterID, req.TargetClusterID, req.TargetRegion).
+		Scan(&target.ID, &target.Name, &target.Region, &target.GRPCEndpoint)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Target is not a standby cluster of this namespace", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	failover := NamespaceFailover{
+		ID:            uuid.New(),
+		NamespaceID:   nsID,
+		FromClusterID: *activeClusterID,
+		ToClusterID:   target.ID,
+		Reason:        req.Reason,
+		Status:        "in_progress",
+		CreatedAt:     time.Now(),
+	}
+	_, err = s.db.Exec(r.Context(),
+		`INSERT INTO namespace_failovers (id, namespace_id, from_cluster_id, to_cluster_id, reason, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		failover.ID, failover.NamespaceID, failover.FromClusterID, failover.ToClusterID, failover.Reason,
+		failover.Status, failover.CreatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Failover is issued on the target cluster so it works while the active one is down
+	if err := failoverTemporalNamespace(r.Context(), target, temporalNamespace); err != nil {
+		log.Printf("Failover of %s to %s failed: %v", temporalNamespace, target.Name, err)
+		failover.Status = "failed"
+		failover.Error = err.Error()
+		s.db.Exec(context.Background(),
+			`UPDATE namespace_failovers SET status = $1, error = $2, completed_at = NOW() WHERE id = $3`,
+			failover.Status, failover.Error, failover.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(failover)
+		return
+	}
+
+	now := time.Now()
+	failover.Status = "completed"
+	failover.CompletedAt = &now
+	tx, err := s.db.Begin(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+	_, err = tx.Exec(r.Context(), `UPDATE namespaces SET cluster_id = $1, updated_at = NOW() WHERE id = $2`, target.ID, nsID)
+	if err == nil {
+		_, err = tx.Exec(r.Context(),
+			`UPDATE namespace_failovers SET status = $1, completed_at = $2 WHERE id = $3`,
+			failover.Status, now, failover.ID)
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Failed over namespace %s to cluster %s", temporalNamespace, target.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(failover)
+}
+
+// failoverTemporalNamespace makes the target cluster active for the namespace
+func failoverTemporalNamespace(ctx context.Context, target Cluster, temporalNamespace string) error {
+	conn, err := dialTemporal(ctx, target.GRPCEndpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = workflowservice.NewWorkflowServiceClient(conn).UpdateNamespace(ctx, &workflowservice.UpdateNamespaceRequest{
+		Namespace: temporalNamespace,
+		ReplicationConfig: &replicationpb.NamespaceReplicationConfig{
+			ActiveClusterName: target.Name,
+		},
+	})
+	return err
+}
+
+// ListNamespaceFailovers returns the failover history of a namespace
+func (s *BillingService) ListNamespaceFailovers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nsID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid namespace ID", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := s.db.Query(r.Context(),
+		`SELECT id, namespace_id, from_cluster_id, to_cluster_id, COALESCE(reason, ''), status,
+		        COALESCE(error, ''), created_at, completed_at
+		 FROM namespace_failovers WHERE namespace_id = $1 ORDER BY created_at DESC`, nsID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	failovers := make([]NamespaceFailover, 0)
+	for rows.Next() {
+		var f NamespaceFailover
+		if err := rows.Scan(&f.ID, &f.NamespaceID, &f.FromClusterID, &f.ToClusterID, &f.Reason, &f.Status,
+			&f.Error, &f.CreatedAt, &f.CompletedAt); err != nil {
+			continue
+		}
+		failovers = append(failovers, f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(failovers)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlaceReplicas(t *testing.T) {
+	clusters := []Cluster{
+		{Name: "use1-a", Region: "us-east-1", Status: "active", Health: "healthy"},
+		{Name: "usw2-a", Region: "us-west-2", Status: "active", Health: "healthy"},
+		{Name: "euw1-a", Region: "eu-west-1", Status: "active", Health: "unhealthy"},
+	}
+
+	t.Run("one standby per region", func(t *testing.T) {
+		standbys, err := placeReplicas(clusters, "us-east-1", []string{"us-west-2"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(standbys) != 1 || standbys[0].Name != "usw2-a" {
+			t.Errorf("Expected standby usw2-a, got %+v", standbys)
+		}
+	})
+
+	tests := []struct {
+		name           string
+		standbyRegions []string
+		expectedErr    error
+	}{
+		{"no standby regions", nil, errInvalidStandbyRegions},
+		{"standby in active region", []string{"us-east-1"}, errInvalidStandbyRegions},
+		{"duplicate standby regions", []string{"us-west-2", "us-west-2"}, errInvalidStandbyRegions},
+		{"standby region unhealthy", []string{"eu-west-1"}, errNoClusterAvailable},
+		{"standby region not served", []string{"ap-south-1"}, errRegionNotServed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := placeReplicas(clusters, "us-east-1", tt.standbyRegions)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCalculateCostIncludesReplicatedActions(t *testing.T) {
+	base := mustCost(t, UsageSummary{TotalActions: 2000000}, defaultPlan("free"))
+	replicated := mustCost(t, UsageSummary{TotalActions: 2000000, ReplicatedActions: 2000000}, defaultPlan("free"))
+
+	if replicated-base != 2*PricePerMillionReplicatedActions {
+		t.Errorf("Expected replicated actions to add %d cents, got %d", 2*PricePerMillionReplicatedActions, replicated-base)
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -15,34 +15,76 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
-	"github.com/stripe/stripe-go/v76"
-	checkoutsession "github.com/stripe/stripe-go/v76/checkout/session"
-	"github.com/stripe/stripe-go/v76/customer"
-	"github.com/stripe/stripe-go/v76/subscription"
-	"github.com/stripe/stripe-go/v76/webhook"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/billing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/contracts"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/coupons"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/credits"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/email"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/money"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/pricing"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/proration"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/tax"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/trials"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/webhooks"
+	replicationpb "go.temporal.io/api/replication/v1"
 	"go.temporal.io/api/workflowservice/v1"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type BillingService struct {
-	db *pgxpool.Pool
+	db            *pgxpool.Pool
+	dynamicConfig *DynamicConfigWriter
+	catalog       *plans.Catalog
+	contracts     *contracts.Store
+	coupons       *coupons.Store
+	changes       *proration.Store
+	credits       *credits.Ledger
+	invoices      *invoices.Store
+	issuer        invoices.Party
+	taxes         *tax.Store
+	trials        *trials.Store
+	mailer        *email.Sender
+	payments      payments.Provider
+	webhooks      *webhooks.Store
+	rounding      money.Rounding
 }
 
-func NewBillingService(db *pgxpool.Pool) *BillingService {
-	return &BillingService{db: db}
+func NewBillingService(db *pgxpool.Pool, provider payments.Provider) *BillingService {
+	return &BillingService{
+		db:            db,
+		dynamicConfig: NewDynamicConfigWriterFromEnv(),
+		catalog:       plans.NewCatalog(db),
+		contracts:     contracts.NewStore(db),
+		coupons:       coupons.NewStore(db),
+		changes:       proration.NewStore(db),
+		credits:       credits.NewLedger(db),
+		invoices:      invoices.NewStore(db),
+		issuer:        invoiceIssuerFromEnv(),
+		taxes:         tax.NewStore(db),
+		trials:        trials.NewStore(db),
+		mailer:        email.NewSenderFromEnv(),
+		payments:      provider,
+		webhooks:      webhooks.NewStore(db),
+		rounding:      billing.RoundingFromEnv(),
+	}
 }
 
 // Organization represents a customer organization
 type Organization struct {
-	ID               uuid.UUID `json:"id"`
-	Name             string    `json:"name"`
-	Slug             string    `json:"slug"`
-	StripeCustomerID string    `json:"stripe_customer_id,omitempty"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               uuid.UUID      `json:"id"`
+	Name             string         `json:"name"`
+	Slug             string         `json:"slug"`
+	StripeCustomerID string         `json:"stripe_customer_id,omitempty"`
+	BillingCurrency  money.Currency `json:"billing_currency"`
+	// BillingTimezone is the IANA time zone billing periods start in
+	BillingTimezone string    `json:"billing_timezone"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // Subscription represents a billing subscription
@@ -57,6 +99,10 @@ type Subscription struct {
 	RetainedStorageGB    decimal.Decimal `json:"retained_storage_gb"`
 	CurrentPeriodStart   time.Time       `json:"current_period_start"`
 	CurrentPeriodEnd     time.Time       `json:"current_period_end"`
+	TrialEndsAt          *time.Time      `json:"trial_ends_at,omitempty"`
+
+	// Plan change that takes effect at the end of the current period
+	ScheduledChange *proration.Change `json:"scheduled_change,omitempty"`
 }
 
 // UsageSummary represents usage for a period
@@ -65,54 +111,78 @@ type UsageSummary struct {
 	PeriodStart        time.Time       `json:"period_start"`
 	PeriodEnd          time.Time       `json:"period_end"`
 	TotalActions       int64           `json:"total_actions"`
+	ReplicatedActions  int64           `json:"replicated_actions"`
 	ActiveStorageGBH   decimal.Decimal `json:"active_storage_gbh"`
 	RetainedStorageGBH decimal.Decimal `json:"retained_storage_gbh"`
+	ArchivedStorageGBH decimal.Decimal `json:"archived_storage_gbh"`
+	Currency           money.Currency  `json:"currency"`
 	EstimatedCostCents int64           `json:"estimated_cost_cents"`
-}
 
-// Invoice represents a billing invoice
-type Invoice struct {
-	ID             uuid.UUID `json:"id"`
-	OrganizationID uuid.UUID `json:"organization_id"`
-	InvoiceNumber  string    `json:"invoice_number"`
-	PeriodStart    time.Time `json:"period_start"`
-	PeriodEnd      time.Time `json:"period_end"`
-	SubtotalCents  int64     `json:"subtotal_cents"`
-	TotalCents     int64     `json:"total_cents"`
-	Status         string    `json:"status"`
+	// End of a trial overlapping the period; usage before it is not billed
+	TrialEndsAt *time.Time `json:"trial_ends_at,omitempty"`
 }
 
 // Namespace represents a Temporal namespace
 type Namespace struct {
-	ID                uuid.UUID `json:"id"`
-	OrganizationID    uuid.UUID `json:"organization_id"`
-	Name              string    `json:"name"`
-	TemporalNamespace string    `json:"temporal_namespace"`
-	Region            string    `json:"region"`
-	Status            string    `json:"status"`
-	RetentionDays     int       `json:"retention_days"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                uuid.UUID      `json:"id"`
+	OrganizationID    uuid.UUID      `json:"organization_id"`
+	Name              string         `json:"name"`
+	TemporalNamespace string         `json:"temporal_namespace"`
+	Region            string         `json:"region"`
+	ClusterID         *uuid.UUID     `json:"cluster_id,omitempty"`
+	IsGlobal          bool           `json:"is_global"`
+	StandbyClusterIDs []uuid.UUID    `json:"standby_cluster_ids,omitempty"`
+	Status            string         `json:"status"`
+	RetentionDays     int            `json:"retention_days"`
+	Archival          ArchivalConfig `json:"archival"`
+	// SearchAttributes are custom search attributes (name to type) added when provisioning
+	SearchAttributes   map[string]string   `json:"search_attributes,omitempty"`
+	CertificateFilters []CertificateFilter `json:"certificate_filters,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
 }
 
 // CreateOrganization creates a new organization with Stripe customer
 func (s *BillingService) CreateOrganization(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Currency string `json:"currency"`
+		Timezone string `json:"timezone"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Create Stripe customer
-	params := &stripe.CustomerParams{
-		Name:  stripe.String(req.Name),
-		Email: stripe.String(req.Email),
+	// Organizations are invoiced in one currency, chosen at sign-up
+	currency, err := money.ParseCurrency(req.Currency)
+	if err != nil {
+		http.Error(w, "Invalid currency", http.StatusBadRequest)
+		return
+	}
+	// Billing periods start at midnight in the organization's time zone
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		http.Error(w, "Invalid timezone", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	free, err := s.catalog.Get(r.Context(), plans.FreePlanID, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	cust, err := customer.New(params)
+	if !free.Offers(currency) {
+		http.Error(w, fmt.Sprintf("Billing in %s is not supported", currency), http.StatusBadRequest)
+		return
+	}
+
+	// Create the customer at the payment provider
+	cust, err := s.payments.CreateCustomer(r.Context(), payments.CustomerParams{Name: req.Name, Email: req.Email})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create Stripe customer: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to create %s customer: %v", s.payments.Name(), err), http.StatusInternalServerError)
 		return
 	}
 
@@ -122,24 +192,25 @@ func (s *BillingService) CreateOrganization(w http.ResponseWriter, r *http.Reque
 		Name:             req.Name,
 		Slug:             generateSlug(req.Name),
 		StripeCustomerID: cust.ID,
-		CreatedAt:        time.Now(),
+		BillingCurrency:  currency,
+		BillingTimezone:  req.Timezone,
+		CreatedAt:        now,
 	}
 
 	_, err = s.db.Exec(r.Context(),
-		`INSERT INTO organizations (id, name, slug, stripe_customer_id, created_at) VALUES ($1, $2, $3, $4, $5)`,
-		org.ID, org.Name, org.Slug, org.StripeCustomerID, org.CreatedAt)
+		`INSERT INTO organizations (id, name, slug, stripe_customer_id, billing_currency, billing_timezone, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		org.ID, org.Name, org.Slug, org.StripeCustomerID, org.BillingCurrency, org.BillingTimezone, org.CreatedAt)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Create default subscription (free tier)
-	now := time.Now()
 	periodEnd := now.AddDate(0, 1, 0) // 1 month from now
 	_, err = s.db.Exec(r.Context(),
-		`INSERT INTO subscriptions (organization_id, plan, status, actions_included, active_storage_gb, retained_storage_gb, current_period_start, current_period_end)
-		 VALUES ($1, 'free', 'active', 100000, 0.1, 4, $2, $3)`,
-		org.ID, now, periodEnd)
+		`INSERT INTO subscriptions (organization_id, plan, status, actions_included, active_storage_gb, retained_storage_gb, current_period_start, current_period_end, billing_anchor)
+		 VALUES ($1, $2, 'active', $3, $4, $5, $6, $7, $6)`,
+		org.ID, free.ID, free.ActionsIncluded, free.ActiveStorageGB, free.RetainedStorageGB, now, periodEnd)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -161,8 +232,8 @@ func (s *BillingService) GetOrganization(w http.ResponseWriter, r *http.Request)
 	var org Organization
 	var stripeCustomerID *string
 	err = s.db.QueryRow(r.Context(),
-		`SELECT id, name, slug, stripe_customer_id, created_at FROM organizations WHERE id = $1`, id).
-		Scan(&org.ID, &org.Name, &org.Slug, &stripeCustomerID, &org.CreatedAt)
+		`SELECT id, name, slug, stripe_customer_id, billing_currency, billing_timezone, created_at FROM organizations WHERE id = $1`, id).
+		Scan(&org.ID, &org.Name, &org.Slug, &stripeCustomerID, &org.BillingCurrency, &org.BillingTimezone, &org.CreatedAt)
 	if err != nil {
 		http.Error(w, "Organization not found", http.StatusNotFound)
 		return
@@ -189,11 +260,11 @@ func (s *BillingService) GetSubscription(w http.ResponseWriter, r *http.Request)
 	var periodStart, periodEnd *time.Time
 	err = s.db.QueryRow(r.Context(),
 		`SELECT id, organization_id, stripe_subscription_id, plan, status, actions_included, 
-		        active_storage_gb, retained_storage_gb, current_period_start, current_period_end
+		        active_storage_gb, retained_storage_gb, current_period_start, current_period_end, trial_ends_at
 		 FROM subscriptions WHERE organization_id = $1`, orgID).
 		Scan(&sub.ID, &sub.OrganizationID, &stripeSubID, &sub.Plan, &sub.Status,
 			&sub.ActionsIncluded, &sub.ActiveStorageGB, &sub.RetainedStorageGB,
-			&periodStart, &periodEnd)
+			&periodStart, &periodEnd, &sub.TrialEndsAt)
 	if err != nil {
 		http.Error(w, `{"error": "Subscription not found"}`, http.StatusNotFound)
 		return
@@ -216,11 +287,21 @@ func (s *BillingService) GetSubscription(w http.ResponseWriter, r *http.Request)
 		sub.CurrentPeriodEnd = sub.CurrentPeriodStart.AddDate(0, 1, 0)
 	}
 
+	scheduled, err := s.changes.Scheduled(r.Context(), orgID)
+	if err != nil && !errors.Is(err, proration.ErrNoChangeScheduled) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		sub.ScheduledChange = &scheduled
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sub)
 }
 
-// UpdateSubscription upgrades/downgrades a subscription
+// UpdateSubscription upgrades/downgrades a subscription, immediately or at the end of
+// the current billing period
 func (s *BillingService) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orgID, err := uuid.Parse(vars["org_id"])
@@ -230,62 +311,55 @@ func (s *BillingService) UpdateSubscription(w http.ResponseWriter, r *http.Reque
 	}
 
 	var req struct {
-		Plan string `json:"plan"`
+		Plan        string `json:"plan"`
+		SkipTrial   bool   `json:"skip_trial"`
+		AtPeriodEnd bool   `json:"at_period_end"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Get plan limits
-	limits := getPlanLimits(req.Plan)
-
-	// Get organization's Stripe customer ID
-	var stripeCustomerID string
-	err = s.db.QueryRow(r.Context(),
-		`SELECT stripe_customer_id FROM organizations WHERE id = $1`, orgID).
-		Scan(&stripeCustomerID)
-	if err != nil {
-		http.Error(w, "Organization not found", http.StatusNotFound)
+	current, plan, ok := s.planChangeTarget(w, r, orgID, req.Plan)
+	if !ok {
 		return
 	}
 
-	// Create/update Stripe subscription if not free
-	var stripeSubID string
-	if req.Plan != "free" {
-		priceID := getPlanPriceID(req.Plan)
-		if priceID == "" {
-			http.Error(w, "Stripe price not configured for plan", http.StatusBadRequest)
-			return
-		}
-		params := &stripe.SubscriptionParams{
-			Customer: stripe.String(stripeCustomerID),
-			Items: []*stripe.SubscriptionItemsParams{
-				{Price: stripe.String(priceID)},
-			},
-		}
-		sub, err := subscription.New(params)
+	if req.AtPeriodEnd {
+		_, periodEnd := s.currentPeriod(r.Context(), orgID)
+		change, err := s.changes.Schedule(r.Context(), proration.Change{
+			OrganizationID: orgID,
+			FromPlan:       current.Plan,
+			ToPlan:         plan.ID,
+			EffectiveAt:    periodEnd,
+		})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create Stripe subscription: %v", err), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		stripeSubID = sub.ID
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"status": "scheduled", "scheduled_change": change})
+		return
 	}
 
-	// Update subscription in DB
-	_, err = s.db.Exec(r.Context(),
-		`UPDATE subscriptions SET plan = $1, stripe_subscription_id = $2, 
-		 actions_included = $3, active_storage_gb = $4, retained_storage_gb = $5,
-		 updated_at = NOW()
-		 WHERE organization_id = $6`,
-		req.Plan, stripeSubID, limits.ActionsIncluded, limits.ActiveStorageGB, limits.RetainedStorageGB, orgID)
+	next, err := s.changePlan(r.Context(), proration.Change{
+		OrganizationID: orgID,
+		FromPlan:       current.Plan,
+		ToPlan:         plan.ID,
+		EffectiveAt:    time.Now(),
+	}, plan, req.SkipTrial)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+	resp := map[string]any{"status": "updated", "subscription_status": next.Status}
+	if next.Status == trials.StatusTrialing {
+		resp["trial_ends_at"] = next.EndsAt
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
 // GetUsage retrieves usage for a period
@@ -315,10 +389,24 @@ func (s *BillingService) GetUsage(w http.ResponseWriter, r *http.Request) {
 
 	var summary UsageSummary
 	err = s.db.QueryRow(r.Context(),
-		`SELECT COALESCE(SUM(total_actions), 0), COALESCE(SUM(active_storage_gbh), 0), COALESCE(SUM(retained_storage_gbh), 0)
+		`SELECT COALESCE(SUM(total_actions), 0), COALESCE(SUM(total_replicated_actions), 0),
+		        COALESCE(SUM(active_storage_gbh), 0), COALESCE(SUM(retained_storage_gbh), 0),
+		        COALESCE(SUM(archived_storage_gbh), 0)
 		 FROM usage_aggregates WHERE organization_id = $1 AND period_start >= $2 AND period_end <= $3`,
 		orgID, start, end).
-		Scan(&summary.TotalActions, &summary.ActiveStorageGBH, &summary.RetainedStorageGBH)
+		Scan(&summary.TotalActions, &summary.ReplicatedActions, &summary.ActiveStorageGBH, &summary.RetainedStorageGBH,
+			&summary.ArchivedStorageGBH)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	plan, currency, err := s.billingPlan(r.Context(), orgID, start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redemption, err := s.coupons.Active(r.Context(), orgID, end)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -327,7 +415,18 @@ func (s *BillingService) GetUsage(w http.ResponseWriter, r *http.Request) {
 	summary.OrganizationID = orgID
 	summary.PeriodStart = start
 	summary.PeriodEnd = end
-	summary.EstimatedCostCents = calculateCost(summary)
+	summary.Currency = currency
+	billable, err := s.billableUsage(r.Context(), summary)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summary.TrialEndsAt = billable.TrialEndsAt
+	summary.EstimatedCostCents, err = calculateCost(billable, plan, currency, s.rounding, redemption)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(summary)
@@ -342,89 +441,50 @@ func (s *BillingService) GetCurrentUsage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get current period from subscription
-	var periodStart, periodEnd time.Time
-	err = s.db.QueryRow(r.Context(),
-		`SELECT current_period_start, current_period_end FROM subscriptions WHERE organization_id = $1`, orgID).
-		Scan(&periodStart, &periodEnd)
-	if err != nil {
-		// Default to current month
-		now := time.Now()
-		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-		periodEnd = periodStart.AddDate(0, 1, 0)
-	}
+	periodStart, periodEnd := s.currentPeriod(r.Context(), orgID)
 
 	var summary UsageSummary
 	err = s.db.QueryRow(r.Context(),
-		`SELECT COALESCE(SUM(total_actions), 0), COALESCE(SUM(active_storage_gbh), 0), COALESCE(SUM(retained_storage_gbh), 0)
+		`SELECT COALESCE(SUM(total_actions), 0), COALESCE(SUM(total_replicated_actions), 0),
+		        COALESCE(SUM(active_storage_gbh), 0), COALESCE(SUM(retained_storage_gbh), 0),
+		        COALESCE(SUM(archived_storage_gbh), 0)
 		 FROM usage_aggregates WHERE organization_id = $1 AND period_start >= $2 AND period_end <= $3`,
 		orgID, periodStart, periodEnd).
-		Scan(&summary.TotalActions, &summary.ActiveStorageGBH, &summary.RetainedStorageGBH)
+		Scan(&summary.TotalActions, &summary.ReplicatedActions, &summary.ActiveStorageGBH, &summary.RetainedStorageGBH,
+			&summary.ArchivedStorageGBH)
 	if err != nil {
 		summary = UsageSummary{}
 	}
 
-	summary.OrganizationID = orgID
-	summary.PeriodStart = periodStart
-	summary.PeriodEnd = periodEnd
-	summary.EstimatedCostCents = calculateCost(summary)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(summary)
-}
-
-// ListInvoices lists invoices for an organization
-func (s *BillingService) ListInvoices(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	orgID, err := uuid.Parse(vars["org_id"])
+	plan, currency, err := s.billingPlan(r.Context(), orgID, periodStart, periodEnd)
 	if err != nil {
-		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	rows, err := s.db.Query(r.Context(),
-		`SELECT id, organization_id, invoice_number, period_start, period_end, subtotal_cents, total_cents, status
-		 FROM invoices WHERE organization_id = $1 ORDER BY created_at DESC LIMIT 50`, orgID)
+	redemption, err := s.coupons.Active(r.Context(), orgID, periodEnd)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	invoices := make([]Invoice, 0) // Initialize as empty slice, not nil
-	for rows.Next() {
-		var inv Invoice
-		rows.Scan(&inv.ID, &inv.OrganizationID, &inv.InvoiceNumber, &inv.PeriodStart, &inv.PeriodEnd,
-			&inv.SubtotalCents, &inv.TotalCents, &inv.Status)
-		invoices = append(invoices, inv)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(invoices)
-}
 
-// GetInvoice retrieves a specific invoice
-func (s *BillingService) GetInvoice(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := uuid.Parse(vars["id"])
+	summary.OrganizationID = orgID
+	summary.PeriodStart = periodStart
+	summary.PeriodEnd = periodEnd
+	summary.Currency = currency
+	billable, err := s.billableUsage(r.Context(), summary)
 	if err != nil {
-		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	var inv Invoice
-	err = s.db.QueryRow(r.Context(),
-		`SELECT id, organization_id, invoice_number, period_start, period_end, subtotal_cents, total_cents, status
-		 FROM invoices WHERE id = $1`, id).
-		Scan(&inv.ID, &inv.OrganizationID, &inv.InvoiceNumber, &inv.PeriodStart, &inv.PeriodEnd,
-			&inv.SubtotalCents, &inv.TotalCents, &inv.Status)
+	summary.TrialEndsAt = billable.TrialEndsAt
+	summary.EstimatedCostCents, err = calculateCost(billable, plan, currency, s.rounding, redemption)
 	if err != nil {
-		http.Error(w, "Invoice not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(inv)
+	json.NewEncoder(w).Encode(summary)
 }
 
 // ListNamespaces lists namespaces for an organization
@@ -437,8 +497,7 @@ func (s *BillingService) ListNamespaces(w http.ResponseWriter, r *http.Request)
 	}
 
 	rows, err := s.db.Query(r.Context(),
-		`SELECT id, organization_id, name, temporal_namespace, region, status, retention_days, created_at
-		 FROM namespaces WHERE organization_id = $1`, orgID)
+		`SELECT `+namespaceColumns+` FROM namespaces n WHERE n.organization_id = $1`, orgID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -447,8 +506,10 @@ func (s *BillingService) ListNamespaces(w http.ResponseWriter, r *http.Request)
 
 	var namespaces []Namespace
 	for rows.Next() {
-		var ns Namespace
-		rows.Scan(&ns.ID, &ns.OrganizationID, &ns.Name, &ns.TemporalNamespace, &ns.Region, &ns.Status, &ns.RetentionDays, &ns.CreatedAt)
+		ns, err := scanNamespace(rows)
+		if err != nil {
+			continue
+		}
 		namespaces = append(namespaces, ns)
 	}
 
@@ -456,6 +517,36 @@ func (s *BillingService) ListNamespaces(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(namespaces)
 }
 
+// namespaceColumns are the columns read by scanNamespace, for queries aliasing namespaces as n
+const namespaceColumns = `n.id, n.organization_id, n.name, n.temporal_namespace, n.region, n.cluster_id, n.is_global,
+	ARRAY(SELECT nc.cluster_id::text FROM namespace_clusters nc
+	      WHERE nc.namespace_id = n.id AND nc.cluster_id IS DISTINCT FROM n.cluster_id ORDER BY 1),
+	n.status, n.retention_days, n.history_archival_state, COALESCE(n.history_archival_uri, ''),
+	n.visibility_archival_state, COALESCE(n.visibility_archival_uri, ''),
+	COALESCE(n.certificate_filters, '[]'::jsonb), n.created_at`
+
+func scanNamespace(row pgx.Row) (Namespace, error) {
+	var ns Namespace
+	var standbys []string
+	err := row.Scan(&ns.ID, &ns.OrganizationID, &ns.Name, &ns.TemporalNamespace, &ns.Region, &ns.ClusterID, &ns.IsGlobal,
+		&standbys, &ns.Status, &ns.RetentionDays, &ns.Archival.HistoryState, &ns.Archival.HistoryURI,
+		&ns.Archival.VisibilityState, &ns.Archival.VisibilityURI, &ns.CertificateFilters, &ns.CreatedAt)
+	if err != nil {
+		return ns, err
+	}
+	for _, id := range standbys {
+		if clusterID, err := uuid.Parse(id); err == nil {
+			ns.StandbyClusterIDs = append(ns.StandbyClusterIDs, clusterID)
+		}
+	}
+	return ns, nil
+}
+
+// getNamespace loads a single namespace by ID
+func (s *BillingService) getNamespace(ctx context.Context, id uuid.UUID) (Namespace, error) {
+	return scanNamespace(s.db.QueryRow(ctx, `SELECT `+namespaceColumns+` FROM namespaces n WHERE n.id = $1`, id))
+}
+
 // CreateNamespace creates a new namespace
 func (s *BillingService) CreateNamespace(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -466,9 +557,12 @@ func (s *BillingService) CreateNamespace(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		Name          string `json:"name"`
-		Region        string `json:"region"`
-		RetentionDays int    `json:"retention_days"`
+		Name           string          `json:"name"`
+		Region         string          `json:"region"`
+		RetentionDays  int             `json:"retention_days"`
+		IsGlobal       bool            `json:"is_global"`
+		StandbyRegions []string        `json:"standby_regions"`
+		Archival       *ArchivalConfig `json:"archival"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -481,6 +575,15 @@ func (s *BillingService) CreateNamespace(w http.ResponseWriter, r *http.Request)
 	if req.Region == "" {
 		req.Region = "us-east-1"
 	}
+	var archival ArchivalConfig
+	if req.Archival != nil {
+		archival = *req.Archival
+	}
+	archival = archival.withDefaults()
+	if err := archival.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	ns := Namespace{
 		ID:                uuid.New(),
@@ -488,103 +591,102 @@ func (s *BillingService) CreateNamespace(w http.ResponseWriter, r *http.Request)
 		Name:              req.Name,
 		TemporalNamespace: fmt.Sprintf("%s.%s", orgID.String()[:8], req.Name),
 		Region:            req.Region,
+		IsGlobal:          req.IsGlobal,
 		Status:            "provisioning",
 		RetentionDays:     req.RetentionDays,
+		Archival:          archival,
 		CreatedAt:         time.Now(),
 	}
 
-	_, err = s.db.Exec(r.Context(),
-		`INSERT INTO namespaces (id, organization_id, name, temporal_namespace, region, status, retention_days, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		ns.ID, ns.OrganizationID, ns.Name, ns.TemporalNamespace, ns.Region, ns.Status, ns.RetentionDays, ns.CreatedAt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.createNamespace(r.Context(), &ns, req.StandbyRegions, nil); err != nil {
+		writeNamespaceError(w, err)
 		return
 	}
 
-	go s.provisionNamespace(r.Context(), ns)
+	go s.provisionNamespace(context.Background(), ns)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(ns)
 }
 
-// HandleStripeWebhook handles Stripe webhook events
-func (s *BillingService) HandleStripeWebhook(w http.ResponseWriter, r *http.Request) {
-	payload, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
-	if webhookSecret == "" {
-		http.Error(w, "Stripe webhook secret not configured", http.StatusInternalServerError)
-		return
+// createNamespace places a namespace on its clusters and stores it within the plan quota,
+// along with its grants. A namespace with a ClusterID already set keeps that cluster.
+func (s *BillingService) createNamespace(ctx context.Context, ns *Namespace, standbyRegions []string, grants []NamespaceGrant) error {
+	if ns.ClusterID == nil {
+		cluster, err := s.placeNamespace(ctx, ns.Region)
+		if err != nil {
+			return fmt.Errorf("region %s: %w", ns.Region, err)
+		}
+		if cluster != nil {
+			ns.ClusterID = &cluster.ID
+		}
 	}
 
-	event, err := webhook.ConstructEvent(payload, r.Header.Get("Stripe-Signature"), webhookSecret)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	// Replicated namespaces get one standby cluster per standby region
+	if ns.IsGlobal {
+		standbys, err := s.placeStandbyClusters(ctx, ns.Region, standbyRegions)
+		if err != nil {
+			return err
+		}
+		ns.StandbyClusterIDs = standbys
 	}
 
-	switch event.Type {
-	case "invoice.paid":
-		var inv stripe.Invoice
-		json.Unmarshal(event.Data.Raw, &inv)
-		s.handleInvoicePaid(r.Context(), &inv)
-
-	case "invoice.payment_failed":
-		var inv stripe.Invoice
-		json.Unmarshal(event.Data.Raw, &inv)
-		s.handlePaymentFailed(r.Context(), &inv)
+	return s.insertNamespaceWithinQuota(ctx, *ns, grants)
+}
 
-	case "customer.subscription.updated":
-		var sub stripe.Subscription
-		json.Unmarshal(event.Data.Raw, &sub)
-		s.handleSubscriptionUpdated(r.Context(), &sub)
+// writeNamespaceError maps namespace placement and quota errors to HTTP responses
+func writeNamespaceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNamespaceLimitReached), errors.Is(err, errReplicationNotInPlan):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errNoClusterAvailable), errors.Is(err, errClusterUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, errRegionNotServed), errors.Is(err, errInvalidStandbyRegions),
+		errors.Is(err, errReplicationNeedsCluster), errors.Is(err, errRetentionTooLong),
+		errors.Is(err, errClusterNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
-		log.Printf("Unhandled Stripe webhook type: %s", event.Type)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-
-	w.WriteHeader(http.StatusOK)
-}
-
-func (s *BillingService) handleInvoicePaid(ctx context.Context, inv *stripe.Invoice) {
-	// Update invoice status in DB
-	s.db.Exec(ctx,
-		`UPDATE invoices SET status = 'paid', paid_at = NOW() WHERE stripe_invoice_id = $1`,
-		inv.ID)
 }
 
-func (s *BillingService) handlePaymentFailed(ctx context.Context, inv *stripe.Invoice) {
-	// Update invoice status
-	s.db.Exec(ctx,
-		`UPDATE invoices SET status = 'payment_failed' WHERE stripe_invoice_id = $1`,
-		inv.ID)
-	// TODO: Send notification, potentially suspend service
-}
+// registerNamespaceRequest builds the Temporal registration for a namespace
+func (s *BillingService) registerNamespaceRequest(ctx context.Context, ns Namespace) (*workflowservice.RegisterNamespaceRequest, error) {
+	req := &workflowservice.RegisterNamespaceRequest{
+		Namespace:                        ns.TemporalNamespace,
+		WorkflowExecutionRetentionPeriod: durationpb.New(time.Duration(ns.RetentionDays) * 24 * time.Hour),
+		HistoryArchivalState:             archivalState(ns.Archival.HistoryState),
+		HistoryArchivalUri:               ns.Archival.HistoryURI,
+		VisibilityArchivalState:          archivalState(ns.Archival.VisibilityState),
+		VisibilityArchivalUri:            ns.Archival.VisibilityURI,
+	}
+	if !ns.IsGlobal {
+		return req, nil
+	}
 
-func (s *BillingService) handleSubscriptionUpdated(ctx context.Context, sub *stripe.Subscription) {
-	// Update subscription status
-	s.db.Exec(ctx,
-		`UPDATE subscriptions SET status = $1, current_period_start = $2, current_period_end = $3
-		 WHERE stripe_subscription_id = $4`,
-		sub.Status, time.Unix(sub.CurrentPeriodStart, 0), time.Unix(sub.CurrentPeriodEnd, 0), sub.ID)
+	active, names, err := s.replicationClusterNames(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	req.IsGlobalNamespace = true
+	req.ActiveClusterName = active
+	for _, name := range names {
+		req.Clusters = append(req.Clusters, &replicationpb.ClusterReplicationConfig{ClusterName: name})
+	}
+	return req, nil
 }
 
 // provisionNamespace calls the Temporal cluster to register a namespace and updates status in DB.
 func (s *BillingService) provisionNamespace(ctx context.Context, ns Namespace) {
-	endpoint := os.Getenv("TEMPORAL_GRPC_ENDPOINT")
-	if endpoint == "" {
-		endpoint = "localhost:7233"
+	endpoint, err := s.clusterEndpoint(ctx, ns.ClusterID)
+	if err != nil {
+		log.Printf("Namespace provision failed for %s: %v", ns.TemporalNamespace, err)
+		s.db.Exec(context.Background(), `UPDATE namespaces SET status = 'failed' WHERE id = $1`, ns.ID)
+		return
 	}
 
-	dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
-	defer cancel()
-
-	conn, err := grpc.DialContext(dialCtx, endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := dialTemporal(ctx, endpoint)
 	if err != nil {
 		log.Printf("Namespace provision dial failed for %s: %v", ns.TemporalNamespace, err)
 		s.db.Exec(context.Background(), `UPDATE namespaces SET status = 'failed' WHERE id = $1`, ns.ID)
@@ -593,77 +695,121 @@ func (s *BillingService) provisionNamespace(ctx context.Context, ns Namespace) {
 	defer conn.Close()
 
 	client := workflowservice.NewWorkflowServiceClient(conn)
-	retention := durationpb.New(time.Duration(ns.RetentionDays) * 24 * time.Hour)
 
-	_, err = client.RegisterNamespace(ctx, &workflowservice.RegisterNamespaceRequest{
-		Namespace:                        ns.TemporalNamespace,
-		WorkflowExecutionRetentionPeriod: retention,
-	})
+	req, err := s.registerNamespaceRequest(ctx, ns)
+	if err == nil {
+		_, err = client.RegisterNamespace(ctx, req)
+	}
 	if err != nil {
 		log.Printf("Namespace provision failed for %s: %v", ns.TemporalNamespace, err)
 		s.db.Exec(context.Background(), `UPDATE namespaces SET status = 'failed', updated_at = NOW() WHERE id = $1`, ns.ID)
 		return
 	}
 
+	if len(ns.SearchAttributes) > 0 {
+		if err := addSearchAttributes(ctx, conn, ns.TemporalNamespace, ns.SearchAttributes); err != nil {
+			log.Printf("Namespace provision failed to add search attributes for %s: %v", ns.TemporalNamespace, err)
+			s.db.Exec(context.Background(), `UPDATE namespaces SET status = 'failed', updated_at = NOW() WHERE id = $1`, ns.ID)
+			return
+		}
+	}
+
 	_, _ = s.db.Exec(context.Background(),
 		`UPDATE namespaces SET status = 'active', updated_at = NOW() WHERE id = $1`, ns.ID)
 	log.Printf("Provisioned namespace %s for org %s", ns.TemporalNamespace, ns.OrganizationID.String())
+
+	if err := s.syncDynamicConfig(context.Background()); err != nil {
+		log.Printf("Failed to sync dynamic config for %s: %v", ns.TemporalNamespace, err)
+	}
 }
 
 // Helper functions
 
-type PlanLimits struct {
-	ActionsIncluded   int64
-	ActiveStorageGB   decimal.Decimal
-	RetainedStorageGB decimal.Decimal
-	BasePriceCents    int64
-}
-
-func getPlanLimits(plan string) PlanLimits {
-	limits := map[string]PlanLimits{
-		"free":       {100000, decimal.NewFromFloat(0.1), decimal.NewFromInt(4), 0},
-		"essential":  {1000000, decimal.NewFromInt(1), decimal.NewFromInt(40), 10000},
-		"business":   {2500000, decimal.NewFromFloat(2.5), decimal.NewFromInt(100), 50000},
-		"enterprise": {10000000, decimal.NewFromInt(10), decimal.NewFromInt(400), 0}, // Custom pricing
-	}
-	if l, ok := limits[plan]; ok {
-		return l
+// stripePriceID returns the Stripe price of a plan in a currency. STRIPE_PRICE_<PLAN>
+// (STRIPE_PRICE_<PLAN>_<CURRENCY> outside USD) overrides the catalog so each
+// environment can point at its own Stripe account.
+func stripePriceID(plan plans.Plan, currency money.Currency) string {
+	envKey := fmt.Sprintf("STRIPE_PRICE_%s", strings.ToUpper(plan.ID))
+	if currency != money.USD {
+		envKey += "_" + string(currency)
 	}
-	return limits["free"]
-}
-
-func getPlanPriceID(plan string) string {
-	// Prefer environment overrides (e.g., STRIPE_PRICE_ESSENTIAL)
-	envKey := fmt.Sprintf("STRIPE_PRICE_%s", strings.ToUpper(plan))
 	if price := os.Getenv(envKey); price != "" {
 		return price
 	}
+	cp, _, err := plan.PricesIn(currency)
+	if err != nil {
+		return ""
+	}
+	return cp.StripePriceID
+}
 
-	// Fallback to static placeholders for dev
-	prices := map[string]string{
-		"essential":  "price_essential_monthly",
-		"business":   "price_business_monthly",
-		"enterprise": "price_enterprise_monthly",
+// calculateCost estimates the bill for a usage summary. It prices usage exactly as
+// CalculateMonthlyBill does, base price, trial and coupon discount included.
+func calculateCost(usage UsageSummary, plan plans.Plan, currency money.Currency, rounding money.Rounding, redemption *coupons.Redemption) (int64, error) {
+	bill, err := billing.NewMonthlyBill(usage.OrganizationID, usage.PeriodStart, usage.PeriodEnd, plan, currency, usage.pricingUsage(), rounding)
+	if err != nil {
+		return 0, err
 	}
-	return prices[plan]
+	bill.ApplyTrial(usage.TrialEndsAt)
+	bill.ApplyDiscount(redemption, plan.ID)
+	return bill.TotalCents, nil
 }
 
-func calculateCost(usage UsageSummary) int64 {
-	var cost int64
+func (u UsageSummary) pricingUsage() pricing.Usage {
+	return pricing.Usage{
+		Actions:            u.TotalActions,
+		ReplicatedActions:  u.ReplicatedActions,
+		ActiveStorageGBH:   u.ActiveStorageGBH,
+		RetainedStorageGBH: u.RetainedStorageGBH,
+		ArchivedStorageGBH: u.ArchivedStorageGBH,
+	}
+}
 
-	// Actions: $25-50 per million (using $25 for simplicity)
-	actionMillions := usage.TotalActions / 1000000
-	cost += actionMillions * 2500 // $25 in cents
+// subscriptionPlan returns the plan version an organization is billed on at the given
+// time, priced on its contract if one is in effect, and its billing currency.
+// Organizations without a subscription are on the free plan.
+func (s *BillingService) subscriptionPlan(ctx context.Context, orgID uuid.UUID, at time.Time) (plans.Plan, money.Currency, error) {
+	return s.billingPlan(ctx, orgID, at, at)
+}
 
-	// Active storage: $0.042/GBh
-	activeStorageCost := usage.ActiveStorageGBH.Mul(decimal.NewFromFloat(4.2)).IntPart()
-	cost += activeStorageCost
+// billingPlan returns the plan a billing period is charged on, prorated across plan
+// changes made during it as CalculateMonthlyBill does, and the billing currency
+func (s *BillingService) billingPlan(ctx context.Context, orgID uuid.UUID, periodStart, periodEnd time.Time) (plans.Plan, money.Currency, error) {
+	at := periodStart
+	planID := plans.FreePlanID
+	currency := money.USD
+	err := s.db.QueryRow(ctx,
+		`SELECT o.billing_currency, COALESCE(s.plan, $2)
+		 FROM organizations o LEFT JOIN subscriptions s ON s.organization_id = o.id
+		 WHERE o.id = $1`, orgID, plans.FreePlanID).Scan(&currency, &planID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return plans.Plan{}, "", fmt.Errorf("failed to get subscription: %w", err)
+	}
+	plan, err := s.catalog.Lookup(ctx, planID, at)
+	if err != nil {
+		return plans.Plan{}, "", err
+	}
 
-	// Retained storage: $0.00105/GBh
-	retainedStorageCost := usage.RetainedStorageGBH.Mul(decimal.NewFromFloat(0.105)).IntPart()
-	cost += retainedStorageCost
+	changes, err := s.changes.Since(ctx, orgID, periodStart)
+	if err != nil {
+		return plans.Plan{}, "", err
+	}
+	segments, err := proration.Segments(plan, changes, periodStart, periodEnd, func(id string) (plans.Plan, error) {
+		return s.catalog.Lookup(ctx, id, at)
+	})
+	if err != nil {
+		return plans.Plan{}, "", err
+	}
+	plan = proration.Blend(segments, periodStart, periodEnd)
 
-	return cost
+	contract, err := s.contracts.Active(ctx, orgID, at)
+	if err != nil {
+		return plans.Plan{}, "", fmt.Errorf("failed to get contract: %w", err)
+	}
+	if contract != nil {
+		plan = contract.Apply(plan)
+	}
+	return plan, currency, nil
 }
 
 func generateSlug(name string) string {
@@ -687,6 +833,7 @@ type CheckoutRequest struct {
 	PlanID         string `json:"plan_id"`
 	SuccessURL     string `json:"success_url"`
 	CancelURL      string `json:"cancel_url"`
+	SkipTrial      bool   `json:"skip_trial"`
 }
 
 // CreateCheckoutSession creates a Stripe checkout session for subscription
@@ -697,55 +844,62 @@ func (s *BillingService) CreateCheckoutSession(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Map plan ID to Stripe price ID
-	priceID := os.Getenv("STRIPE_PRICE_" + req.PlanID)
-	if priceID == "" {
-		// Use default price IDs based on plan
-		switch req.PlanID {
-		case "essential":
-			priceID = os.Getenv("STRIPE_PRICE_ESSENTIAL")
-		case "business":
-			priceID = os.Getenv("STRIPE_PRICE_BUSINESS")
-		default:
-			http.Error(w, "Invalid plan ID", http.StatusBadRequest)
-			return
-		}
+	// Only self-serve plans can be bought through checkout
+	plan, err := s.catalog.Get(r.Context(), req.PlanID, time.Now())
+	if errors.Is(err, plans.ErrPlanNotFound) || (err == nil && !plan.SelfServe) {
+		http.Error(w, "Invalid plan ID", http.StatusBadRequest)
+		return
 	}
-
-	if priceID == "" {
-		http.Error(w, "Stripe price not configured for this plan", http.StatusInternalServerError)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Get or create Stripe customer
 	var stripeCustomerID string
+	currency := money.USD
 	orgID, err := uuid.Parse(req.OrganizationID)
 	if err == nil {
 		var org Organization
 		err = s.db.QueryRow(r.Context(),
-			"SELECT stripe_customer_id FROM organizations WHERE id = $1",
+			"SELECT stripe_customer_id, billing_currency FROM organizations WHERE id = $1",
 			orgID,
-		).Scan(&org.StripeCustomerID)
-		if err == nil && org.StripeCustomerID != "" {
+		).Scan(&org.StripeCustomerID, &org.BillingCurrency)
+		if err == nil {
+			currency = org.BillingCurrency
 			stripeCustomerID = org.StripeCustomerID
 		}
 	}
+	if !plan.Offers(currency) {
+		http.Error(w, fmt.Sprintf("Plan is not offered in %s", currency), http.StatusBadRequest)
+		return
+	}
+
+	priceID := stripePriceID(plan, currency)
 
-	// Create Stripe checkout session
-	params := &stripe.CheckoutSessionParams{
-		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			{
-				Price:    stripe.String(priceID),
-				Quantity: stripe.Int64(1),
-			},
-		},
-		SuccessURL: stripe.String(req.SuccessURL),
-		CancelURL:  stripe.String(req.CancelURL),
+	if priceID == "" {
+		http.Error(w, "Stripe price not configured for this plan", http.StatusInternalServerError)
+		return
 	}
 
-	if stripeCustomerID != "" {
-		params.Customer = stripe.String(stripeCustomerID)
+	// Create checkout session
+	params := payments.CheckoutParams{
+		CustomerID: stripeCustomerID,
+		PriceID:    priceID,
+		SuccessURL: req.SuccessURL,
+		CancelURL:  req.CancelURL,
+	}
+
+	// Organizations upgrading from free start with the plan's trial
+	if plan.TrialDays > 0 && !req.SkipTrial {
+		current, err := s.trials.Get(r.Context(), orgID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if trials.Eligible(current) {
+			params.TrialDays = plan.TrialDays
+		}
 	}
 
 	// Add metadata
@@ -754,8 +908,12 @@ func (s *BillingService) CreateCheckoutSession(w http.ResponseWriter, r *http.Re
 		"plan_id":         req.PlanID,
 	}
 
-	checkoutSession, err := checkoutsession.New(params)
+	checkoutSession, err := s.payments.CreateCheckoutSession(r.Context(), params)
 	if err != nil {
+		if errors.Is(err, payments.ErrUnsupported) {
+			http.Error(w, fmt.Sprintf("%s does not support checkout", s.payments.Name()), http.StatusNotImplemented)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to create checkout session: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -769,104 +927,59 @@ func (s *BillingService) CreateCheckoutSession(w http.ResponseWriter, r *http.Re
 
 // PlanResponse represents a pricing plan for API response
 type PlanResponse struct {
-	ID                string   `json:"id"`
-	Name              string   `json:"name"`
-	Description       string   `json:"description"`
-	BasePriceCents    int64    `json:"base_price_cents"`
-	BasePriceDisplay  string   `json:"base_price_display"`
-	ActionsIncluded   int64    `json:"actions_included"`
-	ActiveStorageGB   float64  `json:"active_storage_gb"`
-	RetainedStorageGB float64  `json:"retained_storage_gb"`
-	Features          []string `json:"features"`
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	Description       string         `json:"description"`
+	Currency          money.Currency `json:"currency"`
+	BasePriceCents    int64          `json:"base_price_cents"`
+	BasePriceDisplay  string         `json:"base_price_display"`
+	ActionsIncluded   int64          `json:"actions_included"`
+	ActiveStorageGB   float64        `json:"active_storage_gb"`
+	RetainedStorageGB float64        `json:"retained_storage_gb"`
+	TrialDays         int            `json:"trial_days,omitempty"`
+	Features          []string       `json:"features"`
 }
 
 // GetPlans returns all available pricing plans - matching temporal.io/pricing
 func (s *BillingService) GetPlans(w http.ResponseWriter, r *http.Request) {
-	plans := []PlanResponse{
-		{
-			ID:                "essential",
-			Name:              "Essential",
-			Description:       "For basic workflows",
-			BasePriceCents:    10000,
-			BasePriceDisplay:  "Starting at $100/mo",
-			ActionsIncluded:   1000000,
-			ActiveStorageGB:   1,
-			RetainedStorageGB: 40,
-			Features: []string{
-				"1M Actions included",
-				"1 GB Active Storage",
-				"40 GB Retained Storage",
-				"99.9% SLA, 99.99% HA Options",
-				"Multi-Cloud & Multi-Region",
-				"User Roles & API Keys",
-				"Audit Logging",
-				"1 Business Day P0 Response",
-			},
-		},
-		{
-			ID:                "business",
-			Name:              "Business",
-			Description:       "For teams scaling Temporal",
-			BasePriceCents:    50000,
-			BasePriceDisplay:  "Starting at $500/mo",
-			ActionsIncluded:   2500000,
-			ActiveStorageGB:   2.5,
-			RetainedStorageGB: 100,
-			Features: []string{
-				"2.5M Actions included",
-				"2.5 GB Active Storage",
-				"100 GB Retained Storage",
-				"Everything in Essentials",
-				"SAML SSO Included",
-				"SCIM Add-on",
-				"2 Business Hours P0 Response",
-				"Workflow Troubleshooting",
-			},
-		},
-		{
-			ID:                "enterprise",
-			Name:              "Enterprise",
-			Description:       "For enterprise and mission critical",
-			BasePriceCents:    0,
-			BasePriceDisplay:  "Contact Sales",
-			ActionsIncluded:   10000000,
-			ActiveStorageGB:   10,
-			RetainedStorageGB: 400,
-			Features: []string{
-				"10M Actions included",
-				"10 GB Active Storage",
-				"400 GB Retained Storage",
-				"Everything in Business",
-				"SCIM Included",
-				"24/7, 30 Minute P0 Response",
-				"Technical Onboarding",
-				"Design Review",
-			},
-		},
-		{
-			ID:                "mission_critical",
-			Name:              "Mission Critical",
-			Description:       "For mission critical workloads",
-			BasePriceCents:    0,
-			BasePriceDisplay:  "Contact Sales",
-			ActionsIncluded:   10000000,
-			ActiveStorageGB:   10,
-			RetainedStorageGB: 400,
-			Features: []string{
-				"10M+ Actions",
-				"10+ GB Active Storage",
-				"400+ GB Retained Storage",
-				"Everything in Enterprise",
-				"Designated Support Engineer",
-				"Worker Tuning",
-				"Cost Reviews",
-				"Security Reviews",
-			},
-		},
+	currency, err := money.ParseCurrency(r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, "Invalid currency", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := s.catalog.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]PlanResponse, 0)
+	for _, p := range plans.Current(versions, time.Now()) {
+		if !p.Public || !p.Offers(currency) {
+			continue
+		}
+		price, _, err := p.PricesIn(currency)
+		if err != nil {
+			continue
+		}
+		resp = append(resp, PlanResponse{
+			ID:                p.ID,
+			Name:              p.Name,
+			Description:       p.Description,
+			Currency:          currency,
+			BasePriceCents:    price.BasePriceMinor,
+			BasePriceDisplay:  price.BasePriceDisplay,
+			ActionsIncluded:   p.ActionsIncluded,
+			ActiveStorageGB:   p.ActiveStorageGB.InexactFloat64(),
+			RetainedStorageGB: p.RetainedStorageGB.InexactFloat64(),
+			TrialDays:         p.TrialDays,
+			Features:          p.Features,
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(plans)
+	json.NewEncoder(w).Encode(resp)
 }
 
 // MagicLinkRequest represents a magic link request
@@ -3,16 +3,23 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
 )
 
 // CreateOrganizationRequest for testing
@@ -244,7 +251,7 @@ func TestUsageSummaryCalculation(t *testing.T) {
 	}
 
 	// Calculate estimated cost for Essential plan
-	plan := Plans["essential"]
+	plan := plans.Effective(plans.Defaults(), "essential", time.Now())
 
 	// Action overage: 0.5M over -> rounds to 1M = $25
 	var actionCost int64 = 0
@@ -283,3 +290,102 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func TestPaymentWebhook(t *testing.T) {
+	fake := payments.NewFake("whsec_test")
+	svc := &BillingService{payments: fake}
+
+	// Checkouts not created for an organization are acknowledged without touching the database
+	fake.SetWebhookHandler(http.HandlerFunc(svc.HandlePaymentWebhook))
+	cs, _ := fake.CreateCheckoutSession(context.Background(), payments.CheckoutParams{PriceID: "price_essential"})
+	if _, err := fake.CompleteCheckout(cs.ID); err != nil {
+		t.Errorf("Expected the webhook to be acknowledged, got %v", err)
+	}
+
+	// Events that cannot be recorded fail, so the provider delivers them again
+	cust, _ := fake.CreateCustomer(context.Background(), payments.CustomerParams{})
+	inv, _ := fake.CreateInvoice(context.Background(), payments.InvoiceParams{CustomerID: cust.ID, Currency: "USD",
+		Items: []payments.InvoiceItem{{AmountCents: 5000}}})
+	if err := fake.PayInvoice(inv.ID); err == nil || !strings.Contains(err.Error(), "returned 500") {
+		t.Errorf("Expected status 500 without a database, got %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhooks/stripe", bytes.NewReader([]byte(`{"Type":"invoice.paid"}`)))
+	req.Header.Set(payments.FakeSignatureHeader, "forged")
+	w := httptest.NewRecorder()
+	svc.HandlePaymentWebhook(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a forged webhook, got %d", w.Code)
+	}
+
+	// Events without their object are rejected rather than retried
+	unsigned := payments.NewFake("")
+	payload := []byte(`{"ID":"evt_1","Type":"invoice.paid"}`)
+	mac := hmac.New(sha256.New, nil)
+	mac.Write(payload)
+	req = httptest.NewRequest("POST", "/webhooks/stripe", bytes.NewReader(payload))
+	req.Header.Set(payments.FakeSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	w = httptest.NewRecorder()
+	(&BillingService{payments: unsigned}).HandlePaymentWebhook(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invoice event without an invoice, got %d", w.Code)
+	}
+}
+
+func TestDownloadInvoice(t *testing.T) {
+	svc := &BillingService{db: nil}
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/invoices/{id}/download", svc.DownloadInvoice).Methods("GET")
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"unknown format", "/api/v1/invoices/" + uuid.New().String() + "/download?format=docx", http.StatusBadRequest},
+		{"invalid id", "/api/v1/invoices/not-a-uuid/download", http.StatusBadRequest},
+		{"missing invoice", "/api/v1/invoices/" + uuid.New().String() + "/download?format=html", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestTaxDetailsValidation(t *testing.T) {
+	svc := &BillingService{db: nil}
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/organizations/{org_id}/billing-address", svc.UpdateBillingAddress).Methods("PUT")
+	r.HandleFunc("/api/v1/organizations/{org_id}/tax-ids", svc.AddTaxID).Methods("POST")
+	r.HandleFunc("/api/v1/organizations/{org_id}/tax-ids/{id}", svc.DeleteTaxID).Methods("DELETE")
+	org := uuid.New().String()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"country name instead of code", "PUT", "/billing-address", `{"country":"Germany"}`, http.StatusBadRequest},
+		{"malformed EU VAT", "POST", "/tax-ids", `{"type":"eu_vat","value":"DE12345"}`, http.StatusBadRequest},
+		{"GB VAT failing its checksum", "POST", "/tax-ids", `{"type":"gb_vat","value":"GB999999974"}`, http.StatusBadRequest},
+		{"unsupported type", "POST", "/tax-ids", `{"type":"us_ein","value":"12-3456789"}`, http.StatusBadRequest},
+		{"unknown tax ID", "DELETE", "/tax-ids/" + uuid.New().String(), "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/organizations/"+org+tt.path, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/tax"
+)
+
+// GetTaxDetails returns an organization's billing address and tax IDs
+func (s *BillingService) GetTaxDetails(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	customer, err := s.taxes.Customer(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+// UpdateBillingAddress replaces an organization's billing address. Its country
+// decides which VAT or GST applies to future invoices.
+func (s *BillingService) UpdateBillingAddress(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req tax.Address
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	address, err := s.taxes.SetAddress(r.Context(), orgID, req)
+	if errors.Is(err, tax.ErrInvalidAddress) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(address)
+}
+
+// AddTaxID adds an EU VAT, GB VAT or ABN number to an organization. Businesses with
+// a tax ID in their own country are reverse charged when buying from abroad.
+func (s *BillingService) AddTaxID(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(mux.Vars(r)["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Type  tax.IDType `json:"type"`
+		Value string     `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := tax.ParseID(req.Type, req.Value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	err = s.db.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM organizations WHERE id = $1)`, orgID).Scan(&exists)
+	if err != nil || !exists {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	id, err := s.taxes.AddID(r.Context(), orgID, req.Type, req.Value)
+	if errors.Is(err, tax.ErrTaxIDExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(id)
+}
+
+// DeleteTaxID removes a tax ID from an organization
+func (s *BillingService) DeleteTaxID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, err := uuid.Parse(vars["org_id"])
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid tax ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.taxes.DeleteID(r.Context(), orgID, id)
+	if errors.Is(err, tax.ErrNotFound) {
+		http.Error(w, "Tax ID not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
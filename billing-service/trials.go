@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/trials"
+)
+
+// RunTrialTransitions periodically sends trial reminders and settles ended trials
+// until ctx is done
+func (s *BillingService) RunTrialTransitions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.remindTrials(ctx, time.Now())
+		s.settleTrials(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// remindTrials emails the billing admins of organizations whose trial ends soon
+func (s *BillingService) remindTrials(ctx context.Context, now time.Time) {
+	due, err := s.trials.DueForReminder(ctx, now, trials.DefaultReminderLead)
+	if err != nil {
+		log.Printf("Trial reminders failed to list trials: %v", err)
+		return
+	}
+
+	for _, t := range due {
+		to, err := s.billingAdmins(ctx, t.OrganizationID)
+		if err != nil {
+			log.Printf("Trial reminder for org %s failed to list admins: %v", t.OrganizationID, err)
+			continue
+		}
+		subject := fmt.Sprintf("Your Temporal Cloud trial ends on %s", t.EndsAt.UTC().Format("January 2"))
+		body := fmt.Sprintf("<h2>Your %s trial ends soon</h2>"+
+			"<p>The trial for %s ends on %s. Add a payment method to stay on the plan; "+
+			"otherwise the organization moves to the Free plan when the trial ends.</p>",
+			html.EscapeString(t.Plan), html.EscapeString(t.OrganizationName), t.EndsAt.UTC().Format("January 2, 2006 15:04 MST"))
+		if err := s.mailer.Send(ctx, to, subject, body); err != nil {
+			log.Printf("Trial reminder for org %s failed: %v", t.OrganizationID, err)
+			continue
+		}
+		if err := s.trials.MarkReminded(ctx, t.OrganizationID, now); err != nil {
+			log.Printf("Failed to record trial reminder for org %s: %v", t.OrganizationID, err)
+		}
+	}
+}
+
+// settleTrials converts ended trials that the payment provider charged and downgrades the rest to free
+func (s *BillingService) settleTrials(ctx context.Context, now time.Time) {
+	ended, err := s.trials.Ended(ctx, now)
+	if err != nil {
+		log.Printf("Trial settlement failed to list trials: %v", err)
+		return
+	}
+
+	for _, t := range ended {
+		status := ""
+		if t.StripeSubscriptionID != "" {
+			sub, err := s.payments.GetSubscription(ctx, t.StripeSubscriptionID)
+			if err != nil {
+				log.Printf("Trial settlement for org %s failed to get %s subscription: %v", t.OrganizationID, s.payments.Name(), err)
+				continue
+			}
+			status = sub.Status
+		}
+
+		switch trials.OutcomeFor(status) {
+		case trials.Convert:
+			err = s.trials.Convert(ctx, t.OrganizationID)
+		case trials.Downgrade:
+			err = s.downgradeToFree(ctx, t, status)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Trial settlement for org %s failed: %v", t.OrganizationID, err)
+		}
+	}
+}
+
+// downgradeToFree moves an organization whose trial ended unpaid to the free plan
+func (s *BillingService) downgradeToFree(ctx context.Context, t trials.Trial, providerStatus string) error {
+	ended := payments.Subscription{Status: providerStatus}.Ended()
+	if t.StripeSubscriptionID != "" && !ended {
+		if err := s.payments.CancelSubscription(ctx, t.StripeSubscriptionID); err != nil {
+			return fmt.Errorf("failed to cancel %s subscription: %w", s.payments.Name(), err)
+		}
+	}
+
+	free, err := s.catalog.Get(ctx, plans.FreePlanID, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx,
+		`UPDATE subscriptions SET plan = $1, status = 'active', stripe_subscription_id = NULL,
+		 actions_included = $2, active_storage_gb = $3, retained_storage_gb = $4, updated_at = NOW()
+		 WHERE organization_id = $5 AND status = 'trialing'`,
+		free.ID, free.ActionsIncluded, free.ActiveStorageGB, free.RetainedStorageGB, t.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	// Namespace rate limits follow the plan
+	s.syncDynamicConfigAsync(t.OrganizationID)
+	return nil
+}
+
+// billingAdmins returns the email addresses of an organization's owners and admins
+func (s *BillingService) billingAdmins(ctx context.Context, orgID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT email FROM identities
+		 WHERE organization_id = $1 AND type = 'user' AND role IN ('account_owner', 'admin') AND status <> 'inactive'`,
+		orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// billableUsage returns the part of a usage summary that is billed: usage before
+// the end of a trial overlapping the period is left out
+func (s *BillingService) billableUsage(ctx context.Context, summary UsageSummary) (UsageSummary, error) {
+	state, err := s.trials.Get(ctx, summary.OrganizationID)
+	if err != nil {
+		return UsageSummary{}, err
+	}
+	from := trials.PaidFrom(summary.PeriodStart, state.EndsAt)
+	if !from.After(summary.PeriodStart) {
+		return summary, nil
+	}
+
+	billable := UsageSummary{
+		OrganizationID: summary.OrganizationID,
+		PeriodStart:    summary.PeriodStart,
+		PeriodEnd:      summary.PeriodEnd,
+		Currency:       summary.Currency,
+		TrialEndsAt:    state.EndsAt,
+	}
+	if !from.Before(summary.PeriodEnd) {
+		return billable, nil
+	}
+	err = s.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(total_actions), 0), COALESCE(SUM(total_replicated_actions), 0),
+		        COALESCE(SUM(active_storage_gbh), 0), COALESCE(SUM(retained_storage_gbh), 0),
+		        COALESCE(SUM(archived_storage_gbh), 0)
+		 FROM usage_aggregates WHERE organization_id = $1 AND period_start >= $2 AND period_end <= $3`,
+		summary.OrganizationID, from, summary.PeriodEnd).
+		Scan(&billable.TotalActions, &billable.ReplicatedActions, &billable.ActiveStorageGBH, &billable.RetainedStorageGBH,
+			&billable.ArchivedStorageGBH)
+	return billable, err
+}
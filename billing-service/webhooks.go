@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/invoices"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/payments"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/plans"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/proration"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/reconcile"
+	"github.com/temporalio/temporal-cloud-offering/billing-service/internal/webhooks"
+)
+
+// errMalformedEvent is returned for events without the object their type carries,
+// which no redelivery will fix
+var errMalformedEvent = errors.New("webhook event has no object")
+
+// HandlePaymentWebhook handles payment provider webhook events. Each event is handled
+// once: it is claimed before it is handled, so a redelivery of an event already handled
+// is acknowledged without handling it again, and one that arrives while the event is
+// being handled is refused with a conflict for the provider to retry. Failing to handle an event releases the claim and
+// returns a server error, so the provider delivers it again later; handlers are
+// idempotent, so an event handled but not recorded as handled is safe to handle twice.
+func (s *BillingService) HandlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := s.payments.ParseWebhook(payload, r.Header)
+	if errors.Is(err, payments.ErrWebhookNotConfigured) {
+		http.Error(w, "Webhook secret not configured", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claim, claimed, err := s.webhooks.Claim(r.Context(), s.payments.Name(), event)
+	if errors.Is(err, webhooks.ErrInProgress) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to claim %s webhook %s: %v", s.payments.Name(), event.ID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		log.Printf("Skipping %s webhook %s (%s), already handled", s.payments.Name(), event.ID, event.Type)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	defer claim.Release(context.WithoutCancel(r.Context()))
+
+	if err := s.handlePaymentEvent(r.Context(), event); err != nil {
+		log.Printf("Failed to handle %s webhook %s (%s): %v", s.payments.Name(), event.ID, event.Type, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errMalformedEvent) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err := claim.Commit(r.Context()); err != nil {
+		log.Printf("Failed to record %s webhook %s: %v", s.payments.Name(), event.ID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePaymentEvent updates the local records after a webhook event
+func (s *BillingService) handlePaymentEvent(ctx context.Context, e payments.Event) error {
+	switch {
+	case strings.HasPrefix(e.Type, "invoice.") && e.Invoice == nil,
+		strings.HasPrefix(e.Type, "customer.subscription.") && e.Subscription == nil,
+		strings.HasPrefix(e.Type, "checkout.session.") && e.Checkout == nil,
+		strings.HasPrefix(e.Type, "charge.") && e.Charge == nil:
+		return fmt.Errorf("%w: %s", errMalformedEvent, e.Type)
+	}
+
+	switch e.Type {
+	case payments.EventCheckoutCompleted:
+		return s.handleCheckoutCompleted(ctx, e.Checkout)
+	case payments.EventInvoiceFinalized:
+		return s.handleInvoiceFinalized(ctx, e.Invoice)
+	case payments.EventInvoicePaid:
+		return s.handleInvoicePaid(ctx, e.Invoice)
+	case payments.EventInvoicePaymentFailed:
+		return s.handlePaymentFailed(ctx, e.Invoice)
+	case payments.EventChargeRefunded:
+		return s.handleChargeRefunded(ctx, e.Charge)
+	case payments.EventSubscriptionUpdated:
+		return s.handleSubscriptionUpdated(ctx, e.Subscription)
+	case payments.EventSubscriptionDeleted:
+		return s.handleSubscriptionDeleted(ctx, e.Subscription)
+	default:
+		log.Printf("Unhandled %s webhook type: %s", s.payments.Name(), e.Type)
+		return nil
+	}
+}
+
+// handleCheckoutCompleted moves the organization that paid a checkout session to the
+// plan in the session's metadata, on the subscription the checkout created
+func (s *BillingService) handleCheckoutCompleted(ctx context.Context, cs *payments.CheckoutSession) error {
+	orgID, err := uuid.Parse(cs.Metadata["organization_id"])
+	if err != nil || cs.SubscriptionID == "" {
+		// Sessions not created by CreateCheckoutSession, such as payment links
+		log.Printf("Ignoring %s checkout session %s without an organization or subscription", s.payments.Name(), cs.ID)
+		return nil
+	}
+	plan, err := s.catalog.Get(ctx, cs.Metadata["plan_id"], time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get plan of checkout session %s: %w", cs.ID, err)
+	}
+	sub, err := s.payments.GetSubscription(ctx, cs.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to get %s subscription: %w", s.payments.Name(), err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var from, previousSubID string
+	err = tx.QueryRow(ctx,
+		`SELECT plan, COALESCE(stripe_subscription_id, '') FROM subscriptions WHERE organization_id = $1 FOR UPDATE`,
+		orgID).Scan(&from, &previousSubID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Ignoring %s checkout session %s of deleted org %s", s.payments.Name(), cs.ID, orgID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if previousSubID == sub.ID {
+		return nil
+	}
+
+	// Checkout creates the customer of organizations that had none
+	if _, err := tx.Exec(ctx,
+		`UPDATE organizations SET stripe_customer_id = $2 WHERE id = $1 AND COALESCE(stripe_customer_id, '') = ''`,
+		orgID, cs.CustomerID); err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	var anchor *time.Time
+	if !sub.BillingCycleAnchor.IsZero() {
+		anchor = &sub.BillingCycleAnchor
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE subscriptions SET plan = $2, stripe_subscription_id = $3,
+		 actions_included = $4, active_storage_gb = $5, retained_storage_gb = $6,
+		 status = $7, trial_ends_at = COALESCE($8, trial_ends_at), current_period_start = $9, current_period_end = $10,
+		 billing_anchor = COALESCE($11, billing_anchor), updated_at = NOW()
+		 WHERE organization_id = $1`,
+		orgID, plan.ID, sub.ID, plan.ActionsIncluded, plan.ActiveStorageGB, plan.RetainedStorageGB,
+		sub.Status, sub.TrialEnd, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, anchor)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if from != plan.ID {
+		if err := s.changes.CancelScheduled(ctx, tx, orgID); err != nil {
+			return err
+		}
+		change := proration.Change{OrganizationID: orgID, FromPlan: from, ToPlan: plan.ID, EffectiveAt: time.Now()}
+		if _, err := s.changes.Record(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// The subscription checkout replaced is not billed any more. A failure to cancel it
+	// is left for reconciliation, which reports it as a live subscription missing locally.
+	if previousSubID != "" {
+		previous, err := s.payments.GetSubscription(ctx, previousSubID)
+		if err == nil && !previous.Ended() {
+			err = s.payments.CancelSubscription(ctx, previousSubID)
+		}
+		if err != nil {
+			log.Printf("Failed to cancel %s subscription %s replaced by checkout for org %s: %v",
+				s.payments.Name(), previousSubID, orgID, err)
+		}
+	}
+
+	// Namespace rate limits follow the plan
+	s.syncDynamicConfigAsync(orgID)
+	return nil
+}
+
+// localInvoice returns the local record of a provider invoice, reporting false when
+// there is none
+func (s *BillingService) localInvoice(ctx context.Context, providerInvoiceID string) (invoices.Invoice, bool, error) {
+	inv, err := s.invoices.ByProviderID(ctx, providerInvoiceID)
+	if errors.Is(err, invoices.ErrNotFound) {
+		return invoices.Invoice{}, false, nil
+	}
+	if err != nil {
+		return invoices.Invoice{}, false, err
+	}
+	return inv, true, nil
+}
+
+// handleInvoiceFinalized records the invoices the provider issues itself for a
+// subscription's price. Billing stores the invoices it creates once the provider has
+// finalized them, which may be after this event, so they are left to it.
+func (s *BillingService) handleInvoiceFinalized(ctx context.Context, inv *payments.Invoice) error {
+	local, found, err := s.localInvoice(ctx, inv.ID)
+	if err != nil {
+		return err
+	}
+	if found {
+		if local.Status == "draft" {
+			return s.invoices.SetStatus(ctx, local.ID, "pending", nil)
+		}
+		return nil
+	}
+	if inv.SubscriptionID == "" {
+		return nil
+	}
+
+	var orgID uuid.UUID
+	err = s.db.QueryRow(ctx, `SELECT id FROM organizations WHERE stripe_customer_id = $1`, inv.CustomerID).Scan(&orgID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("No organization for %s customer %s of invoice %s", s.payments.Name(), inv.CustomerID, inv.ID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+	_, err = s.invoices.Create(ctx, reconcile.ImportedInvoice(orgID, s.payments.Name(), *inv))
+	return err
+}
+
+func (s *BillingService) handleInvoicePaid(ctx context.Context, inv *payments.Invoice) error {
+	local, found, err := s.localInvoice(ctx, inv.ID)
+	if err != nil || !found || local.Status == "paid" || local.Status == "refunded" {
+		return err
+	}
+	paidAt := inv.PaidAt
+	if paidAt == nil {
+		now := time.Now()
+		paidAt = &now
+	}
+	return s.invoices.SetStatus(ctx, local.ID, "paid", paidAt)
+}
+
+func (s *BillingService) handlePaymentFailed(ctx context.Context, inv *payments.Invoice) error {
+	// A failure delivered after the invoice was paid is stale
+	local, found, err := s.localInvoice(ctx, inv.ID)
+	if err != nil || !found || local.Status == "paid" || local.Status == "refunded" {
+		return err
+	}
+	// TODO: Send notification, potentially suspend service
+	return s.invoices.SetStatus(ctx, local.ID, "payment_failed", nil)
+}
+
+// handleChargeRefunded records how much of the invoice a charge paid has been refunded
+func (s *BillingService) handleChargeRefunded(ctx context.Context, ch *payments.Charge) error {
+	if ch.InvoiceID == "" {
+		return nil
+	}
+	local, found, err := s.localInvoice(ctx, ch.InvoiceID)
+	if err != nil || !found {
+		return err
+	}
+	return s.invoices.SetRefunded(ctx, local.ID, ch.RefundedCents)
+}
+
+func (s *BillingService) handleSubscriptionUpdated(ctx context.Context, sub *payments.Subscription) error {
+	// Update subscription status, and the anchor of its billing periods if the provider
+	// moved it
+	var anchor *time.Time
+	if !sub.BillingCycleAnchor.IsZero() {
+		anchor = &sub.BillingCycleAnchor
+	}
+	_, err := s.db.Exec(ctx,
+		`UPDATE subscriptions SET status = $1, current_period_start = $2, current_period_end = $3,
+		        billing_anchor = COALESCE($5, billing_anchor), updated_at = NOW()
+		 WHERE stripe_subscription_id = $4`,
+		sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.ID, anchor)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return nil
+}
+
+// handleSubscriptionDeleted moves an organization whose subscription the provider
+// canceled to the free plan. Subscriptions the organization already left, such as one
+// canceled by a plan change, are ignored.
+func (s *BillingService) handleSubscriptionDeleted(ctx context.Context, sub *payments.Subscription) error {
+	free, err := s.catalog.Get(ctx, plans.FreePlanID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var orgID uuid.UUID
+	var from string
+	err = tx.QueryRow(ctx,
+		`SELECT organization_id, plan FROM subscriptions WHERE stripe_subscription_id = $1 FOR UPDATE`, sub.ID).
+		Scan(&orgID, &from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE subscriptions SET plan = $1, status = 'active', stripe_subscription_id = NULL,
+		 actions_included = $2, active_storage_gb = $3, retained_storage_gb = $4, updated_at = NOW()
+		 WHERE organization_id = $5`,
+		free.ID, free.ActionsIncluded, free.ActiveStorageGB, free.RetainedStorageGB, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if err := s.changes.CancelScheduled(ctx, tx, orgID); err != nil {
+		return err
+	}
+	change := proration.Change{OrganizationID: orgID, FromPlan: from, ToPlan: free.ID, EffectiveAt: time.Now()}
+	if _, err := s.changes.Record(ctx, tx, change); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Namespace rate limits follow the plan
+	s.syncDynamicConfigAsync(orgID)
+	return nil
+}
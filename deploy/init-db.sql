@@ -9,10 +9,53 @@ CREATE TABLE organizations (
     name VARCHAR(255) NOT NULL,
     slug VARCHAR(255) UNIQUE NOT NULL,
     stripe_customer_id VARCHAR(255),
+    billing_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    billing_address JSONB NOT NULL DEFAULT '{}', -- line1, line2, city, state, postal_code, country
+    billing_timezone VARCHAR(64) NOT NULL DEFAULT 'UTC', -- IANA zone billing periods start in
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Tax IDs used to decide VAT/GST and reverse charge
+CREATE TABLE organization_tax_ids (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL, -- eu_vat, gb_vat, au_abn
+    value VARCHAR(50) NOT NULL,
+    country VARCHAR(2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (organization_id, type, value)
+);
+
+-- Plan catalog (effective-dated versions)
+CREATE TABLE plan_versions (
+    plan_id VARCHAR(50) NOT NULL,
+    version INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    base_price_cents BIGINT NOT NULL DEFAULT 0,
+    base_price_display VARCHAR(255) NOT NULL DEFAULT '',
+    actions_included BIGINT NOT NULL DEFAULT 0,
+    active_storage_gb DECIMAL(10,2) NOT NULL DEFAULT 0,
+    retained_storage_gb DECIMAL(10,2) NOT NULL DEFAULT 0,
+    stripe_price_id VARCHAR(255),
+    trial_days INT NOT NULL DEFAULT 0,
+    prices JSONB NOT NULL DEFAULT '{}',
+    currency_prices JSONB NOT NULL DEFAULT '{}',
+    max_namespaces INT NOT NULL DEFAULT 0,
+    max_retention_days INT NOT NULL DEFAULT 0,
+    namespace_rps INT NOT NULL DEFAULT 0,
+    namespace_global_rps INT NOT NULL DEFAULT 0,
+    replication BOOLEAN NOT NULL DEFAULT FALSE,
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    self_serve BOOLEAN NOT NULL DEFAULT FALSE,
+    sort_order INT NOT NULL DEFAULT 0,
+    features TEXT[] NOT NULL DEFAULT '{}',
+    effective_from TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (plan_id, version)
+);
+
 -- Subscriptions
 CREATE TABLE subscriptions (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -25,10 +68,29 @@ CREATE TABLE subscriptions (
     retained_storage_gb DECIMAL(10,2) DEFAULT 4,
     current_period_start TIMESTAMPTZ,
     current_period_end TIMESTAMPTZ,
+    billing_anchor TIMESTAMPTZ, -- periods start on its day of the month
+    billed_through TIMESTAMPTZ, -- end of the last period invoiced
+    trial_ends_at TIMESTAMPTZ,
+    trial_reminded_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+CREATE INDEX idx_subscriptions_trial ON subscriptions(trial_ends_at) WHERE status = 'trialing';
+
+-- Plan changes, applied or scheduled for the end of a billing period
+CREATE TABLE subscription_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    from_plan VARCHAR(50) NOT NULL,
+    to_plan VARCHAR(50) NOT NULL,
+    effective_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'applied',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_subscription_changes_org ON subscription_changes(organization_id, effective_at);
+
 -- Usage Records (collected from Prometheus)
 CREATE TABLE usage_records (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -36,8 +98,10 @@ CREATE TABLE usage_records (
     namespace_id VARCHAR(255) NOT NULL,
     recorded_at TIMESTAMPTZ NOT NULL,
     action_count BIGINT DEFAULT 0,
+    replicated_action_count BIGINT DEFAULT 0,
     active_storage_bytes BIGINT DEFAULT 0,
     retained_storage_bytes BIGINT DEFAULT 0,
+    archived_storage_bytes BIGINT DEFAULT 0,
     workflow_started BIGINT DEFAULT 0,
     activity_started BIGINT DEFAULT 0,
     timer_started BIGINT DEFAULT 0,
@@ -56,13 +120,34 @@ CREATE TABLE usage_aggregates (
     period_start TIMESTAMPTZ NOT NULL,
     period_end TIMESTAMPTZ NOT NULL,
     total_actions BIGINT DEFAULT 0,
+    total_replicated_actions BIGINT DEFAULT 0,
     active_storage_gbh DECIMAL(20,6) DEFAULT 0,
     retained_storage_gbh DECIMAL(20,6) DEFAULT 0,
+    archived_storage_gbh DECIMAL(20,6) DEFAULT 0,
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
 CREATE INDEX idx_aggregates_org_period ON usage_aggregates(organization_id, period_start);
 
+-- Committed-spend contracts
+CREATE TABLE contracts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    commit_cents BIGINT NOT NULL DEFAULT 0,
+    monthly_fee_cents BIGINT NOT NULL DEFAULT 0,
+    prices JSONB NOT NULL DEFAULT '{}',
+    discounts JSONB NOT NULL DEFAULT '{}',
+    true_up VARCHAR(20) NOT NULL DEFAULT 'shortfall', -- shortfall, none
+    true_up_cents BIGINT,
+    true_up_invoiced_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_contracts_org ON contracts(organization_id, starts_at);
+
 -- Invoices
 CREATE TABLE invoices (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -72,21 +157,90 @@ CREATE TABLE invoices (
     period_start TIMESTAMPTZ NOT NULL,
     period_end TIMESTAMPTZ NOT NULL,
     subtotal_cents BIGINT DEFAULT 0,
+    discount_cents BIGINT DEFAULT 0,
     tax_cents BIGINT DEFAULT 0,
     total_cents BIGINT DEFAULT 0,
+    refunded_cents BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    kind VARCHAR(20) NOT NULL DEFAULT 'usage', -- usage, true_up, provider
+    contract_id UUID REFERENCES contracts(id),
     status VARCHAR(50) DEFAULT 'draft',
-    line_items JSONB,
+    line_items JSONB, -- bill snapshot of invoices issued before invoice_line_items
     paid_at TIMESTAMPTZ,
     due_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
 CREATE INDEX idx_invoices_org ON invoices(organization_id, created_at);
+CREATE INDEX idx_invoices_contract ON invoices(contract_id);
+CREATE UNIQUE INDEX idx_invoices_number ON invoices(organization_id, invoice_number);
+CREATE INDEX idx_invoices_provider ON invoices(stripe_invoice_id);
+-- A contract is trued up at most once
+CREATE UNIQUE INDEX idx_invoices_true_up ON invoices(contract_id) WHERE kind = 'true_up';
+
+-- Invoice line items, in display order
+CREATE TABLE invoice_line_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    kind VARCHAR(20) NOT NULL, -- base, usage, discount, credit, true_up, tax
+    description TEXT NOT NULL,
+    meter VARCHAR(50),
+    quantity DECIMAL(24, 6) NOT NULL DEFAULT 0,
+    unit VARCHAR(50),
+    amount_cents BIGINT NOT NULL,
+    UNIQUE (invoice_id, position)
+);
+
+-- Last invoice number issued to each organization
+CREATE TABLE invoice_sequences (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+
+-- Billing runs, one per period, with the state of every organization billed in it
+CREATE TABLE billing_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    period_start TIMESTAMPTZ NOT NULL UNIQUE,
+    period_end TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- running, completed, failed
+    attempts INT NOT NULL DEFAULT 1,
+    processed INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE TABLE billing_run_organizations (
+    run_id UUID NOT NULL REFERENCES billing_runs(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL, -- invoiced, skipped, failed
+    period_start TIMESTAMPTZ, -- subscription period billed last
+    provider_invoice_id VARCHAR(255),
+    invoice_id UUID REFERENCES invoices(id),
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, organization_id)
+);
+
+-- Payment provider webhook events already processed, so redeliveries are skipped
+CREATE TABLE webhook_events (
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processed',
+    claimed_at TIMESTAMPTZ,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
 
 -- Credits
 CREATE TABLE credits (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL DEFAULT 'promotional', -- promotional, sla, prepaid
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
     amount_cents BIGINT NOT NULL,
     remaining_cents BIGINT NOT NULL,
     description VARCHAR(255),
@@ -94,6 +248,69 @@ CREATE TABLE credits (
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+CREATE INDEX idx_credits_org ON credits(organization_id, expires_at);
+
+-- Credits burned against invoices
+CREATE TABLE credit_debits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    credit_id UUID NOT NULL REFERENCES credits(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    invoice_number VARCHAR(50) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (credit_id, invoice_number)
+);
+
+CREATE INDEX idx_credit_debits_org ON credit_debits(organization_id, invoice_number);
+
+-- Coupons and their promotion codes
+CREATE TABLE coupons (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    percent_off DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    amount_off_cents BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3),
+    duration VARCHAR(20) NOT NULL,
+    duration_in_months INT NOT NULL DEFAULT 0,
+    max_redemptions INT NOT NULL DEFAULT 0,
+    times_redeemed INT NOT NULL DEFAULT 0,
+    plans TEXT[] NOT NULL DEFAULT '{}',
+    redeem_by TIMESTAMPTZ,
+    stripe_coupon_id VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Coupons redeemed by organizations
+CREATE TABLE coupon_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    coupon_id UUID NOT NULL REFERENCES coupons(id),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    redeemed_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (coupon_id, organization_id)
+);
+
+CREATE INDEX idx_coupon_redemptions_org ON coupon_redemptions(organization_id, redeemed_at);
+
+-- Clusters (Temporal clusters namespaces are placed on)
+CREATE TABLE clusters (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) UNIQUE NOT NULL,
+    region VARCHAR(50) NOT NULL,
+    grpc_endpoint VARCHAR(255) NOT NULL,
+    prometheus_url VARCHAR(255),
+    max_namespaces INT NOT NULL DEFAULT 0,
+    status VARCHAR(50) NOT NULL DEFAULT 'active',
+    health VARCHAR(50) NOT NULL DEFAULT 'unknown',
+    last_health_check_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_clusters_region ON clusters(region);
+
 -- Namespaces (maps to Temporal namespaces)
 CREATE TABLE namespaces (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -101,13 +318,44 @@ CREATE TABLE namespaces (
     name VARCHAR(255) NOT NULL,
     temporal_namespace VARCHAR(255) UNIQUE NOT NULL,
     region VARCHAR(50) DEFAULT 'us-east-1',
+    cluster_id UUID REFERENCES clusters(id),
+    is_global BOOLEAN NOT NULL DEFAULT FALSE,
     status VARCHAR(50) DEFAULT 'active',
     retention_days INT DEFAULT 7,
+    history_archival_state VARCHAR(20) NOT NULL DEFAULT 'disabled',
+    history_archival_uri TEXT,
+    visibility_archival_state VARCHAR(20) NOT NULL DEFAULT 'disabled',
+    visibility_archival_uri TEXT,
+    certificate_filters JSONB NOT NULL DEFAULT '[]',
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
 CREATE INDEX idx_namespaces_org ON namespaces(organization_id);
+CREATE INDEX idx_namespaces_cluster ON namespaces(cluster_id);
+
+-- Namespace replication sets (active and standby clusters)
+CREATE TABLE namespace_clusters (
+    namespace_id UUID REFERENCES namespaces(id) ON DELETE CASCADE,
+    cluster_id UUID REFERENCES clusters(id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (namespace_id, cluster_id)
+);
+
+-- Namespace failover history
+CREATE TABLE namespace_failovers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    namespace_id UUID REFERENCES namespaces(id) ON DELETE CASCADE,
+    from_cluster_id UUID REFERENCES clusters(id),
+    to_cluster_id UUID REFERENCES clusters(id),
+    reason TEXT,
+    status VARCHAR(50) NOT NULL DEFAULT 'in_progress',
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_namespace_failovers_ns ON namespace_failovers(namespace_id, created_at);
 
 -- Users
 CREATE TABLE users (
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveDir is a local directory holding a namespace's archives. Directories below it
+// that hold other namespaces' archives are skipped.
+type archiveDir struct {
+	path string
+	skip []string
+}
+
+// localArchives works out which local directories hold each namespace's file://
+// archives, keyed by Temporal namespace. A namespace whose history and visibility
+// URIs are the same directory, or one inside the other, has it measured once. The
+// archives of other namespaces nested in a namespace's directory are not its own, and
+// a directory several namespaces archive to is measured only in the subdirectory named
+// after each of them, so no archive is billed to two namespaces.
+func localArchives(namespaces []TrackedNamespace) map[string][]archiveDir {
+	roots := make(map[string][]string, len(namespaces))
+	owners := map[string][]string{}
+	for _, ns := range namespaces {
+		var paths []string
+		for _, uri := range ns.ArchivalURIs {
+			u, err := url.Parse(uri)
+			if err != nil || u.Scheme != "file" || u.Path == "" {
+				continue
+			}
+			paths = append(paths, filepath.Clean(u.Path))
+		}
+		for _, p := range outermost(paths) {
+			roots[ns.TemporalNamespace] = append(roots[ns.TemporalNamespace], p)
+			owners[p] = append(owners[p], ns.TemporalNamespace)
+		}
+	}
+
+	dirs := make(map[string][]archiveDir, len(roots))
+	for namespace, paths := range roots {
+		for _, p := range paths {
+			dir := archiveDir{path: p}
+			if len(owners[p]) > 1 {
+				dir.path = filepath.Join(p, namespace)
+			}
+			for other := range owners {
+				if other != p && contains(dir.path, other) {
+					dir.skip = append(dir.skip, other)
+				}
+			}
+			dirs[namespace] = append(dirs[namespace], dir)
+		}
+	}
+	return dirs
+}
+
+// outermost de-duplicates paths and drops those inside another one
+func outermost(paths []string) []string {
+	var out []string
+	for i, p := range paths {
+		keep := true
+		for j, q := range paths {
+			if (p == q && j < i) || (p != q && contains(q, p)) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// contains reports whether path is dir or below it
+func contains(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// archivedBytes measures the size of a namespace's archives. Local filesystem archives are
+// measured directly; blob store archives rely on a best-effort Prometheus metric.
+func (c *UsageCollector) archivedBytes(ctx context.Context, prometheusURL string, ns TrackedNamespace) int64 {
+	var total int64
+	for _, dir := range ns.archiveDirs {
+		size, err := directorySize(dir.path, dir.skip)
+		if err != nil {
+			log.Printf("Failed to measure archive %s for namespace %s: %v", dir.path, ns.TemporalNamespace, err)
+			continue
+		}
+		total += size
+	}
+
+	for _, uri := range ns.ArchivalURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "file" {
+			continue
+		}
+		archivedSize, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
+			`sum(archived_storage_bytes{namespace="%s"})`, ns.TemporalNamespace))
+		if err == nil && archivedSize > 0 {
+			total += int64(archivedSize)
+		}
+		break
+	}
+	return total
+}
+
+// directorySize sums the sizes of all regular files below root, leaving out the
+// directories in skip
+func directorySize(root string, skip []string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return fs.SkipDir // nothing archived yet
+			}
+			return err
+		}
+		if d.IsDir() {
+			for _, s := range skip {
+				if path == s {
+					return fs.SkipDir
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
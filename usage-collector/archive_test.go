@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocalArchives(t *testing.T) {
+	namespaces := []TrackedNamespace{
+		// History and visibility in one directory, or one inside the other
+		{TemporalNamespace: "orders", ArchivalURIs: []string{"file:///archive/orders", "file:///archive/orders/visibility"}},
+		{TemporalNamespace: "billing", ArchivalURIs: []string{"file:///archive/billing/", "file:///archive/billing"}},
+		// A namespace archiving inside another's directory
+		{TemporalNamespace: "orders-eu", ArchivalURIs: []string{"file:///archive/orders/eu"}},
+		// A directory shared by two namespaces
+		{TemporalNamespace: "payments", ArchivalURIs: []string{"file:///shared", "s3://bucket/payments"}},
+		{TemporalNamespace: "refunds", ArchivalURIs: []string{"file:///shared"}},
+		{TemporalNamespace: "blobs", ArchivalURIs: []string{"gs://bucket/blobs", "file://"}},
+	}
+
+	expected := map[string][]archiveDir{
+		"orders":    {{path: "/archive/orders", skip: []string{"/archive/orders/eu"}}},
+		"billing":   {{path: "/archive/billing"}},
+		"orders-eu": {{path: "/archive/orders/eu"}},
+		"payments":  {{path: "/shared/payments"}},
+		"refunds":   {{path: "/shared/refunds"}},
+	}
+	if dirs := localArchives(namespaces); !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, dirs)
+	}
+}
+
+func writeArchive(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchivedBytesMeasuresOwnArchives(t *testing.T) {
+	root := t.TempDir()
+	writeArchive(t, filepath.Join(root, "orders", "1_1.history"), 100)
+	writeArchive(t, filepath.Join(root, "orders", "visibility", "ns-id", "1.visibility"), 10)
+	writeArchive(t, filepath.Join(root, "orders", "eu", "1_1.history"), 1000)
+	writeArchive(t, filepath.Join(root, "shared", "payments", "1_1.history"), 200)
+	writeArchive(t, filepath.Join(root, "shared", "refunds", "1_1.history"), 300)
+	writeArchive(t, filepath.Join(root, "shared", "unattributed.history"), 5000)
+
+	uri := func(path ...string) string { return "file://" + filepath.Join(append([]string{root}, path...)...) }
+	namespaces := []TrackedNamespace{
+		{TemporalNamespace: "orders", ArchivalURIs: []string{uri("orders"), uri("orders", "visibility")}},
+		{TemporalNamespace: "orders-eu", ArchivalURIs: []string{uri("orders", "eu")}},
+		{TemporalNamespace: "payments", ArchivalURIs: []string{uri("shared")}},
+		{TemporalNamespace: "refunds", ArchivalURIs: []string{uri("shared")}},
+		{TemporalNamespace: "new", ArchivalURIs: []string{uri("new")}},
+	}
+	dirs := localArchives(namespaces)
+
+	expected := map[string]int64{"orders": 110, "orders-eu": 1000, "payments": 200, "refunds": 300, "new": 0}
+	c := &UsageCollector{}
+	for _, ns := range namespaces {
+		ns.archiveDirs = dirs[ns.TemporalNamespace]
+		if size := c.archivedBytes(context.Background(), "", ns); size != expected[ns.TemporalNamespace] {
+			t.Errorf("Expected %s to have %d archived bytes, got %d", ns.TemporalNamespace, expected[ns.TemporalNamespace], size)
+		}
+	}
+}
@@ -109,9 +109,13 @@ func (c *UsageCollector) Collect(ctx context.Context) {
 		log.Printf("Failed to get namespaces: %v", err)
 		return
 	}
+	dirs := localArchives(namespaces)
+	for i := range namespaces {
+		namespaces[i].archiveDirs = dirs[namespaces[i].TemporalNamespace]
+	}
 
 	for _, ns := range namespaces {
-		usage, err := c.collectNamespaceUsage(ctx, ns.TemporalNamespace)
+		usage, err := c.collectNamespaceUsage(ctx, ns)
 		if err != nil {
 			log.Printf("Failed to collect usage for namespace %s: %v", ns.TemporalNamespace, err)
 			continue
@@ -132,11 +136,27 @@ func (c *UsageCollector) Collect(ctx context.Context) {
 type TrackedNamespace struct {
 	OrganizationID    uuid.UUID
 	TemporalNamespace string
+	// PrometheusURL of the cluster hosting the namespace, empty for the default
+	PrometheusURL string
+	// StandbyClusters is the number of clusters replicating a global namespace
+	StandbyClusters int64
+	// ArchivalURIs are the enabled history and visibility archival URIs
+	ArchivalURIs []string
+	// archiveDirs are the local directories of the namespace's file:// archives
+	archiveDirs []archiveDir
 }
 
 func (c *UsageCollector) getTrackedNamespaces(ctx context.Context) ([]TrackedNamespace, error) {
 	rows, err := c.db.Query(ctx,
-		`SELECT organization_id, temporal_namespace FROM namespaces WHERE status = 'active'`)
+		`SELECT n.organization_id, n.temporal_namespace, COALESCE(cl.prometheus_url, ''),
+		        CASE WHEN n.is_global THEN GREATEST(
+		            (SELECT COUNT(*) FROM namespace_clusters nc WHERE nc.namespace_id = n.id) - 1, 0)
+		        ELSE 0 END,
+		        CASE WHEN n.history_archival_state = 'enabled' THEN COALESCE(n.history_archival_uri, '') ELSE '' END,
+		        CASE WHEN n.visibility_archival_state = 'enabled' THEN COALESCE(n.visibility_archival_uri, '') ELSE '' END
+		 FROM namespaces n
+		 LEFT JOIN clusters cl ON cl.id = n.cluster_id
+		 WHERE n.status = 'active'`)
 	if err != nil {
 		return nil, err
 	}
@@ -145,36 +165,53 @@ func (c *UsageCollector) getTrackedNamespaces(ctx context.Context) ([]TrackedNam
 	var namespaces []TrackedNamespace
 	for rows.Next() {
 		var ns TrackedNamespace
-		if err := rows.Scan(&ns.OrganizationID, &ns.TemporalNamespace); err != nil {
+		var historyURI, visibilityURI string
+		if err := rows.Scan(&ns.OrganizationID, &ns.TemporalNamespace, &ns.PrometheusURL, &ns.StandbyClusters,
+			&historyURI, &visibilityURI); err != nil {
 			continue
 		}
+		if historyURI != "" {
+			ns.ArchivalURIs = append(ns.ArchivalURIs, historyURI)
+		}
+		if visibilityURI != "" && visibilityURI != historyURI {
+			ns.ArchivalURIs = append(ns.ArchivalURIs, visibilityURI)
+		}
 		namespaces = append(namespaces, ns)
 	}
 	return namespaces, nil
 }
 
 type NamespaceUsage struct {
-	ActionCount          int64
-	ActiveStorageBytes   int64
-	RetainedStorageBytes int64
-	WorkflowStarted      int64
-	ActivityStarted      int64
-	TimerStarted         int64
-	SignalSent           int64
+	ActionCount           int64
+	ReplicatedActionCount int64
+	ActiveStorageBytes    int64
+	RetainedStorageBytes  int64
+	ArchivedStorageBytes  int64
+	WorkflowStarted       int64
+	ActivityStarted       int64
+	TimerStarted          int64
+	SignalSent            int64
 }
 
-func (c *UsageCollector) collectNamespaceUsage(ctx context.Context, namespace string) (*NamespaceUsage, error) {
+func (c *UsageCollector) collectNamespaceUsage(ctx context.Context, ns TrackedNamespace) (*NamespaceUsage, error) {
 	usage := &NamespaceUsage{}
+	namespace := ns.TemporalNamespace
+
+	// Route queries to the Prometheus scraping the namespace's cluster
+	prometheusURL := ns.PrometheusURL
+	if prometheusURL == "" {
+		prometheusURL = c.prometheusURL
+	}
 
 	// Query workflow task completions (actions)
-	workflowTasks, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	workflowTasks, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(increase(workflow_task_completed_total{namespace="%s"}[1h]))`, namespace))
 	if err == nil {
 		usage.ActionCount += int64(workflowTasks)
 	}
 
 	// Query activity task completions
-	activityTasks, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	activityTasks, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(increase(activity_task_completed_total{namespace="%s"}[1h]))`, namespace))
 	if err == nil {
 		usage.ActionCount += int64(activityTasks)
@@ -182,14 +219,14 @@ func (c *UsageCollector) collectNamespaceUsage(ctx context.Context, namespace st
 	}
 
 	// Query workflow started
-	workflowStarted, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	workflowStarted, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(increase(workflow_started_total{namespace="%s"}[1h]))`, namespace))
 	if err == nil {
 		usage.WorkflowStarted = int64(workflowStarted)
 	}
 
 	// Query timer started
-	timerStarted, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	timerStarted, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(increase(timer_started_total{namespace="%s"}[1h]))`, namespace))
 	if err == nil {
 		usage.TimerStarted = int64(timerStarted)
@@ -197,7 +234,7 @@ func (c *UsageCollector) collectNamespaceUsage(ctx context.Context, namespace st
 	}
 
 	// Query signal sent
-	signalSent, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	signalSent, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(increase(signal_sent_total{namespace="%s"}[1h]))`, namespace))
 	if err == nil {
 		usage.SignalSent = int64(signalSent)
@@ -205,24 +242,30 @@ func (c *UsageCollector) collectNamespaceUsage(ctx context.Context, namespace st
 	}
 
 	// Query history size (active storage)
-	historySize, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	historySize, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(history_size_bytes{namespace="%s"})`, namespace))
 	if err == nil {
 		usage.ActiveStorageBytes = int64(historySize)
 	}
 
 	// Query retained storage size if metric exists (best-effort)
-	retainedSize, err := c.queryPrometheus(ctx, fmt.Sprintf(
+	retainedSize, err := c.queryPrometheus(ctx, prometheusURL, fmt.Sprintf(
 		`sum(history_size_retained_bytes{namespace="%s"})`, namespace))
 	if err == nil && retainedSize > 0 {
 		usage.RetainedStorageBytes = int64(retainedSize)
 	}
 
+	// Archived storage is billed separately from retained history
+	usage.ArchivedStorageBytes = c.archivedBytes(ctx, prometheusURL, ns)
+
+	// Every action on a replicated namespace is applied again on each standby cluster
+	usage.ReplicatedActionCount = usage.ActionCount * ns.StandbyClusters
+
 	return usage, nil
 }
 
-func (c *UsageCollector) queryPrometheus(ctx context.Context, query string) (float64, error) {
-	url := fmt.Sprintf("%s/api/v1/query?query=%s", c.prometheusURL, query)
+func (c *UsageCollector) queryPrometheus(ctx context.Context, prometheusURL, query string) (float64, error) {
+	url := fmt.Sprintf("%s/api/v1/query?query=%s", prometheusURL, query)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -260,11 +303,11 @@ func (c *UsageCollector) queryPrometheus(ctx context.Context, query string) (flo
 func (c *UsageCollector) storeUsage(ctx context.Context, orgID uuid.UUID, namespace string, usage *NamespaceUsage) error {
 	_, err := c.db.Exec(ctx,
 		`INSERT INTO usage_records (organization_id, namespace_id, recorded_at, action_count, 
-		 active_storage_bytes, retained_storage_bytes, workflow_started, activity_started, 
-		 timer_started, signal_sent)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-		orgID, namespace, time.Now(), usage.ActionCount, usage.ActiveStorageBytes,
-		usage.RetainedStorageBytes, usage.WorkflowStarted, usage.ActivityStarted,
+		 replicated_action_count, active_storage_bytes, retained_storage_bytes, archived_storage_bytes,
+		 workflow_started, activity_started, timer_started, signal_sent)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		orgID, namespace, time.Now(), usage.ActionCount, usage.ReplicatedActionCount, usage.ActiveStorageBytes,
+		usage.RetainedStorageBytes, usage.ArchivedStorageBytes, usage.WorkflowStarted, usage.ActivityStarted,
 		usage.TimerStarted, usage.SignalSent)
 	return err
 }
@@ -277,15 +320,18 @@ func (c *UsageCollector) aggregateHourly(ctx context.Context) {
 
 	_, err := c.db.Exec(ctx, `
 		INSERT INTO usage_aggregates (organization_id, namespace_id, period_start, period_end, 
-		                              total_actions, active_storage_gbh, retained_storage_gbh)
+		                              total_actions, total_replicated_actions, active_storage_gbh, retained_storage_gbh,
+		                              archived_storage_gbh)
 		SELECT 
 			organization_id,
 			namespace_id,
 			$1 as period_start,
 			$2 as period_end,
 			SUM(action_count) as total_actions,
+			SUM(replicated_action_count) as total_replicated_actions,
 			SUM(active_storage_bytes) / 1073741824.0 as active_storage_gbh,
-			SUM(retained_storage_bytes) / 1073741824.0 as retained_storage_gbh
+			SUM(retained_storage_bytes) / 1073741824.0 as retained_storage_gbh,
+			SUM(archived_storage_bytes) / 1073741824.0 as archived_storage_gbh
 		FROM usage_records
 		WHERE recorded_at >= $1 AND recorded_at < $2
 		GROUP BY organization_id, namespace_id
@@ -308,8 +354,10 @@ func ensureUsageSchema(pool *pgxpool.Pool) error {
 			namespace_id VARCHAR(255) NOT NULL,
 			recorded_at TIMESTAMPTZ NOT NULL,
 			action_count BIGINT DEFAULT 0,
+			replicated_action_count BIGINT DEFAULT 0,
 			active_storage_bytes BIGINT DEFAULT 0,
 			retained_storage_bytes BIGINT DEFAULT 0,
+			archived_storage_bytes BIGINT DEFAULT 0,
 			workflow_started BIGINT DEFAULT 0,
 			activity_started BIGINT DEFAULT 0,
 			timer_started BIGINT DEFAULT 0,
@@ -323,10 +371,16 @@ func ensureUsageSchema(pool *pgxpool.Pool) error {
 			period_start TIMESTAMPTZ NOT NULL,
 			period_end TIMESTAMPTZ NOT NULL,
 			total_actions BIGINT DEFAULT 0,
+			total_replicated_actions BIGINT DEFAULT 0,
 			active_storage_gbh DECIMAL(20,6) DEFAULT 0,
 			retained_storage_gbh DECIMAL(20,6) DEFAULT 0,
+			archived_storage_gbh DECIMAL(20,6) DEFAULT 0,
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		);`,
+		`ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS replicated_action_count BIGINT DEFAULT 0;`,
+		`ALTER TABLE usage_aggregates ADD COLUMN IF NOT EXISTS total_replicated_actions BIGINT DEFAULT 0;`,
+		`ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS archived_storage_bytes BIGINT DEFAULT 0;`,
+		`ALTER TABLE usage_aggregates ADD COLUMN IF NOT EXISTS archived_storage_gbh DECIMAL(20,6) DEFAULT 0;`,
 	}
 	for _, stmt := range stmts {
 		if _, err := pool.Exec(ctx, stmt); err != nil {